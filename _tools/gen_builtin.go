@@ -49,6 +49,7 @@ func run(input, output string) error {
 			name = name[1:]
 		}
 		fd.Minify()
+		gojq.Walk(fd, clearOffset)
 		qs[name] = append(qs[fd.Name], fd)
 	}
 	t, err := astgen.Build(qs)
@@ -73,6 +74,19 @@ func run(input, output string) error {
 	return err
 }
 
+// clearOffset clears the source offsets, which are meaningless for builtins.
+func clearOffset(node gojq.Node) bool {
+	switch e := node.(type) {
+	case *gojq.Query:
+		e.Offset = 0
+	case *gojq.Term:
+		e.Offset = 0
+	case *gojq.Index:
+		e.Offset = 0
+	}
+	return true
+}
+
 func printCompositeLit(out *strings.Builder, t *ast.CompositeLit) error {
 	err := printer.Fprint(out, token.NewFileSet(), t.Type)
 	if err != nil {
@@ -0,0 +1,72 @@
+package gojq
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// transliterations is the table of the ASCII transliterations of the Greek
+// and Cyrillic letters, and the punctuations and symbols.
+var transliterations = map[rune]string{
+	// Greek
+	'Ά': "A", 'Έ': "E", 'Ή': "I", 'Ί': "I", 'Ό': "O", 'Ύ': "Y", 'Ώ': "O", 'ΐ': "i",
+	'Α': "A", 'Β': "V", 'Γ': "G", 'Δ': "D", 'Ε': "E", 'Ζ': "Z", 'Η': "I", 'Θ': "Th",
+	'Ι': "I", 'Κ': "K", 'Λ': "L", 'Μ': "M", 'Ν': "N", 'Ξ': "X", 'Ο': "O", 'Π': "P",
+	'Ρ': "R", 'Σ': "S", 'Τ': "T", 'Υ': "Y", 'Φ': "F", 'Χ': "Ch", 'Ψ': "Ps", 'Ω': "O",
+	'Ϊ': "I", 'Ϋ': "Y", 'ά': "a", 'έ': "e", 'ή': "i", 'ί': "i", 'ΰ': "y", 'α': "a",
+	'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th", 'ι': "i",
+	'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p", 'ρ': "r",
+	'ς': "s", 'σ': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+	'ϊ': "i", 'ϋ': "y", 'ό': "o", 'ύ': "y", 'ώ': "o",
+
+	// Cyrillic
+	'Ё': "Yo", 'Ђ': "Dj", 'Ѓ': "G", 'Є': "Ye", 'Ѕ': "Dz", 'І': "I", 'Ї': "Yi", 'Ј': "J",
+	'Љ': "Lj", 'Њ': "Nj", 'Ћ': "C", 'Ќ': "K", 'Ў': "U", 'Џ': "Dz", 'А': "A", 'Б': "B",
+	'В': "V", 'Г': "G", 'Д': "D", 'Е': "E", 'Ж': "Zh", 'З': "Z", 'И': "I", 'Й': "Y",
+	'К': "K", 'Л': "L", 'М': "M", 'Н': "N", 'О': "O", 'П': "P", 'Р': "R", 'С': "S",
+	'Т': "T", 'У': "U", 'Ф': "F", 'Х': "Kh", 'Ц': "Ts", 'Ч': "Ch", 'Ш': "Sh", 'Щ': "Shch",
+	'Ъ': "", 'Ы': "Y", 'Ь': "", 'Э': "E", 'Ю': "Yu", 'Я': "Ya", 'а': "a", 'б': "b",
+	'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ж': "zh", 'з': "z", 'и': "i", 'й': "y",
+	'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s",
+	'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya", 'ё': "yo", 'ђ': "dj",
+	'ѓ': "g", 'є': "ye", 'ѕ': "dz", 'і': "i", 'ї': "yi", 'ј': "j", 'љ': "lj", 'њ': "nj",
+	'ћ': "c", 'ќ': "k", 'ў': "u", 'џ': "dz", 'Ґ': "G", 'ґ': "g",
+
+	// punctuations and symbols
+	'¡': "!", '¿': "?", '«': "<<", '»': ">>", '‹': "<", '›': ">", '•': "*", '·': ".",
+	'‘': "'", '’': "'", '‚': "'", '‛': "'", '“': "\"", '”': "\"", '„': "\"", '‟': "\"",
+	'‐': "-", '‑': "-", '‒': "-", '–': "-", '—': "-", '―': "-", '…': "...", '′': "'",
+	'″': "\"", '×': "x", '÷': "/", '©': "(C)", '®': "(R)", '™': "TM", '€': "EUR", '£': "GBP",
+	'¥': "JPY", '¢': "c", '°': "deg", '±': "+-", '½': "1/2", '¼': "1/4", '¾': "3/4", '¹': "1",
+	'²': "2", '³': "3", 'ﬀ': "ff", 'ﬁ': "fi", 'ﬂ': "fl", 'ﬃ': "ffi", 'ﬄ': "ffl", 'ﬅ': "st",
+	'ﬆ': "st",
+}
+
+// funcAsciify transliterates the string to ASCII. The diacritics are removed,
+// and the characters without the transliterations are removed.
+func funcAsciify(v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return &funcTypeError{"asciify", v}
+	}
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r < utf8.RuneSelf:
+			sb.WriteByte(byte(r))
+		case 0xFF01 <= r && r <= 0xFF5E: // fullwidth forms
+			sb.WriteByte(byte(r - 0xFEE0))
+		case unicode.IsSpace(r):
+			sb.WriteByte(' ')
+		default:
+			if b, ok := latinBase(r); ok {
+				sb.WriteString(b)
+			} else {
+				sb.WriteString(transliterations[r])
+			}
+		}
+	}
+	return sb.String()
+}
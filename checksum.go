@@ -0,0 +1,94 @@
+package gojq
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"hash/fnv"
+	"math/big"
+	"math/bits"
+)
+
+// checksumFunc creates the function to calculate the non-cryptographic hash of
+// a string (in UTF-8) or a byte string, which results in an unsigned integer.
+func checksumFunc(name string, f func([]byte) uint64) function {
+	return argFunc0(func(v interface{}) interface{} {
+		bs, ok := toBytes(v)
+		if !ok {
+			return &funcTypeError{name, v}
+		}
+		return normalizeNumbers(new(big.Int).SetUint64(f(bs)))
+	})
+}
+
+func checksumCRC32(bs []byte) uint64 {
+	return uint64(crc32.ChecksumIEEE(bs))
+}
+
+func checksumFNV1a(bs []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(bs)
+	return h.Sum64()
+}
+
+// The primes of XXH64 are variables to wrap around on the constant expressions.
+var (
+	xxhPrime1 uint64 = 11400714785074694791
+	xxhPrime2 uint64 = 14029467366897019727
+	xxhPrime3 uint64 = 1609587929392839161
+	xxhPrime4 uint64 = 9650029242287828579
+	xxhPrime5 uint64 = 2870177450012600261
+)
+
+// checksumXXHash64 calculates the XXH64 hash with the seed 0.
+func checksumXXHash64(bs []byte) uint64 {
+	n := uint64(len(bs))
+	var h uint64
+	if len(bs) >= 32 {
+		v1 := xxhPrime1 + xxhPrime2
+		v2 := xxhPrime2
+		v3 := uint64(0)
+		v4 := -xxhPrime1
+		for ; len(bs) >= 32; bs = bs[32:] {
+			v1 = xxhRound(v1, binary.LittleEndian.Uint64(bs[0:8]))
+			v2 = xxhRound(v2, binary.LittleEndian.Uint64(bs[8:16]))
+			v3 = xxhRound(v3, binary.LittleEndian.Uint64(bs[16:24]))
+			v4 = xxhRound(v4, binary.LittleEndian.Uint64(bs[24:32]))
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) +
+			bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		h = xxhMergeRound(h, v1)
+		h = xxhMergeRound(h, v2)
+		h = xxhMergeRound(h, v3)
+		h = xxhMergeRound(h, v4)
+	} else {
+		h = xxhPrime5
+	}
+	h += n
+	for ; len(bs) >= 8; bs = bs[8:] {
+		h ^= xxhRound(0, binary.LittleEndian.Uint64(bs))
+		h = bits.RotateLeft64(h, 27)*xxhPrime1 + xxhPrime4
+	}
+	if len(bs) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(bs)) * xxhPrime1
+		h = bits.RotateLeft64(h, 23)*xxhPrime2 + xxhPrime3
+		bs = bs[4:]
+	}
+	for _, b := range bs {
+		h ^= uint64(b) * xxhPrime5
+		h = bits.RotateLeft64(h, 11) * xxhPrime1
+	}
+	h ^= h >> 33
+	h *= xxhPrime2
+	h ^= h >> 29
+	h *= xxhPrime3
+	h ^= h >> 32
+	return h
+}
+
+func xxhRound(acc, x uint64) uint64 {
+	return bits.RotateLeft64(acc+x*xxhPrime2, 31) * xxhPrime1
+}
+
+func xxhMergeRound(acc, v uint64) uint64 {
+	return (acc^xxhRound(0, v))*xxhPrime1 + xxhPrime4
+}
@@ -0,0 +1,261 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/itchyny/go-flags"
+
+	"github.com/itchyny/gojq"
+)
+
+type benchopts struct {
+	Warmup      int      `long:"warmup" default:"1" description:"number of iterations before measuring"`
+	Iterations  int      `short:"n" long:"iterations" default:"10" description:"number of iterations to measure"`
+	InputFormat string   `long:"input-format" default:"json" description:"input format"`
+	FromFile    string   `short:"f" long:"from-file" description:"load query from file"`
+	ModulePaths []string `short:"L" description:"directory to search modules from"`
+}
+
+// benchInput is an input file read into the memory in advance, so that the
+// measurement does not depend on the disk.
+type benchInput struct {
+	name string
+	data []byte
+}
+
+// benchStats is the result of the iterations of the benchmark.
+type benchStats struct {
+	parse, compile, run []time.Duration
+	records             int
+	allocs, bytes       uint64
+}
+
+// runBench runs the query on the inputs repeatedly, and reports the time spent
+// on parsing and compiling the query and running it on the inputs, along with
+// the throughput and the allocations. The results of the query are discarded.
+func (cli *cli) runBench(args []string) error {
+	var opts benchopts
+	args, err := flags.NewParser(
+		&opts, flags.HelpFlag|flags.PassDoubleDash,
+	).ParseArgs(args)
+	if err != nil {
+		if err, ok := err.(*flags.Error); ok && err.Type == flags.ErrHelp {
+			fmt.Fprintf(cli.outStream, `%[1]s bench - measure the performance of the query
+
+Synopsis:
+  %% %[1]s bench -n 20 '.[] | .foo' file.json
+
+`, name)
+			fmt.Fprintln(cli.outStream, strings.Replace(err.Error(), "[OPTIONS]", "bench [OPTIONS] QUERY [FILE...]", 1))
+			return nil
+		}
+		return &flagParseError{err}
+	}
+	if opts.Warmup < 0 || opts.Iterations <= 0 {
+		return &flagParseError{fmt.Errorf(
+			"invalid number of iterations: %d (warmup: %d)", opts.Iterations, opts.Warmup)}
+	}
+	format, ok := inputFormats[opts.InputFormat]
+	if !ok {
+		return &flagParseError{fmt.Errorf(
+			"unknown input format: %q (expected %s)", opts.InputFormat, inputFormatNames())}
+	}
+	var arg, fname string
+	if opts.FromFile != "" {
+		src, err := ioutil.ReadFile(opts.FromFile)
+		if err != nil {
+			return err
+		}
+		arg, fname = string(src), opts.FromFile
+	} else if len(args) == 0 {
+		return &flagParseError{errors.New("query is required for bench")}
+	} else {
+		arg, fname = strings.TrimSpace(args[0]), "<arg>"
+		args = args[1:]
+	}
+	var inputs []benchInput
+	var size int
+	if len(args) == 0 {
+		data, err := ioutil.ReadAll(cli.inStream)
+		if err != nil {
+			return err
+		}
+		inputs = append(inputs, benchInput{"<stdin>", data})
+		size += len(data)
+	}
+	for _, fname := range args {
+		data, err := ioutil.ReadFile(fname)
+		if err != nil {
+			return err
+		}
+		inputs = append(inputs, benchInput{fname, data})
+		size += len(data)
+	}
+	newIter := newInputFormatIter(format, InputFormatOptions{})
+	loader := newModuleLoader(opts.ModulePaths)
+	var stats benchStats
+	var m runtime.MemStats
+	for i := -opts.Warmup; i < opts.Iterations; i++ {
+		if i == 0 {
+			runtime.GC()
+			runtime.ReadMemStats(&m)
+			stats.allocs, stats.bytes = m.Mallocs, m.TotalAlloc
+		}
+		start := time.Now()
+		query, err := gojq.Parse(arg)
+		if err != nil {
+			return &queryParseError{"query", fname, arg, err}
+		}
+		parsed := time.Now()
+		iter := &benchInputIter{newIter: newIter, inputs: inputs}
+		code, err := gojq.Compile(query,
+			gojq.WithModuleLoader(loader),
+			gojq.WithEnvironLoader(os.Environ),
+			gojq.WithFunction("debug", 0, 0, cli.funcDebug),
+			gojq.WithFunction("stderr", 0, 0, cli.funcStderr),
+			gojq.WithInputIter(iter),
+		)
+		if err != nil {
+			return &compileError{err}
+		}
+		compiled := time.Now()
+		records, err := cli.runBenchIteration(code, iter)
+		if err != nil {
+			return err
+		}
+		if i >= 0 {
+			stats.parse = append(stats.parse, parsed.Sub(start))
+			stats.compile = append(stats.compile, compiled.Sub(parsed))
+			stats.run = append(stats.run, time.Since(compiled))
+			stats.records = records
+		}
+	}
+	runtime.ReadMemStats(&m)
+	stats.allocs, stats.bytes = m.Mallocs-stats.allocs, m.TotalAlloc-stats.bytes
+	cli.printBenchStats(&stats, opts.Iterations, size)
+	return nil
+}
+
+// runBenchIteration runs the query on the inputs, and returns the number of
+// the inputs. The first error of the inputs or the query stops the benchmark.
+func (cli *cli) runBenchIteration(code *gojq.Code, iter inputIter) (int, error) {
+	defer iter.Close()
+	var records int
+	for {
+		if cli.ctx.Err() != nil {
+			return 0, &interruptError{}
+		}
+		v, ok := iter.Next()
+		if !ok {
+			return records, nil
+		}
+		if err, ok := v.(error); ok {
+			return 0, err
+		}
+		records++
+		results := code.RunWithContext(cli.ctx, v)
+		for {
+			v, ok := results.Next()
+			if !ok {
+				break
+			}
+			if err, ok := v.(error); ok {
+				if err, ok := err.(interface{ IsEmptyError() bool }); ok && err.IsEmptyError() {
+					continue
+				}
+				return 0, err
+			}
+		}
+	}
+}
+
+func (cli *cli) printBenchStats(stats *benchStats, n, size int) {
+	run := averageDuration(stats.run).Seconds()
+	w := cli.outStream
+	for _, x := range []struct {
+		name string
+		ds   []time.Duration
+	}{{"parse", stats.parse}, {"compile", stats.compile}, {"run", stats.run}} {
+		min, max := x.ds[0], x.ds[0]
+		for _, d := range x.ds {
+			if d < min {
+				min = d
+			} else if d > max {
+				max = d
+			}
+		}
+		fmt.Fprintf(w, "%-12s%v (min: %v, max: %v)\n", x.name+":",
+			roundDuration(averageDuration(x.ds)), roundDuration(min), roundDuration(max))
+	}
+	if run > 0 {
+		fmt.Fprintf(w, "%-12s%.2f MB/s, %.0f records/s\n", "throughput:",
+			float64(size)/run/1e6, float64(stats.records)/run)
+	}
+	fmt.Fprintf(w, "%-12s%d allocs/op, %d B/op\n", "allocs:",
+		stats.allocs/uint64(n), stats.bytes/uint64(n))
+	fmt.Fprintf(w, "%-12s%d iterations, %d records, %d bytes\n", "inputs:",
+		n, stats.records, size)
+}
+
+func averageDuration(ds []time.Duration) time.Duration {
+	var sum time.Duration
+	for _, d := range ds {
+		sum += d
+	}
+	return sum / time.Duration(len(ds))
+}
+
+// roundDuration rounds the duration to three or four significant digits.
+func roundDuration(d time.Duration) time.Duration {
+	for _, u := range []time.Duration{time.Second, time.Millisecond, time.Microsecond} {
+		if d >= u {
+			return d.Round(u / 1000)
+		}
+	}
+	return d
+}
+
+// benchInputIter emits the values decoded from the inputs in the memory.
+type benchInputIter struct {
+	newIter func(io.Reader, string) inputIter
+	inputs  []benchInput
+	iter    inputIter
+}
+
+func (i *benchInputIter) Next() (interface{}, bool) {
+	for {
+		if i.iter == nil {
+			if len(i.inputs) == 0 {
+				return nil, false
+			}
+			i.iter = i.newIter(bytes.NewReader(i.inputs[0].data), i.inputs[0].name)
+			i.inputs = i.inputs[1:]
+		}
+		if v, ok := i.iter.Next(); ok {
+			return v, ok
+		}
+		i.iter.Close()
+		i.iter = nil
+	}
+}
+
+func (i *benchInputIter) lineNumber() int {
+	return inputLineNumber(i.iter)
+}
+
+func (i *benchInputIter) Close() error {
+	if i.iter != nil {
+		i.iter.Close()
+		i.iter = nil
+	}
+	i.inputs = nil
+	return nil
+}
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/itchyny/go-flags"
 	"github.com/mattn/go-isatty"
+	"gopkg.in/yaml.v3"
 
 	"github.com/itchyny/gojq"
 )
@@ -31,7 +33,12 @@ const (
 	exitCodeDefaultErr
 )
 
+// exitCodeInterruptErr is the exit code when the query is interrupted by
+// SIGINT, following the shell convention of 128 + the signal number.
+const exitCodeInterruptErr = 130
+
 type cli struct {
+	ctx       context.Context
 	inStream  io.Reader
 	outStream io.Writer
 	errStream io.Writer
@@ -43,16 +50,41 @@ type cli struct {
 	outputYAML    bool
 	outputIndent  *int
 	outputTab     bool
-	inputRaw      bool
+	outputColor   bool
+	nonFinite     string
+	inputFormat   string
 	inputSlurp    bool
-	inputStream   bool
-	inputYAML     bool
+	preserveOrder bool
+	lazyInput     bool
+	simdInput     bool
+	strictInput   bool
+	invalidUTF8   string
+	streamErrors  bool
+	streamDepth   int
+	yamlAliases   string
+	aliasLimit    int
+	yamlRoundTrip bool
+	datetime      bool
+
+	argnames     []string
+	argvalues    []interface{}
+	capabilities capabilities
+
+	protobufDescriptors protobufDescriptors
+	openTerminal        func() (terminal, error)
 
-	argnames  []string
-	argvalues []interface{}
+	schema     interface{}
+	schemaCode *gojq.Code
 
 	outputYAMLSeparator bool
+	yamlNoDocSep        bool
+	yamlEndMarker       bool
+	yamlSequence        bool
+	yamlValues          []interface{}
+	yamlDocument        *yaml.Node
+	yamlDecoder         *yamlNodeDecoder
 	exitCodeError       error
+	errorFormat         string
 }
 
 type flagopts struct {
@@ -65,24 +97,63 @@ type flagopts struct {
 	OutputYAML    bool              `long:"yaml-output" description:"output by YAML"`
 	OutputIndent  *int              `long:"indent" description:"number of spaces for indentation"`
 	OutputTab     bool              `long:"tab" description:"use tabs for indentation"`
+	NonFinite     string            `long:"nonfinite" description:"output NaN and infinities by clamp, null, string or error" choice:"clamp" choice:"null" choice:"string" choice:"error"`
 	InputNull     bool              `short:"n" long:"null-input" description:"use null as input value"`
 	InputRaw      bool              `short:"R" long:"raw-input" description:"read input as raw strings"`
 	InputSlurp    bool              `short:"s" long:"slurp" description:"read all inputs into an array"`
+	SlurpStream   bool              `long:"slurp-stream" description:"read inputs into an array lazily on iterating it"`
 	InputStream   bool              `long:"stream" description:"parse input in stream fashion"`
+	StreamDepth   int               `long:"stream-depth" description:"parse input in stream fashion and emit values at the depth as they are"`
+	StreamErrors  bool              `long:"stream-errors" description:"parse input in stream fashion and emit parse errors as events"`
 	InputYAML     bool              `long:"yaml-input" description:"read input as YAML"`
+	InputBinary   bool              `long:"binary-input" description:"read each input file as a byte string"`
+	InputFormat   string            `long:"input-format" description:"read input in the named format (json, raw, stream, yaml, binary)"`
+	Precise       bool              `long:"precise-numbers" description:"keep the precision of numbers"`
+	Decimal       bool              `long:"decimal" description:"calculate numbers in decimals"`
+	PreserveOrder bool              `long:"preserve-order" description:"keep the order of object keys"`
+	LazyInput     bool              `long:"lazy-input" description:"decode input values lazily and emit untouched values as they are"`
+	SIMDInput     bool              `long:"simd-input" description:"decode JSON input by the simdjson-style decoder"`
+	StrictInput   bool              `long:"strict" description:"reject duplicate keys, lone surrogates and trailing characters in JSON input"`
+	InvalidUTF8   string            `long:"invalid-utf8" description:"handle invalid UTF-8 in input by replace, error or binary" choice:"replace" choice:"error" choice:"binary"`
+	YAMLAliases   string            `long:"yaml-aliases" description:"handle YAML aliases by expand, error or preserve" choice:"expand" choice:"error" choice:"preserve"`
+	YAMLNoDocSep  bool              `long:"yaml-no-doc-separator" description:"stop printing --- between YAML documents"`
+	YAMLEndMarker bool              `long:"yaml-end-marker" description:"print ... at the end of each YAML document"`
+	YAMLSequence  bool              `long:"yaml-sequence" description:"output all values in a YAML sequence document"`
+	YAMLRoundTrip bool              `long:"yaml-roundtrip" description:"keep comments and styles of YAML input in YAML output"`
+	AliasLimit    int               `long:"yaml-alias-limit" default:"1000000" description:"maximum number of values expanded from YAML aliases (0: unlimited)"`
+	Datetime      bool              `long:"datetime" description:"handle dates as datetime values"`
+	Optimize      bool              `long:"optimize" description:"optimize the compiled instructions"`
+	Disasm        bool              `long:"disasm" description:"print the compiled instructions and exit"`
+	Seed          *int64            `long:"seed" description:"seed of random functions for reproducible results"`
 	FromFile      string            `short:"f" long:"from-file" description:"load query from file"`
 	ModulePaths   []string          `short:"L" description:"directory to search modules from"`
+	RemoteModules bool              `long:"remote-modules" description:"allow importing modules from https URLs"`
+	AllowRead     bool              `long:"allow-read" description:"allow reading files by readfile functions"`
+	AllowWrite    bool              `long:"allow-write" description:"allow writing files by writefile function"`
+	AllowNet      bool              `long:"allow-net" description:"allow sending requests by http functions"`
+	AllowPrompt   bool              `long:"allow-prompt" description:"allow reading the terminal by prompt functions"`
+	Offline       bool              `long:"offline" description:"load remote modules only from the cache"`
 	Args          map[string]string `long:"arg" description:"set variable to string value" count:"2" unquote:"false"`
 	ArgsJSON      map[string]string `long:"argjson" description:"set variable to JSON value" count:"2" unquote:"false"`
 	SlurpFile     map[string]string `long:"slurpfile" description:"set variable to the JSON contents of the file" count:"2" unquote:"false"`
 	RawFile       map[string]string `long:"rawfile" description:"set variable to the contents of the file" count:"2" unquote:"false"`
+	SchemaFile    string            `long:"schema-file" description:"validate each input against the JSON schema file"`
 	ExitStatus    bool              `short:"e" long:"exit-status" description:"exit 1 when the last value is false or null"`
+	NoWarnings    bool              `long:"no-warnings" description:"stop printing warnings of the query"`
+	TypeCheck     bool              `long:"typecheck" description:"warn expressions which always fail"`
+	WarnShadowing bool              `long:"warn-shadowing" description:"warn variables shadowing the outer ones"`
+	CPUProfile    string            `long:"cpuprofile" description:"write the CPU profile of the run to the file"`
+	MemProfile    string            `long:"memprofile" description:"write the memory allocation profile of the run to the file"`
+	ErrorFormat   string            `long:"error-format" description:"print errors in text or json" choice:"text" choice:"json"`
 	Version       bool              `short:"v" long:"version" description:"print version"`
 }
 
 var addDefaultModulePaths = true
 
 func (cli *cli) run(args []string) int {
+	if cli.ctx == nil {
+		cli.ctx = context.Background()
+	}
 	if err := cli.runInternal(args); err != nil {
 		cli.printError(err)
 		if err, ok := err.(interface{ ExitCode() int }); ok {
@@ -94,6 +165,12 @@ func (cli *cli) run(args []string) int {
 }
 
 func (cli *cli) runInternal(args []string) (err error) {
+	if len(args) > 0 && args[0] == "serve" {
+		return cli.runServe(args[1:])
+	}
+	if len(args) > 0 && args[0] == "bench" {
+		return cli.runBench(args[1:])
+	}
 	var opts flagopts
 	args, err = flags.NewParser(
 		&opts, flags.HelpFlag|flags.PassDoubleDash,
@@ -118,19 +195,31 @@ Synopsis:
 		fmt.Fprintf(cli.outStream, "%s %s (rev: %s/%s)\n", name, version, revision, runtime.Version())
 		return nil
 	}
+	if opts.CPUProfile != "" || opts.MemProfile != "" {
+		stop, err := startProfiles(opts.CPUProfile, opts.MemProfile)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if er := stop(); er != nil && err == nil {
+				err = er
+			}
+		}()
+	}
 	cli.outputCompact, cli.outputRaw, cli.outputJoin, cli.outputNul,
 		cli.outputYAML, cli.outputIndent, cli.outputTab =
 		opts.OutputCompact, opts.OutputRaw, opts.OutputJoin, opts.OutputNul,
 		opts.OutputYAML, opts.OutputIndent, opts.OutputTab
-	defer func(x bool) { noColor = x }(noColor)
+	cli.nonFinite = opts.NonFinite
+	cli.errorFormat = opts.ErrorFormat
 	if opts.OutputColor || opts.OutputMono {
-		noColor = opts.OutputMono
+		cli.outputColor = !opts.OutputMono
 	} else if os.Getenv("NO_COLOR") != "" {
-		noColor = true
+		cli.outputColor = false
 	} else {
-		noColor = !isTTY(cli.outStream)
+		cli.outputColor = isTTY(cli.outStream)
 	}
-	if !noColor {
+	if cli.outputColor {
 		if colors := os.Getenv("GOJQ_COLORS"); colors != "" {
 			if err := setColors(colors); err != nil {
 				return err
@@ -144,11 +233,64 @@ Synopsis:
 			return fmt.Errorf("negative indentation count: %d", *i)
 		}
 	}
-	if opts.OutputYAML && opts.OutputTab {
+	if (opts.OutputYAML || opts.YAMLRoundTrip) && opts.OutputTab {
 		return errors.New("cannot use tabs for YAML output")
 	}
-	cli.inputRaw, cli.inputSlurp, cli.inputStream, cli.inputYAML =
-		opts.InputRaw, opts.InputSlurp, opts.InputStream, opts.InputYAML
+	switch {
+	case opts.InputFormat != "":
+		if _, ok := inputFormats[opts.InputFormat]; !ok {
+			return &flagParseError{fmt.Errorf(
+				"unknown input format: %q (expected %s)", opts.InputFormat, inputFormatNames())}
+		}
+		cli.inputFormat = opts.InputFormat
+	case opts.InputBinary:
+		cli.inputFormat = "binary"
+	case opts.InputRaw:
+		cli.inputFormat = "raw"
+	case opts.InputStream, opts.StreamErrors, opts.StreamDepth != 0:
+		cli.inputFormat = "stream"
+	case opts.InputYAML, opts.YAMLRoundTrip:
+		cli.inputFormat = "yaml"
+	default:
+		cli.inputFormat = "json"
+	}
+	cli.inputSlurp = opts.InputSlurp
+	cli.capabilities = capabilities{
+		read: opts.AllowRead, write: opts.AllowWrite, net: opts.AllowNet, prompt: opts.AllowPrompt,
+	}
+	cli.preserveOrder, cli.datetime = opts.PreserveOrder, opts.Datetime
+	cli.lazyInput, cli.strictInput = opts.LazyInput, opts.StrictInput
+	cli.invalidUTF8, cli.simdInput = opts.InvalidUTF8, opts.SIMDInput
+	if opts.StreamErrors && cli.inputFormat != "stream" {
+		return errors.New("cannot use --stream-errors option without stream input")
+	}
+	if opts.StreamDepth != 0 && cli.inputFormat != "stream" {
+		return errors.New("cannot use --stream-depth option without stream input")
+	} else if opts.StreamDepth < 0 {
+		return fmt.Errorf("negative stream depth: %d", opts.StreamDepth)
+	}
+	cli.streamErrors, cli.streamDepth = opts.StreamErrors, opts.StreamDepth
+	cli.yamlAliases, cli.aliasLimit = opts.YAMLAliases, opts.AliasLimit
+	if opts.YAMLRoundTrip {
+		if cli.inputFormat != "yaml" {
+			return errors.New("cannot use --yaml-roundtrip option without YAML input")
+		}
+		cli.outputYAML, cli.yamlRoundTrip = true, true
+	}
+	for _, o := range []struct {
+		name string
+		ok   bool
+	}{
+		{"--yaml-no-doc-separator", opts.YAMLNoDocSep},
+		{"--yaml-end-marker", opts.YAMLEndMarker},
+		{"--yaml-sequence", opts.YAMLSequence},
+	} {
+		if o.ok && !cli.outputYAML {
+			return errors.New("cannot use " + o.name + " option without YAML output")
+		}
+	}
+	cli.yamlNoDocSep, cli.yamlEndMarker, cli.yamlSequence =
+		opts.YAMLNoDocSep, opts.YAMLEndMarker, opts.YAMLSequence
 	for k, v := range opts.Args {
 		cli.argnames = append(cli.argnames, "$"+k)
 		cli.argvalues = append(cli.argvalues, v)
@@ -177,6 +319,11 @@ Synopsis:
 		cli.argnames = append(cli.argnames, "$"+k)
 		cli.argvalues = append(cli.argvalues, string(val))
 	}
+	if opts.SchemaFile != "" {
+		if err := cli.loadSchema(opts.SchemaFile); err != nil {
+			return err
+		}
+	}
 	var arg, fname string
 	if opts.FromFile != "" {
 		src, err := ioutil.ReadFile(opts.FromFile)
@@ -211,17 +358,74 @@ Synopsis:
 			modulePaths = modulePaths[1:]
 		}
 	}
+	loader := newModuleLoader(modulePaths)
+	if opts.RemoteModules || opts.Offline {
+		loader = append(moduleLoader{newHTTPModuleLoader(opts.Offline)}, loader...)
+	}
 	iter := cli.createInputIter(args)
 	defer iter.Close()
-	code, err := gojq.Compile(query,
-		gojq.WithModuleLoader(gojq.NewModuleLoader(modulePaths)),
+	compilerOpts := []gojq.CompilerOption{
+		gojq.WithModuleLoader(loader),
 		gojq.WithEnvironLoader(os.Environ),
 		gojq.WithVariables(cli.argnames),
 		gojq.WithFunction("debug", 0, 0, cli.funcDebug),
 		gojq.WithFunction("stderr", 0, 0, cli.funcStderr),
+		gojq.WithFunction("input_line_number", 0, 0,
+			func(interface{}, []interface{}) interface{} {
+				return inputLineNumber(iter)
+			}),
+		gojq.WithFunction("readfile", 1, 1, cli.funcReadFile),
+		gojq.WithIterFunction("readfile_json", 1, 1, cli.funcReadFileJSON),
+		gojq.WithFunction("writefile", 1, 1, cli.funcWriteFile),
+		gojq.WithFunction("http_get", 2, 2, cli.funcHTTPGet),
+		gojq.WithFunction("http_post", 2, 2, cli.funcHTTPPost),
+		gojq.WithFunction("protobuf_decode", 2, 2, cli.funcProtobufDecode),
+		gojq.WithFunction("protobuf_encode", 2, 2, cli.funcProtobufEncode),
+		gojq.WithFunction("zstd", 0, 0, funcZstd),
+		gojq.WithFunction("unzstd", 0, 0, funcUnzstd),
+		gojq.WithFunction("prompt", 1, 1, cli.funcPrompt),
+		gojq.WithFunction("getpass", 1, 1, cli.funcGetPass),
 		gojq.WithInputIter(iter),
-	)
+	}
+	if opts.Precise {
+		compilerOpts = append(compilerOpts, gojq.WithPreciseNumbers())
+	}
+	if opts.Decimal {
+		compilerOpts = append(compilerOpts, gojq.WithDecimal())
+	}
+	if opts.Datetime {
+		compilerOpts = append(compilerOpts, gojq.WithDatetime())
+	}
+	if opts.PreserveOrder {
+		compilerOpts = append(compilerOpts, gojq.WithPreserveOrder())
+	}
+	if opts.Seed != nil {
+		compilerOpts = append(compilerOpts, gojq.WithRandomSeed(*opts.Seed))
+	}
+	var disasm string
+	if opts.Disasm && opts.Optimize {
+		// compile without the optimization to show the instructions before it
+		if code, err := gojq.Compile(query, compilerOpts...); err == nil {
+			disasm = "# before optimization\n" + code.Disassemble() + "# after optimization\n"
+		}
+	}
+	if opts.Optimize {
+		compilerOpts = append(compilerOpts, gojq.WithOptimization())
+	}
+	if !opts.NoWarnings {
+		compilerOpts = append(compilerOpts, gojq.WithWarningHandler(cli.printWarning))
+		if opts.TypeCheck {
+			compilerOpts = append(compilerOpts, gojq.WithTypeCheck())
+		}
+		if opts.WarnShadowing {
+			compilerOpts = append(compilerOpts, gojq.WithShadowingWarning())
+		}
+	}
+	code, err := gojq.Compile(query, compilerOpts...)
 	if err != nil {
+		if err, ok := err.(*queryParseError); ok {
+			return err
+		}
 		if err, ok := err.(interface {
 			QueryParseError() (string, string, string, error)
 		}); ok {
@@ -239,8 +443,18 @@ Synopsis:
 		}
 		return &compileError{err}
 	}
+	if opts.Disasm {
+		_, err := io.WriteString(cli.outStream, disasm+code.Disassemble())
+		return err
+	}
 	if opts.InputNull {
-		iter = newNullInputIter()
+		return cli.process(newNullInputIter(), code)
+	}
+	if opts.SlurpStream && !opts.InputSlurp {
+		iter := newSlurpStreamInputIter(iter, newInputFormatIter(
+			inputFormats["json"], InputFormatOptions{PreserveOrder: cli.preserveOrder}))
+		defer iter.Close()
+		return cli.process(iter, code)
 	}
 	return cli.process(iter, code)
 }
@@ -255,21 +469,57 @@ func slurpFile(name string) (interface{}, error) {
 	return val, nil
 }
 
-func (cli *cli) createInputIter(args []string) (iter inputIter) {
-	var newIter func(io.Reader, string) inputIter
-	switch {
-	case cli.inputRaw:
-		newIter = newRawInputIter
-	case cli.inputStream:
-		newIter = newStreamInputIter
-	case cli.inputYAML:
-		newIter = newYAMLInputIter
+// loadSchema loads the JSON schema file to validate the inputs before running
+// the query.
+func (cli *cli) loadSchema(name string) error {
+	val, err := slurpFile(name)
+	if err != nil {
+		return err
+	}
+	if vs := val.([]interface{}); len(vs) != 1 {
+		return fmt.Errorf("expected one schema in %s but got %d values", name, len(vs))
+	}
+	query, err := gojq.Parse("validate($schema)")
+	if err != nil {
+		return err
+	}
+	code, err := gojq.Compile(query, gojq.WithVariables([]string{"$schema"}))
+	if err != nil {
+		return err
+	}
+	cli.schema, cli.schemaCode = val.([]interface{})[0], code
+	return nil
+}
+
+func (cli *cli) validateSchema(v interface{}) error {
+	r, _ := cli.schemaCode.Run(v, cli.schema).Next()
+	switch r := r.(type) {
+	case error:
+		return r
+	case []interface{}:
+		return &schemaValidationError{r}
 	default:
-		newIter = newJSONInputIter
+		return nil
 	}
+}
+
+func (cli *cli) createInputIter(args []string) (iter inputIter) {
+	newIter := newInputFormatIter(inputFormats[cli.inputFormat], InputFormatOptions{
+		PreserveOrder:  cli.preserveOrder,
+		Datetime:       cli.datetime,
+		Lazy:           cli.lazyInput && !cli.outputYAML,
+		SIMD:           cli.simdInput,
+		Strict:         cli.strictInput,
+		InvalidUTF8:    cli.invalidUTF8,
+		StreamErrors:   cli.streamErrors,
+		StreamDepth:    cli.streamDepth,
+		YAMLAliases:    cli.yamlAliases,
+		YAMLAliasLimit: cli.aliasLimit,
+		YAMLRoundTrip:  cli.yamlRoundTrip,
+	})
 	if cli.inputSlurp {
 		defer func() {
-			if cli.inputRaw {
+			if cli.inputFormat == "raw" {
 				iter = newSlurpRawInputIter(iter)
 			} else {
 				iter = newSlurpInputIter(iter)
@@ -285,8 +535,16 @@ func (cli *cli) createInputIter(args []string) (iter inputIter) {
 func (cli *cli) process(iter inputIter, code *gojq.Code) error {
 	var err error
 	for {
+		if cli.ctx.Err() != nil {
+			return &interruptError{}
+		}
 		v, ok := iter.Next()
 		if !ok {
+			if cli.yamlSequence {
+				if er := cli.printYAMLSequence(); er != nil {
+					return er
+				}
+			}
 			return err
 		}
 		if er, ok := v.(error); ok {
@@ -294,7 +552,20 @@ func (cli *cli) process(iter inputIter, code *gojq.Code) error {
 			err = &emptyError{er}
 			continue
 		}
-		if er := cli.printValues(code.Run(v, cli.argvalues...)); er != nil {
+		if cli.schemaCode != nil {
+			if er := cli.validateSchema(v); er != nil {
+				cli.printError(er)
+				err = &emptyError{er}
+				continue
+			}
+		}
+		if cli.yamlRoundTrip {
+			cli.yamlDocument, cli.yamlDecoder = inputYAMLDocument(iter)
+		}
+		if er := cli.printValues(code.RunWithContext(cli.ctx, v, cli.argvalues...)); er != nil {
+			if errors.Is(er, context.Canceled) {
+				return &interruptError{}
+			}
 			cli.printError(er)
 			err = &emptyError{er}
 		}
@@ -311,13 +582,20 @@ func (cli *cli) printValues(iter gojq.Iter) error {
 		if err, ok := v.(error); ok {
 			return err
 		}
-		if cli.outputYAMLSeparator {
-			cli.outStream.Write([]byte("---\n"))
+		if cli.yamlSequence {
+			cli.yamlValues = append(cli.yamlValues, v)
 		} else {
-			cli.outputYAMLSeparator = cli.outputYAML
-		}
-		if err := m.marshal(v, cli.outStream); err != nil {
-			return err
+			if cli.outputYAMLSeparator {
+				cli.outStream.Write([]byte("---\n"))
+			} else {
+				cli.outputYAMLSeparator = cli.outputYAML && !cli.yamlNoDocSep
+			}
+			if err := m.marshal(v, cli.outStream); err != nil {
+				return err
+			}
+			if cli.yamlEndMarker {
+				cli.outStream.Write([]byte("...\n"))
+			}
 		}
 		if cli.exitCodeError != nil {
 			if v == nil || v == false {
@@ -337,9 +615,28 @@ func (cli *cli) printValues(iter gojq.Iter) error {
 	return nil
 }
 
+// printYAMLSequence prints the values collected by --yaml-sequence option in
+// one YAML document.
+func (cli *cli) printYAMLSequence() error {
+	vs := cli.yamlValues
+	if vs == nil {
+		vs = []interface{}{}
+	}
+	cli.yamlValues = nil
+	if err := yamlFormatter(cli.outputIndent, cli.preserveOrder).marshal(vs, cli.outStream); err != nil {
+		return err
+	}
+	if cli.yamlEndMarker {
+		cli.outStream.Write([]byte("...\n"))
+	}
+	return nil
+}
+
 func (cli *cli) createMarshaler() marshaler {
 	if cli.outputYAML {
-		return yamlFormatter(cli.outputIndent)
+		m := yamlFormatter(cli.outputIndent, cli.preserveOrder)
+		m.doc, m.dec = cli.yamlDocument, cli.yamlDecoder
+		return m
 	}
 	indent := 2
 	if cli.outputCompact {
@@ -349,7 +646,8 @@ func (cli *cli) createMarshaler() marshaler {
 	} else if i := cli.outputIndent; i != nil {
 		indent = *i
 	}
-	f := newEncoder(cli.outputTab, indent)
+	f := newEncoder(cli.outputTab, indent, cli.outputColor)
+	f.nonFinite = cli.nonFinite
 	if cli.outputRaw || cli.outputJoin || cli.outputNul {
 		return &rawMarshaler{f}
 	}
@@ -357,22 +655,47 @@ func (cli *cli) createMarshaler() marshaler {
 }
 
 func (cli *cli) funcDebug(v interface{}, _ []interface{}) interface{} {
-	newEncoder(false, 0).marshal([]interface{}{"DEBUG:", v}, cli.errStream)
+	newEncoder(false, 0, cli.outputColor).marshal([]interface{}{"DEBUG:", v}, cli.errStream)
 	cli.errStream.Write([]byte{'\n'})
 	return v
 }
 
 func (cli *cli) funcStderr(v interface{}, _ []interface{}) interface{} {
-	newEncoder(false, 0).marshal(v, cli.errStream)
+	newEncoder(false, 0, cli.outputColor).marshal(v, cli.errStream)
 	return v
 }
 
 func (cli *cli) printError(err error) {
 	if er, ok := err.(interface{ IsEmptyError() bool }); !ok || !er.IsEmptyError() {
+		if cli.errorFormat == "json" {
+			cli.errStream.Write(append(errorToJSON(err), '\n'))
+			return
+		}
 		fmt.Fprintf(cli.errStream, "%s: %s\n", name, err)
 	}
 }
 
+// errorToJSON encodes the error in JSON with the message, the value of the
+// error function, and the name and the span of the function raised the error.
+func errorToJSON(err error) []byte {
+	v := map[string]interface{}{"message": err.Error()}
+	if er, ok := err.(gojq.ValueError); ok {
+		v["value"] = er.Value()
+	}
+	if er, ok := err.(gojq.RuntimeError); ok {
+		v["name"] = er.Name()
+		if start, end := er.Span(); start >= 0 {
+			v["span"] = []interface{}{start, end}
+		}
+	}
+	bs, _ := gojq.Marshal(v)
+	return bs
+}
+
+func (cli *cli) printWarning(msg string) {
+	fmt.Fprintf(cli.errStream, "%s: warning: %s\n", name, msg)
+}
+
 // isTTY attempts to determine whether an output is a TTY.
 func isTTY(w io.Writer) bool {
 	if os.Getenv("TERM") == "dumb" {
@@ -1,13 +1,23 @@
 package cli
 
 import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"gopkg.in/yaml.v3"
+
+	"github.com/itchyny/gojq"
 )
 
 func init() {
@@ -16,6 +26,16 @@ func init() {
 	os.Setenv("GOJQ_COLORS", "")
 }
 
+// tempDir is testing.T.TempDir, which is available since Go 1.15.
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "gojq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
 func setLocation(loc *time.Location) func() {
 	orig := time.Local
 	time.Local = loc
@@ -120,3 +140,564 @@ func TestCliRun(t *testing.T) {
 		})
 	}
 }
+
+func TestCliRun_WriteFile(t *testing.T) {
+	dir := tempDir(t)
+	var outStream, errStream strings.Builder
+	cli := cli{
+		inStream:  strings.NewReader(`"x" {"a":1}`),
+		outStream: &outStream,
+		errStream: &errStream,
+	}
+	code := cli.run([]string{"-c", "--allow-write", "--arg", "dir", dir,
+		`writefile($dir + "/" + type)`})
+	if code != exitCodeOK {
+		t.Errorf("exit code: got: %v, expected: %v", code, exitCodeOK)
+	}
+	if diff := cmp.Diff("\"x\"\n{\"a\":1}\n", outStream.String()); diff != "" {
+		t.Error("standard output:\n" + diff)
+	}
+	if diff := cmp.Diff("", errStream.String()); diff != "" {
+		t.Error("standard error output:\n" + diff)
+	}
+	for name, expected := range map[string]string{"string": "x", "object": "{\"a\":1}\n"} {
+		bs, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(expected, string(bs)); diff != "" {
+			t.Error("file contents:\n" + diff)
+		}
+	}
+}
+
+func TestCliRun_HTTP(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_, _ = w.Write([]byte(`{"foo":[1,2]}`))
+		case "/echo":
+			bs, _ := ioutil.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte(r.Method + " " + r.Header.Get("Content-Type") +
+				" " + r.Header.Get("X-Test") + " " + string(bs)))
+		case "/large":
+			_, _ = w.Write([]byte(strings.Repeat("x", 101)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	var outStream, errStream strings.Builder
+	cli := cli{
+		inStream:  strings.NewReader(`{"a":1}`),
+		outStream: &outStream,
+		errStream: &errStream,
+	}
+	code := cli.run([]string{"-c", "--allow-net", "--arg", "url", ts.URL,
+		`http_get($url + "/json"; {}).body,
+		(http_post($url + "/echo"; {"X-Test": "x"}) | .status, .headers["content-type"], .body),
+		("text" | http_post($url + "/echo"; {"Content-Type": "text/plain"}).body),
+		http_get($url + "/none"; {}).status`})
+	if code != exitCodeOK {
+		t.Errorf("exit code: got: %v, expected: %v", code, exitCodeOK)
+	}
+	expected := `{"foo":[1,2]}
+200
+"text/plain"
+"POST application/json x {\"a\":1}"
+"POST text/plain  text"
+404
+`
+	if diff := cmp.Diff(expected, outStream.String()); diff != "" {
+		t.Error("standard output:\n" + diff)
+	}
+	if diff := cmp.Diff("", errStream.String()); diff != "" {
+		t.Error("standard error output:\n" + diff)
+	}
+
+	defer func(size int) { maxHTTPResponseSize = size }(maxHTTPResponseSize)
+	maxHTTPResponseSize = 100
+	outStream.Reset()
+	errStream.Reset()
+	code = cli.run([]string{"-n", "-c", "--allow-net", "--preserve-order", "--arg", "url", ts.URL,
+		`http_post($url + "/echo"; {"X-Test": "y"}).body, http_get($url + "/large"; {})`})
+	if code != exitCodeDefaultErr {
+		t.Errorf("exit code: got: %v, expected: %v", code, exitCodeDefaultErr)
+	}
+	expected = `"POST application/json y null"
+`
+	if diff := cmp.Diff(expected, outStream.String()); diff != "" {
+		t.Error("standard output:\n" + diff)
+	}
+	if !strings.Contains(errStream.String(), "exceeds the size limit of 100 bytes") {
+		t.Errorf("expected a size limit error but got: %s", errStream.String())
+	}
+}
+
+type fakeTerminal struct {
+	strings.Builder
+	lines []string
+	echo  []bool
+}
+
+func (t *fakeTerminal) readLine(echo bool) (string, error) {
+	t.echo = append(t.echo, echo)
+	s := t.lines[0]
+	t.lines = t.lines[1:]
+	return s, nil
+}
+
+func (*fakeTerminal) Close() error { return nil }
+
+func TestCliRun_Prompt(t *testing.T) {
+	term := &fakeTerminal{lines: []string{"gojq", "secret"}}
+	var outStream, errStream strings.Builder
+	cli := cli{
+		inStream:     strings.NewReader(`{}`),
+		outStream:    &outStream,
+		errStream:    &errStream,
+		openTerminal: func() (terminal, error) { return term, nil },
+	}
+	code := cli.run([]string{"-c", "--allow-prompt", `{name: prompt("Name: "), password: getpass("Password: ")}`})
+	if code != exitCodeOK {
+		t.Errorf("exit code: got: %v, expected: %v", code, exitCodeOK)
+	}
+	if diff := cmp.Diff("{\"name\":\"gojq\",\"password\":\"secret\"}\n", outStream.String()); diff != "" {
+		t.Error("standard output:\n" + diff)
+	}
+	if diff := cmp.Diff("", errStream.String()); diff != "" {
+		t.Error("standard error output:\n" + diff)
+	}
+	if diff := cmp.Diff("Name: Password: ", term.String()); diff != "" {
+		t.Error("terminal output:\n" + diff)
+	}
+	if diff := cmp.Diff([]bool{true, false}, term.echo); diff != "" {
+		t.Error("terminal echo:\n" + diff)
+	}
+}
+
+func TestCliRun_PromptWithoutTerminal(t *testing.T) {
+	var outStream, errStream strings.Builder
+	cli := cli{
+		inStream:  strings.NewReader(`{}`),
+		outStream: &outStream,
+		errStream: &errStream,
+		openTerminal: func() (terminal, error) {
+			return nil, errors.New("no terminal")
+		},
+	}
+	code := cli.run([]string{"--allow-prompt", `prompt("Name: ")`})
+	if code != exitCodeDefaultErr {
+		t.Errorf("exit code: got: %v, expected: %v", code, exitCodeDefaultErr)
+	}
+	if diff := cmp.Diff("", outStream.String()); diff != "" {
+		t.Error("standard output:\n" + diff)
+	}
+	if diff := cmp.Diff("gojq: prompt requires a terminal\n", errStream.String()); diff != "" {
+		t.Error("standard error output:\n" + diff)
+	}
+}
+
+type cancelWriter struct {
+	strings.Builder
+	cancel func()
+	after  string
+}
+
+func (w *cancelWriter) Write(p []byte) (int, error) {
+	if string(p) == w.after {
+		w.cancel()
+	}
+	return w.Builder.Write(p)
+}
+
+func TestCliRun_Interrupt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	outStream := &cancelWriter{cancel: cancel, after: "3"}
+	var errStream strings.Builder
+	cli := cli{
+		ctx:       ctx,
+		inStream:  strings.NewReader("1 2"),
+		outStream: outStream,
+		errStream: &errStream,
+	}
+	code := cli.run([]string{"def f: ., (. + 1 | f); f"})
+	if code != exitCodeInterruptErr {
+		t.Errorf("exit code: got: %v, expected: %v", code, exitCodeInterruptErr)
+	}
+	if diff := cmp.Diff("1\n2\n3\n", outStream.String()); diff != "" {
+		t.Error("standard output:\n" + diff)
+	}
+	if diff := cmp.Diff(name+": interrupted\n", errStream.String()); diff != "" {
+		t.Error("standard error output:\n" + diff)
+	}
+}
+
+func TestCliRun_InputFormat(t *testing.T) {
+	defer func(formats map[string]InputFormat) { inputFormats = formats }(inputFormats)
+	formats := inputFormats
+	inputFormats = make(map[string]InputFormat, len(formats)+1)
+	for name, f := range formats {
+		inputFormats[name] = f
+	}
+	RegisterInputFormat("words", func(r io.Reader, _ string, _ InputFormatOptions) gojq.Iter {
+		bs, err := ioutil.ReadAll(r)
+		if err != nil {
+			return gojq.NewIter(err)
+		}
+		var vs []interface{}
+		for _, w := range strings.Fields(string(bs)) {
+			vs = append(vs, w)
+		}
+		return gojq.NewIter(vs...)
+	})
+	var outStream, errStream strings.Builder
+	cli := cli{
+		inStream:  strings.NewReader("foo bar\nbaz"),
+		outStream: &outStream,
+		errStream: &errStream,
+	}
+	code := cli.run([]string{"--input-format=words", "-c", "[., length]"})
+	if code != exitCodeOK {
+		t.Errorf("exit code: got: %v, expected: %v", code, exitCodeOK)
+	}
+	if diff := cmp.Diff("[\"foo\",3]\n[\"bar\",3]\n[\"baz\",3]\n", outStream.String()); diff != "" {
+		t.Error("standard output:\n" + diff)
+	}
+	if diff := cmp.Diff("", errStream.String()); diff != "" {
+		t.Error("standard error output:\n" + diff)
+	}
+}
+
+type wordsInputIter struct {
+	words  []string
+	reads  *int
+	closed *bool
+}
+
+func (i *wordsInputIter) Next() (interface{}, bool) {
+	if len(i.words) == 0 {
+		return nil, false
+	}
+	w := i.words[0]
+	i.words = i.words[1:]
+	*i.reads++
+	return w, true
+}
+
+func (i *wordsInputIter) Close() error {
+	*i.closed = true
+	return nil
+}
+
+func TestCliRun_InputsShortCircuit(t *testing.T) {
+	defer func(formats map[string]InputFormat) { inputFormats = formats }(inputFormats)
+	formats := inputFormats
+	inputFormats = make(map[string]InputFormat, len(formats)+1)
+	for name, f := range formats {
+		inputFormats[name] = f
+	}
+	var files []string
+	var reads int
+	var closed bool
+	RegisterInputFormat("words", func(r io.Reader, fname string, _ InputFormatOptions) gojq.Iter {
+		files = append(files, filepath.Base(fname))
+		bs, err := ioutil.ReadAll(r)
+		if err != nil {
+			return gojq.NewIter(err)
+		}
+		return &wordsInputIter{strings.Fields(string(bs)), &reads, &closed}
+	})
+	dir := tempDir(t)
+	for name, cnt := range map[string]string{"a.txt": "foo bar baz qux", "b.txt": "quux"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(cnt), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var outStream, errStream strings.Builder
+	cli := cli{
+		inStream:  strings.NewReader(""),
+		outStream: &outStream,
+		errStream: &errStream,
+	}
+	code := cli.run([]string{"--input-format=words", "-n", "-c",
+		`first(inputs | select(startswith("b"))), [limit(2; inputs)]`,
+		filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")})
+	if code != exitCodeOK {
+		t.Errorf("exit code: got: %v, expected: %v", code, exitCodeOK)
+	}
+	if diff := cmp.Diff("\"bar\"\n[\"baz\",\"qux\"]\n", outStream.String()); diff != "" {
+		t.Error("standard output:\n" + diff)
+	}
+	if diff := cmp.Diff("", errStream.String()); diff != "" {
+		t.Error("standard error output:\n" + diff)
+	}
+	if diff := cmp.Diff([]string{"a.txt"}, files); diff != "" {
+		t.Error("opened files:\n" + diff)
+	}
+	if expected := 4; reads != expected {
+		t.Errorf("reads: got: %v, expected: %v", reads, expected)
+	}
+	if !closed {
+		t.Error("input iterator should be closed")
+	}
+}
+
+func TestCliRun_SlurpStream(t *testing.T) {
+	defer func(size int) { slurpStreamBufferSize = size }(slurpStreamBufferSize)
+	slurpStreamBufferSize = 3
+	var outStream, errStream strings.Builder
+	cli := cli{
+		inStream:  strings.NewReader("1 2 3 4 5 6 7 8 9 10"),
+		outStream: &outStream,
+		errStream: &errStream,
+	}
+	code := cli.run([]string{"--slurp-stream", "-c",
+		"first(.[]), add, [.[] as $x | .[] | select(. == $x)] == ., sort_by(-.)[:3], .[7], length"})
+	if code != exitCodeOK {
+		t.Errorf("exit code: got: %v, expected: %v", code, exitCodeOK)
+	}
+	if diff := cmp.Diff("1\n55\ntrue\n[10,9,8]\n8\n10\n", outStream.String()); diff != "" {
+		t.Error("standard output:\n" + diff)
+	}
+	if diff := cmp.Diff("", errStream.String()); diff != "" {
+		t.Error("standard error output:\n" + diff)
+	}
+}
+
+func TestCliRun_Bench(t *testing.T) {
+	var outStream, errStream strings.Builder
+	cli := cli{
+		inStream:  strings.NewReader(`{"a": 1} {"a": 2} {"a": 3}`),
+		outStream: &outStream,
+		errStream: &errStream,
+	}
+	code := cli.run([]string{"bench", "--warmup=2", "-n", "3", ".a + 1, empty"})
+	if code != exitCodeOK {
+		t.Errorf("exit code: got: %v, expected: %v", code, exitCodeOK)
+	}
+	for _, pattern := range []string{
+		`(?m)^parse:\s+\S+ \(min: \S+, max: \S+\)$`,
+		`(?m)^compile:\s+\S+ \(min: \S+, max: \S+\)$`,
+		`(?m)^run:\s+\S+ \(min: \S+, max: \S+\)$`,
+		`(?m)^throughput: [0-9.]+ MB/s, [0-9]+ records/s$`,
+		`(?m)^allocs:\s+[0-9]+ allocs/op, [0-9]+ B/op$`,
+		`(?m)^inputs:\s+3 iterations, 3 records, 26 bytes$`,
+	} {
+		if !regexp.MustCompile(pattern).MatchString(outStream.String()) {
+			t.Errorf("standard output should match %q:\n%s", pattern, outStream.String())
+		}
+	}
+	if diff := cmp.Diff("", errStream.String()); diff != "" {
+		t.Error("standard error output:\n" + diff)
+	}
+
+	outStream.Reset()
+	cli.inStream = strings.NewReader(`{"a": 1} [] {"a": 3}`)
+	code = cli.run([]string{"bench", ".a"})
+	if code != exitCodeDefaultErr {
+		t.Errorf("exit code: got: %v, expected: %v", code, exitCodeDefaultErr)
+	}
+	if diff := cmp.Diff("", outStream.String()); diff != "" {
+		t.Error("standard output:\n" + diff)
+	}
+	if diff := cmp.Diff("gojq: expected an object but got: array ([])\n", errStream.String()); diff != "" {
+		t.Error("standard error output:\n" + diff)
+	}
+}
+
+func TestCliRun_Profile(t *testing.T) {
+	dir := tempDir(t)
+	cpuprofile, memprofile := filepath.Join(dir, "cpu.prof"), filepath.Join(dir, "mem.prof")
+	var outStream, errStream strings.Builder
+	cli := cli{
+		inStream:  strings.NewReader(`{"a": 1} {"a": 2}`),
+		outStream: &outStream,
+		errStream: &errStream,
+	}
+	code := cli.run([]string{"--cpuprofile", cpuprofile, "--memprofile", memprofile, ".a"})
+	if code != exitCodeOK {
+		t.Errorf("exit code: got: %v, expected: %v", code, exitCodeOK)
+	}
+	if diff := cmp.Diff("1\n2\n", outStream.String()); diff != "" {
+		t.Error("standard output:\n" + diff)
+	}
+	if diff := cmp.Diff("", errStream.String()); diff != "" {
+		t.Error("standard error output:\n" + diff)
+	}
+	for _, fname := range []string{cpuprofile, memprofile} {
+		if fi, err := os.Stat(fname); err != nil {
+			t.Error(err)
+		} else if fi.Size() == 0 {
+			t.Errorf("profile should not be empty: %s", fname)
+		}
+	}
+
+	errStream.Reset()
+	code = cli.run([]string{"--cpuprofile", filepath.Join(dir, "x", "cpu.prof"), "."})
+	if code != exitCodeDefaultErr {
+		t.Errorf("exit code: got: %v, expected: %v", code, exitCodeDefaultErr)
+	}
+	if !strings.HasPrefix(errStream.String(), "gojq: open ") {
+		t.Errorf("standard error output should report the error: %q", errStream.String())
+	}
+}
+
+func TestCliRun_InvalidUTF8(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		input    string
+		expected string
+		err      string
+	}{
+		{
+			name:     "json default",
+			args:     []string{"-c", "."},
+			input:    "{\"a\": \"x\xffy\"}",
+			expected: "{\"a\":\"x�y\"}\n",
+		},
+		{
+			name:     "json replace",
+			args:     []string{"--invalid-utf8=replace", "-c", "."},
+			input:    "{\"a\": \"x\xffy\"}",
+			expected: "{\"a\":\"x�y\"}\n",
+		},
+		{
+			name:     "json error",
+			args:     []string{"--invalid-utf8=error", "-c", "."},
+			input:    "\"ok\"\n{\"a\": \"x\xffy\"}",
+			expected: "\"ok\"\n",
+			err: "gojq: invalid json: <stdin>:2\n" +
+				"    2 | {\"a\": \"x�y\"}\n" +
+				"                ^  invalid UTF-8 byte 0xff at byte offset 13\n",
+		},
+		{
+			name:  "json binary",
+			args:  []string{"--invalid-utf8=binary", "-c", `.[] | type, tostring`},
+			input: "[\"x\xffy\\n\\u00e9\", \"ok\", 1, {\"k\xff\": null}]",
+			expected: "\"bytes\"\n\"x\\ufffdy\\né\"\n\"string\"\n\"ok\"\n" +
+				"\"number\"\n\"1\"\n\"object\"\n\"{\\\"k�\\\":null}\"\n",
+		},
+		{
+			name:     "json binary raw output",
+			args:     []string{"--invalid-utf8=binary", "-r", ".a"},
+			input:    "{\"a\": \"x\xffy\"}",
+			expected: "x\xffy\n",
+		},
+		{
+			name:     "raw replace",
+			args:     []string{"--invalid-utf8=replace", "-R", "-c", "."},
+			input:    "ab\nx\xffy\n",
+			expected: "\"ab\"\n\"x�y\"\n",
+		},
+		{
+			name:     "raw error",
+			args:     []string{"--invalid-utf8=error", "-R", "-c", "."},
+			input:    "ab\r\nx\xffy\n",
+			expected: "\"ab\"\n",
+			err:      "gojq: invalid raw input: <stdin>:2: invalid UTF-8 byte 0xff at byte offset 5\n",
+		},
+		{
+			name:     "raw binary",
+			args:     []string{"--invalid-utf8=binary", "-R", "-r", "type, ."},
+			input:    "ab\nx\xffy\n",
+			expected: "string\nab\nbytes\nx\xffy\n",
+		},
+		{
+			name:     "raw binary slurp",
+			args:     []string{"--invalid-utf8=binary", "-R", "-s", "-r", "type, ."},
+			input:    "ab\nx\xffy\n",
+			expected: "bytes\nab\nx\xffy\n\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var outStream, errStream strings.Builder
+			cli := cli{
+				inStream:  strings.NewReader(tc.input),
+				outStream: &outStream,
+				errStream: &errStream,
+			}
+			cli.run(tc.args)
+			if diff := cmp.Diff(tc.expected, outStream.String()); diff != "" {
+				t.Error("standard output:\n" + diff)
+			}
+			if diff := cmp.Diff(tc.err, errStream.String()); diff != "" {
+				t.Error("standard error output:\n" + diff)
+			}
+		})
+	}
+}
+
+func TestCliRun_Warnings(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		input    string
+		expected string
+		err      string
+	}{
+		{
+			name:     "unused function",
+			args:     []string{`def f: 1; def g($x): $x; def h: 2; g(3)`},
+			input:    "null",
+			expected: "3\n",
+			err: "gojq: warning: function f/0 is defined but not used\n" +
+				"gojq: warning: function h/0 is defined but not used\n",
+		},
+		{
+			name:     "comparison with null",
+			args:     []string{"-c", `[length == null, null != (keys), .a == null]`},
+			input:    "{}",
+			expected: "[false,true,true]\n",
+			err: "gojq: warning: comparison of length and null is always false\n" +
+				"gojq: warning: comparison of (keys) and null is always true\n",
+		},
+		{
+			name:     "shadowed variable",
+			args:     []string{"-c", `. as $x | [.[] | . as $x | $x]`},
+			input:    "[1]",
+			expected: "[1]\n",
+		},
+		{
+			name:     "shadowed variable with warn shadowing option",
+			args:     []string{"--warn-shadowing", "-c", `. as $x | [.[] | . as $x | $x], reduce .[] as $y (0; .)`},
+			input:    "[1]",
+			expected: "[1]\n0\n",
+			err:      "gojq: warning: variable $x shadows the variable of the same name in the outer scope\n",
+		},
+		{
+			name: "typecheck option",
+			args: []string{"--typecheck", "-c",
+				`if . then .a else length | .b end, if .a then "x" + 1 else 0 end, try (keys | .c) catch "ok"`},
+			input:    "{}",
+			expected: "null\n0\n\"ok\"\n",
+			err: "gojq: warning: .b always fails: expected an object but got: number\n" +
+				"gojq: warning: \"x\" + 1 always fails: cannot add: string and number\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var outStream, errStream strings.Builder
+			cli := cli{
+				inStream:  strings.NewReader(tc.input),
+				outStream: &outStream,
+				errStream: &errStream,
+			}
+			code := cli.run(tc.args)
+			if code != exitCodeOK {
+				t.Errorf("exit code: got: %v, expected: %v", code, exitCodeOK)
+			}
+			if diff := cmp.Diff(tc.expected, outStream.String()); diff != "" {
+				t.Error("standard output:\n" + diff)
+			}
+			if diff := cmp.Diff(tc.err, errStream.String()); diff != "" {
+				t.Error("standard error output:\n" + diff)
+			}
+		})
+	}
+}
@@ -1,23 +1,14 @@
 package cli
 
 import (
-	"bytes"
 	"fmt"
 	"strings"
 )
 
-var noColor bool
-
 func newColor(c string) []byte {
 	return []byte("\x1b[" + c + "m")
 }
 
-func setColor(buf *bytes.Buffer, color []byte) {
-	if !noColor {
-		buf.Write(color)
-	}
-}
-
 var (
 	resetColor     = newColor("0")    // Reset
 	nullColor      = newColor("90")   // Bright black
@@ -2,32 +2,46 @@ package cli
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math"
 	"math/big"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 	"unicode/utf8"
+
+	"github.com/itchyny/gojq"
 )
 
 type encoder struct {
-	out    io.Writer
-	w      *bytes.Buffer
-	tab    bool
-	indent int
-	depth  int
-	buf    [64]byte
+	out       io.Writer
+	w         *bytes.Buffer
+	tab       bool
+	indent    int
+	color     bool
+	depth     int
+	nonFinite string
+	err       error
+	buf       [64]byte
 }
 
-func newEncoder(tab bool, indent int) *encoder {
+func newEncoder(tab bool, indent int, color bool) *encoder {
 	// reuse the buffer in multiple calls of marshal
-	return &encoder{w: new(bytes.Buffer), tab: tab, indent: indent}
+	return &encoder{w: new(bytes.Buffer), tab: tab, indent: indent, color: color}
 }
 
 func (e *encoder) marshal(v interface{}, w io.Writer) error {
 	e.out = w
 	e.encode(v)
+	if err := e.err; err != nil {
+		e.w.Reset()
+		e.err = nil
+		return err
+	}
 	_, err := w.Write(e.w.Bytes())
 	e.w.Reset()
 	return err
@@ -49,12 +63,24 @@ func (e *encoder) encode(v interface{}) {
 		e.encodeFloat64(v)
 	case *big.Int:
 		e.write(v.Append(e.buf[:0], 10), numberColor)
+	case json.Number:
+		e.write([]byte(v.String()), numberColor)
+	case time.Time:
+		e.encodeString(v.Format(time.RFC3339Nano), stringColor)
+	case []byte:
+		e.encodeString(base64.StdEncoding.EncodeToString(v), stringColor)
 	case string:
 		e.encodeString(v, stringColor)
 	case []interface{}:
 		e.encodeArray(v)
 	case map[string]interface{}:
 		e.encodeMap(v)
+	case *gojq.OrderedMap:
+		e.encodeOrderedMap(v)
+	case *lazyValue:
+		e.encodeLazyValue(v)
+	case gojq.JQValue:
+		e.encode(v.JQValueToJSON())
 	default:
 		panic(fmt.Sprintf("invalid value: %v", v))
 	}
@@ -66,6 +92,22 @@ func (e *encoder) encode(v interface{}) {
 
 // ref: floatEncoder in encoding/json
 func (e *encoder) encodeFloat64(f float64) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		switch e.nonFinite {
+		case "null":
+			e.write([]byte("null"), nullColor)
+			return
+		case "string":
+			e.encodeString(formatNonFinite(f), stringColor)
+			return
+		case "error":
+			if e.err == nil {
+				e.err = &nonFiniteError{f}
+			}
+			e.write([]byte("null"), nullColor)
+			return
+		}
+	}
 	if math.IsNaN(f) {
 		e.write([]byte("null"), nullColor)
 		return
@@ -93,7 +135,7 @@ func (e *encoder) encodeFloat64(f float64) {
 // ref: encodeState#string in encoding/json
 func (e *encoder) encodeString(s string, color []byte) {
 	if color != nil {
-		setColor(e.w, color)
+		e.setColor(color)
 	}
 	e.w.WriteByte('"')
 	start := 0
@@ -143,7 +185,7 @@ func (e *encoder) encodeString(s string, color []byte) {
 	}
 	e.w.WriteByte('"')
 	if color != nil {
-		setColor(e.w, resetColor)
+		e.setColor(resetColor)
 	}
 }
 
@@ -166,13 +208,31 @@ func (e *encoder) encodeArray(vs []interface{}) {
 	e.writeByte(']', arrayColor)
 }
 
-func (e *encoder) encodeMap(vs map[string]interface{}) {
-	e.writeByte('{', objectColor)
-	e.depth += e.indent
-	type keyVal struct {
-		key string
-		val interface{}
+// encodeLazyValue emits the raw bytes of the value with the indentation, so
+// that the value is not decoded. The keys are not sorted, and the numbers and
+// strings are kept as they are in the input.
+func (e *encoder) encodeLazyValue(v *lazyValue) {
+	if e.color {
+		e.encode(v.JQValueToJSON())
+		return
+	}
+	if e.indent == 0 {
+		json.Compact(e.w, v.raw)
+		return
 	}
+	prefix, indent := strings.Repeat(" ", e.depth), strings.Repeat(" ", e.indent)
+	if e.tab {
+		prefix, indent = strings.Repeat("\t", e.depth), "\t"
+	}
+	json.Indent(e.w, v.raw, prefix, indent)
+}
+
+type keyVal struct {
+	key string
+	val interface{}
+}
+
+func (e *encoder) encodeMap(vs map[string]interface{}) {
 	kvs := make([]keyVal, len(vs))
 	var i int
 	for k, v := range vs {
@@ -182,6 +242,21 @@ func (e *encoder) encodeMap(vs map[string]interface{}) {
 	sort.Slice(kvs, func(i, j int) bool {
 		return kvs[i].key < kvs[j].key
 	})
+	e.encodeKeyVals(kvs)
+}
+
+func (e *encoder) encodeOrderedMap(vs *gojq.OrderedMap) {
+	kvs := make([]keyVal, vs.Len())
+	for i, k := range vs.Keys() {
+		v, _ := vs.Get(k)
+		kvs[i] = keyVal{k, v}
+	}
+	e.encodeKeyVals(kvs)
+}
+
+func (e *encoder) encodeKeyVals(kvs []keyVal) {
+	e.writeByte('{', objectColor)
+	e.depth += e.indent
 	for i, kv := range kvs {
 		if i > 0 {
 			e.writeByte(',', objectColor)
@@ -197,7 +272,7 @@ func (e *encoder) encodeMap(vs map[string]interface{}) {
 		e.encode(kv.val)
 	}
 	e.depth -= e.indent
-	if len(vs) > 0 && e.indent != 0 {
+	if len(kvs) > 0 && e.indent != 0 {
 		e.writeIndent()
 	}
 	e.writeByte('}', objectColor)
@@ -224,13 +299,19 @@ func (e *encoder) writeIndent() {
 	}
 }
 
+func (e *encoder) setColor(color []byte) {
+	if e.color {
+		e.w.Write(color)
+	}
+}
+
 func (e *encoder) writeByte(b byte, color []byte) {
 	if color == nil {
 		e.w.WriteByte(b)
 	} else {
-		setColor(e.w, color)
+		e.setColor(color)
 		e.w.WriteByte(b)
-		setColor(e.w, resetColor)
+		e.setColor(resetColor)
 	}
 }
 
@@ -238,8 +319,8 @@ func (e *encoder) write(bs []byte, color []byte) {
 	if color == nil {
 		e.w.Write(bs)
 	} else {
-		setColor(e.w, color)
+		e.setColor(color)
 		e.w.Write(bs)
-		setColor(e.w, resetColor)
+		e.setColor(resetColor)
 	}
 }
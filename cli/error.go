@@ -3,11 +3,14 @@ package cli
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"unicode/utf8"
 
 	"github.com/mattn/go-runewidth"
+
+	"github.com/itchyny/gojq"
 )
 
 type emptyError struct {
@@ -45,6 +48,16 @@ func (err *exitCodeError) ExitCode() int {
 	return err.code
 }
 
+type interruptError struct{}
+
+func (*interruptError) Error() string {
+	return "interrupted"
+}
+
+func (*interruptError) ExitCode() int {
+	return exitCodeInterruptErr
+}
+
 type flagParseError struct {
 	err error
 }
@@ -57,6 +70,85 @@ func (err *flagParseError) ExitCode() int {
 	return exitCodeFlagParseErr
 }
 
+type nonFiniteError struct {
+	v float64
+}
+
+func (err *nonFiniteError) Error() string {
+	return "cannot emit non-finite number: " + formatNonFinite(err.v)
+}
+
+func formatNonFinite(v float64) string {
+	if math.IsNaN(v) {
+		return "NaN"
+	} else if v > 0 {
+		return "Infinity"
+	}
+	return "-Infinity"
+}
+
+type capabilityError struct {
+	name, option string
+}
+
+func (err *capabilityError) Error() string {
+	return err.name + " is not allowed; specify " + err.option + " option to enable it"
+}
+
+type terminalError struct {
+	name string
+}
+
+func (err *terminalError) Error() string {
+	return err.name + " requires a terminal"
+}
+
+type funcTypeError struct {
+	name string
+	v    interface{}
+}
+
+func (err *funcTypeError) Error() string {
+	s := err.name + " cannot be applied to: " + gojq.TypeOf(err.v)
+	if err.v != nil {
+		s += " (" + gojq.Preview(err.v) + ")"
+	}
+	return s
+}
+
+type protobufError struct {
+	name string
+	err  error
+}
+
+func (err *protobufError) Error() string {
+	return err.name + " failed: " + err.err.Error()
+}
+
+type decompressError struct {
+	name string
+	err  error
+}
+
+func (err *decompressError) Error() string {
+	return err.name + " failed to decompress: " + err.err.Error()
+}
+
+type schemaValidationError struct {
+	violations []interface{}
+}
+
+func (err *schemaValidationError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("input does not match the schema")
+	for _, v := range err.violations {
+		if v, ok := v.(map[string]interface{}); ok {
+			fmt.Fprintf(&sb, "\n    #%s: %s", v["instanceLocation"], v["error"])
+		}
+	}
+	return sb.String()
+}
+
 type compileError struct {
 	err error
 }
@@ -112,9 +204,9 @@ func (err *jsonParseError) Error() string {
 			linestr = linestr[i:]
 		}
 		col = runewidth.StringWidth(linestr)
-	} else if er, ok := err.err.(*json.SyntaxError); ok {
+	} else if offset, ok := jsonErrorOffset(err.err); ok {
 		linestr, line, col = getLineByOffset(
-			trimLastInvalidRune(err.contents), int(er.Offset),
+			trimLastInvalidRune(err.contents), int(offset),
 		)
 		if i := strings.IndexAny(err.contents, "\n\r"); i >= 0 && i < len(err.contents)-1 {
 			line += err.line
@@ -127,6 +219,18 @@ func (err *jsonParseError) Error() string {
 		"    " + strings.Repeat(" ", len(prefix)+col) + "^  " + errmsg
 }
 
+// jsonErrorOffset returns the offset of the JSON syntax error.
+func jsonErrorOffset(err error) (int64, bool) {
+	switch err := err.(type) {
+	case *json.SyntaxError:
+		return err.Offset, true
+	case *jsonInputError:
+		return err.offset, true
+	default:
+		return 0, false
+	}
+}
+
 type yamlParseError struct {
 	fname, contents string
 	err             error
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+
+	"github.com/itchyny/gojq"
+)
+
+// capabilities are the permissions of the functions accessing the resources
+// outside of the query, which are disabled by default.
+type capabilities struct {
+	read, write, net, prompt bool
+}
+
+func (cli *cli) funcReadFile(_ interface{}, args []interface{}) interface{} {
+	if !cli.capabilities.read {
+		return &capabilityError{"readfile", "--allow-read"}
+	}
+	name, ok := args[0].(string)
+	if !ok {
+		return &funcTypeError{"readfile", args[0]}
+	}
+	bs, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	return string(bs)
+}
+
+func (cli *cli) funcReadFileJSON(_ interface{}, args []interface{}) gojq.Iter {
+	if !cli.capabilities.read {
+		return gojq.NewIter(&capabilityError{"readfile_json", "--allow-read"})
+	}
+	name, ok := args[0].(string)
+	if !ok {
+		return gojq.NewIter(&funcTypeError{"readfile_json", args[0]})
+	}
+	bs, err := ioutil.ReadFile(name)
+	if err != nil {
+		return gojq.NewIter(err)
+	}
+	return newJSONInputIter(bytes.NewReader(bs), name)
+}
+
+// funcWriteFile writes the input to the file, and emits the input. The strings
+// and the byte strings are written as they are, and the other values in JSON.
+func (cli *cli) funcWriteFile(v interface{}, args []interface{}) interface{} {
+	if !cli.capabilities.write {
+		return &capabilityError{"writefile", "--allow-write"}
+	}
+	name, ok := args[0].(string)
+	if !ok {
+		return &funcTypeError{"writefile", args[0]}
+	}
+	var bs []byte
+	switch w := v.(type) {
+	case string:
+		bs = []byte(w)
+	case []byte:
+		bs = w
+	default:
+		var err error
+		if bs, err = gojq.Marshal(v); err != nil {
+			return err
+		}
+		bs = append(bs, '\n')
+	}
+	if err := ioutil.WriteFile(name, bs, 0o666); err != nil {
+		return err
+	}
+	return v
+}
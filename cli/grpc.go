@@ -0,0 +1,322 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// The gRPC service is defined as follows. Each input produces the results in
+// the same encoding as the input. The health checking protocol is also served.
+//
+//	service Gojq {
+//	  rpc Transform(stream TransformRequest) returns (stream TransformResponse);
+//	}
+//	message TransformRequest {
+//	  oneof input { bytes json = 1; google.protobuf.Value value = 2; }
+//	}
+//	message TransformResponse {
+//	  oneof output { bytes json = 1; google.protobuf.Value value = 2; }
+//	}
+const (
+	grpcServiceName     = "gojq.Gojq"
+	grpcTransformMethod = "/gojq.Gojq/Transform"
+	grpcHealthMethod    = "/grpc.health.v1.Health/Check"
+)
+
+// gRPC status codes.
+const (
+	grpcStatusOK                = 0
+	grpcStatusInvalidArgument   = 3
+	grpcStatusDeadlineExceeded  = 4
+	grpcStatusNotFound          = 5
+	grpcStatusResourceExhausted = 8
+	grpcStatusUnimplemented     = 12
+	grpcStatusInternal          = 13
+)
+
+type grpcError struct {
+	code int
+	msg  string
+}
+
+func (err *grpcError) Error() string {
+	return err.msg
+}
+
+// isGRPCRequest reports whether the request is gRPC, including the connection
+// preface of HTTP/2 over cleartext sent by the plaintext gRPC clients.
+func isGRPCRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") ||
+		r.Method == "PRI" && r.ProtoMajor == 2
+}
+
+func (h *serveHandler) serveGRPC(w http.ResponseWriter, r *http.Request) {
+	// The connection preface of h2c is received as a request when the server
+	// does not support h2c.
+	if r.ProtoMajor != 2 || r.Method == "PRI" {
+		writeServeError(w, http.StatusHTTPVersionNotSupported,
+			errors.New(grpcHTTP2RequiredMessage))
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	var err error
+	switch r.URL.Path {
+	case grpcTransformMethod:
+		err = h.serveGRPCTransform(w, r)
+	case grpcHealthMethod:
+		err = h.serveGRPCHealth(w, r)
+	default:
+		err = &grpcError{grpcStatusUnimplemented, "unknown method: " + r.URL.Path}
+	}
+	code, msg := grpcStatusOK, ""
+	if err != nil {
+		code, msg = grpcStatusInternal, err.Error()
+		if err, ok := err.(*grpcError); ok {
+			code = err.code
+		}
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	w.Header().Set("Grpc-Message", encodeGRPCMessage(msg))
+}
+
+// encodeGRPCMessage percent-encodes the status message.
+func encodeGRPCMessage(msg string) string {
+	var sb strings.Builder
+	for i := 0; i < len(msg); i++ {
+		if c := msg[i]; ' ' <= c && c <= '~' && c != '%' {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+func (h *serveHandler) serveGRPCTransform(w http.ResponseWriter, r *http.Request) error {
+	flusher, _ := w.(http.Flusher)
+	for {
+		msg, err := readGRPCMessage(r.Body, h.maxMessageSize)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		v, isJSON, err := decodeTransformRequest(msg)
+		if err != nil {
+			return &grpcError{grpcStatusInvalidArgument, err.Error()}
+		}
+		if err := h.runGRPCTransform(r.Context(), w, v, isJSON); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+func (h *serveHandler) runGRPCTransform(ctx context.Context, w io.Writer, v interface{}, isJSON bool) error {
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+	iter := h.code.RunWithContext(ctx, v)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			return nil
+		}
+		if err, ok := v.(error); ok {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return &grpcError{grpcStatusDeadlineExceeded, err.Error()}
+			}
+			return &grpcError{grpcStatusInvalidArgument, err.Error()}
+		}
+		msg, err := encodeTransformResponse(v, isJSON)
+		if err != nil {
+			return err
+		}
+		if err := writeGRPCMessage(w, msg); err != nil {
+			return err
+		}
+	}
+}
+
+func decodeTransformRequest(msg []byte) (interface{}, bool, error) {
+	var v interface{}
+	var isJSON, found bool
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return nil, false, protowire.ParseError(n)
+		}
+		msg = msg[n:]
+		if typ != protowire.BytesType || num != 1 && num != 2 {
+			if n = protowire.ConsumeFieldValue(num, typ, msg); n < 0 {
+				return nil, false, protowire.ParseError(n)
+			}
+			msg = msg[n:]
+			continue
+		}
+		bs, n := protowire.ConsumeBytes(msg)
+		if n < 0 {
+			return nil, false, protowire.ParseError(n)
+		}
+		msg = msg[n:]
+		found = true
+		if isJSON = num == 1; isJSON {
+			dec := json.NewDecoder(bytes.NewReader(bs))
+			dec.UseNumber()
+			if err := dec.Decode(&v); err != nil {
+				return nil, false, err
+			}
+		} else {
+			var x structpb.Value
+			if err := proto.Unmarshal(bs, &x); err != nil {
+				return nil, false, err
+			}
+			v = x.AsInterface()
+		}
+	}
+	if !found {
+		return nil, false, errors.New("TransformRequest has neither json nor value")
+	}
+	return v, isJSON, nil
+}
+
+func encodeTransformResponse(v interface{}, isJSON bool) ([]byte, error) {
+	if isJSON {
+		var buf bytes.Buffer
+		if err := newEncoder(false, 0, false).marshal(v, &buf); err != nil {
+			return nil, err
+		}
+		return protowire.AppendBytes(protowire.AppendTag(nil, 1, protowire.BytesType), buf.Bytes()), nil
+	}
+	x, err := structpb.NewValue(toProtoValue(v))
+	if err != nil {
+		return nil, err
+	}
+	bs, err := proto.Marshal(x)
+	if err != nil {
+		return nil, err
+	}
+	return protowire.AppendBytes(protowire.AppendTag(nil, 2, protowire.BytesType), bs), nil
+}
+
+// toProtoValue converts the numbers which structpb.NewValue does not accept.
+func toProtoValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return f
+	case float64:
+		if math.IsNaN(v) {
+			return nil
+		}
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []interface{}:
+		xs := make([]interface{}, len(v))
+		for i, x := range v {
+			xs[i] = toProtoValue(x)
+		}
+		return xs
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, x := range v {
+			m[k] = toProtoValue(x)
+		}
+		return m
+	default:
+		return v
+	}
+}
+
+func (h *serveHandler) serveGRPCHealth(w http.ResponseWriter, r *http.Request) error {
+	msg, err := readGRPCMessage(r.Body, h.maxMessageSize)
+	if err != nil {
+		if err == io.EOF {
+			err = &grpcError{grpcStatusInvalidArgument, "missing health check request"}
+		}
+		return err
+	}
+	var service string
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return &grpcError{grpcStatusInvalidArgument, protowire.ParseError(n).Error()}
+		}
+		msg = msg[n:]
+		if num == 1 && typ == protowire.BytesType {
+			s, n := protowire.ConsumeString(msg)
+			if n < 0 {
+				return &grpcError{grpcStatusInvalidArgument, protowire.ParseError(n).Error()}
+			}
+			service, msg = s, msg[n:]
+			continue
+		}
+		if n = protowire.ConsumeFieldValue(num, typ, msg); n < 0 {
+			return &grpcError{grpcStatusInvalidArgument, protowire.ParseError(n).Error()}
+		}
+		msg = msg[n:]
+	}
+	if service != "" && service != grpcServiceName {
+		return &grpcError{grpcStatusNotFound, "unknown service: " + service}
+	}
+	// HealthCheckResponse{status: SERVING}
+	return writeGRPCMessage(w, protowire.AppendVarint(protowire.AppendTag(nil, 1, protowire.VarintType), 1))
+}
+
+// readGRPCMessage reads a length-prefixed message. The message longer than max
+// bytes is rejected before reading the payload, unless max is zero.
+func readGRPCMessage(r io.Reader, max int) ([]byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = &grpcError{grpcStatusInternal, "unexpected end of message"}
+		}
+		return nil, err
+	}
+	if header[0] != 0 {
+		return nil, &grpcError{grpcStatusUnimplemented, "compressed message is not supported"}
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if max > 0 && uint64(size) > uint64(max) {
+		return nil, &grpcError{grpcStatusResourceExhausted,
+			fmt.Sprintf("message size %d exceeds the limit %d", size, max)}
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, &grpcError{grpcStatusInternal, fmt.Sprintf("unexpected end of message: %s", err)}
+	}
+	return msg, nil
+}
+
+// writeGRPCMessage writes a length-prefixed message.
+func writeGRPCMessage(w io.Writer, msg []byte) error {
+	var header [5]byte
+	binary.BigEndian.PutUint32(header[1:], uint32(len(msg)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(msg)
+	return err
+}
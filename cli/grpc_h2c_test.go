@@ -0,0 +1,69 @@
+//go:build go1.24
+// +build go1.24
+
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/itchyny/gojq"
+)
+
+func TestServeGRPC_H2C(t *testing.T) {
+	query, err := gojq.Parse(`.foo`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(&serveHandler{code, time.Second, 0, 1024})
+	enableUnencryptedHTTP2(server.Config)
+	server.Start()
+	defer server.Close()
+
+	var buf bytes.Buffer
+	if err := writeGRPCMessage(&buf, protowire.AppendBytes(
+		protowire.AppendTag(nil, 1, protowire.BytesType), []byte(`{"foo":1}`))); err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, server.URL+grpcTransformMethod, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Te", "trailers")
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.ProtoMajor != 2 {
+		t.Fatalf("expected HTTP/2 but got: %s", res.Proto)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := readGRPCMessage(bytes.NewReader(body), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bs, n := protowire.ConsumeBytes(msg[1:]); n < 0 || string(bs) != "1" {
+		t.Errorf("expected the response of 1 but got: %q", msg)
+	}
+	if status := res.Trailer.Get("Grpc-Status"); status != "0" {
+		t.Errorf("grpc-status: got: %s, expected: 0", status)
+	}
+}
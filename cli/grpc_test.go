@@ -0,0 +1,196 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/itchyny/gojq"
+)
+
+func TestServeGRPC(t *testing.T) {
+	query, err := gojq.Parse(`.foo, .bar`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(&serveHandler{code, time.Second, 0, 1024})
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	call := func(t *testing.T, method string, msgs ...[]byte) ([][]byte, string, string) {
+		var buf bytes.Buffer
+		for _, msg := range msgs {
+			if err := writeGRPCMessage(&buf, msg); err != nil {
+				t.Fatal(err)
+			}
+		}
+		req, err := http.NewRequest(http.MethodPost, server.URL+method, &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/grpc")
+		req.Header.Set("Te", "trailers")
+		res, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if res.ProtoMajor != 2 {
+			t.Fatalf("expected HTTP/2 but got: %s", res.Proto)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rets [][]byte
+		r := bytes.NewReader(body)
+		for r.Len() > 0 {
+			msg, err := readGRPCMessage(r, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rets = append(rets, msg)
+		}
+		return rets, res.Trailer.Get("Grpc-Status"), res.Trailer.Get("Grpc-Message")
+	}
+
+	t.Run("json", func(t *testing.T) {
+		rets, status, _ := call(t, grpcTransformMethod,
+			protowire.AppendBytes(protowire.AppendTag(nil, 1, protowire.BytesType), []byte(`{"foo":1,"bar":[2]}`)),
+			protowire.AppendBytes(protowire.AppendTag(nil, 1, protowire.BytesType), []byte(`{"foo":"x"}`)),
+		)
+		if status != "0" {
+			t.Errorf("grpc-status: got: %s, expected: 0", status)
+		}
+		var got []string
+		for _, msg := range rets {
+			bs, n := protowire.ConsumeBytes(msg[1:])
+			if n < 0 {
+				t.Fatal(protowire.ParseError(n))
+			}
+			got = append(got, string(bs))
+		}
+		if diff := cmp.Diff([]string{"1", "[2]", `"x"`, "null"}, got); diff != "" {
+			t.Errorf("responses differ (-expected +got):\n%s", diff)
+		}
+	})
+
+	t.Run("value", func(t *testing.T) {
+		x, err := structpb.NewValue(map[string]interface{}{"foo": map[string]interface{}{"x": 1.5}, "bar": true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs, err := proto.Marshal(x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rets, status, _ := call(t, grpcTransformMethod,
+			protowire.AppendBytes(protowire.AppendTag(nil, 2, protowire.BytesType), bs))
+		if status != "0" {
+			t.Errorf("grpc-status: got: %s, expected: 0", status)
+		}
+		var got []interface{}
+		for _, msg := range rets {
+			bs, n := protowire.ConsumeBytes(msg[1:])
+			if n < 0 {
+				t.Fatal(protowire.ParseError(n))
+			}
+			var x structpb.Value
+			if err := proto.Unmarshal(bs, &x); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, x.AsInterface())
+		}
+		if diff := cmp.Diff([]interface{}{map[string]interface{}{"x": 1.5}, true}, got); diff != "" {
+			t.Errorf("responses differ (-expected +got):\n%s", diff)
+		}
+	})
+
+	t.Run("runtime error", func(t *testing.T) {
+		_, status, message := call(t, grpcTransformMethod,
+			protowire.AppendBytes(protowire.AppendTag(nil, 1, protowire.BytesType), []byte(`[]`)))
+		if status != "3" {
+			t.Errorf("grpc-status: got: %s, expected: 3", status)
+		}
+		if expected := "expected an object but got: array ([])"; message != expected {
+			t.Errorf("grpc-message: got: %q, expected: %q", message, expected)
+		}
+	})
+
+	t.Run("empty request", func(t *testing.T) {
+		_, status, message := call(t, grpcTransformMethod, []byte{})
+		if status != "3" {
+			t.Errorf("grpc-status: got: %s, expected: 3", status)
+		}
+		if expected := "TransformRequest has neither json nor value"; message != expected {
+			t.Errorf("grpc-message: got: %q, expected: %q", message, expected)
+		}
+	})
+
+	t.Run("health", func(t *testing.T) {
+		rets, status, _ := call(t, grpcHealthMethod, nil)
+		if status != "0" {
+			t.Errorf("grpc-status: got: %s, expected: 0", status)
+		}
+		if expected := [][]byte{{0x08, 0x01}}; !cmp.Equal(expected, rets) {
+			t.Errorf("health check response: got: %v, expected: %v", rets, expected)
+		}
+		_, status, _ = call(t, grpcHealthMethod,
+			protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), "unknown"))
+		if status != "5" {
+			t.Errorf("grpc-status: got: %s, expected: 5", status)
+		}
+	})
+
+	t.Run("unknown method", func(t *testing.T) {
+		_, status, _ := call(t, "/gojq.Gojq/Unknown")
+		if status != "12" {
+			t.Errorf("grpc-status: got: %s, expected: 12", status)
+		}
+	})
+
+	t.Run("message size limit", func(t *testing.T) {
+		_, status, message := call(t, grpcTransformMethod,
+			protowire.AppendBytes(protowire.AppendTag(nil, 1, protowire.BytesType), bytes.Repeat([]byte(" "), 1024)))
+		if status != "8" {
+			t.Errorf("grpc-status: got: %s, expected: 8", status)
+		}
+		if expected := "message size 1027 exceeds the limit 1024"; message != expected {
+			t.Errorf("grpc-message: got: %q, expected: %q", message, expected)
+		}
+	})
+
+	t.Run("plaintext", func(t *testing.T) {
+		server := httptest.NewServer(&serveHandler{code, time.Second, 0, 1024})
+		defer server.Close()
+		res, err := http.Post(server.URL+grpcTransformMethod, "application/grpc", bytes.NewReader([]byte{0, 0, 0, 0, 0}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusHTTPVersionNotSupported {
+			t.Errorf("status code: got: %d, expected: %d", res.StatusCode, http.StatusHTTPVersionNotSupported)
+		}
+	})
+}
+
+func TestReadGRPCMessageSizeLimit(t *testing.T) {
+	// the header claims 4 GiB - 1 bytes without the payload
+	_, err := readGRPCMessage(bytes.NewReader([]byte{0, 0xff, 0xff, 0xff, 0xff}), 4<<20)
+	if err, ok := err.(*grpcError); !ok || err.code != grpcStatusResourceExhausted {
+		t.Errorf("expected resource exhausted error but got: %v", err)
+	}
+}
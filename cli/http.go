@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/itchyny/gojq"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// maxHTTPResponseSize is the maximum size of the response body.
+var maxHTTPResponseSize = 10 << 20
+
+func (cli *cli) funcHTTPGet(_ interface{}, args []interface{}) interface{} {
+	if !cli.capabilities.net {
+		return &capabilityError{"http_get", "--allow-net"}
+	}
+	return httpRequest(cli.ctx, "http_get", http.MethodGet, args[0], args[1], nil)
+}
+
+func (cli *cli) funcHTTPPost(v interface{}, args []interface{}) interface{} {
+	if !cli.capabilities.net {
+		return &capabilityError{"http_post", "--allow-net"}
+	}
+	var body []byte
+	switch v := v.(type) {
+	case string:
+		body = []byte(v)
+	case []byte:
+		body = v
+	default:
+		var err error
+		if body, err = gojq.Marshal(v); err != nil {
+			return err
+		}
+	}
+	return httpRequest(cli.ctx, "http_post", http.MethodPost, args[0], args[1], body)
+}
+
+// httpRequest sends the request, and returns the status, the headers and the
+// body of the response. The body is decoded when the content type is JSON.
+func httpRequest(
+	ctx context.Context, name, method string, x, y interface{}, body []byte,
+) interface{} {
+	url, ok := x.(string)
+	if !ok {
+		return &funcTypeError{name, x}
+	}
+	if h, ok := y.(gojq.JQValue); ok && h.JQValueType() == "object" {
+		y = h.JQValueToJSON()
+	}
+	headers, ok := y.(map[string]interface{})
+	if !ok {
+		return &funcTypeError{name, y}
+	}
+	var rd io.Reader
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, rd)
+	if err != nil {
+		return err
+	}
+	for k, v := range headers {
+		s, ok := v.(string)
+		if !ok {
+			return &funcTypeError{name, headers}
+		}
+		req.Header.Set(k, s)
+	}
+	if body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	bs, err := ioutil.ReadAll(io.LimitReader(res.Body, int64(maxHTTPResponseSize)+1))
+	if err != nil {
+		return err
+	}
+	if len(bs) > maxHTTPResponseSize {
+		return fmt.Errorf("%s: response of %q exceeds the size limit of %d bytes", name, url, maxHTTPResponseSize)
+	}
+	hs := make(map[string]interface{}, len(res.Header))
+	for k, v := range res.Header {
+		hs[strings.ToLower(k)] = strings.Join(v, ", ")
+	}
+	var resBody interface{} = string(bs)
+	if typ, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type")); typ == "application/json" ||
+		strings.HasSuffix(typ, "+json") {
+		dec := json.NewDecoder(bytes.NewReader(bs))
+		dec.UseNumber()
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return &jsonParseError{url, string(bs), 0, err}
+		}
+		resBody = v
+	}
+	return map[string]interface{}{
+		"status":  res.StatusCode,
+		"headers": hs,
+		"body":    resBody,
+	}
+}
@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/itchyny/gojq"
+)
+
+// httpModuleLoader loads the modules imported by https URLs. The modules are
+// cached on disk, and verified with the checksum in the "sha256" field of the
+// import metadata.
+type httpModuleLoader struct {
+	client   *http.Client
+	cacheDir string
+	offline  bool
+}
+
+// maxModuleSize is the maximum size of the module fetched from the server.
+var maxModuleSize = 10 << 20
+
+func newHTTPModuleLoader(offline bool) *httpModuleLoader {
+	cacheDir, err := os.UserCacheDir()
+	if err == nil {
+		cacheDir = filepath.Join(cacheDir, name, "modules")
+	}
+	return &httpModuleLoader{
+		client:   &http.Client{Timeout: 30 * time.Second},
+		cacheDir: cacheDir,
+		offline:  offline,
+	}
+}
+
+func (l *httpModuleLoader) LoadModuleWithMeta(url string, meta map[string]interface{}) (*gojq.Query, error) {
+	cnt, err := l.load(url, meta)
+	if err != nil {
+		return nil, err
+	}
+	q, err := gojq.Parse(string(cnt))
+	if err != nil {
+		return nil, &queryParseError{"query in module", url, string(cnt), err}
+	}
+	return q, nil
+}
+
+func (l *httpModuleLoader) LoadJSONWithMeta(url string, meta map[string]interface{}) (interface{}, error) {
+	cnt, err := l.load(url, meta)
+	if err != nil {
+		return nil, err
+	}
+	var vals []interface{}
+	dec := json.NewDecoder(bytes.NewReader(cnt))
+	dec.UseNumber()
+	for {
+		var val interface{}
+		if err := dec.Decode(&val); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, &jsonParseError{url, string(cnt), 0, err}
+		}
+		vals = append(vals, val)
+	}
+	return vals, nil
+}
+
+func (l *httpModuleLoader) load(url string, meta map[string]interface{}) ([]byte, error) {
+	if !strings.HasPrefix(url, "https://") {
+		return nil, os.ErrNotExist
+	}
+	var checksum string
+	if x, ok := meta["sha256"]; ok {
+		if checksum, _ = x.(string); checksum == "" {
+			return nil, fmt.Errorf("invalid sha256 checksum of module %q: %v", url, x)
+		}
+	}
+	var path string
+	if l.cacheDir != "" {
+		sum := sha256.Sum256([]byte(url))
+		path = filepath.Join(l.cacheDir, hex.EncodeToString(sum[:]))
+		if cnt, err := ioutil.ReadFile(path); err == nil {
+			if err = verifyChecksum(url, cnt, checksum); err == nil || l.offline {
+				return cnt, err
+			}
+		}
+	}
+	if l.offline {
+		return nil, fmt.Errorf("module not cached in offline mode: %q", url)
+	}
+	cnt, err := l.fetch(url)
+	if err != nil {
+		return nil, err
+	}
+	if err = verifyChecksum(url, cnt, checksum); err != nil {
+		return nil, err
+	}
+	if path != "" {
+		if err = writeFileAtomic(path, cnt); err != nil {
+			return nil, err
+		}
+	}
+	return cnt, nil
+}
+
+func (l *httpModuleLoader) fetch(url string) ([]byte, error) {
+	res, err := l.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot load module %q: %s", url, res.Status)
+	}
+	cnt, err := ioutil.ReadAll(io.LimitReader(res.Body, int64(maxModuleSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(cnt) > maxModuleSize {
+		return nil, fmt.Errorf("cannot load module %q: exceeds the size limit of %d bytes", url, maxModuleSize)
+	}
+	return cnt, nil
+}
+
+func verifyChecksum(url string, cnt []byte, checksum string) error {
+	if checksum == "" {
+		return nil
+	}
+	sum := sha256.Sum256(cnt)
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, checksum) {
+		return fmt.Errorf("checksum mismatch of module %q: expected sha256 %s but got %s", url, checksum, got)
+	}
+	return nil
+}
+
+func writeFileAtomic(path string, cnt []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.Write(cnt); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/itchyny/gojq"
+)
+
+func TestHTTPModuleLoader(t *testing.T) {
+	const module = `def f: .foo + 1;`
+	var requests int
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/lib/foo.jq":
+			w.Write([]byte(module))
+		case "/data.json":
+			w.Write([]byte(`{"bar": 42}`))
+		case "/large.jq":
+			w.Write([]byte(strings.Repeat(" ", 101)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	sum := sha256.Sum256([]byte(module))
+	checksum := hex.EncodeToString(sum[:])
+	cacheDir := tempDir(t)
+
+	run := func(offline bool, src string) (interface{}, error) {
+		query, err := gojq.Parse(strings.ReplaceAll(src, "$URL", server.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+		loader := &httpModuleLoader{server.Client(), cacheDir, offline}
+		code, err := gojq.Compile(query, gojq.WithModuleLoader(moduleLoader{loader}))
+		if err != nil {
+			return nil, err
+		}
+		v, _ := code.Run(map[string]interface{}{"foo": 41}).Next()
+		return v, nil
+	}
+
+	if _, err := run(true, `import "$URL/lib/foo.jq" as foo; foo::f`); err == nil ||
+		!strings.Contains(err.Error(), "module not cached in offline mode") {
+		t.Errorf("expected an offline error but got: %v", err)
+	}
+	if v, err := run(false, `import "$URL/lib/foo.jq" as foo {sha256: "`+checksum+`"}; foo::f`); err != nil || v != 42 {
+		t.Errorf("expected 42 but got: %v, %v", v, err)
+	}
+	if v, err := run(false, `import "$URL/data.json" as $data; $data[0].bar`); err != nil || v != 42 {
+		t.Errorf("expected 42 but got: %v, %v", v, err)
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 requests but got: %d", requests)
+	}
+	if v, err := run(true, `import "$URL/lib/foo.jq" as foo; foo::f`); err != nil || v != 42 {
+		t.Errorf("expected the cached module but got: %v, %v", v, err)
+	}
+	if _, err := run(false, `import "$URL/lib/foo.jq" as foo {sha256: "0000"}; foo::f`); err == nil ||
+		!strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("expected a checksum error but got: %v", err)
+	}
+	if _, err := run(false, `import "$URL/lib/bar.jq" as bar; bar::f`); err == nil ||
+		!strings.Contains(err.Error(), "404 Not Found") {
+		t.Errorf("expected a not found error but got: %v", err)
+	}
+	if requests != 4 {
+		t.Errorf("expected 4 requests but got: %d", requests)
+	}
+	if _, err := run(true, `import "$URL/lib/foo.jq" as foo {sha256: 0}; foo::f`); err == nil ||
+		!strings.Contains(err.Error(), "invalid sha256 checksum") {
+		t.Errorf("expected a checksum error but got: %v", err)
+	}
+	defer func(size int) { maxModuleSize = size }(maxModuleSize)
+	maxModuleSize = 100
+	if _, err := run(false, `import "$URL/large.jq" as large; large::f`); err == nil ||
+		!strings.Contains(err.Error(), "exceeds the size limit of 100 bytes") {
+		t.Errorf("expected a size limit error but got: %v", err)
+	}
+}
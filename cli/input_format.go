@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/itchyny/gojq"
+)
+
+// InputFormatOptions are the options passed to the input formats.
+type InputFormatOptions struct {
+	// PreserveOrder is true when --preserve-order option is specified. The
+	// formats should decode objects to *gojq.OrderedMap to keep the key order.
+	PreserveOrder bool
+
+	// Datetime is true when --datetime option is specified. The formats may
+	// decode timestamps to time.Time.
+	Datetime bool
+
+	// Lazy is true when --lazy-input option is specified. The formats may
+	// keep objects and arrays undecoded in gojq.JQValue.
+	Lazy bool
+
+	// SIMD is true when --simd-input option is specified. The JSON format
+	// decodes the values by the simdjson-style decoder.
+	SIMD bool
+
+	// Strict is true when --strict option is specified. The formats should
+	// reject the inputs which are accepted leniently, like duplicate keys.
+	Strict bool
+
+	// InvalidUTF8 is the handling of invalid UTF-8 sequences specified by
+	// --invalid-utf8 option; "replace", "error", "binary", or empty when the
+	// option is not specified.
+	InvalidUTF8 string
+
+	// StreamErrors is true when --stream-errors option is specified. The stream
+	// format emits the parse error as an event of the error message and the path.
+	StreamErrors bool
+
+	// StreamDepth is the depth specified by --stream-depth option. The stream
+	// format emits the values at the depth as they are, instead of emitting the
+	// events of their elements. Zero means unlimited.
+	StreamDepth int
+
+	// YAMLAliases is the handling of YAML aliases specified by --yaml-aliases
+	// option; "expand", "error", "preserve", or empty to expand them.
+	YAMLAliases string
+
+	// YAMLAliasLimit is the maximum number of values expanded from the YAML
+	// aliases in a document, specified by --yaml-alias-limit option. Zero means
+	// unlimited.
+	YAMLAliasLimit int
+
+	// YAMLRoundTrip is true when --yaml-roundtrip option is specified. The YAML
+	// format keeps the document nodes to restore the comments on the output.
+	YAMLRoundTrip bool
+}
+
+// InputFormat creates an iterator of the values read from r. The fname is the
+// name of the input file (or "<stdin>"), which can be used in error messages.
+// The iterator should emit the parse error as a value, and stop iterating
+// after the error. When the iterator implements io.Closer, it is closed after
+// reading the input.
+type InputFormat func(r io.Reader, fname string, opts InputFormatOptions) gojq.Iter
+
+var inputFormats = map[string]InputFormat{
+	"json": func(r io.Reader, fname string, opts InputFormatOptions) gojq.Iter {
+		var iter inputIter
+		if opts.PreserveOrder {
+			iter = newOrderedJSONInputIter(r, fname)
+		} else if opts.Lazy {
+			iter = newLazyJSONInputIter(r, fname)
+		} else if opts.SIMD && !opts.Strict && opts.InvalidUTF8 == "" {
+			iter = newSIMDJSONInputIter(r, fname)
+		} else {
+			iter = newJSONInputIter(r, fname)
+		}
+		iter.(*jsonInputIter).strict = opts.Strict
+		iter.(*jsonInputIter).invalidUTF8 = opts.InvalidUTF8
+		return iter
+	},
+	"raw": func(r io.Reader, fname string, opts InputFormatOptions) gojq.Iter {
+		iter := newRawInputIter(r, fname)
+		iter.(*rawInputIter).invalidUTF8 = opts.InvalidUTF8
+		return iter
+	},
+	"stream": func(r io.Reader, fname string, opts InputFormatOptions) gojq.Iter {
+		iter := newStreamInputIter(r, fname).(*streamInputIter)
+		iter.errors, iter.stream.depth = opts.StreamErrors, opts.StreamDepth
+		return iter
+	},
+	"yaml": func(r io.Reader, fname string, opts InputFormatOptions) gojq.Iter {
+		iter := newYAMLInputIter(r, fname).(*yamlInputIter)
+		iter.ordered, iter.datetime = opts.PreserveOrder, opts.Datetime
+		iter.aliases, iter.limit = opts.YAMLAliases, opts.YAMLAliasLimit
+		iter.keepNode = opts.YAMLRoundTrip
+		return iter
+	},
+	"binary": func(r io.Reader, fname string, _ InputFormatOptions) gojq.Iter {
+		return newBinaryInputIter(r, fname)
+	},
+}
+
+// RegisterInputFormat registers the input format, which can be selected by
+// --input-format option. The builtin formats are json, raw, stream, yaml and
+// binary, and registering the same name replaces the format. Call this
+// function before calling Run.
+func RegisterInputFormat(name string, f InputFormat) {
+	inputFormats[name] = f
+}
+
+func inputFormatNames() string {
+	names := make([]string, 0, len(inputFormats))
+	for name := range inputFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// newInputFormatIter adapts the input format to the iterator of the files.
+func newInputFormatIter(f InputFormat, opts InputFormatOptions) func(io.Reader, string) inputIter {
+	return func(r io.Reader, fname string) inputIter {
+		iter := f(r, fname, opts)
+		if iter, ok := iter.(inputIter); ok {
+			return iter
+		}
+		return &inputFormatIter{iter}
+	}
+}
+
+type inputFormatIter struct {
+	gojq.Iter
+}
+
+func (i *inputFormatIter) lineNumber() int {
+	return inputLineNumber(i.Iter)
+}
+
+func (*inputFormatIter) Close() error {
+	return nil
+}
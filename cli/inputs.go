@@ -4,7 +4,10 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"math/bits"
 	"os"
 	"strings"
 
@@ -66,37 +69,225 @@ type inputIter interface {
 	io.Closer
 }
 
-type jsonInputIter struct {
-	dec    *json.Decoder
-	ir     *inputReader
-	fname  string
+// lineReader counts the newlines read from the reader. The decoders read ahead
+// of the values, so it keeps the offsets of the newlines in the last two chunks
+// to count the newlines before the end of the value.
+type lineReader struct {
+	io.Reader
 	offset int64
-	line   int
-	err    error
+	lines  int
+	prev   []int64
+	curr   []int64
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	return &lineReader{Reader: r}
+}
+
+func (r *lineReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.lines += len(r.prev)
+		r.prev, r.curr = r.curr, r.prev[:0]
+		for i := 0; ; {
+			j := bytes.IndexByte(p[i:n], '\n')
+			if j < 0 {
+				break
+			}
+			r.curr = append(r.curr, r.offset+int64(i+j))
+			i += j + 1
+		}
+		r.offset += int64(n)
+	}
+	return n, err
+}
+
+// lineNumber returns the line number at the offset.
+func (r *lineReader) lineNumber(offset int64) int {
+	lines := r.lines + 1
+	for _, xs := range [][]int64{r.prev, r.curr} {
+		for _, x := range xs {
+			if x >= offset {
+				return lines
+			}
+			lines++
+		}
+	}
+	return lines
+}
+
+// inputLineNumber returns the line number of the input where the last value
+// ends, or 0 if the input iterator does not count the lines.
+func inputLineNumber(iter gojq.Iter) int {
+	if iter, ok := iter.(interface{ lineNumber() int }); ok {
+		return iter.lineNumber()
+	}
+	return 0
+}
+
+// inputYAMLDocument returns the YAML document node of the last value along with
+// its decoder, or nil if the input iterator does not keep the node.
+func inputYAMLDocument(iter gojq.Iter) (*yaml.Node, *yamlNodeDecoder) {
+	if iter, ok := iter.(interface {
+		yamlDocument() (*yaml.Node, *yamlNodeDecoder)
+	}); ok {
+		return iter.yamlDocument()
+	}
+	return nil, nil
+}
+
+type jsonInputIter struct {
+	dec         *json.Decoder
+	simd        *simdJSONDecoder
+	ir          *inputReader
+	lr          *lineReader
+	fname       string
+	offset      int64
+	line        int
+	lineno      int
+	ordered     bool
+	lazy        bool
+	strict      bool
+	invalidUTF8 string
+	err         error
 }
 
 func newJSONInputIter(r io.Reader, fname string) inputIter {
 	ir := newInputReader(r)
-	dec := json.NewDecoder(ir)
+	lr := newLineReader(ir)
+	dec := json.NewDecoder(lr)
+	dec.UseNumber()
+	return &jsonInputIter{dec: dec, ir: ir, lr: lr, fname: fname}
+}
+
+func newOrderedJSONInputIter(r io.Reader, fname string) inputIter {
+	iter := newJSONInputIter(r, fname).(*jsonInputIter)
+	iter.ordered = true
+	return iter
+}
+
+func newLazyJSONInputIter(r io.Reader, fname string) inputIter {
+	iter := newJSONInputIter(r, fname).(*jsonInputIter)
+	iter.lazy = true
+	return iter
+}
+
+// newSIMDJSONInputIter creates the iterator which decodes the values by the
+// simdjson-style decoder. The decoder classifies the input in 64-bit words, so
+// the standard decoder is used on the platforms of 32-bit words.
+func newSIMDJSONInputIter(r io.Reader, fname string) inputIter {
+	iter := newJSONInputIter(r, fname).(*jsonInputIter)
+	if bits.UintSize == 64 {
+		iter.simd = newSIMDJSONDecoder(iter.lr)
+	}
+	return iter
+}
+
+func (i *jsonInputIter) decode() (v interface{}, err error) {
+	if i.simd != nil {
+		return i.simd.Decode()
+	}
+	if i.strict || i.invalidUTF8 != "" {
+		return i.decodeChecked()
+	}
+	if i.ordered {
+		return gojq.DecodeOrdered(i.dec)
+	}
+	if i.lazy {
+		var raw json.RawMessage
+		if err = i.dec.Decode(&raw); err != nil {
+			return
+		}
+		return newLazyValue(raw), nil
+	}
+	err = i.dec.Decode(&v)
+	return
+}
+
+// decodeChecked decodes the value with the checks by --strict option and the
+// handling of invalid UTF-8 sequences by --invalid-utf8 option.
+func (i *jsonInputIter) decodeChecked() (interface{}, error) {
+	var raw json.RawMessage
+	if err := i.dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	end := i.dec.InputOffset()
+	if i.strict {
+		if err := checkStrictJSON(raw); err != nil {
+			err.offset += end - int64(len(raw))
+			return nil, err
+		}
+		if err := i.checkTrailing(raw[0], end); err != nil {
+			return nil, err
+		}
+	}
+	if i.invalidUTF8 != "" {
+		if j := invalidUTF8Index(raw); j >= 0 {
+			switch i.invalidUTF8 {
+			case invalidUTF8Error:
+				offset := end - int64(len(raw)) + int64(j)
+				return nil, &jsonInputError{invalidUTF8Message(raw[j], offset), offset + 1}
+			case invalidUTF8Binary:
+				return decodeBinaryStrings(raw, i.ordered)
+			}
+		}
+	}
+	if i.lazy {
+		return newLazyValue(raw), nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
 	dec.UseNumber()
-	return &jsonInputIter{dec: dec, ir: ir, fname: fname}
+	if i.ordered {
+		return gojq.DecodeOrdered(dec)
+	}
+	var v interface{}
+	err := dec.Decode(&v)
+	return v, err
+}
+
+// checkTrailing rejects the trailing characters which cannot start the next
+// value for --strict option. The characters are checked before emitting the
+// value as far as the decoder has read ahead, and the rest are rejected on
+// decoding the next value.
+func (i *jsonInputIter) checkTrailing(c byte, end int64) error {
+	adjacent := strings.IndexByte(`{["`, c) < 0
+	r := i.dec.Buffered().(io.ByteReader)
+	for next := end + 1; ; next++ {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil
+		}
+		if strings.IndexByte(" \t\r\n", c) >= 0 {
+			adjacent = false
+			continue
+		}
+		if adjacent || strings.IndexByte(`{["-0123456789tfn`, c) < 0 {
+			return &jsonInputError{
+				"invalid character " + quoteChar(c) + " after top-level value", next}
+		}
+		return nil
+	}
 }
 
 func (i *jsonInputIter) Next() (interface{}, bool) {
 	if i.err != nil {
 		return nil, false
 	}
-	var v interface{}
-	if err := i.dec.Decode(&v); err != nil {
+	v, err := i.decode()
+	if err != nil {
 		if err == io.EOF {
 			i.err = err
 			return nil, false
 		}
 		var offset *int64
 		var line *int
-		if err, ok := err.(*json.SyntaxError); ok {
+		switch err := err.(type) {
+		case *json.SyntaxError:
 			err.Offset -= i.offset
 			offset, line = &err.Offset, &i.line
+		case *jsonInputError:
+			err.offset -= i.offset
+			offset, line = &err.offset, &i.line
 		}
 		i.err = &jsonParseError{i.fname, i.ir.getContents(offset, line), i.line, err}
 		return i.err, true
@@ -106,9 +297,18 @@ func (i *jsonInputIter) Next() (interface{}, bool) {
 		i.line += bytes.Count(buf.Bytes(), []byte{'\n'})
 		buf.Reset()
 	}
+	if i.simd != nil {
+		i.lineno = i.simd.lineNumber()
+	} else {
+		i.lineno = i.lr.lineNumber(i.dec.InputOffset())
+	}
 	return v, true
 }
 
+func (i *jsonInputIter) lineNumber() int {
+	return i.lineno
+}
+
 func (i *jsonInputIter) Close() error {
 	i.err = io.EOF
 	return nil
@@ -177,30 +377,71 @@ func (i *filesInputIter) Next() (interface{}, bool) {
 	}
 }
 
+func (i *filesInputIter) lineNumber() int {
+	return inputLineNumber(i.iter)
+}
+
+func (i *filesInputIter) yamlDocument() (*yaml.Node, *yamlNodeDecoder) {
+	return inputYAMLDocument(i.iter)
+}
+
 func (i *filesInputIter) Close() error {
+	if i.iter != nil {
+		i.iter.Close()
+		i.iter = nil
+	}
 	if i.file != nil {
 		i.file.Close()
 		i.file = nil
-		i.err = io.EOF
 	}
+	i.fnames, i.err = nil, io.EOF
 	return nil
 }
 
 type rawInputIter struct {
-	scanner *bufio.Scanner
-	err     error
+	scanner     *bufio.Scanner
+	fname       string
+	lineno      int
+	offset      int64 // offset of the next line
+	invalidUTF8 string
+	err         error
 }
 
-func newRawInputIter(r io.Reader, _ string) inputIter {
-	return &rawInputIter{scanner: bufio.NewScanner(r)}
+func newRawInputIter(r io.Reader, fname string) inputIter {
+	i := &rawInputIter{scanner: bufio.NewScanner(r), fname: fname}
+	i.scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		i.offset += int64(advance)
+		return advance, token, err
+	})
+	return i
 }
 
 func (i *rawInputIter) Next() (interface{}, bool) {
 	if i.err != nil {
 		return nil, false
 	}
+	offset := i.offset
 	if i.scanner.Scan() {
-		return i.scanner.Text(), true
+		i.lineno++
+		if i.invalidUTF8 == "" {
+			return i.scanner.Text(), true
+		}
+		line := i.scanner.Bytes()
+		j := invalidUTF8Index(line)
+		if j < 0 {
+			return string(line), true
+		}
+		switch i.invalidUTF8 {
+		case invalidUTF8Error:
+			i.err = fmt.Errorf("invalid raw input: %s:%d: %s", i.fname, i.lineno,
+				invalidUTF8Message(line[j], offset+int64(j)))
+			return i.err, true
+		case invalidUTF8Binary:
+			return append([]byte{}, line...), true
+		default:
+			return replaceInvalidUTF8(string(line)), true
+		}
 	}
 	if i.err = i.scanner.Err(); i.err != nil {
 		return i.err, true
@@ -209,25 +450,63 @@ func (i *rawInputIter) Next() (interface{}, bool) {
 	return nil, false
 }
 
+func (i *rawInputIter) lineNumber() int {
+	return i.lineno
+}
+
 func (i *rawInputIter) Close() error {
 	i.err = io.EOF
 	return nil
 }
 
+type binaryInputIter struct {
+	r   io.Reader
+	err error
+}
+
+func newBinaryInputIter(r io.Reader, _ string) inputIter {
+	return &binaryInputIter{r: r}
+}
+
+func (i *binaryInputIter) Next() (interface{}, bool) {
+	if i.err != nil {
+		return nil, false
+	}
+	bs, err := ioutil.ReadAll(i.r)
+	if err != nil {
+		i.err = err
+		return err, true
+	}
+	i.err = io.EOF
+	return bs, true
+}
+
+func (i *binaryInputIter) Close() error {
+	i.err = io.EOF
+	return nil
+}
+
 type streamInputIter struct {
 	stream *jsonStream
 	ir     *inputReader
+	lr     *lineReader
 	fname  string
 	offset int64
 	line   int
+	lineno int
+	errors bool
+	path   interface{} // the path of the last event for --stream-errors option
 	err    error
 }
 
 func newStreamInputIter(r io.Reader, fname string) inputIter {
 	ir := newInputReader(r)
-	dec := json.NewDecoder(ir)
+	lr := newLineReader(ir)
+	dec := json.NewDecoder(lr)
 	dec.UseNumber()
-	return &streamInputIter{stream: newJSONStream(dec), ir: ir, fname: fname}
+	return &streamInputIter{
+		stream: newJSONStream(dec), ir: ir, lr: lr, fname: fname, path: []interface{}{},
+	}
 }
 
 func (i *streamInputIter) Next() (interface{}, bool) {
@@ -247,6 +526,10 @@ func (i *streamInputIter) Next() (interface{}, bool) {
 			offset, line = &err.Offset, &i.line
 		}
 		i.err = &jsonParseError{i.fname, i.ir.getContents(offset, line), i.line, err}
+		if i.errors {
+			v, i.err = i.errorEvent(i.err.(*jsonParseError)), io.EOF
+			return v, true
+		}
 		return i.err, true
 	}
 	if buf := i.ir.buf; buf != nil && buf.Len() >= 16*1024 {
@@ -254,9 +537,29 @@ func (i *streamInputIter) Next() (interface{}, bool) {
 		i.line += bytes.Count(buf.Bytes(), []byte{'\n'})
 		buf.Reset()
 	}
+	i.lineno = i.lr.lineNumber(i.stream.dec.InputOffset())
+	i.path = v.([]interface{})[0]
 	return v, true
 }
 
+// errorEvent returns the event of the parse error for --stream-errors option,
+// which is a pair of the error message and the path of the last event.
+func (i *streamInputIter) errorEvent(err *jsonParseError) interface{} {
+	offset := len(err.contents)
+	if o, ok := jsonErrorOffset(err.err); ok {
+		offset = int(o)
+	}
+	_, line, col := getLineByOffset(trimLastInvalidRune(err.contents), offset)
+	return []interface{}{
+		fmt.Sprintf("%s at %s:%d:%d", err.err, err.fname, line+err.line, col+1),
+		i.path,
+	}
+}
+
+func (i *streamInputIter) lineNumber() int {
+	return i.lineno
+}
+
 func (i *streamInputIter) Close() error {
 	i.err = io.EOF
 	return nil
@@ -266,7 +569,10 @@ type yamlInputIter struct {
 	dec   *yaml.Decoder
 	ir    *inputReader
 	fname string
-	err   error
+	yamlNodeDecoder
+	keepNode bool
+	node     *yaml.Node
+	err      error
 }
 
 func newYAMLInputIter(r io.Reader, fname string) inputIter {
@@ -279,8 +585,16 @@ func (i *yamlInputIter) Next() (interface{}, bool) {
 	if i.err != nil {
 		return nil, false
 	}
+	var n yaml.Node
+	err := i.dec.Decode(&n)
 	var v interface{}
-	if err := i.dec.Decode(&v); err != nil {
+	if err == nil {
+		v, err = i.decode(&n)
+		if i.keepNode {
+			i.node = &n
+		}
+	}
+	if err != nil {
 		if err == io.EOF {
 			i.err = err
 			return nil, false
@@ -288,7 +602,11 @@ func (i *yamlInputIter) Next() (interface{}, bool) {
 		i.err = &yamlParseError{i.fname, i.ir.getContents(nil, nil), err}
 		return i.err, true
 	}
-	return normalizeYAML(v), true
+	return v, true
+}
+
+func (i *yamlInputIter) yamlDocument() (*yaml.Node, *yamlNodeDecoder) {
+	return i.node, &i.yamlNodeDecoder
 }
 
 func (i *yamlInputIter) Close() error {
@@ -325,6 +643,10 @@ func (i *slurpInputIter) Next() (interface{}, bool) {
 	}
 }
 
+func (i *slurpInputIter) lineNumber() int {
+	return inputLineNumber(i.iter)
+}
+
 func (i *slurpInputIter) Close() error {
 	if i.iter != nil {
 		i.iter.Close()
@@ -349,20 +671,33 @@ func (i *slurpRawInputIter) Next() (interface{}, bool) {
 	}
 	var vs []string
 	var v interface{}
-	var ok bool
+	var ok, binary bool
 	for {
 		v, ok = i.iter.Next()
 		if !ok {
 			i.err = io.EOF
+			if binary {
+				return []byte(strings.Join(vs, "")), true
+			}
 			return strings.Join(vs, ""), true
 		}
 		if i.err, ok = v.(error); ok {
 			return i.err, true
 		}
-		vs = append(vs, v.(string), "\n")
+		switch v := v.(type) {
+		case string:
+			vs = append(vs, v, "\n")
+		case []byte:
+			vs = append(vs, string(v), "\n")
+			binary = true
+		}
 	}
 }
 
+func (i *slurpRawInputIter) lineNumber() int {
+	return inputLineNumber(i.iter)
+}
+
 func (i *slurpRawInputIter) Close() error {
 	if i.iter != nil {
 		i.iter.Close()
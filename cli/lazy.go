@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// lazyValue is an object or an array kept in the raw bytes of the input. The
+// elements are scanned on the first access, and decoded when the query touches
+// them. The values not touched by the query are emitted as they are in the
+// input, without decoding and encoding the elements.
+type lazyValue struct {
+	raw    []byte
+	keys   []string
+	elems  [][]byte
+	values []interface{}
+	index  map[string]int
+}
+
+// newLazyValue creates a value from the raw bytes of a valid JSON value. The
+// scalar values are decoded immediately.
+func newLazyValue(raw []byte) interface{} {
+	switch raw[0] {
+	case '{', '[':
+		return &lazyValue{raw: raw}
+	case 'n':
+		return nil
+	case 't':
+		return true
+	case 'f':
+		return false
+	case '"':
+		if bytes.IndexByte(raw, '\\') < 0 {
+			return string(raw[1 : len(raw)-1])
+		}
+		var s string
+		json.Unmarshal(raw, &s)
+		return s
+	default:
+		return json.Number(raw)
+	}
+}
+
+func (v *lazyValue) isObject() bool {
+	return v.raw[0] == '{'
+}
+
+// scan splits the raw bytes into the elements, without decoding them. The
+// duplicate keys keep the first position and the last value.
+func (v *lazyValue) scan() {
+	if v.elems != nil {
+		return
+	}
+	v.elems = [][]byte{}
+	if v.isObject() {
+		v.keys, v.index = []string{}, make(map[string]int)
+	}
+	bs := v.raw
+	for i := skipJSONSpaces(bs, 1); bs[i] != '}' && bs[i] != ']'; {
+		var key string
+		if v.isObject() {
+			j := skipJSONValue(bs, i)
+			key = newLazyValue(bs[i:j]).(string)
+			i = skipJSONSpaces(bs, skipJSONSpaces(bs, j)+1) // skip the colon
+		}
+		j := skipJSONValue(bs, i)
+		if k, ok := v.index[key]; ok {
+			v.elems[k] = bs[i:j]
+		} else {
+			if v.isObject() {
+				v.index[key] = len(v.elems)
+				v.keys = append(v.keys, key)
+			}
+			v.elems = append(v.elems, bs[i:j])
+		}
+		if i = skipJSONSpaces(bs, j); bs[i] == ',' {
+			i = skipJSONSpaces(bs, i+1)
+		}
+	}
+	v.values = make([]interface{}, len(v.elems))
+}
+
+func (v *lazyValue) value(i int) interface{} {
+	if v.values[i] == nil {
+		v.values[i] = newLazyValue(v.elems[i])
+	}
+	return v.values[i]
+}
+
+// JQValueType implements gojq.JQValue.
+func (v *lazyValue) JQValueType() string {
+	if v.isObject() {
+		return "object"
+	}
+	return "array"
+}
+
+// JQValueLength implements gojq.JQValue.
+func (v *lazyValue) JQValueLength() int {
+	v.scan()
+	return len(v.elems)
+}
+
+// JQValueIndex implements gojq.JQValue.
+func (v *lazyValue) JQValueIndex(key interface{}) interface{} {
+	v.scan()
+	switch key := key.(type) {
+	case string:
+		if i, ok := v.index[key]; ok {
+			return v.value(i)
+		}
+	case int:
+		if !v.isObject() && 0 <= key && key < len(v.elems) {
+			return v.value(key)
+		}
+	}
+	return nil
+}
+
+// JQValueKeys implements gojq.JQValue.
+func (v *lazyValue) JQValueKeys() []interface{} {
+	v.scan()
+	ks := make([]interface{}, len(v.elems))
+	if v.isObject() {
+		keys := make([]string, len(v.keys))
+		copy(keys, v.keys)
+		sort.Strings(keys)
+		for i, k := range keys {
+			ks[i] = k
+		}
+	} else {
+		for i := range ks {
+			ks[i] = i
+		}
+	}
+	return ks
+}
+
+// JQValueToJSON implements gojq.JQValue.
+func (v *lazyValue) JQValueToJSON() interface{} {
+	v.scan()
+	if v.isObject() {
+		m := make(map[string]interface{}, len(v.keys))
+		for i, k := range v.keys {
+			m[k] = v.value(i)
+		}
+		return m
+	}
+	vs := make([]interface{}, len(v.elems))
+	for i := range vs {
+		vs[i] = v.value(i)
+	}
+	return vs
+}
+
+func skipJSONSpaces(bs []byte, i int) int {
+	for i < len(bs) && (bs[i] == ' ' || bs[i] == '\t' || bs[i] == '\n' || bs[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// skipJSONValue returns the offset of the end of the value starting at i. The
+// bytes are assumed to be valid JSON, which the decoder has already checked.
+func skipJSONValue(bs []byte, i int) int {
+	var depth int
+	for ; i < len(bs); i++ {
+		switch bs[i] {
+		case '"':
+			for i++; bs[i] != '"'; i++ {
+				if bs[i] == '\\' {
+					i++
+				}
+			}
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth == 0 {
+				return i
+			}
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		case ',', ':', ' ', '\t', '\n', '\r':
+			if depth == 0 {
+				return i
+			}
+		}
+		if depth == 0 && bs[i] == '"' {
+			return i + 1
+		}
+	}
+	return i
+}
@@ -15,22 +15,49 @@ type rawMarshaler struct {
 }
 
 func (m *rawMarshaler) marshal(v interface{}, w io.Writer) error {
-	if s, ok := v.(string); ok {
-		_, err := w.Write([]byte(s))
+	switch v := v.(type) {
+	case string:
+		_, err := w.Write([]byte(v))
 		return err
+	case []byte:
+		_, err := w.Write(v)
+		return err
+	default:
+		return m.m.marshal(v, w)
 	}
-	return m.m.marshal(v, w)
 }
 
-func yamlFormatter(indent *int) *yamlMarshaler {
-	return &yamlMarshaler{indent}
+func yamlFormatter(indent *int, ordered bool) *yamlMarshaler {
+	return &yamlMarshaler{indent: indent, ordered: ordered}
 }
 
 type yamlMarshaler struct {
-	indent *int
+	indent  *int
+	ordered bool
+	doc     *yaml.Node // the input document to keep the comments
+	dec     *yamlNodeDecoder
 }
 
 func (m *yamlMarshaler) marshal(v interface{}, w io.Writer) error {
+	if m.doc != nil {
+		n, err := patchYAMLDocument(m.doc, m.dec, v)
+		if err != nil {
+			return err
+		}
+		if n != nil {
+			return m.encode(n, w)
+		}
+	}
+	if m.ordered {
+		var err error
+		if v, err = orderedYAMLValue(v); err != nil {
+			return err
+		}
+	}
+	return m.encode(v, w)
+}
+
+func (m *yamlMarshaler) encode(v interface{}, w io.Writer) error {
 	enc := yaml.NewEncoder(w)
 	if i := m.indent; i != nil {
 		enc.SetIndent(*i)
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"errors"
+	"os"
+
+	"github.com/itchyny/gojq"
+)
+
+// moduleLoader looks up the modules in each loader in order, until it finds
+// the module.
+type moduleLoader []gojq.ModuleLoader
+
+func newModuleLoader(paths []string) moduleLoader {
+	loader := moduleLoader{gojq.NewModuleLoader(paths)}
+	if l := stdlibModuleLoader(); l != nil {
+		loader = append(loader, l)
+	}
+	return loader
+}
+
+func (ls moduleLoader) LoadInitModules() ([]*gojq.Query, error) {
+	var qs []*gojq.Query
+	for _, l := range ls {
+		if l, ok := l.(interface {
+			LoadInitModules() ([]*gojq.Query, error)
+		}); ok {
+			xs, err := l.LoadInitModules()
+			if err != nil {
+				return nil, err
+			}
+			qs = append(qs, xs...)
+		}
+	}
+	return qs, nil
+}
+
+func (ls moduleLoader) LoadModuleWithMeta(name string, meta map[string]interface{}) (q *gojq.Query, err error) {
+	for _, l := range ls {
+		if q, err = l.(interface {
+			LoadModuleWithMeta(string, map[string]interface{}) (*gojq.Query, error)
+		}).LoadModuleWithMeta(name, meta); !errors.Is(err, os.ErrNotExist) {
+			break
+		}
+	}
+	return
+}
+
+func (ls moduleLoader) LoadJSONWithMeta(name string, meta map[string]interface{}) (v interface{}, err error) {
+	for _, l := range ls {
+		if v, err = l.(interface {
+			LoadJSONWithMeta(string, map[string]interface{}) (interface{}, error)
+		}).LoadJSONWithMeta(name, meta); !errors.Is(err, os.ErrNotExist) {
+			break
+		}
+	}
+	return
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// terminal is the controlling terminal used by the prompt functions, which is
+// separated from the standard input since it is used for the inputs.
+type terminal interface {
+	io.Writer
+	readLine(echo bool) (string, error)
+	Close() error
+}
+
+type ttyTerminal struct {
+	in, out *os.File
+	r       *bufio.Reader
+}
+
+func (t *ttyTerminal) Write(p []byte) (int, error) {
+	return t.out.Write(p)
+}
+
+func (t *ttyTerminal) readLine(echo bool) (string, error) {
+	if !echo {
+		restore, err := disableEcho(t.in)
+		if err != nil {
+			return "", err
+		}
+		defer func() {
+			restore()
+			_, _ = t.out.WriteString("\n")
+		}()
+	}
+	s, err := t.r.ReadString('\n')
+	if err != nil && (err != io.EOF || s == "") {
+		return "", err
+	}
+	return strings.TrimRight(s, "\r\n"), nil
+}
+
+func (t *ttyTerminal) Close() error {
+	if t.out != t.in {
+		t.out.Close()
+	}
+	return t.in.Close()
+}
+
+func openTTY() (terminal, error) {
+	in, out, err := openTTYFiles()
+	if err != nil {
+		return nil, err
+	}
+	return &ttyTerminal{in, out, bufio.NewReader(in)}, nil
+}
+
+func (cli *cli) funcPrompt(_ interface{}, args []interface{}) interface{} {
+	if !cli.capabilities.prompt {
+		return &capabilityError{"prompt", "--allow-prompt"}
+	}
+	return cli.prompt("prompt", args[0], true)
+}
+
+func (cli *cli) funcGetPass(_ interface{}, args []interface{}) interface{} {
+	if !cli.capabilities.prompt {
+		return &capabilityError{"getpass", "--allow-prompt"}
+	}
+	return cli.prompt("getpass", args[0], false)
+}
+
+// prompt shows the message on the terminal and reads a line. The input is not
+// echoed back for secrets.
+func (cli *cli) prompt(name string, x interface{}, echo bool) interface{} {
+	msg, ok := x.(string)
+	if !ok {
+		return &funcTypeError{name, x}
+	}
+	open := cli.openTerminal
+	if open == nil {
+		open = openTTY
+	}
+	t, err := open()
+	if err != nil {
+		return &terminalError{name}
+	}
+	defer t.Close()
+	if _, err := io.WriteString(t, msg); err != nil {
+		return err
+	}
+	s, err := t.readLine(echo)
+	if err != nil {
+		return err
+	}
+	return s
+}
@@ -0,0 +1,17 @@
+//go:build plan9 || js
+// +build plan9 js
+
+package cli
+
+import (
+	"errors"
+	"os"
+)
+
+func openTTYFiles() (*os.File, *os.File, error) {
+	return nil, nil, errors.New("terminal is not supported")
+}
+
+func disableEcho(*os.File) (func(), error) {
+	return nil, errors.New("terminal is not supported")
+}
@@ -0,0 +1,29 @@
+//go:build !windows && !plan9 && !js
+// +build !windows,!plan9,!js
+
+package cli
+
+import (
+	"os"
+	"os/exec"
+)
+
+func openTTYFiles() (*os.File, *os.File, error) {
+	f, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	return f, f, err
+}
+
+// disableEcho disables the echo of the terminal using stty command, to avoid
+// depending on the termios requests which differ on the platforms.
+func disableEcho(f *os.File) (func(), error) {
+	if err := stty(f, "-echo"); err != nil {
+		return nil, err
+	}
+	return func() { _ = stty(f, "echo") }, nil
+}
+
+func stty(f *os.File, arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = f
+	return cmd.Run()
+}
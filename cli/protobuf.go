@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/itchyny/gojq"
+)
+
+// protobufDescriptors caches the file descriptor sets by the serialized bytes,
+// since the same descriptor set is usually passed on each invocation.
+type protobufDescriptors map[string]*protoregistry.Files
+
+func (cli *cli) funcProtobufDecode(v interface{}, args []interface{}) interface{} {
+	md, err := cli.protobufMessage("protobuf_decode", args[0], args[1])
+	if err != nil {
+		return err
+	}
+	bs, ok := decodeBytes(v)
+	if !ok {
+		return &funcTypeError{"protobuf_decode", v}
+	}
+	m := dynamicpb.NewMessage(md)
+	if err := proto.Unmarshal(bs, m); err != nil {
+		return &protobufError{"protobuf_decode", err}
+	}
+	if bs, err = protojson.Marshal(m); err != nil {
+		return &protobufError{"protobuf_decode", err}
+	}
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	dec.UseNumber()
+	var w interface{}
+	if err := dec.Decode(&w); err != nil {
+		return err
+	}
+	return w
+}
+
+func (cli *cli) funcProtobufEncode(v interface{}, args []interface{}) interface{} {
+	md, err := cli.protobufMessage("protobuf_encode", args[0], args[1])
+	if err != nil {
+		return err
+	}
+	bs, err := gojq.Marshal(v)
+	if err != nil {
+		return err
+	}
+	m := dynamicpb.NewMessage(md)
+	if err := protojson.Unmarshal(bs, m); err != nil {
+		return &protobufError{"protobuf_encode", err}
+	}
+	if bs, err = (proto.MarshalOptions{Deterministic: true}).Marshal(m); err != nil {
+		return &protobufError{"protobuf_encode", err}
+	}
+	return bs
+}
+
+// protobufMessage finds the message descriptor in the file descriptor set,
+// which is serialized in a byte string or a base64 string.
+func (cli *cli) protobufMessage(name string, x, y interface{}) (protoreflect.MessageDescriptor, error) {
+	bs, ok := decodeBytes(x)
+	if !ok {
+		return nil, &funcTypeError{name, x}
+	}
+	message, ok := y.(string)
+	if !ok {
+		return nil, &funcTypeError{name, y}
+	}
+	files, ok := cli.protobufDescriptors[string(bs)]
+	if !ok {
+		var set descriptorpb.FileDescriptorSet
+		if err := proto.Unmarshal(bs, &set); err != nil {
+			return nil, &protobufError{name, err}
+		}
+		var err error
+		if files, err = protodesc.NewFiles(&set); err != nil {
+			return nil, &protobufError{name, err}
+		}
+		if cli.protobufDescriptors == nil {
+			cli.protobufDescriptors = protobufDescriptors{}
+		}
+		cli.protobufDescriptors[string(bs)] = files
+	}
+	d, _ := files.FindDescriptorByName(protoreflect.FullName(message))
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, &protobufError{name, fmt.Errorf("message not found: %q", message)}
+	}
+	return md, nil
+}
+
+func decodeBytes(v interface{}) ([]byte, bool) {
+	switch v := v.(type) {
+	case []byte:
+		return v, true
+	case string:
+		bs, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			if bs, err = base64.RawStdEncoding.DecodeString(v); err != nil {
+				return nil, false
+			}
+		}
+		return bs, true
+	default:
+		return nil, false
+	}
+}
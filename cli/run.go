@@ -1,10 +1,30 @@
 package cli
 
-import "os"
+import (
+	"context"
+	"os"
+	"os/signal"
+)
 
-// Run gojq.
+// Run gojq. The first interrupt signal cancels the running query, and the
+// command exits with code 130 after printing the results emitted so far. The
+// second signal terminates the process as usual.
 func Run() int {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt)
+	defer signal.Stop(ch)
+	go func() {
+		select {
+		case <-ch:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(ch)
+	}()
 	return (&cli{
+		ctx:       ctx,
 		inStream:  os.Stdin,
 		outStream: os.Stdout,
 		errStream: os.Stderr,
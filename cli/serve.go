@@ -0,0 +1,265 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/itchyny/go-flags"
+
+	"github.com/itchyny/gojq"
+)
+
+type serveopts struct {
+	Listen      string        `long:"listen" default:":8080" description:"address to listen on"`
+	Timeout     time.Duration `long:"timeout" default:"30s" description:"timeout of each request"`
+	MaxSteps    int           `long:"max-steps" description:"maximum number of execution steps for each input"`
+	MaxDepth    int           `long:"max-depth" description:"maximum depth of function calls"`
+	MaxSize     int           `long:"max-value-size" description:"maximum size of constructed values"`
+	MaxBodySize int64         `long:"max-body-size" default:"10485760" description:"maximum size of request bodies in bytes"`
+	MaxMsgSize  int           `long:"max-message-size" default:"4194304" description:"maximum size of gRPC messages in bytes"`
+	TLSCert     string        `long:"tls-cert" description:"certificate file to serve over TLS"`
+	TLSKey      string        `long:"tls-key" description:"private key file to serve over TLS"`
+	FromFile    string        `short:"f" long:"from-file" description:"load query from file"`
+	ModulePaths []string      `short:"L" description:"directory to search modules from"`
+}
+
+// runServe runs the HTTP server applying the query to each request body.
+func (cli *cli) runServe(args []string) error {
+	var opts serveopts
+	args, err := flags.NewParser(
+		&opts, flags.HelpFlag|flags.PassDoubleDash,
+	).ParseArgs(args)
+	if err != nil {
+		if err, ok := err.(*flags.Error); ok && err.Type == flags.ErrHelp {
+			fmt.Fprintf(cli.outStream, `%[1]s serve - run the query as an HTTP server
+
+Synopsis:
+  %% %[1]s serve --listen :8080 '.foo'
+  %% curl -d '{"foo": 128}' localhost:8080
+
+`, name)
+			fmt.Fprintln(cli.outStream, strings.Replace(err.Error(), "serve [OPTIONS]", "serve [OPTIONS] QUERY", 1))
+			return nil
+		}
+		return &flagParseError{err}
+	}
+	code, err := cli.compileServeQuery(opts, args)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{
+		Addr:              opts.Listen,
+		Handler:           &serveHandler{code, opts.Timeout, opts.MaxBodySize, opts.MaxMsgSize},
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	enableUnencryptedHTTP2(server)
+	fmt.Fprintf(cli.errStream, "%s: listening on %s\n", name, opts.Listen)
+	if opts.TLSCert != "" || opts.TLSKey != "" {
+		return server.ListenAndServeTLS(opts.TLSCert, opts.TLSKey)
+	}
+	return server.ListenAndServe()
+}
+
+func (cli *cli) compileServeQuery(opts serveopts, args []string) (*gojq.Code, error) {
+	var arg, fname string
+	if opts.FromFile != "" {
+		src, err := ioutil.ReadFile(opts.FromFile)
+		if err != nil {
+			return nil, err
+		}
+		arg, fname = string(src), opts.FromFile
+	} else if len(args) == 0 {
+		arg = "."
+	} else if len(args) == 1 {
+		arg, fname = strings.TrimSpace(args[0]), "<arg>"
+	} else {
+		return nil, &flagParseError{errors.New("too many arguments for serve")}
+	}
+	query, err := gojq.Parse(arg)
+	if err != nil {
+		return nil, &queryParseError{"query", fname, arg, err}
+	}
+	code, err := gojq.Compile(query,
+		gojq.WithModuleLoader(newModuleLoader(opts.ModulePaths)),
+		gojq.WithFunction("debug", 0, 0, cli.funcDebug),
+		gojq.WithFunction("stderr", 0, 0, cli.funcStderr),
+		gojq.WithMaxSteps(opts.MaxSteps),
+		gojq.WithMaxDepth(opts.MaxDepth),
+		gojq.WithMaxValueSize(opts.MaxSize),
+	)
+	if err != nil {
+		return nil, &compileError{err}
+	}
+	return code, nil
+}
+
+// serveHandler applies the query to the request body. When the content type
+// is application/x-ndjson, the body is a stream of JSON values and the results
+// are streamed in NDJSON. When the request is gRPC over HTTP/2, the gRPC
+// service is served. Otherwise the body is a JSON value, and the results are
+// responded in a JSON array. The request body except for gRPC is limited by
+// maxBodySize, and each gRPC message is limited by maxMessageSize.
+type serveHandler struct {
+	code           *gojq.Code
+	timeout        time.Duration
+	maxBodySize    int64
+	maxMessageSize int
+}
+
+func (h *serveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if isGRPCRequest(r) {
+		h.serveGRPC(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeServeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	ctx := r.Context()
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+	var body io.Reader = r.Body
+	if h.maxBodySize > 0 {
+		body = &limitedBodyReader{body, h.maxBodySize}
+	}
+	dec := json.NewDecoder(body)
+	dec.UseNumber()
+	if t, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); t == "application/x-ndjson" {
+		h.serveStream(ctx, w, dec)
+	} else {
+		h.serveValue(ctx, w, dec)
+	}
+}
+
+func (h *serveHandler) serveValue(ctx context.Context, w http.ResponseWriter, dec *json.Decoder) {
+	var v interface{}
+	err := dec.Decode(&v)
+	if err == nil {
+		if _, err = dec.Token(); err == io.EOF {
+			err = nil
+		} else if err == nil {
+			err = errors.New("invalid json: multiple values")
+		}
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == errBodyTooLarge {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeServeError(w, status, err)
+		return
+	}
+	vs := []interface{}{}
+	iter := h.code.RunWithContext(ctx, v)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			writeServeError(w, serveErrorStatus(err), err)
+			return
+		}
+		vs = append(vs, v)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := newEncoder(false, 0, false)
+	enc.marshal(vs, w)
+	w.Write([]byte{'\n'})
+}
+
+func (h *serveHandler) serveStream(ctx context.Context, w http.ResponseWriter, dec *json.Decoder) {
+	// Allow reading the request body after writing the response for HTTP/1.x.
+	if d, ok := w.(interface{ EnableFullDuplex() error }); ok {
+		d.EnableFullDuplex()
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	enc := newEncoder(false, 0, false)
+	write := func(v interface{}) error {
+		if err := enc.marshal(v, w); err != nil {
+			return err
+		}
+		_, err := w.Write([]byte{'\n'})
+		return err
+	}
+	for {
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			if err != io.EOF {
+				write(map[string]interface{}{"error": err.Error()})
+			}
+			return
+		}
+		iter := h.code.RunWithContext(ctx, v)
+		for {
+			v, ok := iter.Next()
+			if !ok {
+				break
+			}
+			if err, ok := v.(error); ok {
+				write(map[string]interface{}{"error": err.Error()})
+				return
+			}
+			if err := write(v); err != nil {
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+var errBodyTooLarge = errors.New("http: request body too large")
+
+// limitedBodyReader limits the size of the request body, and returns
+// errBodyTooLarge when the body exceeds the limit. This is used instead of
+// http.MaxBytesReader to tell the error without http.MaxBytesError, which is
+// available since Go 1.19.
+type limitedBodyReader struct {
+	r io.Reader
+	n int64 // remaining bytes, or -1 when exceeded
+}
+
+func (r *limitedBodyReader) Read(p []byte) (int, error) {
+	if r.n < 0 {
+		return 0, errBodyTooLarge
+	}
+	if int64(len(p)) > r.n+1 {
+		p = p[:r.n+1]
+	}
+	n, err := r.r.Read(p)
+	if int64(n) <= r.n {
+		r.n -= int64(n)
+		return n, err
+	}
+	n, r.n = int(r.n), -1
+	return n, errBodyTooLarge
+}
+
+func serveErrorStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusUnprocessableEntity
+}
+
+func writeServeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	newEncoder(false, 0, false).marshal(map[string]interface{}{"error": err.Error()}, w)
+	w.Write([]byte{'\n'})
+}
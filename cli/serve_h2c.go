@@ -0,0 +1,17 @@
+//go:build go1.24
+// +build go1.24
+
+package cli
+
+import "net/http"
+
+const grpcHTTP2RequiredMessage = "gRPC requires HTTP/2 over TLS or cleartext (h2c)"
+
+// enableUnencryptedHTTP2 enables HTTP/2 over cleartext (h2c) with the prior
+// knowledge, which the plaintext gRPC clients use.
+func enableUnencryptedHTTP2(server *http.Server) {
+	server.Protocols = new(http.Protocols)
+	server.Protocols.SetHTTP1(true)
+	server.Protocols.SetHTTP2(true)
+	server.Protocols.SetUnencryptedHTTP2(true)
+}
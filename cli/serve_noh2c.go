@@ -0,0 +1,12 @@
+//go:build !go1.24
+// +build !go1.24
+
+package cli
+
+import "net/http"
+
+const grpcHTTP2RequiredMessage = "gRPC requires HTTP/2 over TLS (specify --tls-cert and --tls-key)"
+
+// enableUnencryptedHTTP2 does nothing since HTTP/2 over cleartext (h2c) is
+// supported by net/http of Go 1.24 and later.
+func enableUnencryptedHTTP2(*http.Server) {}
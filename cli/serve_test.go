@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/itchyny/gojq"
+)
+
+func TestServeHandler(t *testing.T) {
+	query, err := gojq.Parse(`if .sleep then last(range(1e9)) else .foo, .bar end`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(&serveHandler{code, 100 * time.Millisecond, 64, 0})
+	defer server.Close()
+
+	testCases := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		status      int
+		expected    string
+	}{
+		{
+			name:     "json",
+			method:   http.MethodPost,
+			body:     `{"foo": 1, "bar": [2]}`,
+			status:   http.StatusOK,
+			expected: "[1,[2]]\n",
+		},
+		{
+			name:        "ndjson",
+			method:      http.MethodPost,
+			contentType: "application/x-ndjson",
+			body:        "{\"foo\": 1, \"bar\": 2}\n{\"foo\": 3}\n",
+			status:      http.StatusOK,
+			expected:    "1\n2\n3\nnull\n",
+		},
+		{
+			name:        "ndjson error",
+			method:      http.MethodPost,
+			contentType: "application/x-ndjson",
+			body:        "{\"foo\": 1}\n[]\n{\"foo\": 3}\n",
+			status:      http.StatusOK,
+			expected:    "1\nnull\n{\"error\":\"expected an object but got: array ([])\"}\n",
+		},
+		{
+			name:     "invalid json",
+			method:   http.MethodPost,
+			body:     `{"foo": }`,
+			status:   http.StatusBadRequest,
+			expected: "{\"error\":\"invalid character '}' looking for beginning of value\"}\n",
+		},
+		{
+			name:     "body at the limit",
+			method:   http.MethodPost,
+			body:     `{"foo": "` + strings.Repeat("x", 53) + `"}`,
+			status:   http.StatusOK,
+			expected: `["` + strings.Repeat("x", 53) + `",null]` + "\n",
+		},
+		{
+			name:        "ndjson body too large",
+			method:      http.MethodPost,
+			contentType: "application/x-ndjson",
+			body:        "{\"foo\": 1}\n{\"foo\": \"" + strings.Repeat("x", 64) + "\"}\n",
+			status:      http.StatusOK,
+			expected:    "1\nnull\n{\"error\":\"http: request body too large\"}\n",
+		},
+		{
+			name:     "body too large",
+			method:   http.MethodPost,
+			body:     `{"foo": "` + strings.Repeat("x", 64) + `"}`,
+			status:   http.StatusRequestEntityTooLarge,
+			expected: "{\"error\":\"http: request body too large\"}\n",
+		},
+		{
+			name:     "runtime error",
+			method:   http.MethodPost,
+			body:     `1`,
+			status:   http.StatusUnprocessableEntity,
+			expected: "{\"error\":\"expected an object but got: number (1)\"}\n",
+		},
+		{
+			name:     "timeout",
+			method:   http.MethodPost,
+			body:     `{"sleep": true}`,
+			status:   http.StatusServiceUnavailable,
+			expected: "{\"error\":\"context deadline exceeded\"}\n",
+		},
+		{
+			name:     "method not allowed",
+			method:   http.MethodGet,
+			status:   http.StatusMethodNotAllowed,
+			expected: "{\"error\":\"method not allowed\"}\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, server.URL, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tc.status {
+				t.Errorf("status code: got: %d, expected: %d", res.StatusCode, tc.status)
+			}
+			got, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.expected {
+				t.Errorf("response body: got: %q, expected: %q", got, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,514 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math/bits"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// simdJSONDecoder decodes the stream of JSON values in two stages like
+// simdjson. The first stage classifies the input in blocks of 64 bytes with
+// the bitwise operations on 64-bit words (SIMD within a register), and collects
+// the indices of the structural characters, the quotes and the starts of the
+// scalar values. The second stage builds the values walking the indices, so it
+// does not examine the whitespaces and the strings byte by byte. When the
+// second stage does not accept the value, the decoder falls back to
+// encoding/json from the value to emit the same values and errors.
+type simdJSONDecoder struct {
+	r        io.Reader
+	buf      []byte
+	base     int64 // offset of buf[0] in the input
+	eof      bool
+	indices  []int
+	index    int // index of the next value in indices
+	end      int // end of the last value in buf
+	lines    int // number of newlines before end
+	scanned  int // buf[:scanned] is classified in full blocks
+	full     int // number of indices in the full blocks
+	state    simdState
+	errPos   int // position of the first control character in a string
+	errFull  int
+	fallback *json.Decoder
+	fbBase   int64
+	lr       *lineReader
+}
+
+// simdState is the state of the first stage carried over the blocks.
+type simdState struct {
+	escaped  uint64 // the first byte of the next block is escaped
+	inString uint64 // all ones if the next block starts in a string
+	scalar   uint64 // the last byte of the previous block is in a scalar
+}
+
+const simdBlockSize = 64
+
+func newSIMDJSONDecoder(lr *lineReader) *simdJSONDecoder {
+	return &simdJSONDecoder{
+		r: lr, buf: make([]byte, 0, 64*1024), errPos: -1, errFull: -1, lr: lr,
+	}
+}
+
+// Decode decodes the next value, and returns io.EOF at the end of the input.
+func (d *simdJSONDecoder) Decode() (interface{}, error) {
+	if d.fallback != nil {
+		return d.decodeFallback()
+	}
+	for {
+		if d.index < len(d.indices) {
+			start := d.indices[d.index]
+			if d.eof && start == len(d.buf) {
+				return nil, io.EOF
+			}
+			v, next, end, status := d.parse(d.index)
+			if status == simdOK && (d.errPos < start || d.errPos >= end) {
+				d.index = next
+				d.lines += bytes.Count(d.buf[d.end:end], []byte{'\n'})
+				d.end = end
+				return v, nil
+			}
+			if status != simdIncomplete || d.eof {
+				return d.startFallback(start)
+			}
+		} else if d.eof {
+			return nil, io.EOF
+		}
+		if err := d.fill(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// InputOffset returns the offset of the end of the last value in the input.
+func (d *simdJSONDecoder) InputOffset() int64 {
+	if d.fallback != nil {
+		return d.fbBase + d.fallback.InputOffset()
+	}
+	return d.base + int64(d.end)
+}
+
+// lineNumber returns the line number where the last value ends.
+func (d *simdJSONDecoder) lineNumber() int {
+	if d.fallback != nil {
+		return d.lr.lineNumber(d.InputOffset())
+	}
+	return d.lines + 1
+}
+
+func (d *simdJSONDecoder) startFallback(start int) (interface{}, error) {
+	d.fallback = json.NewDecoder(io.MultiReader(bytes.NewReader(d.buf[start:]), d.r))
+	d.fallback.UseNumber()
+	d.fbBase = d.base + int64(start)
+	return d.decodeFallback()
+}
+
+func (d *simdJSONDecoder) decodeFallback() (interface{}, error) {
+	var v interface{}
+	if err := d.fallback.Decode(&v); err != nil {
+		if err, ok := err.(*json.SyntaxError); ok {
+			err.Offset += d.fbBase
+		}
+		return nil, err
+	}
+	return v, nil
+}
+
+// fill discards the decoded values, reads the input and runs the first stage.
+// The indices in the last partial block are discarded and classified again
+// after reading, so that the values are decoded without waiting for the input
+// to fill the block.
+func (d *simdJSONDecoder) fill() error {
+	cut := d.end
+	if cut > d.scanned {
+		cut = d.scanned
+	}
+	d.indices = d.indices[:d.full]
+	k := 0
+	for k < len(d.indices) && d.indices[k] < cut {
+		k++
+	}
+	n := copy(d.indices, d.indices[k:])
+	d.indices = d.indices[:n]
+	for i := range d.indices {
+		d.indices[i] -= cut
+	}
+	d.full = n
+	if cut > 0 {
+		d.buf = d.buf[:copy(d.buf, d.buf[cut:])]
+		d.base += int64(cut)
+		d.scanned -= cut
+		d.end -= cut
+		if d.errFull -= cut; d.errFull < 0 {
+			d.errFull = -1
+		}
+	}
+	d.errPos = d.errFull
+	if len(d.buf) == cap(d.buf) {
+		buf := make([]byte, len(d.buf), cap(d.buf)*2)
+		copy(buf, d.buf)
+		d.buf = buf
+	}
+	n, err := d.r.Read(d.buf[len(d.buf):cap(d.buf)])
+	d.buf = d.buf[:len(d.buf)+n]
+	if err != nil {
+		if err != io.EOF {
+			return err
+		}
+		d.eof = true
+	}
+	for ; d.scanned+simdBlockSize <= len(d.buf); d.scanned += simdBlockSize {
+		d.scanBlock(d.buf[d.scanned:d.scanned+simdBlockSize], d.scanned, &d.state)
+	}
+	d.full, d.errFull = len(d.indices), d.errPos
+	if d.scanned < len(d.buf) {
+		var block [simdBlockSize]byte
+		copy(block[copy(block[:], d.buf[d.scanned:]):], bytes.Repeat([]byte{' '}, simdBlockSize))
+		state := d.state
+		d.scanBlock(block[:], d.scanned, &state)
+		for len(d.indices) > d.full && d.indices[len(d.indices)-1] >= len(d.buf) {
+			d.indices = d.indices[:len(d.indices)-1]
+		}
+	}
+	if d.eof {
+		d.indices = append(d.indices, len(d.buf)) // sentinel
+	}
+	for d.index = 0; d.index < len(d.indices) && d.indices[d.index] < d.end; d.index++ {
+	}
+	return nil
+}
+
+const (
+	simdLSB = 0x0101010101010101
+	simdLow = 0x7f7f7f7f7f7f7f7f
+)
+
+// simdEqual returns the mask of the bytes in the word equal to c.
+func simdEqual(w uint64, c byte) uint64 {
+	x := w ^ simdLSB*uint64(c)
+	return simdMoveMask(^((x&simdLow + simdLow) | x | simdLow))
+}
+
+// simdLess returns the mask of the bytes in the word less than c (c <= 0x80).
+func simdLess(w uint64, c byte) uint64 {
+	return simdMoveMask(^((w&simdLow + simdLSB*uint64(0x80-c)) | w) &^ simdLow)
+}
+
+// simdMoveMask gathers the highest bits of the bytes into the lowest 8 bits.
+func simdMoveMask(x uint64) uint64 {
+	return (x >> 7 & simdLSB) * 0x0102040810204080 >> 56
+}
+
+// scanBlock classifies the block of 64 bytes at the offset, and appends the
+// indices of the structural characters, the quotes and the starts of scalars.
+func (d *simdJSONDecoder) scanBlock(block []byte, offset int, state *simdState) {
+	var quote, backslash, op, space, control uint64
+	for i := 0; i < simdBlockSize; i += 8 {
+		w := binary.LittleEndian.Uint64(block[i:])
+		quote |= simdEqual(w, '"') << i
+		backslash |= simdEqual(w, '\\') << i
+		// {, }, [ and ] (and Y, _, y and DEL, which are invalid out of strings)
+		op |= (simdEqual(w|simdLSB*0x26, 0x7f) | simdEqual(w, ':') | simdEqual(w, ',')) << i
+		space |= (simdEqual(w, ' ') | simdEqual(w, '\t') |
+			simdEqual(w, '\n') | simdEqual(w, '\r')) << i
+		control |= simdLess(w, 0x20) << i
+	}
+	quote &^= simdEscaped(backslash, &state.escaped)
+	inString := simdPrefixXor(quote) ^ state.inString
+	state.inString = uint64(int64(inString) >> 63)
+	if x := control & inString; x != 0 && d.errPos < 0 {
+		d.errPos = offset + bits.TrailingZeros64(x)
+	}
+	scalar := ^(op | quote | space | inString)
+	starts := scalar &^ (scalar<<1 | state.scalar)
+	state.scalar = scalar >> 63
+	for x := op&^inString | quote | starts; x != 0; x &= x - 1 {
+		d.indices = append(d.indices, offset+bits.TrailingZeros64(x))
+	}
+}
+
+// simdEscaped returns the mask of the bytes escaped by the backslashes, where
+// escaped is whether the first byte is escaped by the previous block (ref:
+// find_escaped_branchless in simdjson).
+func simdEscaped(backslash uint64, escaped *uint64) uint64 {
+	const evenBits = 0x5555555555555555
+	backslash &^= *escaped
+	followsEscape := backslash<<1 | *escaped
+	oddStarts := backslash &^ evenBits &^ followsEscape
+	evenStarts, carry := bits.Add64(oddStarts, backslash, 0)
+	*escaped = carry
+	return (evenBits ^ evenStarts<<1) & followsEscape
+}
+
+// simdPrefixXor sets each bit to the parity of the bits up to the position, so
+// that the bits are set from the opening quotes to before the closing quotes.
+func simdPrefixXor(x uint64) uint64 {
+	x ^= x << 1
+	x ^= x << 2
+	x ^= x << 4
+	x ^= x << 8
+	x ^= x << 16
+	x ^= x << 32
+	return x
+}
+
+type simdStatus int
+
+const (
+	simdOK simdStatus = iota
+	simdInvalid
+	simdIncomplete
+)
+
+// parse builds the value starting at the k-th index, and returns the value,
+// the index after the value and the end position of the value.
+func (d *simdJSONDecoder) parse(k int) (interface{}, int, int, simdStatus) {
+	if k >= len(d.indices) {
+		return nil, 0, 0, simdIncomplete
+	}
+	p := d.indices[k]
+	if p >= len(d.buf) {
+		return nil, 0, 0, simdInvalid
+	}
+	switch d.buf[p] {
+	case '{':
+		m := make(map[string]interface{})
+		k++
+		if k < len(d.indices) && d.isByte(k, '}') {
+			return m, k + 1, d.indices[k] + 1, simdOK
+		}
+		for {
+			key, next, _, status := d.parseString(k)
+			if status != simdOK {
+				return nil, 0, 0, status
+			}
+			if k = next; k >= len(d.indices) {
+				return nil, 0, 0, simdIncomplete
+			} else if !d.isByte(k, ':') {
+				return nil, 0, 0, simdInvalid
+			}
+			v, next, _, status := d.parse(k + 1)
+			if status != simdOK {
+				return nil, 0, 0, status
+			}
+			m[key] = v
+			if k = next; k >= len(d.indices) {
+				return nil, 0, 0, simdIncomplete
+			} else if d.isByte(k, '}') {
+				return m, k + 1, d.indices[k] + 1, simdOK
+			} else if !d.isByte(k, ',') {
+				return nil, 0, 0, simdInvalid
+			}
+			k++
+		}
+	case '[':
+		vs := []interface{}{}
+		k++
+		if k < len(d.indices) && d.isByte(k, ']') {
+			return vs, k + 1, d.indices[k] + 1, simdOK
+		}
+		for {
+			v, next, _, status := d.parse(k)
+			if status != simdOK {
+				return nil, 0, 0, status
+			}
+			vs = append(vs, v)
+			if k = next; k >= len(d.indices) {
+				return nil, 0, 0, simdIncomplete
+			} else if d.isByte(k, ']') {
+				return vs, k + 1, d.indices[k] + 1, simdOK
+			} else if !d.isByte(k, ',') {
+				return nil, 0, 0, simdInvalid
+			}
+			k++
+		}
+	case '"':
+		s, next, end, status := d.parseString(k)
+		return s, next, end, status
+	case '}', ']', ':', ',':
+		return nil, 0, 0, simdInvalid
+	default:
+		if k+1 >= len(d.indices) {
+			return nil, 0, 0, simdIncomplete
+		}
+		end := d.indices[k+1]
+		for end > p && isJSONSpace(d.buf[end-1]) {
+			end--
+		}
+		s := d.buf[p:end]
+		switch {
+		case string(s) == "null":
+			return nil, k + 1, end, simdOK
+		case string(s) == "true":
+			return true, k + 1, end, simdOK
+		case string(s) == "false":
+			return false, k + 1, end, simdOK
+		case isJSONNumber(s):
+			return json.Number(s), k + 1, end, simdOK
+		default:
+			return nil, 0, 0, simdInvalid
+		}
+	}
+}
+
+func (d *simdJSONDecoder) isByte(k int, c byte) bool {
+	p := d.indices[k]
+	return p < len(d.buf) && d.buf[p] == c
+}
+
+// parseString parses the string from the opening quote at the k-th index to
+// the closing quote at the next index.
+func (d *simdJSONDecoder) parseString(k int) (string, int, int, simdStatus) {
+	if k+1 >= len(d.indices) {
+		return "", 0, 0, simdIncomplete
+	}
+	if !d.isByte(k, '"') || !d.isByte(k+1, '"') {
+		return "", 0, 0, simdInvalid
+	}
+	p, q := d.indices[k], d.indices[k+1]
+	if p < d.errPos && d.errPos < q {
+		return "", 0, 0, simdInvalid
+	}
+	s := d.buf[p+1 : q]
+	if bytes.IndexByte(s, '\\') < 0 && utf8.Valid(s) {
+		return string(s), k + 2, q + 1, simdOK
+	}
+	t, ok := unquoteJSONString(s)
+	if !ok {
+		return "", 0, 0, simdInvalid
+	}
+	return t, k + 2, q + 1, simdOK
+}
+
+// unquoteJSONString decodes the escape sequences, and replaces the invalid
+// UTF-8 sequences and the lone surrogates with U+FFFD like encoding/json.
+func unquoteJSONString(s []byte) (string, bool) {
+	bs := make([]byte, 0, len(s))
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c != '\\' {
+			if c < utf8.RuneSelf {
+				bs = append(bs, c)
+				i++
+				continue
+			}
+			r, size := utf8.DecodeRune(s[i:])
+			bs = append(bs, s[i:i+size]...)
+			if r == utf8.RuneError && size == 1 {
+				bs = append(bs[:len(bs)-1], string(unicode.ReplacementChar)...)
+			}
+			i += size
+			continue
+		}
+		if i++; i >= len(s) {
+			return "", false
+		}
+		switch c = s[i]; c {
+		case '"', '\\', '/':
+			bs = append(bs, c)
+		case 'b':
+			bs = append(bs, '\b')
+		case 'f':
+			bs = append(bs, '\f')
+		case 'n':
+			bs = append(bs, '\n')
+		case 'r':
+			bs = append(bs, '\r')
+		case 't':
+			bs = append(bs, '\t')
+		case 'u':
+			r := getHex4(s[i+1:])
+			if r < 0 {
+				return "", false
+			}
+			i += 4
+			if utf16.IsSurrogate(r) {
+				if i+2 < len(s) && s[i+1] == '\\' && s[i+2] == 'u' {
+					if r2 := getHex4(s[i+3:]); r2 < 0 {
+						return "", false
+					} else if x := utf16.DecodeRune(r, r2); x != unicode.ReplacementChar {
+						r, i = x, i+6
+					} else {
+						r = unicode.ReplacementChar
+					}
+				} else {
+					r = unicode.ReplacementChar
+				}
+			}
+			bs = append(bs, string(r)...)
+		default:
+			return "", false
+		}
+		i++
+	}
+	return string(bs), true
+}
+
+func getHex4(s []byte) rune {
+	if len(s) < 4 {
+		return -1
+	}
+	var r rune
+	for _, c := range s[:4] {
+		switch {
+		case '0' <= c && c <= '9':
+			c -= '0'
+		case 'a' <= c && c <= 'f':
+			c -= 'a' - 10
+		case 'A' <= c && c <= 'F':
+			c -= 'A' - 10
+		default:
+			return -1
+		}
+		r = r*16 + rune(c)
+	}
+	return r
+}
+
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// isJSONNumber reports whether the bytes are a number in the JSON grammar.
+func isJSONNumber(s []byte) bool {
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	if i < len(s) && s[i] == '0' {
+		i++
+	} else if j := skipDigits(s, i); j > i {
+		i = j
+	} else {
+		return false
+	}
+	if i < len(s) && s[i] == '.' {
+		if j := skipDigits(s, i+1); j > i+1 {
+			i = j
+		} else {
+			return false
+		}
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		if j := skipDigits(s, i); j > i {
+			i = j
+		} else {
+			return false
+		}
+	}
+	return i == len(s)
+}
+
+func skipDigits(s []byte, i int) int {
+	for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+		i++
+	}
+	return i
+}
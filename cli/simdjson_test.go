@@ -0,0 +1,196 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func decodeAllStandard(input string) ([]interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(input))
+	dec.UseNumber()
+	var vs []interface{}
+	for {
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return vs, err
+		}
+		vs = append(vs, v)
+	}
+}
+
+func decodeAllSIMD(r io.Reader) ([]interface{}, error) {
+	dec := newSIMDJSONDecoder(newLineReader(r))
+	var vs []interface{}
+	for {
+		v, err := dec.Decode()
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return vs, err
+		}
+		vs = append(vs, v)
+	}
+}
+
+func testSIMDJSONDecoder(t *testing.T, input string) {
+	t.Helper()
+	expected, expectedErr := decodeAllStandard(input)
+	for i, r := range []io.Reader{
+		strings.NewReader(input),
+		iotest.OneByteReader(strings.NewReader(input)),
+		iotest.HalfReader(strings.NewReader(input)),
+	} {
+		got, err := decodeAllSIMD(r)
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("input: %q\n     got: %#v\nexpected: %#v", input, got, expected)
+		}
+		// encoding/json reports the invalid multi-byte character differently
+		// depending on the chunks read from the reader
+		if i > 0 {
+			if reflect.TypeOf(err) != reflect.TypeOf(expectedErr) {
+				t.Fatalf("input: %q\n     got error: %#v\nexpected error: %#v", input, err, expectedErr)
+			}
+		} else if fmt.Sprintf("%#v", err) != fmt.Sprintf("%#v", expectedErr) {
+			t.Fatalf("input: %q\n     got error: %#v\nexpected error: %#v", input, err, expectedErr)
+		}
+	}
+}
+
+func TestSIMDJSONDecoder(t *testing.T) {
+	testCases := []string{
+		``,
+		` `,
+		`null`,
+		`true false null`,
+		`0 -1 1.5 -0.25e+10 1E-3 12345678901234567890`,
+		`{} [] "" {"a":1} [1,[2,[3]]]`,
+		`{"a": {"b": [1, "c", {"d": null}]}, "e": true}`,
+		` { "a" : 1 , "b" : [ 1 , 2 ] } ` + "\n\t\r",
+		`{"a":1}{"b":2}[3]"x"4`,
+		`"\"\\\/\b\f\n\r\tAéあ😀"`,
+		`"\ud800" "\udc00" "\ud800A" "\ud800\ud800"`,
+		`"\\" "\\\\" "a\\\"b" "\\\\\""`,
+		"\"\xff\xfe\" \"a\xc3\" \"\xe3\x81\"",
+		"\"あいう\" {\"キー\":\"値\"}",
+		`truefalse 01 1true "a"1 1"a" [1]2`,
+		`tru`,
+		`truex`,
+		`nul`,
+		`-`,
+		`1.`,
+		`.5`,
+		`1e`,
+		`+1`,
+		`[1,]`,
+		`[1 2]`,
+		`{"a" 1}`,
+		`{"a":1,}`,
+		`{1:2}`,
+		`{"a":1`,
+		`[1, 2`,
+		`"abc`,
+		`"\x"`,
+		`"\'"`,
+		`"\u12"`,
+		"\"a\x01b\"",
+		"\"a\tb\"",
+		"1 \x00",
+		`}`,
+		`]`,
+		`:`,
+		`,`,
+		`Y _ y`,
+		`[Y]`,
+		`{"a":[{"b":"` + strings.Repeat("x", 100) + `"}]}`,
+		strings.Repeat(`{"key":"value","number":12345,"array":[1,2,3]}`+"\n", 100),
+		strings.Repeat(`"`+strings.Repeat(`\\`, 40)+`"`, 5),
+		strings.Repeat(`"`+strings.Repeat(`\"`, 40)+`"`, 5),
+		strings.Repeat("[", 200) + strings.Repeat("]", 200),
+		strings.Repeat(" ", 200) + `1` + strings.Repeat(" ", 200),
+		strings.Repeat("1 ", 100) + "x",
+	}
+	for _, input := range testCases {
+		testSIMDJSONDecoder(t, input)
+	}
+}
+
+func TestSIMDJSONDecoderRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	pieces := []string{
+		`{`, `}`, `[`, `]`, `:`, `,`, ` `, "\n", `"`, `\`, `\"`, `\\`, `é`,
+		`"a"`, `"b\n"`, `1`, `-0.5e3`, `true`, `null`, `false`, `x`, "\x01", "\xff",
+		`{"a":1}`, `[1,2]`, `"` + strings.Repeat("s", 70) + `"`,
+	}
+	for i := 0; i < 2000; i++ {
+		var sb strings.Builder
+		for j := rnd.Intn(40); j >= 0; j-- {
+			sb.WriteString(pieces[rnd.Intn(len(pieces))])
+		}
+		testSIMDJSONDecoder(t, sb.String())
+	}
+}
+
+func TestSIMDJSONDecoderLineNumber(t *testing.T) {
+	input := "1\n{\"a\":\n2}\n\n[\n3]\n" + strings.Repeat(" ", 100) + "\n\"x\""
+	dec := newSIMDJSONDecoder(newLineReader(iotest.OneByteReader(strings.NewReader(input))))
+	var got []int
+	for {
+		if _, err := dec.Decode(); err != nil {
+			break
+		}
+		got = append(got, dec.lineNumber())
+	}
+	if expected := []int{1, 3, 6, 8}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("got: %v, expected: %v", got, expected)
+	}
+}
+
+func benchmarkInput() []byte {
+	var buf bytes.Buffer
+	for i := 0; buf.Len() < 16*1024*1024; i++ {
+		fmt.Fprintf(&buf, `{"id":%d,"name":"user %d","tags":["a","b","c"],`+
+			`"score":%d.5,"active":true,"note":null}`+"\n", i, i, i%100)
+	}
+	return buf.Bytes()
+}
+
+func BenchmarkJSONDecoder(b *testing.B) {
+	input := benchmarkInput()
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dec := json.NewDecoder(bytes.NewReader(input))
+		dec.UseNumber()
+		for {
+			var v interface{}
+			if err := dec.Decode(&v); err != nil {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkSIMDJSONDecoder(b *testing.B) {
+	input := benchmarkInput()
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dec := newSIMDJSONDecoder(newLineReader(bytes.NewReader(input)))
+		for {
+			if _, err := dec.Decode(); err != nil {
+				break
+			}
+		}
+	}
+}
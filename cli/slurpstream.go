@@ -0,0 +1,278 @@
+package cli
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/itchyny/gojq"
+)
+
+// slurpStreamBufferSize is the number of the values kept in the memory by
+// --slurp-stream. The values beyond this are spilled to a temporary file.
+var slurpStreamBufferSize = 4096
+
+// slurpStreamInputIter emits the array of the inputs for --slurp-stream, and
+// then the error raised on reading the inputs into the array.
+type slurpStreamInputIter struct {
+	value   *slurpStreamValue
+	emitted bool
+	err     error
+}
+
+func newSlurpStreamInputIter(
+	iter inputIter, newIter func(io.Reader, string) inputIter,
+) inputIter {
+	return &slurpStreamInputIter{value: &slurpStreamValue{iter: iter, newIter: newIter}}
+}
+
+func (i *slurpStreamInputIter) Next() (interface{}, bool) {
+	if i.err != nil {
+		return nil, false
+	}
+	if !i.emitted {
+		i.emitted = true
+		return i.value, true
+	}
+	i.err = io.EOF
+	if err := i.value.err; err != nil {
+		return err, true
+	}
+	return nil, false
+}
+
+func (i *slurpStreamInputIter) lineNumber() int {
+	return inputLineNumber(i.value.iter)
+}
+
+func (i *slurpStreamInputIter) Close() error {
+	i.err = io.EOF
+	return i.value.Close()
+}
+
+// slurpStreamValue is the array of the inputs, which reads the inputs on
+// iterating the array (.[] and add) instead of reading all of them in advance.
+// The values are kept in the memory up to slurpStreamBufferSize, and spilled
+// to a temporary file beyond that, so that the aggregations like reduce .[] as
+// $x (...) run in bounded memory, while the array can be iterated again or
+// converted to []interface{} (by sort_by and others) with the same values.
+type slurpStreamValue struct {
+	iter    inputIter
+	newIter func(io.Reader, string) inputIter // decodes the spilled values
+	values  []interface{}                     // the values after the spilled ones
+	all     []interface{}                     // all the values read into the array
+	file    *os.File
+	readers []*os.File
+	spilled int
+	done    bool
+	err     error
+}
+
+// read reads the next input. This method returns io.EOF after the last input.
+func (v *slurpStreamValue) read() error {
+	if v.done {
+		return io.EOF
+	}
+	x, ok := v.iter.Next()
+	if !ok {
+		v.done = true
+		return io.EOF
+	}
+	if err, ok := x.(error); ok {
+		v.done = true
+		return err
+	}
+	if len(v.values) >= slurpStreamBufferSize {
+		if err := v.spill(); err != nil {
+			v.done = true
+			return err
+		}
+	}
+	v.values = append(v.values, x)
+	return nil
+}
+
+// spill writes the values in the memory to the temporary file.
+func (v *slurpStreamValue) spill() error {
+	if v.file == nil {
+		f, err := ioutil.TempFile("", "gojq-slurp-stream-")
+		if err != nil {
+			return err
+		}
+		v.file = f
+	}
+	var bs []byte
+	for _, x := range v.values {
+		s, err := gojq.Marshal(x)
+		if err != nil {
+			return err
+		}
+		bs = append(append(bs, s...), '\n')
+	}
+	if _, err := v.file.Write(bs); err != nil {
+		return err
+	}
+	v.spilled += len(v.values)
+	v.values = nil
+	return nil
+}
+
+// readAll reads all the inputs into an array. The error on reading the inputs
+// is reported after running the query.
+func (v *slurpStreamValue) readAll() []interface{} {
+	if v.all != nil {
+		return v.all
+	}
+	for {
+		if err := v.read(); err != nil {
+			if err != io.EOF && v.err == nil {
+				v.err = err
+			}
+			break
+		}
+	}
+	xs := make([]interface{}, 0, v.spilled+len(v.values))
+	iter := &slurpStreamIter{value: v}
+	defer iter.Close()
+	for len(xs) < v.spilled {
+		x, _ := iter.Next()
+		if err, ok := x.(error); ok {
+			if v.err == nil {
+				v.err = err
+			}
+			return xs
+		}
+		xs = append(xs, x)
+	}
+	v.all = append(xs, v.values...)
+	return v.all
+}
+
+// Close closes the inputs and removes the temporary file.
+func (v *slurpStreamValue) Close() error {
+	v.iter.Close()
+	v.done = true
+	if v.file == nil {
+		return nil
+	}
+	for _, f := range v.readers {
+		f.Close()
+	}
+	v.file.Close()
+	err := os.Remove(v.file.Name())
+	v.file, v.readers = nil, nil
+	return err
+}
+
+// JQValueType implements gojq.JQValue.
+func (v *slurpStreamValue) JQValueType() string {
+	return "array"
+}
+
+// JQValueLength implements gojq.JQValue.
+func (v *slurpStreamValue) JQValueLength() int {
+	return len(v.readAll())
+}
+
+// JQValueIndex implements gojq.JQValue.
+func (v *slurpStreamValue) JQValueIndex(key interface{}) interface{} {
+	if i, ok := key.(int); ok {
+		if xs := v.readAll(); 0 <= i && i < len(xs) {
+			return xs[i]
+		}
+	}
+	return nil
+}
+
+// JQValueKeys implements gojq.JQValue.
+func (v *slurpStreamValue) JQValueKeys() []interface{} {
+	ks := make([]interface{}, len(v.readAll()))
+	for i := range ks {
+		ks[i] = i
+	}
+	return ks
+}
+
+// JQValueToJSON implements gojq.JQValue.
+func (v *slurpStreamValue) JQValueToJSON() interface{} {
+	return v.readAll()
+}
+
+// JQValueIter implements the lazy iteration of gojq.JQValue.
+func (v *slurpStreamValue) JQValueIter() gojq.Iter {
+	return &slurpStreamIter{value: v}
+}
+
+// slurpStreamIter emits the values of the array from the first one, reading
+// the spilled values from the temporary file and the inputs on demand.
+type slurpStreamIter struct {
+	value  *slurpStreamValue
+	index  int
+	file   *os.File
+	iter   inputIter
+	offset int // index of the value which iter emits next
+}
+
+func (i *slurpStreamIter) Next() (interface{}, bool) {
+	v := i.value
+	if v.all != nil {
+		if i.index < len(v.all) {
+			i.index++
+			return v.all[i.index-1], true
+		}
+		return nil, false
+	}
+	if i.index == v.spilled+len(v.values) {
+		if err := v.read(); err != nil {
+			if err == io.EOF {
+				i.Close()
+				return nil, false
+			}
+			return err, true
+		}
+	}
+	if i.index < v.spilled {
+		return i.readSpilled()
+	}
+	x := v.values[i.index-v.spilled]
+	i.index++
+	return x, true
+}
+
+// readSpilled reads the spilled value at the index. The file is opened again
+// when the decoder has reached the end of the file before spilling the value.
+func (i *slurpStreamIter) readSpilled() (interface{}, bool) {
+	for retry := true; ; retry = false {
+		if i.iter == nil {
+			f, err := os.Open(i.value.file.Name())
+			if err != nil {
+				return err, true
+			}
+			i.file, i.iter, i.offset = f, i.value.newIter(f, f.Name()), 0
+			i.value.readers = append(i.value.readers, f)
+		}
+		for i.offset <= i.index {
+			x, ok := i.iter.Next()
+			if _, isErr := x.(error); !ok || isErr {
+				break
+			}
+			if i.offset++; i.offset > i.index {
+				i.index++
+				return x, true
+			}
+		}
+		i.Close()
+		if !retry {
+			return io.ErrUnexpectedEOF, true
+		}
+	}
+}
+
+func (i *slurpStreamIter) Close() error {
+	if i.file != nil {
+		i.iter.Close()
+		i.file.Close()
+		i.file, i.iter = nil, nil
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+//go:build go1.16
+// +build go1.16
+
+package cli
+
+import (
+	"io/fs"
+
+	"github.com/itchyny/gojq"
+)
+
+// Stdlib is the file system of the modules bundled with the command. These
+// modules are looked up after the module search paths. Set this variable
+// before calling Run to ship .jq modules embedded by go:embed.
+var Stdlib fs.FS
+
+func stdlibModuleLoader() gojq.ModuleLoader {
+	if Stdlib == nil {
+		return nil
+	}
+	return gojq.NewModuleLoaderFS(Stdlib, []string{"."})
+}
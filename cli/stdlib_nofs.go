@@ -0,0 +1,11 @@
+//go:build !go1.16
+// +build !go1.16
+
+package cli
+
+import "github.com/itchyny/gojq"
+
+// The bundled modules require io/fs, which is available since Go 1.16.
+func stdlibModuleLoader() gojq.ModuleLoader {
+	return nil
+}
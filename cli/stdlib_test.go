@@ -0,0 +1,37 @@
+//go:build go1.16
+// +build go1.16
+
+package cli
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCliRun_Stdlib(t *testing.T) {
+	defer func(fsys fs.FS) { Stdlib = fsys }(Stdlib)
+	Stdlib = fstest.MapFS{
+		"std.jq":   {Data: []byte(`def double: . * 2;`)},
+		"std.json": {Data: []byte(`{"x": 1}`)},
+	}
+	var outStream, errStream strings.Builder
+	cli := cli{
+		inStream:  strings.NewReader("21"),
+		outStream: &outStream,
+		errStream: &errStream,
+	}
+	code := cli.run([]string{"-c", "-L", "testdata", `import "std" as std; import "std" as $std; std::double, $std`})
+	if code != exitCodeOK {
+		t.Errorf("exit code: got: %v, expected: %v", code, exitCodeOK)
+	}
+	if diff := cmp.Diff("42\n[{\"x\":1}]\n", outStream.String()); diff != "" {
+		t.Error("standard output:\n" + diff)
+	}
+	if diff := cmp.Diff("", errStream.String()); diff != "" {
+		t.Error("standard error output:\n" + diff)
+	}
+}
@@ -6,6 +6,7 @@ type jsonStream struct {
 	dec    *json.Decoder
 	path   []interface{}
 	states []int
+	depth  int // the depth of the values emitted as they are (0: unlimited)
 }
 
 func newJSONStream(dec *json.Decoder) *jsonStream {
@@ -42,6 +43,14 @@ func (s *jsonStream) next() (interface{}, error) {
 		}
 	}
 	for {
+		if s.depth > 0 && len(s.path) >= s.depth {
+			switch s.states[len(s.states)-1] {
+			case jsonStateArrayStart, jsonStateArrayValue, jsonStateObjectKey:
+				if s.dec.More() {
+					return s.decodeValue()
+				}
+			}
+		}
 		token, err := s.dec.Token()
 		if err != nil {
 			return nil, err
@@ -65,17 +74,17 @@ func (s *jsonStream) next() (interface{}, error) {
 				if s.states[len(s.states)-1] == jsonStateArrayStart {
 					s.states[len(s.states)-1] = jsonStateArrayEmptyEnd
 					s.path = s.path[:len(s.path)-1]
-					return []interface{}{s.path, []interface{}{}}, nil
+					return []interface{}{s.copyPath(), []interface{}{}}, nil
 				}
 				s.states[len(s.states)-1] = jsonStateArrayEnd
-				return []interface{}{s.path}, nil
+				return []interface{}{s.copyPath()}, nil
 			case '}':
 				if s.states[len(s.states)-1] == jsonStateObjectStart {
 					s.states[len(s.states)-1] = jsonStateObjectEmptyEnd
-					return []interface{}{s.path, map[string]interface{}{}}, nil
+					return []interface{}{s.copyPath(), map[string]interface{}{}}, nil
 				}
 				s.states[len(s.states)-1] = jsonStateObjectEnd
-				return []interface{}{s.path}, nil
+				return []interface{}{s.copyPath()}, nil
 			default:
 				panic(d)
 			}
@@ -85,17 +94,41 @@ func (s *jsonStream) next() (interface{}, error) {
 				s.states[len(s.states)-1] = jsonStateArrayValue
 				fallthrough
 			case jsonStateArrayValue:
-				return []interface{}{s.path, token}, nil
+				return []interface{}{s.copyPath(), token}, nil
 			case jsonStateObjectStart, jsonStateObjectValue:
 				s.states[len(s.states)-1] = jsonStateObjectKey
 				s.path = append(s.path, token)
 			case jsonStateObjectKey:
 				s.states[len(s.states)-1] = jsonStateObjectValue
-				return []interface{}{s.path, token}, nil
+				return []interface{}{s.copyPath(), token}, nil
 			default:
 				s.states[len(s.states)-1] = jsonStateTopValue
-				return []interface{}{s.path, token}, nil
+				return []interface{}{s.copyPath(), token}, nil
 			}
 		}
 	}
 }
+
+// decodeValue decodes the value at the depth limit as a whole, instead of
+// emitting the events of the elements.
+func (s *jsonStream) decodeValue() (interface{}, error) {
+	var v interface{}
+	if err := s.dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	switch s.states[len(s.states)-1] {
+	case jsonStateArrayStart:
+		s.states[len(s.states)-1] = jsonStateArrayValue
+	case jsonStateObjectKey:
+		s.states[len(s.states)-1] = jsonStateObjectValue
+	}
+	return []interface{}{s.copyPath(), v}, nil
+}
+
+// copyPath returns the copy of the path, which is updated in place by the
+// following tokens.
+func (s *jsonStream) copyPath() []interface{} {
+	path := make([]interface{}, len(s.path))
+	copy(path, s.path)
+	return path
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// jsonInputError is the error of the JSON input rejected by --strict option or
+// --invalid-utf8=error option. The offset is counted in the same way as
+// json.SyntaxError.
+type jsonInputError struct {
+	msg    string
+	offset int64
+}
+
+func (err *jsonInputError) Error() string {
+	return err.msg
+}
+
+// checkStrictJSON checks the duplicate keys of the objects and the surrogates
+// of the strings not paired in the valid JSON value.
+func checkStrictJSON(raw []byte) *jsonInputError {
+	var objects []map[string]struct{} // nil for arrays
+	var expectKey bool
+	for i := 0; i < len(raw); i++ {
+		switch raw[i] {
+		case '{':
+			objects = append(objects, map[string]struct{}{})
+			expectKey = true
+		case '[':
+			objects = append(objects, nil)
+		case '}', ']':
+			objects = objects[:len(objects)-1]
+		case ',':
+			expectKey = objects[len(objects)-1] != nil
+		case '"':
+			j, err := scanStrictString(raw, i)
+			if err != nil {
+				return err
+			}
+			if expectKey {
+				var key string
+				json.Unmarshal(raw[i:j], &key)
+				keys := objects[len(objects)-1]
+				if _, ok := keys[key]; ok {
+					return &jsonInputError{
+						"duplicate key in object: " + strconv.Quote(key), int64(i + 1)}
+				}
+				keys[key] = struct{}{}
+				expectKey = false
+			}
+			i = j - 1
+		}
+	}
+	return nil
+}
+
+// scanStrictString returns the index after the string starting at i, or the
+// error of the surrogate not paired.
+func scanStrictString(raw []byte, i int) (int, *jsonInputError) {
+	var high bool // the last escape is a high surrogate
+	for j := i + 1; j < len(raw); j++ {
+		switch raw[j] {
+		case '"':
+			if high {
+				return 0, loneSurrogateError(raw, j-6)
+			}
+			return j + 1, nil
+		case '\\':
+			j++
+			if raw[j] != 'u' {
+				if high {
+					return 0, loneSurrogateError(raw, j-7)
+				}
+				continue
+			}
+			r, _ := strconv.ParseUint(string(raw[j+1:j+5]), 16, 16)
+			switch {
+			case 0xd800 <= r && r < 0xdc00:
+				if high {
+					return 0, loneSurrogateError(raw, j-7)
+				}
+				high = true
+			case 0xdc00 <= r && r < 0xe000:
+				if !high {
+					return 0, loneSurrogateError(raw, j-1)
+				}
+				high = false
+			default:
+				if high {
+					return 0, loneSurrogateError(raw, j-7)
+				}
+			}
+			j += 4
+		default:
+			if high {
+				return 0, loneSurrogateError(raw, j-6)
+			}
+		}
+	}
+	return len(raw), nil
+}
+
+// loneSurrogateError creates the error of the surrogate escape at i.
+func loneSurrogateError(raw []byte, i int) *jsonInputError {
+	return &jsonInputError{"lone surrogate in string: " + string(raw[i:i+6]), int64(i + 1)}
+}
+
+// quoteChar formats the character as encoding/json does in the error messages.
+func quoteChar(c byte) string {
+	if c == '\'' {
+		return `'\''`
+	}
+	if c == '"' {
+		return `'"'`
+	}
+	s := strconv.Quote(string(c))
+	return "'" + s[1:len(s)-1] + "'"
+}
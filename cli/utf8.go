@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/itchyny/gojq"
+)
+
+// The handlings of invalid UTF-8 sequences in the inputs by --invalid-utf8.
+const (
+	invalidUTF8Replace = "replace"
+	invalidUTF8Error   = "error"
+	invalidUTF8Binary  = "binary"
+)
+
+// invalidUTF8Index returns the index of the first invalid UTF-8 sequence.
+func invalidUTF8Index(bs []byte) int {
+	for i := 0; i < len(bs); {
+		if bs[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(bs[i:])
+		if r == utf8.RuneError && size == 1 {
+			return i
+		}
+		i += size
+	}
+	return -1
+}
+
+// replaceInvalidUTF8 replaces each byte of invalid UTF-8 sequences with U+FFFD,
+// in the same way as the JSON decoder does.
+func replaceInvalidUTF8(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s) + 2)
+	for _, r := range s {
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// decodeBinaryStrings decodes the JSON value, keeping the strings of invalid
+// UTF-8 sequences as byte strings. The object keys are replaced with U+FFFD.
+func decodeBinaryStrings(raw []byte, ordered bool) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	d := &binaryStringDecoder{dec, raw, ordered}
+	tok, offset, err := d.token()
+	if err != nil {
+		return nil, err
+	}
+	return d.value(tok, offset)
+}
+
+type binaryStringDecoder struct {
+	dec     *json.Decoder
+	raw     []byte
+	ordered bool
+}
+
+// token returns the next token and the offset before reading the token.
+func (d *binaryStringDecoder) token() (json.Token, int64, error) {
+	offset := d.dec.InputOffset()
+	tok, err := d.dec.Token()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return tok, offset, err
+}
+
+func (d *binaryStringDecoder) value(tok json.Token, offset int64) (interface{}, error) {
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '[' {
+			vs := []interface{}{}
+			for {
+				tok, offset, err := d.token()
+				if err != nil {
+					return nil, err
+				}
+				if tok == json.Delim(']') {
+					return vs, nil
+				}
+				v, err := d.value(tok, offset)
+				if err != nil {
+					return nil, err
+				}
+				vs = append(vs, v)
+			}
+		}
+		var keys []string
+		m := map[string]interface{}{}
+		for {
+			tok, _, err := d.token()
+			if err != nil {
+				return nil, err
+			}
+			if tok == json.Delim('}') {
+				if d.ordered {
+					om := gojq.NewOrderedMap()
+					for _, k := range keys {
+						om.Set(k, m[k])
+					}
+					return om, nil
+				}
+				return m, nil
+			}
+			key := tok.(string)
+			tok, offset, err := d.token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := d.value(tok, offset)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := m[key]; !ok {
+				keys = append(keys, key)
+			}
+			m[key] = v
+		}
+	case string:
+		s := d.raw[offset:d.dec.InputOffset()]
+		s = s[bytes.IndexByte(s, '"'):]
+		if utf8.Valid(s) {
+			return tok, nil
+		}
+		return unquoteBytes(s), nil
+	default:
+		return tok, nil
+	}
+}
+
+// unquoteBytes unquotes the JSON string literal, keeping the bytes of invalid
+// UTF-8 sequences as they are.
+func unquoteBytes(s []byte) []byte {
+	bs := make([]byte, 0, len(s))
+	for i := 1; i < len(s)-1; i++ {
+		if s[i] != '\\' {
+			bs = append(bs, s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'b':
+			bs = append(bs, '\b')
+		case 'f':
+			bs = append(bs, '\f')
+		case 'n':
+			bs = append(bs, '\n')
+		case 'r':
+			bs = append(bs, '\r')
+		case 't':
+			bs = append(bs, '\t')
+		case 'u':
+			r1, _ := strconv.ParseUint(string(s[i+1:i+5]), 16, 16)
+			r := rune(r1)
+			i += 4
+			if utf16.IsSurrogate(r) {
+				var r2 uint64
+				if i+6 < len(s) && s[i+1] == '\\' && s[i+2] == 'u' {
+					r2, _ = strconv.ParseUint(string(s[i+3:i+7]), 16, 16)
+				}
+				if r = utf16.DecodeRune(r, rune(r2)); r != utf8.RuneError {
+					i += 6
+				}
+			}
+			var buf [utf8.UTFMax]byte
+			bs = append(bs, buf[:utf8.EncodeRune(buf[:], r)]...)
+		default:
+			bs = append(bs, s[i])
+		}
+	}
+	return bs
+}
+
+// invalidUTF8Message returns the error message of the invalid UTF-8 byte.
+func invalidUTF8Message(c byte, offset int64) string {
+	return fmt.Sprintf("invalid UTF-8 byte %#x at byte offset %d", c, offset)
+}
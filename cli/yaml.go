@@ -2,38 +2,724 @@ package cli
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
 	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/itchyny/gojq"
 )
 
-// Workaround for https://github.com/go-yaml/yaml/issues/139
 func normalizeYAML(v interface{}) interface{} {
+	return normalizeYAMLWith(v, false)
+}
+
+// Workaround for https://github.com/go-yaml/yaml/issues/139
+// When datetime is true, this function keeps the timestamps as time.Time.
+func normalizeYAMLWith(v interface{}, datetime bool) interface{} {
 	switch v := v.(type) {
 	case map[interface{}]interface{}:
 		w := make(map[string]interface{}, len(v))
 		for k, v := range v {
-			w[fmt.Sprint(k)] = normalizeYAML(v)
+			w[fmt.Sprint(k)] = normalizeYAMLWith(v, datetime)
 		}
 		return w
 
 	case map[string]interface{}:
 		w := make(map[string]interface{}, len(v))
 		for k, v := range v {
-			w[k] = normalizeYAML(v)
+			w[k] = normalizeYAMLWith(v, datetime)
 		}
 		return w
 
 	case []interface{}:
 		for i, w := range v {
-			v[i] = normalizeYAML(w)
+			v[i] = normalizeYAMLWith(w, datetime)
 		}
 		return v
 
-	// go-yaml unmarshals timestamp string to time.Time but gojq cannot handle it.
-	// It is impossible to keep the original timestamp strings.
+	// go-yaml unmarshals timestamp string to time.Time but gojq cannot handle it
+	// unless the datetime mode is enabled. It is impossible to keep the original
+	// timestamp strings.
 	case time.Time:
+		if datetime {
+			return v
+		}
 		return v.Format(time.RFC3339)
 
 	default:
 		return v
 	}
 }
+
+// yamlNodeDecoder converts the YAML nodes to values, in the same manner as
+// normalizeYAMLWith. The merge keys (<<) are resolved so that the keys of the
+// mapping take precedence over the merged ones, and the aliases are handled
+// by the aliases mode; expand, error or preserve.
+type yamlNodeDecoder struct {
+	ordered  bool
+	datetime bool
+	aliases  string
+	limit    int // maximum number of values expanded from aliases (0: unlimited)
+	count    int
+	alias    *yaml.Node // the outermost alias being expanded
+}
+
+const (
+	yamlAliasesExpand   = "expand"
+	yamlAliasesError    = "error"
+	yamlAliasesPreserve = "preserve"
+)
+
+func (d *yamlNodeDecoder) decode(n *yaml.Node) (interface{}, error) {
+	d.count, d.alias = 0, nil
+	return d.value(n)
+}
+
+func (d *yamlNodeDecoder) value(n *yaml.Node) (interface{}, error) {
+	if d.alias != nil {
+		if d.count++; d.limit > 0 && d.count > d.limit {
+			return nil, fmt.Errorf("yaml: line %d: alias *%s expands to more than %d values",
+				d.alias.Line, d.alias.Value, d.limit)
+		}
+	}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+		return d.value(n.Content[0])
+	case yaml.SequenceNode:
+		vs := make([]interface{}, len(n.Content))
+		for i, n := range n.Content {
+			v, err := d.value(n)
+			if err != nil {
+				return nil, err
+			}
+			vs[i] = v
+		}
+		return vs, nil
+	case yaml.MappingNode:
+		return d.mapping(n)
+	case yaml.AliasNode:
+		switch d.aliases {
+		case yamlAliasesError:
+			return nil, fmt.Errorf("yaml: line %d: alias *%s is not allowed", n.Line, n.Value)
+		case yamlAliasesPreserve:
+			return map[string]interface{}{"$alias": n.Value}, nil
+		}
+		if d.alias != nil {
+			return d.value(n.Alias)
+		}
+		d.alias = n
+		defer func() { d.alias = nil }()
+		return d.value(n.Alias)
+	default:
+		var v interface{}
+		if err := n.Decode(&v); err != nil {
+			return nil, err
+		}
+		return normalizeYAMLWith(v, d.datetime), nil
+	}
+}
+
+func (d *yamlNodeDecoder) mapping(n *yaml.Node) (interface{}, error) {
+	m := gojq.NewOrderedMap()
+	lines := make(map[string]int, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		if k.Tag == "!!merge" && d.aliases != yamlAliasesPreserve {
+			if err := d.merge(m, v); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		key, err := d.key(k)
+		if err != nil {
+			return nil, err
+		}
+		if line, ok := lines[key]; ok {
+			return nil, fmt.Errorf("yaml: line %d: mapping key %q already defined at line %d",
+				k.Line, key, line)
+		}
+		lines[key] = k.Line
+		w, err := d.value(v)
+		if err != nil {
+			return nil, err
+		}
+		m.Set(key, w)
+	}
+	if d.ordered {
+		return m, nil
+	}
+	w := make(map[string]interface{}, m.Len())
+	for _, k := range m.Keys() {
+		w[k], _ = m.Get(k)
+	}
+	return w, nil
+}
+
+func (d *yamlNodeDecoder) key(n *yaml.Node) (string, error) {
+	if n.Kind == yaml.AliasNode {
+		switch d.aliases {
+		case yamlAliasesError:
+			return "", fmt.Errorf("yaml: line %d: alias *%s is not allowed", n.Line, n.Value)
+		case yamlAliasesPreserve:
+			return "*" + n.Value, nil
+		}
+	}
+	var key interface{}
+	if err := n.Decode(&key); err != nil {
+		return "", err
+	}
+	return fmt.Sprint(key), nil
+}
+
+// merge merges the mappings referred by the merge key (<<). The keys already
+// in the mapping, including the ones merged earlier, are not overwritten.
+func (d *yamlNodeDecoder) merge(m *gojq.OrderedMap, n *yaml.Node) error {
+	if n.Kind == yaml.SequenceNode {
+		for _, n := range n.Content {
+			if err := d.merge(m, n); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	ordered := d.ordered
+	d.ordered = true
+	v, err := d.value(n)
+	d.ordered = ordered
+	if err != nil {
+		return err
+	}
+	w, ok := v.(*gojq.OrderedMap)
+	if !ok {
+		return fmt.Errorf("yaml: line %d: map merge requires map or sequence of maps as the value", n.Line)
+	}
+	for _, k := range w.Keys() {
+		if _, ok := m.Get(k); !ok {
+			x, _ := w.Get(k)
+			m.Set(k, x)
+		}
+	}
+	return nil
+}
+
+// orderedYAMLValue converts the ordered maps in the value to YAML mapping nodes
+// to keep the key order in the YAML output.
+func orderedYAMLValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case *gojq.OrderedMap:
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, k := range v.Keys() {
+			x, _ := v.Get(k)
+			w, err := orderedYAMLValue(x)
+			if err != nil {
+				return nil, err
+			}
+			kn, vn := new(yaml.Node), new(yaml.Node)
+			if err := kn.Encode(k); err != nil {
+				return nil, err
+			}
+			if err := vn.Encode(w); err != nil {
+				return nil, err
+			}
+			n.Content = append(n.Content, kn, vn)
+		}
+		return n, nil
+	case []interface{}:
+		vs := make([]interface{}, len(v))
+		for i, x := range v {
+			w, err := orderedYAMLValue(x)
+			if err != nil {
+				return nil, err
+			}
+			vs[i] = w
+		}
+		return vs, nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, x := range v {
+			w, err := orderedYAMLValue(x)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = w
+		}
+		return m, nil
+	default:
+		return v, nil
+	}
+}
+
+// yamlPatcher creates the YAML node of the output value from the node of the
+// input document, so that the comments, the styles and the key order of the
+// parts not changed by the query are kept in the output.
+type yamlPatcher struct {
+	dec  *yamlNodeDecoder
+	kept map[*yaml.Node]bool // the anchored nodes emitted as they are
+}
+
+// patchYAMLDocument returns the document node of the value, or nil when the
+// value corresponds to neither the document nor its subtree.
+func patchYAMLDocument(n *yaml.Node, dec *yamlNodeDecoder, v interface{}) (*yaml.Node, error) {
+	if n.Kind != yaml.DocumentNode || len(n.Content) == 0 {
+		return nil, nil
+	}
+	p := &yamlPatcher{dec, make(map[*yaml.Node]bool)}
+	c, err := p.lookup(n.Content[0], v)
+	if err != nil || c == nil {
+		return nil, err
+	}
+	m := *n
+	if c != n.Content[0] {
+		// the comments of the document are not for the subtree
+		m = yaml.Node{Kind: yaml.DocumentNode}
+	}
+	if c, err = p.patch(c, v); err != nil {
+		return nil, err
+	}
+	m.Content = []*yaml.Node{untagYAMLMergeKeys(c)}
+	return &m, nil
+}
+
+// lookup returns the node in the tree which the value is likely to come from,
+// so that the comments of the subtree selected by the query (like .a.b) are
+// kept. This is the first node equal to the value, otherwise the node of the
+// same kind which shares the most keys and scalar values with the value. The
+// outer nodes take precedence, and the root node is used when it has the same
+// keys as the value or none of the other nodes shares the entries.
+func (p *yamlPatcher) lookup(root *yaml.Node, v interface{}) (*yaml.Node, error) {
+	var kind yaml.Kind
+	var size int
+	keys, values := yamlMappingValues(v)
+	switch v := v.(type) {
+	case map[string]interface{}, *gojq.OrderedMap:
+		kind, size = yaml.MappingNode, len(keys)
+	case []interface{}:
+		kind, size = yaml.SequenceNode, len(v)
+	default:
+		return nil, nil
+	}
+	var best *yaml.Node
+	var bestScore int
+	if root.Kind == kind {
+		best = root
+	}
+	var walk func(*yaml.Node) (bool, error)
+	walk = func(n *yaml.Node) (bool, error) {
+		if n.Kind == yaml.AliasNode {
+			return false, nil
+		}
+		if n.Kind == kind {
+			if merged, length := yamlNodeLength(n); merged || length == size {
+				w, err := p.dec.decode(n)
+				if err != nil {
+					return false, err
+				}
+				if reflect.DeepEqual(w, v) {
+					best = n
+					return true, nil
+				}
+			}
+			score, err := p.score(n, v, values)
+			if err != nil {
+				return false, err
+			}
+			if n == root && kind == yaml.MappingNode && score.keys == size &&
+				score.keys == len(n.Content)/2 {
+				return true, nil
+			}
+			if s := score.keys + score.values; s > bestScore {
+				best, bestScore = n, s
+			}
+		}
+		for _, c := range n.Content {
+			if found, err := walk(c); found || err != nil {
+				return found, err
+			}
+		}
+		return false, nil
+	}
+	if _, err := walk(root); err != nil {
+		return nil, err
+	}
+	return best, nil
+}
+
+// yamlNodeLength returns the number of the entries of the mapping or sequence
+// node, which is inaccurate when the mapping has merge keys.
+func yamlNodeLength(n *yaml.Node) (merged bool, length int) {
+	if n.Kind == yaml.SequenceNode {
+		return false, len(n.Content)
+	}
+	for i := 0; i < len(n.Content); i += 2 {
+		if n.Content[i].Tag == "!!merge" {
+			return true, 0
+		}
+	}
+	return false, len(n.Content) / 2
+}
+
+type yamlNodeScore struct {
+	keys, values int
+}
+
+// score counts the keys of the mapping node (including the merged ones) in the
+// value, and the scalar values (or the elements of the sequence) equal to the
+// ones of the value.
+func (p *yamlPatcher) score(n *yaml.Node, v interface{}, values map[string]interface{}) (yamlNodeScore, error) {
+	var score yamlNodeScore
+	if n.Kind == yaml.SequenceNode {
+		vs := v.([]interface{})
+		for i, c := range n.Content {
+			if i >= len(vs) {
+				break
+			}
+			w, err := p.dec.decode(c)
+			if err != nil {
+				return score, err
+			}
+			if reflect.DeepEqual(w, vs[i]) {
+				score.values++
+			}
+		}
+		return score, nil
+	}
+	done := make(map[string]bool)
+	for i, xs := 0, n.Content; i+1 < len(xs); i += 2 {
+		k, x := xs[i], xs[i+1]
+		if k.Tag == "!!merge" {
+			// the merged entries follow the explicit ones which take precedence
+			xs = append(xs[:len(xs):len(xs)], yamlMergedEntries(x)...)
+			continue
+		}
+		key, err := p.dec.key(k)
+		if err != nil {
+			return score, err
+		}
+		w, ok := values[key]
+		if !ok || done[key] {
+			continue
+		}
+		done[key] = true
+		score.keys++
+		if x.Kind == yaml.ScalarNode {
+			y, err := p.dec.decode(x)
+			if err != nil {
+				return score, err
+			}
+			if reflect.DeepEqual(y, w) {
+				score.values++
+			}
+		}
+	}
+	return score, nil
+}
+
+// untagYAMLMergeKeys clears the tags of the merge keys, which go-yaml emits
+// explicitly like !!merge <<. The nodes are copied on changing the tags.
+func untagYAMLMergeKeys(n *yaml.Node) *yaml.Node {
+	var content []*yaml.Node
+	for i, c := range n.Content {
+		d := c
+		if n.Kind == yaml.MappingNode && i%2 == 0 && c.Tag == "!!merge" && c.Style == 0 {
+			e := *c
+			e.Tag = ""
+			d = &e
+		} else {
+			d = untagYAMLMergeKeys(c)
+		}
+		if d != c {
+			if content == nil {
+				content = append([]*yaml.Node(nil), n.Content...)
+			}
+			content[i] = d
+		}
+	}
+	if content == nil {
+		return n
+	}
+	m := *n
+	m.Content = content
+	return &m
+}
+
+func (p *yamlPatcher) patch(n *yaml.Node, v interface{}) (*yaml.Node, error) {
+	if w, err := p.dec.decode(n); err != nil {
+		return nil, err
+	} else if reflect.DeepEqual(w, v) && p.keep(n) {
+		return n, nil
+	}
+	if n.Kind == yaml.AliasNode {
+		return p.patch(n.Alias, v)
+	}
+	switch v := v.(type) {
+	case map[string]interface{}, *gojq.OrderedMap:
+		if n.Kind == yaml.MappingNode {
+			return p.patchMapping(n, v)
+		}
+	case []interface{}:
+		if n.Kind == yaml.SequenceNode {
+			return p.patchSequence(n, v)
+		}
+	}
+	m, err := encodeYAMLNode(v)
+	if err != nil {
+		return nil, err
+	}
+	m.HeadComment, m.LineComment, m.FootComment =
+		n.HeadComment, n.LineComment, n.FootComment
+	if m.Tag == "!!str" && n.Kind == yaml.ScalarNode &&
+		n.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		m.Style = n.Style // quoting the string never changes the value
+	}
+	return m, nil
+}
+
+// keep reports whether the node can be emitted as it is, that is, all the
+// aliases in the node refer to the anchors emitted before.
+func (p *yamlPatcher) keep(n *yaml.Node) bool {
+	var anchors []*yaml.Node
+	var walk func(*yaml.Node) bool
+	walk = func(n *yaml.Node) bool {
+		if n.Kind == yaml.AliasNode {
+			return p.kept[n.Alias]
+		}
+		if n.Anchor != "" && !p.kept[n] {
+			p.kept[n] = true
+			anchors = append(anchors, n)
+		}
+		for _, n := range n.Content {
+			if !walk(n) {
+				return false
+			}
+		}
+		return true
+	}
+	if walk(n) {
+		return true
+	}
+	for _, n := range anchors {
+		delete(p.kept, n)
+	}
+	return false
+}
+
+func (p *yamlPatcher) patchMapping(n *yaml.Node, v interface{}) (*yaml.Node, error) {
+	keys, values := yamlMappingValues(v)
+	merged, err := p.mergedKeys(n, values)
+	if err != nil {
+		return nil, err
+	}
+	m := *n
+	m.Anchor, m.Content = "", nil
+	done := make(map[string]bool, len(keys))
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, x := n.Content[i], n.Content[i+1]
+		if k.Tag == "!!merge" {
+			if merged != nil {
+				m.Content = append(m.Content, k, x)
+				continue
+			}
+			// expand the merged entries in place of the merge key
+			xs := yamlMergedEntries(x)
+			for i := 0; i < len(xs); i += 2 {
+				k := xs[i]
+				key, err := p.dec.key(k)
+				if err != nil {
+					return nil, err
+				}
+				w, ok := values[key]
+				if !ok || done[key] || hasYAMLMappingKey(p.dec, n, key) {
+					continue
+				}
+				done[key] = true
+				c, err := encodeYAMLNode(w)
+				if err != nil {
+					return nil, err
+				}
+				m.Content = append(m.Content, k, c)
+			}
+			continue
+		}
+		key, err := p.dec.key(k)
+		if err != nil {
+			return nil, err
+		}
+		w, ok := values[key]
+		if !ok || done[key] {
+			continue
+		}
+		done[key] = true
+		c, err := p.patch(x, w)
+		if err != nil {
+			return nil, err
+		}
+		m.Content = append(m.Content, k, c)
+	}
+	for _, key := range keys {
+		if done[key] || merged[key] {
+			continue
+		}
+		k, err := encodeYAMLNode(key)
+		if err != nil {
+			return nil, err
+		}
+		x, err := encodeYAMLNode(values[key])
+		if err != nil {
+			return nil, err
+		}
+		m.Content = append(m.Content, k, x)
+	}
+	return &m, nil
+}
+
+// yamlMergedEntries returns the key and value nodes of the mappings merged by
+// the merge key, in the order of the precedence.
+func yamlMergedEntries(n *yaml.Node) []*yaml.Node {
+	for n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	var xs []*yaml.Node
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if k := n.Content[i]; k.Tag == "!!merge" {
+				xs = append(xs, yamlMergedEntries(n.Content[i+1])...)
+			} else {
+				xs = append(xs, k, n.Content[i+1])
+			}
+		}
+	case yaml.SequenceNode:
+		for _, c := range n.Content {
+			xs = append(xs, yamlMergedEntries(c)...)
+		}
+	}
+	return xs
+}
+
+// hasYAMLMappingKey reports whether the mapping node has the key explicitly.
+func hasYAMLMappingKey(dec *yamlNodeDecoder, n *yaml.Node, key string) bool {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if k := n.Content[i]; k.Tag != "!!merge" {
+			if l, err := dec.key(k); err == nil && l == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mergedKeys returns the keys merged by the merge keys (<<) of the mapping, or
+// nil when the mapping has no merge key or the merged values are changed.
+func (p *yamlPatcher) mergedKeys(n *yaml.Node, values map[string]interface{}) (map[string]bool, error) {
+	explicit := make(map[string]bool)
+	var merge bool
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if k := n.Content[i]; k.Tag != "!!merge" {
+			key, err := p.dec.key(k)
+			if err != nil {
+				return nil, err
+			}
+			explicit[key] = true
+		} else if !p.keep(n.Content[i+1]) {
+			return nil, nil
+		} else {
+			merge = true
+		}
+	}
+	if !merge {
+		return nil, nil
+	}
+	v, err := p.dec.decode(n)
+	if err != nil {
+		return nil, err
+	}
+	_, origs := yamlMappingValues(v)
+	merged := make(map[string]bool)
+	for k, x := range origs {
+		if explicit[k] {
+			continue
+		}
+		if y, ok := values[k]; !ok || !reflect.DeepEqual(x, y) {
+			return nil, nil
+		}
+		merged[k] = true
+	}
+	return merged, nil
+}
+
+func (p *yamlPatcher) patchSequence(n *yaml.Node, vs []interface{}) (*yaml.Node, error) {
+	m := *n
+	m.Anchor, m.Content = "", make([]*yaml.Node, len(vs))
+	var j int
+	for i, v := range vs {
+		if len(vs) == len(n.Content) {
+			c, err := p.patch(n.Content[i], v)
+			if err != nil {
+				return nil, err
+			}
+			m.Content[i] = c
+			continue
+		}
+		// Find the element not changed, when some elements are added or deleted.
+		for k := j; k < len(n.Content); k++ {
+			w, err := p.dec.decode(n.Content[k])
+			if err != nil {
+				return nil, err
+			}
+			if reflect.DeepEqual(w, v) {
+				c, err := p.patch(n.Content[k], v)
+				if err != nil {
+					return nil, err
+				}
+				m.Content[i], j = c, k+1
+				break
+			}
+		}
+		if m.Content[i] == nil {
+			c, err := encodeYAMLNode(v)
+			if err != nil {
+				return nil, err
+			}
+			m.Content[i] = c
+		}
+	}
+	return &m, nil
+}
+
+func yamlMappingValues(v interface{}) ([]string, map[string]interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys, v
+	case *gojq.OrderedMap:
+		keys := v.Keys()
+		values := make(map[string]interface{}, len(keys))
+		for _, k := range keys {
+			values[k], _ = v.Get(k)
+		}
+		return keys, values
+	default:
+		return nil, nil
+	}
+}
+
+func encodeYAMLNode(v interface{}) (*yaml.Node, error) {
+	v, err := orderedYAMLValue(v)
+	if err != nil {
+		return nil, err
+	}
+	n := new(yaml.Node)
+	if err := n.Encode(v); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// The encoder and the decoder are safe for concurrent use of EncodeAll and
+// DecodeAll, so they are created once and shared.
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+)
+
+func initZstd() {
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+}
+
+func funcZstd(v interface{}, _ []interface{}) interface{} {
+	var bs []byte
+	switch v := v.(type) {
+	case string:
+		bs = []byte(v)
+	case []byte:
+		bs = v
+	default:
+		return &funcTypeError{"zstd", v}
+	}
+	zstdOnce.Do(initZstd)
+	return zstdEncoder.EncodeAll(bs, nil)
+}
+
+func funcUnzstd(v interface{}, _ []interface{}) interface{} {
+	bs, ok := decodeBytes(v)
+	if !ok {
+		return &funcTypeError{"unzstd", v}
+	}
+	zstdOnce.Do(initZstd)
+	bs, err := zstdDecoder.DecodeAll(bs, nil)
+	if err != nil {
+		return &decompressError{"unzstd", err}
+	}
+	return bs
+}
@@ -0,0 +1,7 @@
+//go:build go1.15
+// +build go1.15
+
+package main
+
+// embed the time zone database for the systems without it
+import _ "time/tzdata"
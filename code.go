@@ -1,5 +1,11 @@
 package gojq
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 type code struct {
 	v  interface{}
 	op opcode
@@ -35,6 +41,10 @@ const (
 	opexpend
 	oppathbegin
 	oppathend
+	oppmap
+	opindex
+	opcallmemo
+	optailcall
 )
 
 func (op opcode) String() string {
@@ -93,7 +103,53 @@ func (op opcode) String() string {
 		return "pathbegin"
 	case oppathend:
 		return "pathend"
+	case oppmap:
+		return "pmap"
+	case opindex:
+		return "index"
+	case opcallmemo:
+		return "callmemo"
+	case optailcall:
+		return "tailcall"
 	default:
 		panic(op)
 	}
 }
+
+// Disassemble returns the listing of the compiled instructions, one instruction
+// in each line with the program counter, the operation and the operand.
+func (c *Code) Disassemble() string {
+	var sb strings.Builder
+	for pc, code := range c.codes {
+		sb.WriteString(strconv.Itoa(pc))
+		sb.WriteByte('\t')
+		sb.WriteString(code.op.String())
+		if s := code.operand(); s != "" {
+			sb.WriteByte('\t')
+			sb.WriteString(s)
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func (c *code) operand() string {
+	switch v := c.v.(type) {
+	case [3]interface{}:
+		return fmt.Sprintf("%s/%d", v[2], v[1])
+	case [2]int:
+		return fmt.Sprintf("[%d,%d]", v[0], v[1])
+	case int:
+		if c.op == oppush || c.op == opconst || c.op == opindex {
+			return jsonMarshal(v)
+		}
+		return strconv.Itoa(v)
+	case nil:
+		if c.op == oppush || c.op == opconst {
+			return "null"
+		}
+		return ""
+	default:
+		return jsonMarshal(v)
+	}
+}
@@ -0,0 +1,187 @@
+package gojq
+
+import (
+	"strings"
+	"unicode"
+)
+
+// latinBases is the table of the base letters of the Latin letters from U+00C0
+// to U+025F and from U+1E00 to U+1EFF. The spaces denote the letters without
+// the base letters, and the asterisks denote the ligatures in latinLigatures.
+const latinBases = "" +
+	"AAAAAA*CEEEEIIIIDNOOOOO OUUUUY**" + // U+00C0
+	"aaaaaa*ceeeeiiiidnooooo ouuuuy*y" + // U+00E0
+	"AaAaAaCcCcCcCcDdDdEeEeEeEeEeGgGg" + // U+0100
+	"GgGgHhHhIiIiIiIiIi**JjKkqLlLlLlL" + // U+0120
+	"lLlNnNnNn **OoOoOo**RrRrRrSsSsSs" + // U+0140
+	"SsTtTtTtUuUuUuUuUuUuWwYyYZzZzZzs" + // U+0160
+	"bB     Cc DDd    FfG   IKkl  Nn " + // U+0180
+	"Oo  Pp     tTtTUu VYyZz         " + // U+01A0
+	"    *********AaIiOoUuUuUuUuUu Aa" + // U+01C0
+	"Aa**GgGgKkOoOo  j***Gg  NnAa**Oo" + // U+01E0
+	"AaAaEeEeIiIiOoOoRrRrUuUuSsTt  Hh" + // U+0200
+	"    ZzAaEeOoOoOoOoYylntj  ACcLTs" + // U+0220
+	"z  BU EeJjQqRrYy                " + // U+0240
+	"AaBbBbBbCcDdDdDdDdDdEeEeEeEeEeFf" + // U+1E00
+	"GgHhHhHhHhHhIiIiKkKkKkLlLlLlLlMm" + // U+1E20
+	"MmMmNnNnNnNnOoOoOoOoPpPpRrRrRrRr" + // U+1E40
+	"SsSsSsSsSsTtTtTtTtUuUuUuUuUuVvVv" + // U+1E60
+	"WwWwWwWwWwXxXxYyZzZzZzhtwy    * " + // U+1E80
+	"AaAaAaAaAaAaAaAaAaAaAaAaEeEeEeEe" + // U+1EA0
+	"EeEeEeEeIiIiOoOoOoOoOoOoOoOoOoOo" + // U+1EC0
+	"OoOoUuUuUuUuUuUuUuYyYyYyYy      " // U+1EE0
+
+var latinLigatures = map[rune]string{
+	'Æ': "AE", 'Þ': "TH", 'ß': "ss", 'æ': "ae", 'þ': "th", 'Ĳ': "IJ",
+	'ĳ': "ij", 'Ŋ': "NG", 'ŋ': "ng", 'Œ': "OE", 'œ': "oe", 'Ǆ': "DZ",
+	'ǅ': "Dz", 'ǆ': "dz", 'Ǉ': "LJ", 'ǈ': "Lj", 'ǉ': "lj", 'Ǌ': "NJ",
+	'ǋ': "Nj", 'ǌ': "nj", 'Ǣ': "AE", 'ǣ': "ae", 'Ǳ': "DZ", 'ǲ': "Dz",
+	'ǳ': "dz", 'Ǽ': "AE", 'ǽ': "ae", 'ẞ': "SS",
+}
+
+// latinBase returns the base letters of the Latin letter with the diacritics.
+func latinBase(r rune) (string, bool) {
+	var c byte
+	switch {
+	case 0xC0 <= r && r < 0x260:
+		c = latinBases[r-0xC0]
+	case 0x1E00 <= r && r < 0x1F00:
+		c = latinBases[r-0x1E00+0x1A0]
+	default:
+		return "", false
+	}
+	switch c {
+	case ' ':
+		return "", false
+	case '*':
+		return latinLigatures[r], true
+	default:
+		return string(rune(c)), true
+	}
+}
+
+// collationTailorings is the letters sorted after the first letter in order,
+// for each language. These letters are not the variants of the base letters.
+var collationTailorings = map[string][]string{
+	"az": {"cç", "gğ", "hı", "oö", "sş", "uü"},
+	"cs": {"cč", "rř", "sš", "zž"},
+	"da": {"zæøå"},
+	"es": {"nñ"},
+	"fi": {"zåäö"},
+	"nb": {"zæøå"},
+	"nn": {"zæøå"},
+	"no": {"zæøå"},
+	"pl": {"aą", "cć", "eę", "lł", "nń", "oó", "sś", "zźż"},
+	"sv": {"zåäö"},
+	"tr": {"cç", "gğ", "hı", "oö", "sş", "uü"},
+}
+
+// collator compares the strings in the collation order of the locale, which
+// is a simplified version of the Unicode Collation Algorithm. The strings are
+// compared by the base letters (primary), by the accents (secondary), and then
+// by the cases (tertiary); the lowercase letters come first.
+type collator struct {
+	strength  int
+	tailoring map[rune]int
+	special   unicode.SpecialCase
+}
+
+func newCollator(locale string, strength int) *collator {
+	c := &collator{strength: strength}
+	lang := strings.ToLower(locale)
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	if lang == "tr" || lang == "az" {
+		c.special = unicode.TurkishCase
+	}
+	for _, t := range collationTailorings[lang] {
+		if c.tailoring == nil {
+			c.tailoring = make(map[rune]int)
+		}
+		rs := []rune(t)
+		for i, r := range rs[1:] {
+			c.tailoring[r] = int(rs[0])<<4 + i + 1
+		}
+	}
+	return c
+}
+
+type collationElement [3]int
+
+func (c *collator) elements(s string) []collationElement {
+	var es []collationElement
+	for _, r := range s {
+		if unicode.Is(unicode.Mn, r) {
+			if len(es) > 0 {
+				es[len(es)-1][1] = int(r)
+			}
+			continue
+		}
+		l := c.special.ToLower(r)
+		var tertiary int
+		if l != r {
+			tertiary = 1
+		}
+		if w, ok := c.tailoring[l]; ok {
+			es = append(es, collationElement{w, 0, tertiary})
+		} else if b, ok := latinBase(l); ok {
+			for _, b := range b {
+				es = append(es, collationElement{int(b) << 4, int(l), tertiary})
+			}
+		} else {
+			es = append(es, collationElement{int(l) << 4, 0, tertiary})
+		}
+	}
+	return es
+}
+
+func (c *collator) compare(l, r string) int {
+	xs, ys := c.elements(l), c.elements(r)
+	for level := 0; level < c.strength; level++ {
+		for i := 0; i < len(xs) && i < len(ys); i++ {
+			if x, y := xs[i][level], ys[i][level]; x != y {
+				return compare(x, y)
+			}
+		}
+		if len(xs) != len(ys) {
+			return compare(len(xs), len(ys))
+		}
+	}
+	return 0
+}
+
+func funcSortByCollate(v, x, y, z interface{}) interface{} {
+	locale, ok := y.(string)
+	if !ok {
+		return &funcTypeError{"sort_collate", y}
+	}
+	options, ok := z.(map[string]interface{})
+	if !ok {
+		return &funcTypeError{"sort_collate", z}
+	}
+	strength := 3
+	if s, ok := options["strength"]; ok {
+		switch s {
+		case "primary":
+			strength = 1
+		case "secondary":
+			strength = 2
+		case "tertiary":
+		default:
+			return &funcTypeError{"sort_collate", s}
+		}
+	}
+	c := newCollator(locale, strength)
+	items, err := sortItemsWith(v, x, func(l, r interface{}) int {
+		return compareWithStrings(l, r, c.compare)
+	})
+	if err != nil {
+		return err
+	}
+	rs := make([]interface{}, len(items))
+	for i, x := range items {
+		rs[i] = x.value
+	}
+	return rs
+}
@@ -1,11 +1,24 @@
 package gojq
 
 import (
+	"bytes"
+	"encoding/json"
 	"math"
 	"math/big"
+	"strings"
+	"time"
 )
 
 func compare(l, r interface{}) int {
+	if _, ok := l.(json.Number); ok {
+		if cmp, ok := compareExact(l, r); ok {
+			return cmp
+		}
+	} else if _, ok := r.(json.Number); ok {
+		if cmp, ok := compareExact(l, r); ok {
+			return cmp
+		}
+	}
 	return binopTypeSwitch(l, r,
 		func(l, r int) interface{} {
 			switch {
@@ -69,6 +82,31 @@ func compare(l, r interface{}) int {
 			return 0
 		},
 		func(l, r interface{}) interface{} {
+			if _, ok := l.(JQValue); ok {
+				l, _ = resolveJQValue(l)
+				return compare(l, r)
+			}
+			if _, ok := r.(JQValue); ok {
+				r, _ = resolveJQValue(r)
+				return compare(l, r)
+			}
+			if l, ok := l.([]byte); ok {
+				if r, ok := r.([]byte); ok {
+					return bytes.Compare(l, r)
+				}
+			}
+			if l, ok := l.(time.Time); ok {
+				if r, ok := r.(time.Time); ok {
+					switch {
+					case l.Before(r):
+						return -1
+					case l.Equal(r):
+						return 0
+					default:
+						return 1
+					}
+				}
+			}
 			ln, rn := getTypeOrdNum(l), getTypeOrdNum(r)
 			switch {
 			case ln < rn:
@@ -91,15 +129,110 @@ func getTypeOrdNum(v interface{}) int {
 			return 2
 		}
 		return 1
-	case int, float64, *big.Int:
+	case int, float64, *big.Int, json.Number:
 		return 3
-	case string:
+	case time.Time:
 		return 4
-	case []interface{}:
+	case string:
 		return 5
-	case map[string]interface{}:
+	case []byte:
 		return 6
+	case []interface{}:
+		return 7
+	case map[string]interface{}:
+		return 8
 	default:
 		return -1
 	}
 }
+
+// compareExact compares the numbers exactly, which is used when either of them
+// is json.Number kept by WithPreciseNumbers.
+func compareExact(l, r interface{}) (int, bool) {
+	x, ok := toRat(l)
+	if !ok {
+		return 0, false
+	}
+	y, ok := toRat(r)
+	if !ok {
+		return 0, false
+	}
+	return x.Cmp(y), true
+}
+
+func toRat(v interface{}) (*big.Rat, bool) {
+	switch v := v.(type) {
+	case int:
+		return new(big.Rat).SetInt64(int64(v)), true
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, false
+		}
+		return new(big.Rat).SetFloat64(v), true
+	case *big.Int:
+		return new(big.Rat).SetInt(v), true
+	case json.Number:
+		return new(big.Rat).SetString(v.String())
+	default:
+		return nil, false
+	}
+}
+
+// compareNatural compares the values like compare, but the strings are compared
+// in the natural order; the digit sequences are compared numerically so that
+// "file2" comes before "file10".
+func compareNatural(l, r interface{}) int {
+	return compareWithStrings(l, r, compareNaturalString)
+}
+
+// compareWithStrings compares the values like compare, but the strings are
+// compared by the function. The arrays are compared element-wise.
+func compareWithStrings(l, r interface{}, cmp func(string, string) int) int {
+	switch l := l.(type) {
+	case string:
+		if r, ok := r.(string); ok {
+			return cmp(l, r)
+		}
+	case []interface{}:
+		if r, ok := r.([]interface{}); ok {
+			for i := 0; i < len(l) && i < len(r); i++ {
+				if c := compareWithStrings(l[i], r[i], cmp); c != 0 {
+					return c
+				}
+			}
+			return compare(len(l), len(r))
+		}
+	}
+	return compare(l, r)
+}
+
+func compareNaturalString(l, r string) int {
+	for l != "" && r != "" {
+		i, j := digitsLength(l), digitsLength(r)
+		if i > 0 && j > 0 {
+			x, y := strings.TrimLeft(l[:i], "0"), strings.TrimLeft(r[:j], "0")
+			if c := compare(len(x), len(y)); c != 0 {
+				return c
+			}
+			if c := strings.Compare(x, y); c != 0 {
+				return c
+			}
+			l, r = l[i:], r[j:]
+			continue
+		}
+		if l[0] != r[0] {
+			return compare(int(l[0]), int(r[0]))
+		}
+		l, r = l[1:], r[1:]
+	}
+	return compare(len(l), len(r))
+}
+
+func digitsLength(s string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || '9' < s[i] {
+			return i
+		}
+	}
+	return len(s)
+}
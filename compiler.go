@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	mathrand "math/rand"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 type compiler struct {
@@ -16,11 +18,26 @@ type compiler struct {
 	variables     []string
 	customFuncs   map[string]function
 	inputIter     Iter
+	random        *mathrand.Rand
+	randomMu      sync.Mutex
+	precise       bool
+	decimal       bool
+	datetime      bool
+	ordered       bool
+	optimize      bool
+	limits        limits
+	observer      func(*OutputEvent)
+	warn          func(string)
+	typecheck     bool
+	shadowing     bool
 	codes         []*code
 	codeinfos     []codeinfo
+	spans         map[*code]*codespan
 	scopes        []*scopeinfo
 	scopecnt      int
 	funcs         []*funcinfo
+	defs          []*funcinfo
+	tailcalls     map[*code]bool
 }
 
 // Code is a compiled jq query.
@@ -28,6 +45,13 @@ type Code struct {
 	variables []string
 	codes     []*code
 	codeinfos []codeinfo
+	spans     map[int]*codespan
+	precise   bool
+	ordered   bool
+	limits    limits
+	observer  func(*OutputEvent)
+	effects   map[string]struct{}
+	memo      map[int]*memoCache
 }
 
 // Run runs the code with the variable values (which should be in the
@@ -41,15 +65,22 @@ func (c *Code) Run(v interface{}, values ...interface{}) Iter {
 
 // RunWithContext runs the code with context.
 func (c *Code) RunWithContext(ctx context.Context, v interface{}, values ...interface{}) Iter {
+	if c.observer != nil {
+		return newObservedIter(c.runWithContext(ctx, v, values...), c.observer)
+	}
+	return c.runWithContext(ctx, v, values...)
+}
+
+func (c *Code) runWithContext(ctx context.Context, v interface{}, values ...interface{}) Iter {
 	if len(values) > len(c.variables) {
 		return NewIter(&tooManyVariableValuesError{})
 	} else if len(values) < len(c.variables) {
 		return NewIter(&expectedVariableError{c.variables[len(values)]})
 	}
 	for i, v := range values {
-		values[i] = normalizeNumbers(v)
+		values[i] = normalizeNumbersWith(v, c.precise)
 	}
-	return newEnv(ctx).execute(c, normalizeNumbers(v), values...)
+	return newEnv(ctx).execute(c, normalizeNumbersWith(v, c.precise), values...)
 }
 
 // ModuleLoader is an interface for loading modules.
@@ -67,6 +98,13 @@ type codeinfo struct {
 	pc   int
 }
 
+// codespan is the position of a function or an operator in the query source,
+// recorded for the code which may raise an error.
+type codespan struct {
+	start, end int
+	name       string
+}
+
 type scopeinfo struct {
 	id          int
 	depth       int
@@ -85,19 +123,22 @@ type funcinfo struct {
 	pc        int
 	args      []string
 	argsorder []int
+	scope     int  // index of the scope in compiler.scopes
+	impure    bool // refers to the outer variables or calls impure functions
+	memo      bool // can be memoized when it is pure
 }
 
 // Compile compiles a query.
 func Compile(q *Query, options ...CompilerOption) (*Code, error) {
-	c := &compiler{}
+	c := &compiler{spans: make(map[*code]*codespan), tailcalls: make(map[*code]bool)}
 	for _, opt := range options {
 		opt(c)
 	}
 	scope := c.newScope()
 	c.scopes = []*scopeinfo{scope}
-	defer c.lazy(func() *code {
+	setscope := c.lazy(func() *code {
 		return &code{op: opscope, v: [2]int{scope.id, scope.variablecnt}}
-	})()
+	})
 	if c.moduleLoader != nil {
 		if moduleLoader, ok := c.moduleLoader.(interface {
 			LoadInitModules() ([]*Query, error)
@@ -116,12 +157,39 @@ func Compile(q *Query, options ...CompilerOption) (*Code, error) {
 	if err := c.compile(q); err != nil {
 		return nil, err
 	}
+	if c.warn != nil {
+		checkWarnings(q, c.warn, c.shadowing)
+		if c.typecheck {
+			checkTypes(q, c.warn)
+		}
+	}
 	c.optimizeTailRec()
 	c.optimizeJumps()
+	setscope()
+	if c.optimize {
+		c.optimizePeephole()
+	}
+	spans := make(map[int]*codespan, len(c.spans))
+	memo := make(map[int]*memoCache)
+	for pc, code := range c.codes {
+		if span, ok := c.spans[code]; ok {
+			spans[pc] = span
+		}
+		if code.op == opcallmemo {
+			memo[code.v.(int)] = newMemoCache()
+		}
+	}
 	return &Code{
 		variables: c.variables,
 		codes:     c.codes,
 		codeinfos: c.codeinfos,
+		spans:     spans,
+		precise:   c.precise,
+		ordered:   c.ordered,
+		limits:    c.limits,
+		observer:  c.observer,
+		effects:   c.sideEffectFuncs(),
+		memo:      memo,
 	}, nil
 }
 
@@ -200,6 +268,8 @@ func (c *compiler) compileImport(i *Import) error {
 }
 
 func (c *compiler) compileModule(q *Query, alias string) error {
+	defer func(spans map[*code]*codespan) { c.spans = spans }(c.spans)
+	c.spans = nil // offsets in modules are not of the query
 	scope := c.scopes[len(c.scopes)-1]
 	scope.depth++
 	defer func(l int) {
@@ -245,6 +315,34 @@ func (c *compiler) pushVariable(name string) [2]int {
 	return v
 }
 
+// errorValueVariable is the name of the variable of the caught value, which
+// cannot conflict with the variables in the queries.
+const errorValueVariable = "$ error_value"
+
+func (c *compiler) lookupVariable(name string) ([2]int, bool) {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		s := c.scopes[i]
+		for j := len(s.variables) - 1; j >= 0; j-- {
+			if v := s.variables[j]; v.name == name {
+				c.markImpure(i)
+				return v.index, true
+			}
+		}
+	}
+	return [2]int{}, false
+}
+
+// markImpure marks the functions being compiled in the scopes inside the i-th
+// scope as impure, which refer to the variables in the scope. Use -1 to mark
+// all the functions being compiled, on calling the functions with side effects.
+func (c *compiler) markImpure(i int) {
+	for _, fi := range c.defs {
+		if fi.scope > i {
+			fi.impure = true
+		}
+	}
+}
+
 func (c *compiler) newScope() *scopeinfo {
 	i := c.scopecnt // do not use len(c.scopes) because it pops
 	c.scopecnt++
@@ -273,13 +371,22 @@ func (c *compiler) compileFuncDef(e *FuncDef, builtin bool) error {
 	defer c.lazy(func() *code {
 		return &code{op: opjump, v: c.pc()}
 	})()
+	if builtin {
+		defer func(spans map[*code]*codespan) { c.spans = spans }(c.spans)
+		c.spans = nil
+	}
 	c.appendCodeInfo(e.Name)
 	defer c.appendCodeInfo("end of " + e.Name)
 	pc, argsorder := c.pc(), getArgsOrder(e.Args)
-	c.funcs = append(c.funcs, &funcinfo{e.Name, pc, e.Args, argsorder})
-	defer func(l, m int, variables []string) {
-		c.scopes, c.funcs, c.variables = c.scopes[:l], c.funcs[:m], variables
-	}(len(c.scopes), len(c.funcs), c.variables)
+	fi := &funcinfo{
+		name: e.Name, pc: pc, args: e.Args, argsorder: argsorder, scope: len(c.scopes),
+		memo: !builtin && len(e.Args) == 0 && !strings.HasPrefix(e.Name, "lambda:"),
+	}
+	c.funcs = append(c.funcs, fi)
+	defer func(l, m, n int, variables []string) {
+		c.scopes, c.funcs, c.defs, c.variables = c.scopes[:l], c.funcs[:m], c.defs[:n], variables
+	}(len(c.scopes), len(c.funcs), len(c.defs), c.variables)
+	c.defs = append(c.defs, fi)
 	c.variables = c.variables[len(c.variables):]
 	scope := c.newScope()
 	c.scopes = append(c.scopes, scope)
@@ -363,6 +470,7 @@ func (c *compiler) compileQuery(e *Query) error {
 		return c.compileAlt(e.Left, e.Right)
 	case OpAssign, OpModify, OpUpdateAdd, OpUpdateSub,
 		OpUpdateMul, OpUpdateDiv, OpUpdateMod, OpUpdateAlt:
+		defer c.markSpan(e.Offset, e.Offset+len(e.Op.String()), e.Op.String())
 		return c.compileQueryUpdate(e.Left, e.Right, e.Op)
 	case OpOr:
 		return c.compileIf(
@@ -389,13 +497,58 @@ func (c *compiler) compileQuery(e *Query) error {
 			},
 		)
 	default:
-		return c.compileCall(
+		defer c.markSpan(e.Offset, e.Offset+len(e.Op.String()), e.Op.String())
+		pc := c.pc()
+		if err := c.compileCall(
 			e.Op.getFunc(),
 			[]*Query{e.Left, e.Right},
-		)
+		); err != nil {
+			return err
+		}
+		c.optimizeConstantCall(pc)
+		return nil
 	}
 }
 
+// optimizeConstantCall folds the call of the operator with constant operands
+// (opstore, oppush, oppush, opload, opcall) or the unary operator on a constant
+// (opconst, opcall) into a constant. The call is left as it is when it raises
+// an error, so that the error is reported with the position at runtime.
+func (c *compiler) optimizeConstantCall(pc int) {
+	var v interface{}
+	var args []interface{}
+	switch {
+	case len(c.codes) == pc+5 &&
+		c.codes[pc+1].op == oppush && c.codes[pc+2].op == oppush:
+		args = []interface{}{c.codes[pc+2].v, c.codes[pc+1].v}
+	case len(c.codes) == pc+2 && c.codes[pc].op == opconst:
+		v = c.codes[pc].v
+	default:
+		return
+	}
+	x, ok := c.codes[len(c.codes)-1].v.([3]interface{})
+	if !ok || c.codes[len(c.codes)-1].op != opcall {
+		return
+	}
+	if x[2] == "_multiply" {
+		if _, ok := args[0].(string); ok {
+			return // do not repeat strings at compile time
+		} else if _, ok := args[1].(string); ok {
+			return
+		}
+	}
+	f, ok := x[0].(func(interface{}, []interface{}) interface{})
+	if !ok {
+		return // do not fold the functions taking the context
+	}
+	v = f(v, args)
+	if _, ok := v.(error); ok {
+		return
+	}
+	c.codes[pc] = &code{op: opconst, v: v}
+	c.codes = c.codes[:pc+1]
+}
+
 func (c *compiler) compileComma(l, r *Query) error {
 	setfork := c.lazy(func() *code {
 		return &code{op: opfork, v: c.pc() + 1}
@@ -451,7 +604,11 @@ func (c *compiler) compileQueryUpdate(l, r *Query, op Operator) error {
 			}
 			c.append(&code{op: oppush, v: xs})
 			c.append(&code{op: opload, v: v})
-			c.append(&code{op: opcall, v: [3]interface{}{internalFuncs["setpath"].callback, 2, "setpath"}})
+			fn := internalFuncs["setpath"]
+			if c.ordered {
+				fn = orderedFuncs["setpath"]
+			}
+			c.append(&code{op: opcall, v: [3]interface{}{fn.callback, 2, "setpath"}})
 			return nil
 		}
 		fallthrough
@@ -475,7 +632,8 @@ func (c *compiler) compileQueryUpdate(l, r *Query, op Operator) error {
 				Args: []*Query{
 					l,
 					{Term: &Term{
-						Type: TermTypeFunc,
+						Type:   TermTypeFunc,
+						Offset: -1,
 						Func: &Func{
 							Name: op.getFunc(),
 							Args: []*Query{
@@ -609,6 +767,7 @@ func (c *compiler) compilePattern(p *Pattern) ([][2]int, error) {
 
 func (c *compiler) compileIf(e *If) error {
 	c.appendCodeInfo(e)
+	pc := c.pc()
 	c.append(&code{op: opdup}) // duplicate the value for then or else clause
 	c.append(&code{op: opexpbegin})
 	f := c.newScopeDepth()
@@ -616,6 +775,23 @@ func (c *compiler) compileIf(e *If) error {
 		return err
 	}
 	f()
+	if len(c.codes) == pc+3 && c.codes[pc+2].op == opconst {
+		// eliminate the unreachable clause on the constant condition
+		v := c.codes[pc+2].v
+		c.codes = c.codes[:pc]
+		if v != nil && v != false {
+			defer c.newScopeDepth()()
+			return c.compileQuery(e.Then)
+		}
+		if len(e.Elif) > 0 {
+			return c.compileIf(&If{e.Elif[0].Cond, e.Elif[0].Then, e.Elif[1:], e.Else})
+		}
+		if e.Else != nil {
+			defer c.newScopeDepth()()
+			return c.compileQuery(e.Else)
+		}
+		return nil
+	}
 	c.append(&code{op: opexpend})
 	setjumpifnot := c.lazy(func() *code {
 		return &code{op: opjumpifnot, v: c.pc() + 1} // if falsy, skip then clause
@@ -656,6 +832,9 @@ func (c *compiler) compileTry(e *Try) error {
 	setforktrybegin()
 	if e.Catch != nil {
 		defer c.newScopeDepth()()
+		// store the caught value for error_value in the handler
+		c.append(&code{op: opdup})
+		c.append(&code{op: opstore, v: c.pushVariable(errorValueVariable)})
 		return c.compileQuery(e.Catch)
 	}
 	c.append(&code{op: opbacktrack})
@@ -759,21 +938,26 @@ func (c *compiler) compileTerm(e *Term) error {
 	case TermTypeIndex:
 		return c.compileIndex(&Term{Type: TermTypeIdentity}, e.Index)
 	case TermTypeFunc:
+		defer c.markSpan(e.Offset, e.Offset+len(e.Func.Name), e.Func.Name)
 		return c.compileFunc(e.Func)
 	case TermTypeObject:
 		return c.compileObject(e.Object)
 	case TermTypeArray:
 		return c.compileArray(e.Array)
 	case TermTypeNumber:
-		v := normalizeNumbers(json.Number(e.Number))
+		v := normalizeNumbersWith(json.Number(e.Number), c.precise)
 		if err, ok := v.(error); ok {
 			return err
 		}
 		c.append(&code{op: opconst, v: v})
 		return nil
 	case TermTypeUnary:
+		defer c.markSpan(e.Offset, e.Offset+1, e.Unary.Op.String())
 		return c.compileUnary(e.Unary)
 	case TermTypeFormat:
+		if e.Str == nil {
+			defer c.markSpan(e.Offset, e.Offset+len(e.Format), e.Format)
+		}
 		return c.compileFormat(e.Format, e.Str)
 	case TermTypeString:
 		return c.compileString(e.Str, nil)
@@ -800,6 +984,11 @@ func (c *compiler) compileTerm(e *Term) error {
 
 func (c *compiler) compileIndex(e *Term, x *Index) error {
 	c.appendCodeInfo(x)
+	if x.Name != "" {
+		defer c.markSpan(x.Offset, x.Offset+1+len(x.Name), ".")
+	} else {
+		defer c.markSpan(x.Offset, x.Offset+1, ".")
+	}
 	if x.Name != "" {
 		return c.compileCall("_index", []*Query{{Term: e}, {Term: &Term{Type: TermTypeString, Str: &String{Str: x.Name}}}})
 	}
@@ -829,6 +1018,7 @@ func (c *compiler) compileFunc(e *Func) error {
 		for j := len(s.variables) - 1; j >= 0; j-- {
 			v := s.variables[j]
 			if v.name == e.Name && len(e.Args) == 0 {
+				c.markImpure(i)
 				if e.Name[0] == '$' {
 					c.append(&code{op: oppop})
 					c.append(&code{op: opload, v: v.index})
@@ -873,6 +1063,9 @@ func (c *compiler) compileFunc(e *Func) error {
 		}
 	}
 	if fn, ok := internalFuncs[e.Name]; ok && fn.accept(len(e.Args)) {
+		if _, ok := c.sideEffectFuncs()[e.Name]; ok || e.Name == "now" {
+			c.markImpure(-1)
+		}
 		switch e.Name {
 		case "empty":
 			c.append(&code{op: opbacktrack})
@@ -901,6 +1094,22 @@ func (c *compiler) compileFunc(e *Func) error {
 				nil,
 				false,
 			)
+		case "error_value":
+			if v, ok := c.lookupVariable(errorValueVariable); ok {
+				c.append(&code{op: oppop})
+				c.append(&code{op: opload, v: v})
+			} else {
+				c.append(&code{op: opconst, v: nil})
+			}
+			return nil
+		case "have_literal_numbers":
+			c.append(&code{op: opconst, v: c.precise})
+			return nil
+		case "have_decimal_numbers":
+			c.append(&code{op: opconst, v: c.decimal})
+			return nil
+		case "pmap":
+			return c.compilePmap(e.Args)
 		case "modulemeta":
 			return c.compileCallInternal(
 				[3]interface{}{c.funcModulemeta, 0, e.Name},
@@ -908,11 +1117,54 @@ func (c *compiler) compileFunc(e *Func) error {
 				nil,
 				false,
 			)
+		case "uuid":
+			return c.compileCallInternal(
+				[3]interface{}{c.funcUUID, 0, e.Name},
+				e.Args,
+				nil,
+				false,
+			)
+		case "uuid7":
+			return c.compileCallInternal(
+				[3]interface{}{c.funcUUID7, 0, e.Name},
+				e.Args,
+				nil,
+				false,
+			)
+		case "random":
+			return c.compileCallInternal(
+				[3]interface{}{c.funcRandom, 0, e.Name},
+				e.Args,
+				nil,
+				false,
+			)
+		case "randint":
+			return c.compileCallInternal(
+				[3]interface{}{c.funcRandint, 2, e.Name},
+				e.Args,
+				nil,
+				false,
+			)
+		case "shuffle":
+			return c.compileCallInternal(
+				[3]interface{}{c.funcShuffle, 0, e.Name},
+				e.Args,
+				nil,
+				false,
+			)
+		case "setseed":
+			return c.compileCallInternal(
+				[3]interface{}{c.funcSetseed, 1, e.Name},
+				e.Args,
+				nil,
+				false,
+			)
 		default:
 			return c.compileCall(e.Name, e.Args)
 		}
 	}
 	if fn, ok := c.customFuncs[e.Name]; ok && fn.accept(len(e.Args)) {
+		c.markImpure(-1)
 		if err := c.compileCallInternal(
 			[3]interface{}{fn.callback, len(e.Args), e.Name},
 			e.Args,
@@ -929,6 +1181,41 @@ func (c *compiler) compileFunc(e *Func) error {
 	return &funcNotFoundError{e}
 }
 
+// compilePmap compiles pmap($n; f), which pushes the closure of f, the number
+// of goroutines and the input array, and applies the closure to the elements
+// in parallel (ref: env.parallelMap).
+func (c *compiler) compilePmap(args []*Query) error {
+	idx := c.newVariable()
+	c.append(&code{op: opstore, v: idx})
+	pc := c.pc() + 1 // skip opjump (ref: compileFuncDef)
+	name := "lambda:" + strconv.Itoa(pc)
+	if err := c.compileFuncDef(&FuncDef{Name: name, Body: args[1]}, false); err != nil {
+		return err
+	}
+	c.append(&code{op: oppushpc, v: pc})
+	c.append(&code{op: opload, v: idx})
+	if err := c.compileQuery(args[0]); err != nil {
+		return err
+	}
+	c.append(&code{op: opload, v: idx})
+	c.append(&code{op: oppmap})
+	return nil
+}
+
+// sideEffectFuncs returns the names of the functions which pmap cannot call in
+// the goroutines; the functions reading the inputs, the functions using the
+// random source, and the custom functions.
+func (c *compiler) sideEffectFuncs() map[string]struct{} {
+	effects := map[string]struct{}{
+		"input": {}, "random": {}, "randint": {}, "shuffle": {},
+		"setseed": {}, "uuid": {}, "uuid7": {},
+	}
+	for name := range c.customFuncs {
+		effects[name] = struct{}{}
+	}
+	return effects
+}
+
 func (c *compiler) funcBuiltins(interface{}, []interface{}) interface{} {
 	type funcNameArity struct {
 		name  string
@@ -976,7 +1263,7 @@ func (c *compiler) funcInput(interface{}, []interface{}) interface{} {
 	if !ok {
 		return errors.New("break")
 	}
-	return normalizeNumbers(v)
+	return normalizeNumbersWith(v, c.precise)
 }
 
 func (c *compiler) funcModulemeta(v interface{}, _ []interface{}) interface{} {
@@ -1040,7 +1327,11 @@ func (c *compiler) funcModulemeta(v interface{}, _ []interface{}) interface{} {
 func (c *compiler) compileObject(e *Object) error {
 	c.appendCodeInfo(e)
 	if len(e.KeyVals) == 0 {
-		c.append(&code{op: opconst, v: map[string]interface{}{}})
+		if c.ordered {
+			c.append(&code{op: opconst, v: NewOrderedMap()})
+		} else {
+			c.append(&code{op: opconst, v: map[string]interface{}{}})
+		}
 		return nil
 	}
 	defer c.newScopeDepth()()
@@ -1066,10 +1357,16 @@ func (c *compiler) compileObject(e *Object) error {
 		}
 	}
 	w := make(map[string]interface{}, l)
+	keys := make([]string, l)
 	for i := 0; i < l; i++ {
-		w[c.codes[pc+i*3].v.(string)] = c.codes[pc+i*3+2].v
+		keys[i] = c.codes[pc+i*3].v.(string)
+		w[keys[i]] = c.codes[pc+i*3+2].v
+	}
+	if c.ordered {
+		c.codes[pc-1] = &code{op: opconst, v: newOrderedMapWithKeys(keys, w)}
+	} else {
+		c.codes[pc-1] = &code{op: opconst, v: w}
 	}
-	c.codes[pc-1] = &code{op: opconst, v: w}
 	c.codes = c.codes[:pc]
 	return nil
 }
@@ -1180,17 +1477,24 @@ func (c *compiler) compileArray(e *Array) error {
 
 func (c *compiler) compileUnary(e *Unary) error {
 	c.appendCodeInfo(e)
+	pc := c.pc()
 	if err := c.compileTerm(e.Term); err != nil {
 		return err
 	}
+	var name string
 	switch e.Op {
 	case OpAdd:
-		return c.compileCall("_plus", nil)
+		name = "_plus"
 	case OpSub:
-		return c.compileCall("_negate", nil)
+		name = "_negate"
 	default:
 		return fmt.Errorf("unexpected operator in Unary: %s", e.Op)
 	}
+	if err := c.compileCall(name, nil); err != nil {
+		return err
+	}
+	c.optimizeConstantCall(pc)
+	return nil
 }
 
 func (c *compiler) compileFormat(fmt string, str *String) error {
@@ -1215,6 +1519,8 @@ func formatToFunc(fmt string) *Func {
 		return &Func{Name: "tojson"}
 	case "@html":
 		return &Func{Name: "_tohtml"}
+	case "@htmld":
+		return &Func{Name: "_tohtmld"}
 	case "@uri":
 		return &Func{Name: "_touri"}
 	case "@csv":
@@ -1227,6 +1533,18 @@ func formatToFunc(fmt string) *Func {
 		return &Func{Name: "_tobase64"}
 	case "@base64d":
 		return &Func{Name: "_tobase64d"}
+	case "@base32":
+		return &Func{Name: "_tobase32"}
+	case "@base32d":
+		return &Func{Name: "_tobase32d"}
+	case "@base32hex":
+		return &Func{Name: "_tobase32hex"}
+	case "@base32hexd":
+		return &Func{Name: "_tobase32hexd"}
+	case "@zbase32":
+		return &Func{Name: "_tozbase32"}
+	case "@zbase32d":
+		return &Func{Name: "_tozbase32d"}
 	default:
 		return nil
 	}
@@ -1242,14 +1560,14 @@ func (c *compiler) compileString(s *String, f *Func) error {
 	}
 	e := s.Queries[0]
 	if e.Term.Str == nil {
-		e = &Query{Left: e, Op: OpPipe, Right: &Query{Term: &Term{Type: TermTypeFunc, Func: f}}}
+		e = &Query{Left: e, Op: OpPipe, Right: &Query{Term: &Term{Type: TermTypeFunc, Func: f, Offset: -1}}}
 	}
 	for i := 1; i < len(s.Queries); i++ {
 		x := s.Queries[i]
 		if x.Term.Str == nil {
-			x = &Query{Left: x, Op: OpPipe, Right: &Query{Term: &Term{Type: TermTypeFunc, Func: f}}}
+			x = &Query{Left: x, Op: OpPipe, Right: &Query{Term: &Term{Type: TermTypeFunc, Func: f, Offset: -1}}}
 		}
-		e = &Query{Left: e, Op: OpAdd, Right: x}
+		e = &Query{Left: e, Op: OpAdd, Right: x, Offset: -1}
 	}
 	return c.compileQuery(e)
 }
@@ -1288,26 +1606,77 @@ func (c *compiler) compileTermSuffix(e *Term, s *Suffix) error {
 }
 
 func (c *compiler) compileCall(name string, args []*Query) error {
-	return c.compileCallInternal(
-		[3]interface{}{internalFuncs[name].callback, len(args), name},
+	fn := internalFuncs[name]
+	if c.decimal {
+		if f, ok := decimalFuncs[name]; ok {
+			fn = f
+		}
+	}
+	if c.datetime {
+		if f, ok := datetimeFuncs[name]; ok {
+			fn = f
+		}
+	}
+	if c.ordered {
+		if f, ok := orderedFuncs[name]; ok {
+			fn = f
+		}
+	}
+	var callback interface{} = fn.callback
+	if f, ok := contextFuncs[name]; ok {
+		callback = f
+	}
+	if err := c.compileCallInternal(
+		[3]interface{}{callback, len(args), name},
 		args,
 		nil,
 		name == "_index" || name == "_slice",
-	)
+	); err != nil {
+		return err
+	}
+	if fn.iter {
+		c.append(&code{op: opeach})
+	}
+	return nil
 }
 
 func (c *compiler) compileCallPc(fn *funcinfo, args []*Query) error {
-	if len(args) == 0 {
-		return c.compileCallInternal(fn.pc, args, nil, false)
+	if fn.impure {
+		c.markImpure(-1)
+	}
+	var recursive bool
+	for _, fi := range c.defs {
+		if fi == fn { // the purity is not determined yet
+			c.markImpure(fn.scope)
+			recursive = true
+			break
+		}
 	}
+	if len(args) == 0 && fn.memo && !fn.impure && !recursive {
+		c.append(&code{op: opcallmemo, v: fn.pc})
+		return nil
+	}
+	// The call in the body of a function can reuse the scope of the function in
+	// the tail position when the callee is defined outside the scope and takes
+	// no closure arguments, which refer to the scope (ref: optimizeTailRec).
+	tailcall := len(c.defs) > 0 && fn.scope < len(c.scopes) &&
+		!strings.HasPrefix(c.defs[len(c.defs)-1].name, "lambda:")
 	xs, vars := make([]*Query, len(args)), make(map[int]bool, len(fn.args))
 	for i, j := range fn.argsorder {
 		xs[i] = args[j]
 		if fn.args[j][0] == '$' {
 			vars[i] = true
+		} else {
+			tailcall = false
 		}
 	}
-	return c.compileCallInternal(fn.pc, xs, vars, false)
+	if err := c.compileCallInternal(fn.pc, xs, vars, false); err != nil {
+		return err
+	}
+	if tailcall {
+		c.tailcalls[c.codes[len(c.codes)-1]] = true
+	}
+	return nil
 }
 
 func (c *compiler) compileCallInternal(fn interface{}, args []*Query, vars map[int]bool, indexing bool) error {
@@ -1368,6 +1737,16 @@ func (c *compiler) compileCallInternal(fn interface{}, args []*Query, vars map[i
 	return nil
 }
 
+// markSpan records the position of the function or the operator in the source
+// for the last code, which may raise an error. Builtin functions and modules
+// are not recorded, and the errors raised there are reported at call sites.
+func (c *compiler) markSpan(start, end int, name string) {
+	if c.spans == nil || start < 0 || len(c.codes) == 0 {
+		return
+	}
+	c.spans[c.codes[len(c.codes)-1]] = &codespan{start, end, name}
+}
+
 func (c *compiler) append(code *code) {
 	c.codes = append(c.codes, code)
 }
@@ -1382,6 +1761,11 @@ func (c *compiler) lazy(f func() *code) func() {
 	return func() { c.codes[i] = f() }
 }
 
+// optimizeTailRec optimizes the calls in the tail positions, which are followed
+// by returning from the scope. The recursive call of the function without
+// variables is replaced with the jump to the head of the function, and other
+// calls marked in compileCallPc are replaced with optailcall, which reuses the
+// scope of the caller when no fork refers to it.
 func (c *compiler) optimizeTailRec() {
 	var pcs []int
 	targets := map[int]bool{}
@@ -1394,16 +1778,20 @@ L:
 				targets[i] = true
 			}
 		case opcall:
-			if j, ok := c.codes[i].v.(int); !ok ||
-				len(pcs) == 0 || pcs[len(pcs)-1] != j || !targets[j] {
+			j, ok := c.codes[i].v.(int)
+			if !ok || len(pcs) == 0 {
 				break
 			}
-			for j := i + 1; j < l; {
-				switch c.codes[j].op {
+			for k := i + 1; k < l; {
+				switch c.codes[k].op {
 				case opjump:
-					j = c.codes[j].v.(int)
+					k = c.codes[k].v.(int)
 				case opret:
-					c.codes[i] = &code{op: opjump, v: pcs[len(pcs)-1] + 1}
+					if pcs[len(pcs)-1] == j && targets[j] {
+						c.codes[i] = &code{op: opjump, v: j + 1}
+					} else if c.tailcalls[c.codes[i]] {
+						c.codes[i].op = optailcall
+					}
 					continue L
 				default:
 					continue L
@@ -1437,3 +1825,73 @@ func (c *compiler) optimizeJumps() {
 		}
 	}
 }
+
+// optimizePeephole rewrites the short sequences of the instructions. The pairs
+// of pushing a value and popping it (and empty expressions) are removed, the
+// pushing followed by overwriting the value is replaced with pushing the value
+// directly, and the call of _index
+// with a constant key is specialized to opindex. The sequences are rewritten
+// only when no jump targets the middle of them, and the targets are relocated
+// after removing the instructions.
+func (c *compiler) optimizePeephole() {
+	targets := map[int]bool{}
+	refs := map[[2]int]int{}
+	for _, code := range c.codes {
+		switch code.op {
+		case opfork, opforktrybegin, opforkalt, opjump, opjumpifnot, oppushpc, opcall, opcallmemo, optailcall:
+			if pc, ok := code.v.(int); ok {
+				targets[pc] = true
+			}
+		case opload, opstore, opappend:
+			refs[code.v.([2]int)]++
+		}
+	}
+	removed := make([]bool, len(c.codes))
+	for i := 0; i < len(c.codes)-1; i++ {
+		code, next := c.codes[i], c.codes[i+1]
+		switch {
+		case code.op == opnop:
+			removed[i] = true
+		case targets[i+1]:
+		case next.op == oppop && (code.op == oppush || code.op == opdup || code.op == opload),
+			next.op == opexpend && code.op == opexpbegin:
+			removed[i], removed[i+1] = true, true
+			i++
+		case next.op == oppop && code.op == opconst:
+			removed[i] = true
+		case next.op == opconst && (code.op == opdup || code.op == opload):
+			removed[i], next.op = true, oppush
+		case code.op == opstore && i+4 < len(c.codes) && refs[code.v.([2]int)] == 3 &&
+			next.op == oppush && !targets[i+2] && !targets[i+3] && !targets[i+4] &&
+			c.codes[i+2].op == opload && c.codes[i+2].v == code.v &&
+			c.codes[i+3].op == opload && c.codes[i+3].v == code.v &&
+			c.codes[i+4].op == opcall:
+			if x, ok := c.codes[i+4].v.([3]interface{}); ok && x[2] == "_index" {
+				removed[i], removed[i+1], removed[i+2], removed[i+3] = true, true, true, true
+				c.codes[i+4].op, c.codes[i+4].v = opindex, next.v
+				i += 4
+			}
+		}
+	}
+	pcs := make([]int, len(c.codes)+1)
+	codes := make([]*code, 0, len(c.codes))
+	for i, code := range c.codes {
+		pcs[i] = len(codes)
+		if !removed[i] {
+			codes = append(codes, code)
+		}
+	}
+	pcs[len(c.codes)] = len(codes)
+	for _, code := range codes {
+		switch code.op {
+		case opfork, opforktrybegin, opforkalt, opjump, opjumpifnot, oppushpc, opcall, opcallmemo, optailcall:
+			if pc, ok := code.v.(int); ok {
+				code.v = pcs[pc]
+			}
+		}
+	}
+	for i := range c.codeinfos {
+		c.codeinfos[i].pc = pcs[c.codeinfos[i].pc]
+	}
+	c.codes = codes
+}
@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"log"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -130,8 +131,116 @@ func TestCodeCompile_OptimizeConstants(t *testing.T) {
 	}
 }
 
+func TestCodeCompile_OptimizeConstantFolding(t *testing.T) {
+	query, err := gojq.Parse(`if 1 < 2 and -1 != 1 then "a" + "b" else error end`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := reflect.ValueOf(code).Elem().FieldByName("codes").Len(), 3; expected != got {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+	iter := code.Run(nil)
+	for {
+		got, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if expected := "ab"; !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected: %v, got: %v", expected, got)
+		}
+	}
+}
+
+func TestCodeCompile_OptimizePeephole(t *testing.T) {
+	query, err := gojq.Parse(`.foo | if . then {a: .[0]} else empty end`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(query, gojq.WithOptimization())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := reflect.ValueOf(code).Elem().FieldByName("codes").Len(), 12; expected != got {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+	if got, expected := code.Disassemble(), `0	scope	[0,3]
+1	index	"foo"
+2	dup
+3	jumpifnot	10
+4	store	[0,1]
+5	push	"a"
+6	load	[0,1]
+7	index	0
+8	object	1
+9	jump	11
+10	backtrack
+11	ret
+`; expected != got {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+	for _, tc := range []struct {
+		input    interface{}
+		expected []interface{}
+	}{
+		{map[string]interface{}{"foo": []interface{}{1, 2}}, []interface{}{map[string]interface{}{"a": 1}}},
+		{map[string]interface{}{"foo": false}, nil},
+		{map[string]interface{}{"foo": 1}, []interface{}{"expected an array but got: number (1)"}},
+	} {
+		var got []interface{}
+		iter := code.Run(tc.input)
+		for {
+			v, ok := iter.Next()
+			if !ok {
+				break
+			}
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+			got = append(got, v)
+		}
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("expected: %v, got: %v", tc.expected, got)
+		}
+	}
+}
+
+func TestCodeCompile_Memoize(t *testing.T) {
+	for _, tc := range []struct {
+		src      string
+		expected bool
+	}{
+		{`def f: {"a": 1}[.]; f`, true},
+		{`def f: [.[] | . * 2] | add; def g: f + 1; g`, true},
+		{`def f: def g: . + 1; g | g; f`, true},
+		{`def f: 1; f | (def g: . + f; g)`, true},
+		{`. as $x | def f: $x; f`, false},
+		{`def f(g): def h: g; h; f(.)`, false},
+		{`def f: random; f`, false},
+		{`def f: now; def g: f; g`, false},
+		{`def f: .; path(.)`, false},
+	} {
+		t.Run(tc.src, func(t *testing.T) {
+			query, err := gojq.Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, err := gojq.Compile(query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Contains(code.Disassemble(), "callmemo"); got != tc.expected {
+				t.Errorf("expected: %v, got: %v", tc.expected, got)
+			}
+		})
+	}
+}
+
 func TestCodeCompile_OptimizeTailRec(t *testing.T) {
-	query, err := gojq.Parse("range(10)")
+	query, err := gojq.Parse("0 | while(. < 10; . + 1)")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -140,11 +249,11 @@ func TestCodeCompile_OptimizeTailRec(t *testing.T) {
 		t.Fatal(err)
 	}
 	codes := reflect.ValueOf(code).Elem().FieldByName("codes")
-	if got, expected := codes.Len(), 85; expected != got {
+	if got, expected := codes.Len(), 48; expected != got {
 		t.Errorf("expected: %v, got: %v", expected, got)
 	}
-	op1 := codes.Index(1).Elem().FieldByName("op")
-	op2 := codes.Index(32).Elem().FieldByName("op") // test jump of _while
+	op1 := codes.Index(2).Elem().FieldByName("op")
+	op2 := codes.Index(20).Elem().FieldByName("op") // test jump of _while
 	if got, expected := *(*int)(unsafe.Pointer(op2.UnsafeAddr())),
 		*(*int)(unsafe.Pointer(op1.UnsafeAddr())); expected != got {
 		t.Errorf("expected: %v, got: %v", expected, got)
@@ -166,6 +275,44 @@ func TestCodeCompile_OptimizeTailRec(t *testing.T) {
 	}
 }
 
+func TestCodeCompile_OptimizeTailCall(t *testing.T) {
+	for _, tc := range []struct {
+		src      string
+		tailcall bool
+		expected interface{}
+	}{
+		{`def f: if . < 100000 then . + 1 | f else . end; f`, true, 100000},
+		{`def f($n; $acc): if $n > 0 then f($n - 1; $acc + 2) else $acc end; f(100000; 0)`, true, 200000},
+		{`def f: . + 1; def g: if . < 100000 then f | g else . end; g`, true, 100000},
+		{`def f($x): def g: if . < 100000 then . + $x | g else . end; g; f(1)`, true, 100000},
+		{`def f: if . < 5 then (. + 1 | f) * 2 else . end; f`, false, 160},
+		{`def f(g): if . < 10 then g | f(g) else . end; f(. + 1)`, false, 10},
+		{`def f: . as $x | def g: $x; g; f`, false, 0},
+	} {
+		t.Run(tc.src, func(t *testing.T) {
+			query, err := gojq.Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, err := gojq.Compile(query, gojq.WithMaxDepth(20))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Contains(code.Disassemble(), "tailcall"); got != tc.tailcall {
+				t.Errorf("expected: %v, got: %v", tc.tailcall, got)
+			}
+			iter := code.Run(0)
+			got, ok := iter.Next()
+			if !ok {
+				t.Fatal("should emit a value")
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected: %v, got: %v", tc.expected, got)
+			}
+		})
+	}
+}
+
 func TestCodeCompile_OptimizeJumps(t *testing.T) {
 	query, err := gojq.Parse("def f: 1; def g: 2; def h: 3; f")
 	if err != nil {
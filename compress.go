@@ -0,0 +1,65 @@
+package gojq
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+)
+
+// compressFunc creates the function to compress a string (in UTF-8) or a byte
+// string, which results in a byte string.
+func compressFunc(name string, newWriter func(io.Writer) io.WriteCloser) function {
+	return argFunc0(func(v interface{}) interface{} {
+		bs, ok := toBytes(v)
+		if !ok {
+			return &funcTypeError{name, v}
+		}
+		var buf bytes.Buffer
+		w := newWriter(&buf)
+		if _, err := w.Write(bs); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		return buf.Bytes()
+	})
+}
+
+// decompressFunc creates the function to decompress a byte string or a base64
+// string, which results in a byte string.
+func decompressFunc(name string, newReader func(io.Reader) (io.ReadCloser, error)) function {
+	return argFunc0(func(v interface{}) interface{} {
+		var bs []byte
+		switch v := v.(type) {
+		case []byte:
+			bs = v
+		case string:
+			var err error
+			if bs, err = base64.StdEncoding.DecodeString(v); err != nil {
+				if bs, err = base64.RawStdEncoding.DecodeString(v); err != nil {
+					return &funcTypeError{name, v}
+				}
+			}
+		default:
+			return &funcTypeError{name, v}
+		}
+		r, err := newReader(bytes.NewReader(bs))
+		if err != nil {
+			return &decompressError{name, err}
+		}
+		defer r.Close()
+		if bs, err = ioutil.ReadAll(r); err != nil {
+			return &decompressError{name, err}
+		}
+		return bs
+	})
+}
+
+func newGzipWriter(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }
+func newZlibWriter(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }
+
+func newGzipReader(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) }
@@ -0,0 +1,169 @@
+package gojq
+
+import (
+	"encoding/csv"
+	"strings"
+	"unicode/utf8"
+)
+
+// csvOptions are the options of fromcsv and tocsv; header to handle the first
+// record as the field names, and sep to change the field separator.
+type csvOptions struct {
+	header bool
+	sep    rune
+}
+
+func parseCSVOptions(name string, args []interface{}) (*csvOptions, error) {
+	opts := &csvOptions{sep: ','}
+	if len(args) == 0 {
+		return opts, nil
+	}
+	if x, ok := args[0].(*OrderedMap); ok {
+		args[0] = x.JQValueToJSON()
+	}
+	m, ok := args[0].(map[string]interface{})
+	if !ok {
+		return nil, &funcTypeError{name, args[0]}
+	}
+	for k, x := range m {
+		switch k {
+		case "header":
+			if opts.header, ok = x.(bool); !ok {
+				return nil, &funcTypeError{name, x}
+			}
+		case "sep":
+			s, ok := x.(string)
+			if !ok || utf8.RuneCountInString(s) != 1 {
+				return nil, &funcTypeError{name, x}
+			}
+			opts.sep, _ = utf8.DecodeRuneInString(s)
+			if opts.sep == '"' || opts.sep == '\r' || opts.sep == '\n' ||
+				opts.sep == utf8.RuneError {
+				return nil, &funcTypeError{name, x}
+			}
+		default:
+			return nil, &funcTypeError{name, m}
+		}
+	}
+	return opts, nil
+}
+
+// funcFromCSV parses the CSV string to the array of the records (arrays of the
+// strings), or the array of the objects keyed by the header fields.
+func funcFromCSV(v interface{}, args []interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return &funcTypeError{"fromcsv", v}
+	}
+	opts, err := parseCSVOptions("fromcsv", args)
+	if err != nil {
+		return err
+	}
+	r := csv.NewReader(strings.NewReader(s))
+	r.Comma = opts.sep
+	if !opts.header {
+		r.FieldsPerRecord = -1
+	}
+	records, err := r.ReadAll()
+	if err != nil {
+		return err
+	}
+	vs := make([]interface{}, 0, len(records))
+	if !opts.header {
+		for _, record := range records {
+			xs := make([]interface{}, len(record))
+			for i, x := range record {
+				xs[i] = x
+			}
+			vs = append(vs, xs)
+		}
+		return vs
+	}
+	for i, record := range records {
+		if i == 0 {
+			continue
+		}
+		m := make(map[string]interface{}, len(record))
+		for j, x := range record {
+			m[records[0][j]] = x
+		}
+		vs = append(vs, m)
+	}
+	return vs
+}
+
+// funcToCSVRows formats the array of the arrays, or the array of the objects to
+// the CSV string. The columns of the objects are the keys in appearance order,
+// where the keys of each object are sorted unless the object is *OrderedMap.
+func funcToCSVRows(v interface{}, args []interface{}) interface{} {
+	vs, ok := v.([]interface{})
+	if !ok {
+		return &expectedArrayError{v}
+	}
+	opts, err := parseCSVOptions("tocsv", args)
+	if err != nil {
+		return err
+	}
+	var keys []string
+	if len(vs) > 0 {
+		switch vs[0].(type) {
+		case map[string]interface{}, *OrderedMap:
+			keys = []string{}
+			seen := map[string]struct{}{}
+			for _, v := range vs {
+				var ks []string
+				switch m := v.(type) {
+				case map[string]interface{}:
+					ks = sortedKeys(m)
+				case *OrderedMap:
+					ks = m.keys
+				default:
+					return &expectedObjectError{v}
+				}
+				for _, k := range ks {
+					if _, ok := seen[k]; !ok {
+						seen[k] = struct{}{}
+						keys = append(keys, k)
+					}
+				}
+			}
+		}
+	}
+	var s strings.Builder
+	w := csv.NewWriter(&s)
+	w.Comma = opts.sep
+	if opts.header && keys != nil {
+		if err := w.Write(keys); err != nil {
+			return err
+		}
+	}
+	for _, v := range vs {
+		var xs []interface{}
+		if keys != nil {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				m = v.(*OrderedMap).values
+			}
+			xs = make([]interface{}, len(keys))
+			for i, k := range keys {
+				xs[i] = m[k]
+			}
+		} else if xs, ok = v.([]interface{}); !ok {
+			return &expectedArrayError{v}
+		}
+		record := make([]string, len(xs))
+		for i, x := range xs {
+			if record[i], err = toCSVTSV("csv", x, func(s string) string { return s }); err != nil {
+				return err
+			}
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return s.String()
+}
@@ -0,0 +1,102 @@
+package gojq
+
+import (
+	"math"
+	"time"
+)
+
+// datetimeFuncs are the functions replacing the internal functions when the
+// datetime mode is enabled by WithDatetime.
+var datetimeFuncs map[string]function
+
+func init() {
+	datetimeFuncs = map[string]function{
+		"mktime": argFunc0(funcMktimeDatetime),
+	}
+}
+
+// formatDatetime formats the datetime value in the output.
+func formatDatetime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+func funcToDatetime(v interface{}) interface{} {
+	switch v := v.(type) {
+	case time.Time:
+		return v
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t
+			}
+		}
+		return &funcTypeError{"todatetime", v}
+	case []interface{}:
+		t, err := arrayToTime("todatetime", v, time.UTC)
+		if err != nil {
+			return err
+		}
+		return t
+	default:
+		if x, ok := toFloat(v); ok && !math.IsNaN(x) && !math.IsInf(x, 0) {
+			return epochToTime(x).UTC()
+		}
+		return &funcTypeError{"todatetime", v}
+	}
+}
+
+func funcMktimeDatetime(v interface{}) interface{} {
+	if t, ok := v.(time.Time); ok {
+		return t
+	}
+	if a, ok := v.([]interface{}); ok {
+		t, err := arrayToTime("mktime", a, time.UTC)
+		if err != nil {
+			return err
+		}
+		return t
+	}
+	return &funcTypeError{"mktime", v}
+}
+
+func epochToTime(v float64) time.Time {
+	return time.Unix(int64(v), int64((v-math.Floor(v))*1e9))
+}
+
+func timeToEpoch(t time.Time) float64 {
+	return float64(t.Unix()) + float64(t.Nanosecond())/1e9
+}
+
+func secondsToDuration(v float64) time.Duration {
+	return time.Duration(math.Round(v * 1e9))
+}
+
+// addDatetime adds the seconds to the datetime value.
+func addDatetime(l, r interface{}) (interface{}, bool) {
+	if t, ok := l.(time.Time); ok {
+		if x, ok := toFloat(r); ok {
+			return t.Add(secondsToDuration(x)), true
+		}
+	} else if t, ok := r.(time.Time); ok {
+		if x, ok := toFloat(l); ok {
+			return t.Add(secondsToDuration(x)), true
+		}
+	}
+	return nil, false
+}
+
+// subDatetime subtracts the seconds from the datetime value, or calculates the
+// seconds between the datetime values.
+func subDatetime(l, r interface{}) (interface{}, bool) {
+	if t, ok := l.(time.Time); ok {
+		switch r := r.(type) {
+		case time.Time:
+			return float64(t.Unix()-r.Unix()) + float64(t.Nanosecond()-r.Nanosecond())/1e9, true
+		default:
+			if x, ok := toFloat(r); ok {
+				return t.Add(-secondsToDuration(x)), true
+			}
+		}
+	}
+	return nil, false
+}
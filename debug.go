@@ -74,7 +74,7 @@ func (env *env) debugCodes() {
 	for i, c := range env.codes {
 		pc := i
 		switch c.op {
-		case opcall:
+		case opcall, opcallmemo, optailcall:
 			if x, ok := c.v.(int); ok {
 				pc = x
 			}
@@ -86,7 +86,7 @@ func (env *env) debugCodes() {
 		}
 		var s string
 		if name := env.lookupInfoName(pc); name != "" {
-			if (c.op == opcall || c.op == opjump) && !strings.HasPrefix(name, "module ") {
+			if (c.op == opcall || c.op == opcallmemo || c.op == optailcall || c.op == opjump) && !strings.HasPrefix(name, "module ") {
 				s = "\t## call " + name
 			} else {
 				s = "\t## " + name
@@ -113,7 +113,7 @@ func (env *env) debugState(pc int, backtrack bool) {
 		sb.WriteString(debugJSON(env.stack.data[xs[i]].value))
 	}
 	switch c.op {
-	case opcall:
+	case opcall, opcallmemo, optailcall:
 		if x, ok := c.v.(int); ok {
 			pc = x
 		}
@@ -124,7 +124,7 @@ func (env *env) debugState(pc int, backtrack bool) {
 		}
 	}
 	if name := env.lookupInfoName(pc); name != "" {
-		if (c.op == opcall || c.op == opjump) && !strings.HasPrefix(name, "module ") {
+		if (c.op == opcall || c.op == opcallmemo || c.op == optailcall || c.op == opjump) && !strings.HasPrefix(name, "module ") {
 			sb.WriteString("\t\t\t## call " + name)
 		} else {
 			sb.WriteString("\t\t\t## " + name)
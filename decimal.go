@@ -0,0 +1,167 @@
+package gojq
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// decimalDivisionPrecision is the number of digits after the decimal point of
+// the quotients which cannot be represented by finite decimals.
+const decimalDivisionPrecision = 16
+
+// decimalFuncs are the functions replacing the internal functions when the
+// decimal mode is enabled by WithDecimal.
+var decimalFuncs map[string]function
+
+func init() {
+	decimalFuncs = map[string]function{
+		"add":       argFunc0(funcAddDecimal),
+		"_add":      argFunc2(funcOpAddDecimal),
+		"_range":    rangeFunc(funcOpAddDecimal),
+		"_subtract": argFunc2(funcOpSubDecimal),
+		"_multiply": argFunc2(funcOpMulDecimal),
+		"_divide":   argFunc2(funcOpDivDecimal),
+	}
+}
+
+func funcAddDecimal(v interface{}) interface{} {
+	vs, ok := v.([]interface{})
+	if !ok {
+		return internalFuncs["add"].callback(v, nil)
+	}
+	for _, x := range vs {
+		if _, ok := toDecimal(x); !ok && x != nil {
+			return internalFuncs["add"].callback(v, nil)
+		}
+	}
+	v = nil
+	for _, x := range vs {
+		v = funcOpAddDecimal(nil, v, x)
+	}
+	return v
+}
+
+func funcOpAddDecimal(_, l, r interface{}) interface{} {
+	return decimalBinop("_add", l, r, false, func(x, y *big.Rat) interface{} {
+		return fromDecimal(x.Add(x, y))
+	})
+}
+
+func funcOpSubDecimal(_, l, r interface{}) interface{} {
+	return decimalBinop("_subtract", l, r, false, func(x, y *big.Rat) interface{} {
+		return fromDecimal(x.Sub(x, y))
+	})
+}
+
+func funcOpMulDecimal(_, l, r interface{}) interface{} {
+	return decimalBinop("_multiply", l, r, false, func(x, y *big.Rat) interface{} {
+		return fromDecimal(x.Mul(x, y))
+	})
+}
+
+func funcOpDivDecimal(_, l, r interface{}) interface{} {
+	return decimalBinop("_divide", l, r, true, func(x, y *big.Rat) interface{} {
+		if y.Sign() == 0 {
+			if x.Sign() == 0 {
+				return math.NaN()
+			}
+			return &zeroDivisionError{l, r}
+		}
+		return fromDecimal(x.Quo(x, y))
+	})
+}
+
+// decimalBinop calculates the operator in decimals when both operands are
+// numbers, otherwise delegates to the internal function. The operations of
+// integers are delegated unless ints is true, because they are exact.
+func decimalBinop(
+	name string, l, r interface{}, ints bool, f func(*big.Rat, *big.Rat) interface{},
+) interface{} {
+	if !ints && isInteger(l) && isInteger(r) {
+		return internalFuncs[name].callback(nil, []interface{}{l, r})
+	}
+	x, ok := toDecimal(l)
+	if !ok {
+		return internalFuncs[name].callback(nil, []interface{}{l, r})
+	}
+	y, ok := toDecimal(r)
+	if !ok {
+		return internalFuncs[name].callback(nil, []interface{}{l, r})
+	}
+	return f(x, y)
+}
+
+func isInteger(v interface{}) bool {
+	switch v.(type) {
+	case int, *big.Int:
+		return true
+	default:
+		return false
+	}
+}
+
+// toDecimal converts the number to a rational number. Floating-point numbers
+// are converted from the shortest decimal representation, so that 0.1 is
+// handled as 1/10, not as the binary approximation.
+func toDecimal(v interface{}) (*big.Rat, bool) {
+	switch v := v.(type) {
+	case int:
+		return new(big.Rat).SetInt64(int64(v)), true
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, false
+		}
+		return new(big.Rat).SetString(strconv.FormatFloat(v, 'g', -1, 64))
+	case *big.Int:
+		return new(big.Rat).SetInt(v), true
+	case json.Number:
+		return new(big.Rat).SetString(v.String())
+	default:
+		return nil, false
+	}
+}
+
+// fromDecimal converts the rational number to an integer or a json.Number of
+// the decimal representation. The numbers which cannot be represented by finite
+// decimals are rounded to decimalDivisionPrecision digits after the point.
+func fromDecimal(x *big.Rat) interface{} {
+	if x.IsInt() {
+		return normalizeNumbers(new(big.Int).Set(x.Num()))
+	}
+	prec, exact := decimalDigits(x.Denom())
+	if !exact {
+		x, _ = new(big.Rat).SetString(x.FloatString(decimalDivisionPrecision))
+		return fromDecimal(x)
+	}
+	return json.Number(x.FloatString(prec))
+}
+
+// decimalDigits returns the number of digits after the decimal point to
+// represent the fractions of the denominator, and reports whether the fractions
+// can be represented by finite decimals.
+func decimalDigits(d *big.Int) (int, bool) {
+	d = new(big.Int).Set(d)
+	five := big.NewInt(5)
+	var m big.Int
+	var twos, fives int
+	for d.Bit(0) == 0 {
+		d.Rsh(d, 1)
+		twos++
+	}
+	for {
+		if m.Mod(d, five); m.Sign() != 0 {
+			break
+		}
+		d.Quo(d, five)
+		fives++
+	}
+	if d.Cmp(big.NewInt(1)) != 0 {
+		return 0, false
+	}
+	if twos > fives {
+		return twos, true
+	}
+	return fives, true
+}
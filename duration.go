@@ -0,0 +1,213 @@
+package gojq
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// isoDuration is the ISO 8601 duration (PnYnMnWnDTnHnMnS). The nominal length
+// of a year is 365 days, and that of a month is 30 days.
+type isoDuration struct {
+	negative                  bool
+	years, months, weeks      float64
+	days, hours, minutes, sec float64
+}
+
+func parseDuration(s string) (*isoDuration, bool) {
+	d := &isoDuration{}
+	switch {
+	case strings.HasPrefix(s, "-"):
+		d.negative, s = true, s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") {
+		return nil, false
+	}
+	s = s[1:]
+	date, clock := s, ""
+	if i := strings.IndexByte(s, 'T'); i >= 0 {
+		if date, clock = s[:i], s[i+1:]; clock == "" || strings.ContainsAny(date, ".,") {
+			return nil, false
+		}
+	} else if s == "" {
+		return nil, false
+	}
+	if !parseDurationComponents(date, "YMWD",
+		[]*float64{&d.years, &d.months, &d.weeks, &d.days}) ||
+		!parseDurationComponents(clock, "HMS",
+			[]*float64{&d.hours, &d.minutes, &d.sec}) {
+		return nil, false
+	}
+	return d, true
+}
+
+// parseDurationComponents parses the numbers followed by the designators in
+// order. Only the last component can have the fraction.
+func parseDurationComponents(s, designators string, xs []*float64) bool {
+	for s != "" {
+		i := strings.IndexAny(s, designators)
+		if i <= 0 {
+			return false
+		}
+		j := strings.IndexByte(designators, s[i])
+		num := strings.Replace(s[:i], ",", ".", 1)
+		if !isDurationNumber(num) || strings.IndexByte(num, '.') >= 0 && i+1 < len(s) {
+			return false
+		}
+		x, _ := strconv.ParseFloat(num, 64)
+		*xs[j] = x
+		s, designators, xs = s[i+1:], designators[j+1:], xs[j+1:]
+	}
+	return true
+}
+
+func isDurationNumber(s string) bool {
+	i := strings.IndexByte(s, '.')
+	if i < 0 {
+		i = len(s)
+	} else if i == len(s)-1 {
+		return false
+	}
+	for j := 0; j < len(s); j++ {
+		if j != i && (s[j] < '0' || '9' < s[j]) {
+			return false
+		}
+	}
+	return i > 0
+}
+
+func (d *isoDuration) seconds() float64 {
+	x := (((d.years*365+d.months*30+d.weeks*7+d.days)*24+d.hours)*60+d.minutes)*60 + d.sec
+	if d.negative {
+		return -x
+	}
+	return x
+}
+
+// addTo adds the duration to the time. The integral years, months and days
+// are added in the calendar, and the rest is added in seconds. The day of month
+// is clamped to the last day of the month (P1M after January 31 is the last
+// day of February).
+func (d *isoDuration) addTo(t time.Time) time.Time {
+	years, y := math.Modf(d.years)
+	months, m := math.Modf(d.months)
+	days, w := math.Modf(d.weeks*7 + d.days)
+	x := (y*365+m*30+w)*86400 + (d.hours*60+d.minutes)*60 + d.sec
+	if d.negative {
+		years, months, days, x = -years, -months, -days, -x
+	}
+	if years != 0 || months != 0 {
+		year, month, day := t.Date()
+		hour, min, sec := t.Clock()
+		month += time.Month(years*12 + months)
+		if last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day(); day > last {
+			day = last
+		}
+		t = time.Date(year, month, day, hour, min, sec, t.Nanosecond(), t.Location())
+	}
+	return t.AddDate(0, 0, int(days)).Add(secondsToDuration(x))
+}
+
+func formatDuration(x float64) string {
+	var sb strings.Builder
+	if x < 0 {
+		sb.WriteByte('-')
+		x = -x
+	}
+	sb.WriteByte('P')
+	days := math.Floor(x / 86400)
+	if days > 0 {
+		sb.WriteString(strconv.FormatFloat(days, 'f', -1, 64))
+		sb.WriteByte('D')
+	}
+	x -= days * 86400
+	hours, minutes := math.Floor(x/3600), math.Floor(math.Mod(x, 3600)/60)
+	sec := math.Round(math.Mod(x, 60)*1e9) / 1e9
+	if hours == 0 && minutes == 0 && sec == 0 {
+		if days == 0 {
+			sb.WriteString("T0S")
+		}
+		return sb.String()
+	}
+	sb.WriteByte('T')
+	if hours > 0 {
+		sb.WriteString(strconv.FormatFloat(hours, 'f', -1, 64))
+		sb.WriteByte('H')
+	}
+	if minutes > 0 {
+		sb.WriteString(strconv.FormatFloat(minutes, 'f', -1, 64))
+		sb.WriteByte('M')
+	}
+	if sec > 0 {
+		sb.WriteString(strconv.FormatFloat(sec, 'f', -1, 64))
+		sb.WriteByte('S')
+	}
+	return sb.String()
+}
+
+func funcDurationParse(v interface{}, args []interface{}) interface{} {
+	if len(args) > 0 {
+		v = args[0]
+	}
+	s, ok := v.(string)
+	if !ok {
+		return &funcTypeError{"duration_parse", v}
+	}
+	d, ok := parseDuration(s)
+	if !ok {
+		return &durationParseError{s}
+	}
+	return d.seconds()
+}
+
+func funcDurationFormat(v interface{}) interface{} {
+	x, ok := toFloat(v)
+	if !ok || math.IsNaN(x) || math.IsInf(x, 0) {
+		return &funcTypeError{"duration_format", v}
+	}
+	return formatDuration(x)
+}
+
+func funcDateAdd(v, x interface{}) interface{} {
+	t, ok := funcToDatetime(v).(time.Time)
+	if !ok {
+		return &funcTypeError{"date_add", v}
+	}
+	if s, ok := x.(string); ok {
+		d, ok := parseDuration(s)
+		if !ok {
+			return &durationParseError{s}
+		}
+		t = d.addTo(t)
+	} else if x, ok := toFloat(x); ok {
+		t = t.Add(secondsToDuration(x))
+	} else {
+		return &funcTypeError{"date_add", x}
+	}
+	switch v.(type) {
+	case time.Time:
+		return t
+	case string:
+		return t.Format(time.RFC3339Nano)
+	case []interface{}:
+		return epochToArray(timeToEpoch(t), time.UTC)
+	default:
+		return timeToEpoch(t)
+	}
+}
+
+func funcDateDiff(v, x interface{}) interface{} {
+	t, ok := funcToDatetime(v).(time.Time)
+	if !ok {
+		return &funcTypeError{"date_diff", v}
+	}
+	u, ok := funcToDatetime(x).(time.Time)
+	if !ok {
+		return &funcTypeError{"date_diff", x}
+	}
+	d, _ := subDatetime(t, u)
+	return d
+}
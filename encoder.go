@@ -2,19 +2,23 @@ package gojq
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"math"
 	"math/big"
 	"sort"
 	"strconv"
+	"time"
 	"unicode/utf8"
 )
 
 // Marshal returns the jq-flavored JSON encoding of v.
 //
 // This method only accepts limited types (nil, bool, int, float64, *big.Int,
-// string, []interface{} and map[string]interface{}) because these are the
-// possible types a gojq iterator can emit. This method marshals NaN to null,
+// string, []interface{}, map[string]interface{} and JQValue) because these are
+// the possible types a gojq iterator can emit (json.Number is also accepted for
+// WithPreciseNumbers). The byte strings of []byte are encoded in base64. This method marshals NaN to null,
 // truncates infinities to (+|-) math.MaxFloat64 and does not escape '<' and
 // '>' for embedding in HTML. These behaviors are based on the marshaler of jq
 // command and different from the standard library method json.Marshal.
@@ -32,9 +36,18 @@ func jsonMarshalBytes(v interface{}) []byte {
 	return b.Bytes()
 }
 
+func jsonMarshalIndent(v interface{}, indent int, sortKeys bool) string {
+	var b bytes.Buffer
+	(&encoder{w: &b, indent: indent, sortKeys: sortKeys}).encode(v)
+	return b.String()
+}
+
 type encoder struct {
-	w   *bytes.Buffer
-	buf [64]byte
+	w        *bytes.Buffer
+	buf      [64]byte
+	indent   int
+	depth    int
+	sortKeys bool
 }
 
 func (e *encoder) encode(v interface{}) {
@@ -53,12 +66,22 @@ func (e *encoder) encode(v interface{}) {
 		e.encodeFloat64(v)
 	case *big.Int:
 		e.w.Write(v.Append(e.buf[:0], 10))
+	case json.Number:
+		e.w.WriteString(v.String())
+	case time.Time:
+		e.encodeString(formatDatetime(v))
+	case []byte:
+		e.encodeString(base64.StdEncoding.EncodeToString(v))
 	case string:
 		e.encodeString(v)
 	case []interface{}:
 		e.encodeArray(v)
 	case map[string]interface{}:
 		e.encodeMap(v)
+	case *OrderedMap:
+		e.encodeOrderedMap(v)
+	case JQValue:
+		e.encode(normalizeNumbers(v.JQValueToJSON()))
 	default:
 		panic(fmt.Sprintf("invalid value: %v", v))
 	}
@@ -143,21 +166,27 @@ func (e *encoder) encodeString(s string) {
 
 func (e *encoder) encodeArray(vs []interface{}) {
 	e.w.WriteByte('[')
+	e.depth += e.indent
 	for i, v := range vs {
 		if i > 0 {
 			e.w.WriteByte(',')
 		}
+		e.writeIndent()
 		e.encode(v)
 	}
+	e.depth -= e.indent
+	if len(vs) > 0 {
+		e.writeIndent()
+	}
 	e.w.WriteByte(']')
 }
 
+type keyVal struct {
+	key string
+	val interface{}
+}
+
 func (e *encoder) encodeMap(vs map[string]interface{}) {
-	e.w.WriteByte('{')
-	type keyVal struct {
-		key string
-		val interface{}
-	}
 	kvs := make([]keyVal, len(vs))
 	var i int
 	for k, v := range vs {
@@ -167,13 +196,50 @@ func (e *encoder) encodeMap(vs map[string]interface{}) {
 	sort.Slice(kvs, func(i, j int) bool {
 		return kvs[i].key < kvs[j].key
 	})
+	e.encodeKeyVals(kvs)
+}
+
+func (e *encoder) encodeOrderedMap(m *OrderedMap) {
+	kvs := make([]keyVal, len(m.keys))
+	for i, k := range m.keys {
+		kvs[i] = keyVal{k, m.values[k]}
+	}
+	if e.sortKeys {
+		sort.Slice(kvs, func(i, j int) bool {
+			return kvs[i].key < kvs[j].key
+		})
+	}
+	e.encodeKeyVals(kvs)
+}
+
+func (e *encoder) encodeKeyVals(kvs []keyVal) {
+	e.w.WriteByte('{')
+	e.depth += e.indent
 	for i, kv := range kvs {
 		if i > 0 {
 			e.w.WriteByte(',')
 		}
+		e.writeIndent()
 		e.encodeString(kv.key)
 		e.w.WriteByte(':')
+		if e.indent != 0 {
+			e.w.WriteByte(' ')
+		}
 		e.encode(kv.val)
 	}
+	e.depth -= e.indent
+	if len(kvs) > 0 {
+		e.writeIndent()
+	}
 	e.w.WriteByte('}')
 }
+
+func (e *encoder) writeIndent() {
+	if e.indent == 0 {
+		return
+	}
+	e.w.WriteByte('\n')
+	for i := 0; i < e.depth; i++ {
+		e.w.WriteByte(' ')
+	}
+}
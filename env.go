@@ -10,20 +10,31 @@ type env struct {
 	values    []interface{}
 	codes     []*code
 	codeinfos []codeinfo
+	spans     map[int]*codespan
 	forks     []*fork
 	backtrack bool
 	offset    int
 	expdepth  int
 	args      [32]interface{} // len(env.args) > maxarity
 	ctx       context.Context
+	errspan   *codespan
+	errinput  interface{}
+	ordered   bool
+	limits    limits
+	steps     int
+	callindex int
+	parallel  bool
+	effects   map[string]struct{}
+	memo      map[int]*memoCache
 }
 
 func newEnv(ctx context.Context) *env {
 	return &env{
-		stack:  newStack(),
-		scopes: newStack(),
-		paths:  newStack(),
-		ctx:    ctx,
+		stack:     newStack(),
+		scopes:    newStack(),
+		paths:     newStack(),
+		ctx:       ctx,
+		callindex: -1,
 	}
 }
 
@@ -32,6 +43,13 @@ type scope struct {
 	offset    int
 	pc        int
 	saveindex int
+	depth     int
+}
+
+// limits are the limits of the execution set by WithMaxSteps, WithMaxDepth and
+// WithMaxValueSize. Zero values mean unlimited.
+type limits struct {
+	steps, depth, size int
 }
 
 type fork struct {
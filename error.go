@@ -1,10 +1,13 @@
 package gojq
 
 import (
+	"encoding/json"
 	"math/big"
+	"os"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // ValueError is an interface for errors with a value for internal function.
@@ -16,6 +19,149 @@ type ValueError interface {
 	Value() interface{}
 }
 
+// RuntimeError is an interface for errors emitted by the result iterator of
+// a query. Span returns the byte offsets of the function or the operator in
+// the query which raised the error (or -1 when unknown), and Name returns its
+// name (such as "tonumber", "+" and "." for indexing). Input returns the input
+// value of the function, or the value being indexed. Use errors.Unwrap to get
+// the underlying error. The errors returned by the custom functions are also
+// wrapped, so use errors.Is or errors.As instead of comparing them directly.
+type RuntimeError interface {
+	error
+	Span() (start, end int)
+	Name() string
+	Input() interface{}
+}
+
+type runtimeError struct {
+	err   error
+	span  *codespan
+	input interface{}
+}
+
+// newRuntimeError wraps the error emitted by the iterator, keeping the
+// ValueError interface and the exit code of the underlying error.
+func newRuntimeError(err error, span *codespan, input interface{}) error {
+	e := &runtimeError{err, span, input}
+	_, isValue := err.(ValueError)
+	_, hasExitCode := err.(interface{ ExitCode() int })
+	switch {
+	case isValue && hasExitCode:
+		return &runtimeValueExitCodeError{e}
+	case isValue:
+		return &runtimeValueError{e}
+	case hasExitCode:
+		return &runtimeExitCodeError{e}
+	default:
+		return e
+	}
+}
+
+func (err *runtimeError) Error() string {
+	return err.err.Error()
+}
+
+func (err *runtimeError) Unwrap() error {
+	return err.err
+}
+
+func (err *runtimeError) Span() (int, int) {
+	if err.span == nil {
+		return -1, -1
+	}
+	return err.span.start, err.span.end
+}
+
+func (err *runtimeError) Name() string {
+	if err.span == nil {
+		return ""
+	}
+	return err.span.name
+}
+
+func (err *runtimeError) Input() interface{} {
+	return err.input
+}
+
+func (err *runtimeError) IsEmptyError() bool {
+	e, ok := err.err.(interface{ IsEmptyError() bool })
+	return ok && e.IsEmptyError()
+}
+
+type runtimeValueError struct {
+	*runtimeError
+}
+
+func (err *runtimeValueError) Value() interface{} {
+	return err.err.(ValueError).Value()
+}
+
+type runtimeExitCodeError struct {
+	*runtimeError
+}
+
+func (err *runtimeExitCodeError) ExitCode() int {
+	return err.err.(interface{ ExitCode() int }).ExitCode()
+}
+
+type runtimeValueExitCodeError struct {
+	*runtimeError
+}
+
+func (err *runtimeValueExitCodeError) Value() interface{} {
+	return err.err.(ValueError).Value()
+}
+
+func (err *runtimeValueExitCodeError) ExitCode() int {
+	return err.err.(interface{ ExitCode() int }).ExitCode()
+}
+
+// LimitError is an interface for errors emitted by the result iterator when
+// the query exceeds the limits of the execution. Limit returns the name of the
+// limit ("steps", "depth" or "value size") and its value. Refer to WithMaxSteps,
+// WithMaxDepth and WithMaxValueSize to set the limits. These errors cannot be
+// caught by try-catch, and the iterator emits no more values after the error.
+type LimitError interface {
+	error
+	Limit() (string, int)
+}
+
+type stepLimitError struct {
+	limit int
+}
+
+func (err *stepLimitError) Error() string {
+	return "exceeds the maximum number of execution steps: " + strconv.Itoa(err.limit)
+}
+
+func (err *stepLimitError) Limit() (string, int) {
+	return "steps", err.limit
+}
+
+type depthLimitError struct {
+	limit int
+}
+
+func (err *depthLimitError) Error() string {
+	return "exceeds the maximum recursion depth: " + strconv.Itoa(err.limit)
+}
+
+func (err *depthLimitError) Limit() (string, int) {
+	return "depth", err.limit
+}
+
+type valueSizeLimitError struct {
+	limit int
+}
+
+func (err *valueSizeLimitError) Error() string {
+	return "exceeds the maximum value size: " + strconv.Itoa(err.limit)
+}
+
+func (err *valueSizeLimitError) Limit() (string, int) {
+	return "value size", err.limit
+}
+
 type expectedObjectError struct {
 	v interface{}
 }
@@ -120,6 +266,14 @@ func (err *exitCodeError) Value() interface{} {
 	return err.value
 }
 
+type pmapSideEffectError struct {
+	name string
+}
+
+func (err *pmapSideEffectError) Error() string {
+	return "pmap cannot call " + err.name + " in parallel"
+}
+
 type funcContainsError struct {
 	l, r interface{}
 }
@@ -162,6 +316,146 @@ func (err *zeroDivisionError) Error() string {
 	return "cannot divide " + typeErrorPreview(err.l) + " by: " + typeErrorPreview(err.r)
 }
 
+type nonFiniteError struct {
+	v float64
+}
+
+func (err *nonFiniteError) Error() string {
+	return "cannot emit non-finite number: " + formatNonFinite(err.v)
+}
+
+type nonFiniteModeError struct {
+	mode string
+}
+
+func (err *nonFiniteModeError) Error() string {
+	return "invalid mode for nonfinite: " + strconv.Quote(err.mode) +
+		" (expected clamp, null, string or error)"
+}
+
+type hashAlgorithmError struct {
+	name string
+}
+
+func (err *hashAlgorithmError) Error() string {
+	return "unknown hash algorithm: " + strconv.Quote(err.name) + " (expected md5, sha1, sha256 or sha512)"
+}
+
+type decompressError struct {
+	name string
+	err  error
+}
+
+func (err *decompressError) Error() string {
+	return err.name + " failed to decompress: " + err.err.Error()
+}
+
+type shellwordsError struct {
+	msg string
+}
+
+func (err *shellwordsError) Error() string {
+	return "shellwords: " + err.msg
+}
+
+type relPathError struct {
+	path, base string
+}
+
+func (err *relPathError) Error() string {
+	return "relpath cannot make " + strconv.Quote(err.path) + " relative to " + strconv.Quote(err.base)
+}
+
+type globPatternError struct {
+	pattern, msg string
+}
+
+func (err *globPatternError) Error() string {
+	return "invalid glob pattern " + strconv.Quote(err.pattern) + ": " + err.msg
+}
+
+type msgpackError struct {
+	offset int
+	err    error
+}
+
+func (err *msgpackError) Error() string {
+	return "frommsgpack: " + err.err.Error() + " at offset " + strconv.Itoa(err.offset)
+}
+
+type jwtError struct {
+	msg string
+}
+
+func (err *jwtError) Error() string {
+	return "jwt: " + err.msg
+}
+
+type randintRangeError struct {
+	l, r interface{}
+}
+
+func (err *randintRangeError) Error() string {
+	return "randint with invalid range: " + previewValue(err.l) + " > " + previewValue(err.r)
+}
+
+type urlComponentError struct {
+	name string
+	v    interface{}
+}
+
+func (err *urlComponentError) Error() string {
+	return "invalid url component " + err.name + ": " + typeErrorPreview(err.v)
+}
+
+type ipAddressError struct {
+	s string
+}
+
+func (err *ipAddressError) Error() string {
+	return "invalid IP address: " + strconv.Quote(err.s)
+}
+
+type semverError struct {
+	kind, s string
+}
+
+func (err *semverError) Error() string {
+	return "invalid " + err.kind + ": " + strconv.Quote(err.s)
+}
+
+type jsonPathError struct {
+	s string
+}
+
+func (err *jsonPathError) Error() string {
+	return "invalid JSONPath: " + strconv.Quote(err.s)
+}
+
+type tsortCycleError struct {
+	cycle []interface{}
+}
+
+func (err *tsortCycleError) Error() string {
+	return "tsort found a dependency cycle: " + previewValue(err.cycle)
+}
+
+type durationParseError struct {
+	s string
+}
+
+func (err *durationParseError) Error() string {
+	return "invalid duration: " + strconv.Quote(err.s)
+}
+
+type timeZoneError struct {
+	name string
+}
+
+func (err *timeZoneError) Error() string {
+	return "unknown time zone: " + strconv.Quote(err.name)
+}
+
 type zeroModuloError struct {
 	l, r interface{}
 }
@@ -282,6 +576,18 @@ func (err *queryParseError) Error() string {
 	return "invalid " + err.typ + ": " + err.fname + ": " + err.err.Error()
 }
 
+type moduleNotFoundError struct {
+	name string
+}
+
+func (err *moduleNotFoundError) Error() string {
+	return "module not found: " + strconv.Quote(err.name)
+}
+
+func (err *moduleNotFoundError) Unwrap() error {
+	return os.ErrNotExist
+}
+
 type jsonParseError struct {
 	fname, contents string
 	err             error
@@ -306,10 +612,27 @@ func typeErrorPreview(v interface{}) string {
 	return typeof(v) + p
 }
 
+// TypeOf returns the type name of the value in the same manner as the type
+// function, which is used in the error messages. The type of the byte strings
+// is "bytes", and the type of the datetime values is "datetime".
+func TypeOf(v interface{}) string {
+	return typeof(v)
+}
+
 func typeof(v interface{}) (s string) {
 	if v == nil {
 		return "null"
 	}
+	switch v := v.(type) {
+	case JQValue:
+		return v.JQValueType()
+	case json.Number:
+		return "number"
+	case []byte:
+		return "bytes"
+	case time.Time:
+		return "datetime"
+	}
 	k := reflect.TypeOf(v).Kind()
 	switch k {
 	case reflect.Array, reflect.Slice:
@@ -332,6 +655,12 @@ func typeof(v interface{}) (s string) {
 	}
 }
 
+// Preview returns the JSON encoding of the value truncated to 30 bytes, which
+// is used in the error messages.
+func Preview(v interface{}) string {
+	return previewValue(v)
+}
+
 func preview(v interface{}) string {
 	if v == nil {
 		return ""
@@ -10,6 +10,11 @@ import (
 func (env *env) execute(bc *Code, v interface{}, vars ...interface{}) Iter {
 	env.codes = bc.codes
 	env.codeinfos = bc.codeinfos
+	env.spans = bc.spans
+	env.ordered = bc.ordered
+	env.limits = bc.limits
+	env.effects = bc.effects
+	env.memo = bc.memo
 	env.push(v)
 	for i := len(vars) - 1; i >= 0; i-- {
 		env.push(vars[i])
@@ -20,8 +25,9 @@ func (env *env) execute(bc *Code, v interface{}, vars ...interface{}) Iter {
 
 func (env *env) Next() (interface{}, bool) {
 	var err error
-	pc, callpc, index := env.pc, len(env.codes)-1, -1
+	pc, callpc, index := env.pc, len(env.codes)-1, env.callindex
 	backtrack, hasCtx := env.backtrack, env.ctx != context.Background()
+	hasLimits := env.limits != limits{}
 	defer func() { env.pc, env.backtrack = pc, true }()
 loop:
 	for ; pc < len(env.codes); pc++ {
@@ -35,6 +41,12 @@ loop:
 			default:
 			}
 		}
+		if hasLimits {
+			if err := env.checkLimits(pc, backtrack); err != nil {
+				pc, env.forks = len(env.codes), nil
+				return err, true
+			}
+		}
 		switch code.op {
 		case opnop:
 			// nop
@@ -59,14 +71,26 @@ loop:
 			}
 			n := code.v.(int)
 			m := make(map[string]interface{}, n)
+			var keys []string
+			if env.ordered {
+				keys = make([]string, n)
+			}
 			for i := 0; i < n; i++ {
 				v, k := env.pop(), env.pop()
 				s, ok := k.(string)
 				if !ok {
 					err = &objectKeyNotStringError{k}
+					env.setErrorSpan(pc, k)
 					break loop
 				}
 				m[s] = v
+				if keys != nil {
+					keys[n-1-i] = s
+				}
+			}
+			if keys != nil {
+				env.push(newOrderedMapWithKeys(keys, m))
+				break
 			}
 			env.push(m)
 		case opappend:
@@ -158,21 +182,44 @@ loop:
 				pc, callpc, index = v, pc, env.scopes.index
 				goto loop
 			case [3]interface{}:
+				if env.parallel {
+					if _, ok := env.effects[v[2].(string)]; ok {
+						err = &pmapSideEffectError{v[2].(string)}
+						env.setErrorSpan(pc, nil)
+						break loop
+					}
+				}
 				argcnt := v[1].(int)
 				x, args := env.pop(), env.args[:argcnt]
 				for i := 0; i < argcnt; i++ {
 					args[i] = env.pop()
 				}
-				w := v[0].(func(interface{}, []interface{}) interface{})(x, args)
+				var w interface{}
+				if f, ok := v[0].(contextFunc); ok {
+					w = f(env.ctx, x, args)
+				} else {
+					w = v[0].(func(interface{}, []interface{}) interface{})(x, args)
+				}
 				if e, ok := w.(error); ok {
 					err = e
+					if name := v[2].(string); name == "_index" || name == "_slice" {
+						x = args[0]
+					}
+					env.setErrorSpan(pc, x)
 					break loop
 				}
+				if hasLimits {
+					if err := env.checkValueSize(w); err != nil {
+						pc, env.forks = len(env.codes), nil
+						return err, true
+					}
+				}
 				env.push(w)
 				if !env.paths.empty() {
 					var ps []interface{}
 					ps, err = env.pathEntries(v[2].(string), x, args)
 					if err != nil {
+						env.setErrorSpan(pc, x)
 						break loop
 					}
 					for _, p := range ps {
@@ -182,6 +229,71 @@ loop:
 			default:
 				panic(v)
 			}
+		case optailcall:
+			if backtrack {
+				break loop
+			}
+			// reuse the scope of the caller unless forks or closures refer to it
+			if i := env.scopes.index; i > env.scopes.limit {
+				b := env.scopes.data[i]
+				if s := b.value.(scope); b.next == s.saveindex {
+					env.scopes.pop()
+					env.offset = s.offset
+					pc, callpc, index = code.v.(int), s.pc, s.saveindex
+					goto loop
+				}
+			}
+			pc, callpc, index = code.v.(int), pc, env.scopes.index
+			goto loop
+		case opindex:
+			if backtrack {
+				break loop
+			}
+			x, args := env.pop(), env.args[:2]
+			args[0], args[1] = x, code.v
+			w := funcIndex(nil, x, code.v)
+			if e, ok := w.(error); ok {
+				err = e
+				env.setErrorSpan(pc, x)
+				break loop
+			}
+			env.push(w)
+			if !env.paths.empty() {
+				var ps []interface{}
+				ps, err = env.pathEntries("_index", x, args)
+				if err != nil {
+					env.setErrorSpan(pc, x)
+					break loop
+				}
+				for _, p := range ps {
+					env.paths.push([2]interface{}{p, w})
+				}
+			}
+		case opcallmemo:
+			if backtrack {
+				if err != nil {
+					break loop
+				}
+				backtrack = false
+				env.emitValues(code.op, pc, env.pop().([]interface{}))
+				break
+			}
+			if env.paths.empty() {
+				if w, ok := env.callMemo(code.v.(int), env.stack.top()); ok {
+					if e, ok := w.(error); ok {
+						pc, env.forks = len(env.codes), nil
+						return e, true
+					}
+					env.pop()
+					if vs := w.([]interface{}); len(vs) > 0 {
+						env.emitValues(code.op, pc, vs)
+						break
+					}
+					break loop
+				}
+			}
+			pc, callpc, index = code.v.(int), pc, env.scopes.index
+			goto loop
 		case oppushpc:
 			env.push([2]int{code.v.(int), env.scopes.index})
 		case opcallpc:
@@ -197,7 +309,11 @@ loop:
 				env.scopes.save(&i, &l)
 				env.scopes.index = index
 			}
-			env.scopes.push(scope{xs[0], env.offset, callpc, i})
+			var depth int
+			if i >= 0 {
+				depth = env.scopes.data[i].value.(scope).depth + 1
+			}
+			env.scopes.push(scope{xs[0], env.offset, callpc, i, depth})
 			env.offset += xs[1]
 			if env.offset > len(env.values) {
 				vs := make([]interface{}, env.offset*2)
@@ -208,6 +324,10 @@ loop:
 			if backtrack {
 				break loop
 			}
+			if i := env.scopes.index; i > env.scopes.limit {
+				// release the variables of the scope unless forks refer to them
+				env.offset = env.scopes.data[i].value.(scope).offset
+			}
 			s := env.scopes.pop().(scope)
 			pc, env.scopes.index = s.pc, s.saveindex
 			if env.scopes.empty() {
@@ -219,13 +339,18 @@ loop:
 			}
 			backtrack = false
 			var xs [][2]interface{}
-			switch v := env.pop().(type) {
+			x := env.pop()
+			if w, ok := x.(jqValueIter); ok && (env.paths.empty() || env.expdepth > 0) {
+				x = w.JQValueIter()
+			}
+			switch v := x.(type) {
 			case [][2]interface{}:
 				xs = v
 			case []interface{}:
 				if !env.paths.empty() && env.expdepth == 0 &&
 					!reflect.DeepEqual(v, env.paths.top().([2]interface{})[1]) {
 					err = &invalidPathIterError{v}
+					env.setErrorSpan(pc, v)
 					break loop
 				}
 				if len(v) == 0 {
@@ -239,6 +364,7 @@ loop:
 				if !env.paths.empty() && env.expdepth == 0 &&
 					!reflect.DeepEqual(v, env.paths.top().([2]interface{})[1]) {
 					err = &invalidPathIterError{v}
+					env.setErrorSpan(pc, v)
 					break loop
 				}
 				if len(v) == 0 {
@@ -253,9 +379,53 @@ loop:
 				sort.Slice(xs, func(i, j int) bool {
 					return xs[i][0].(string) < xs[j][0].(string)
 				})
+			case []byte:
+				if !env.paths.empty() && env.expdepth == 0 &&
+					!reflect.DeepEqual(v, env.paths.top().([2]interface{})[1]) {
+					err = &invalidPathIterError{v}
+					env.setErrorSpan(pc, v)
+					break loop
+				}
+				if len(v) == 0 {
+					break loop
+				}
+				xs = make([][2]interface{}, len(v))
+				for i, b := range v {
+					xs[i] = [2]interface{}{i, int(b)}
+				}
+			case *OrderedMap:
+				if !env.paths.empty() && env.expdepth == 0 &&
+					!reflect.DeepEqual(v, env.paths.top().([2]interface{})[1]) {
+					err = &invalidPathIterError{v}
+					env.setErrorSpan(pc, v)
+					break loop
+				}
+				if v.Len() == 0 {
+					break loop
+				}
+				xs = make([][2]interface{}, v.Len())
+				for i, k := range v.keys {
+					xs[i] = [2]interface{}{k, v.values[k]}
+				}
+			case JQValue:
+				if !env.paths.empty() && env.expdepth == 0 &&
+					!reflect.DeepEqual(v, env.paths.top().([2]interface{})[1]) {
+					err = &invalidPathIterError{v}
+					env.setErrorSpan(pc, v)
+					break loop
+				}
+				ks := v.JQValueKeys()
+				if len(ks) == 0 {
+					break loop
+				}
+				xs = make([][2]interface{}, len(ks))
+				for i, k := range ks {
+					xs[i] = [2]interface{}{k, v.JQValueIndex(k)}
+				}
 			case Iter:
 				if !env.paths.empty() && env.expdepth == 0 {
 					err = &invalidPathIterError{v}
+					env.setErrorSpan(pc, v)
 					break loop
 				}
 				if w, ok := v.Next(); ok {
@@ -264,6 +434,7 @@ loop:
 					env.pop()
 					if e, ok := w.(error); ok {
 						err = e
+						env.setErrorSpan(pc, nil)
 						break loop
 					}
 					env.push(w)
@@ -272,6 +443,7 @@ loop:
 				break loop
 			default:
 				err = &iteratorError{v}
+				env.setErrorSpan(pc, v)
 				break loop
 			}
 			if len(xs) > 1 {
@@ -305,8 +477,29 @@ loop:
 				env.expdepth = env.paths.pop().(int)
 			} else {
 				err = &invalidPathError{x}
+				env.setErrorSpan(pc, x)
 				break loop
 			}
+		case oppmap:
+			if backtrack {
+				break loop
+			}
+			x, n, f := env.pop(), env.pop(), env.pop().([2]int)
+			w, sub := parallelMap(env, x, n, f)
+			if e, ok := w.(error); ok {
+				if sub == nil {
+					err = e
+					env.setErrorSpan(pc, x)
+					break loop
+				}
+				if _, ok := e.(LimitError); ok || env.ctx.Err() != nil {
+					pc, env.forks = len(env.codes), nil
+					return e, true
+				}
+				err, env.errspan, env.errinput = e, sub.errspan, sub.errinput
+				break loop
+			}
+			env.push(w)
 		default:
 			panic(code.op)
 		}
@@ -316,11 +509,75 @@ loop:
 		goto loop
 	}
 	if err != nil {
-		return err, true
+		return newRuntimeError(err, env.errspan, env.errinput), true
 	}
 	return nil, false
 }
 
+// checkLimits checks the limits of the execution before executing the code at
+// pc. The errors of the limits are not caught by try-catch, and terminate the
+// execution immediately.
+func (env *env) checkLimits(pc int, backtrack bool) error {
+	if env.limits.steps > 0 {
+		if env.steps++; env.steps > env.limits.steps {
+			return &stepLimitError{env.limits.steps}
+		}
+	}
+	if backtrack {
+		return nil
+	}
+	switch code := env.codes[pc]; code.op {
+	case opscope:
+		if env.limits.depth > 0 && !env.scopes.empty() &&
+			env.scopes.top().(scope).depth >= env.limits.depth {
+			return &depthLimitError{env.limits.depth}
+		}
+	case opappend:
+		if env.limits.size > 0 &&
+			len(env.values[env.index(code.v.([2]int))].([]interface{})) >= env.limits.size {
+			return &valueSizeLimitError{env.limits.size}
+		}
+	case opobject:
+		if env.limits.size > 0 && code.v.(int) > env.limits.size {
+			return &valueSizeLimitError{env.limits.size}
+		}
+	}
+	return nil
+}
+
+// checkValueSize checks the size of the value returned by internal functions.
+func (env *env) checkValueSize(v interface{}) error {
+	if env.limits.size > 0 {
+		var size int
+		switch v := v.(type) {
+		case string:
+			size = len(v)
+		case []byte:
+			size = len(v)
+		case []interface{}:
+			size = len(v)
+		case map[string]interface{}:
+			size = len(v)
+		case *OrderedMap:
+			size = v.Len()
+		}
+		if size > env.limits.size {
+			return &valueSizeLimitError{env.limits.size}
+		}
+	}
+	return nil
+}
+
+// setErrorSpan looks up the position of the error raised at pc. When the code
+// has no position (builtin functions and modules), it looks up the call sites.
+func (env *env) setErrorSpan(pc int, v interface{}) {
+	env.errspan, env.errinput = env.spans[pc], v
+	for i := env.scopes.index; env.errspan == nil && i >= 0; {
+		s := env.scopes.data[i].value.(scope)
+		env.errspan, i = env.spans[s.pc], s.saveindex
+	}
+}
+
 func (env *env) push(v interface{}) {
 	env.stack.push(v)
 }
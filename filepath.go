@@ -0,0 +1,212 @@
+package gojq
+
+import "strings"
+
+// The path functions handle both slashes and backslashes as the separators, so
+// that the paths of Windows can be handled on any platforms. The results use
+// backslashes only when the paths contain backslashes but no slashes.
+
+func isPathSep(c byte) bool {
+	return c == '/' || c == '\\'
+}
+
+// pathVolume returns the drive letter of the path of Windows (like "C:").
+func pathVolume(s string) string {
+	if len(s) >= 2 && s[1] == ':' && ('a' <= s[0]|0x20 && s[0]|0x20 <= 'z') {
+		return s[:2]
+	}
+	return ""
+}
+
+func pathSep(ss ...string) string {
+	var backslash bool
+	for _, s := range ss {
+		if strings.ContainsRune(s, '/') {
+			return "/"
+		}
+		backslash = backslash || strings.ContainsRune(s, '\\') || pathVolume(s) != ""
+	}
+	if backslash {
+		return `\`
+	}
+	return "/"
+}
+
+func isAbsPath(s string) bool {
+	s = s[len(pathVolume(s)):]
+	return s != "" && isPathSep(s[0])
+}
+
+func trimPathSeps(s string) string {
+	i := len(s)
+	for i > 0 && isPathSep(s[i-1]) {
+		i--
+	}
+	return s[:i]
+}
+
+func funcDirname(v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return &funcTypeError{"dirname", v}
+	}
+	vol := pathVolume(s)
+	s = s[len(vol):]
+	t := trimPathSeps(s)
+	if t == "" {
+		if s != "" {
+			return vol + s[:1]
+		}
+		if vol != "" {
+			return vol
+		}
+		return "."
+	}
+	i := len(t)
+	for i > 0 && !isPathSep(t[i-1]) {
+		i--
+	}
+	if i == 0 {
+		if vol != "" {
+			return vol
+		}
+		return "."
+	}
+	if t = trimPathSeps(t[:i]); t == "" {
+		return vol + s[:1]
+	}
+	return vol + t
+}
+
+func basename(s string) string {
+	s = s[len(pathVolume(s)):]
+	t := trimPathSeps(s)
+	if t == "" {
+		if s != "" {
+			return s[:1]
+		}
+		return ""
+	}
+	i := len(t)
+	for i > 0 && !isPathSep(t[i-1]) {
+		i--
+	}
+	return t[i:]
+}
+
+func funcBasename(v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return &funcTypeError{"basename", v}
+	}
+	return basename(s)
+}
+
+// funcExtname returns the extension of the base name including the dot, or an
+// empty string when there is none. The dot files (".bashrc") have none.
+func funcExtname(v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return &funcTypeError{"extname", v}
+	}
+	b := basename(s)
+	if i := strings.LastIndexByte(b, '.'); i > 0 {
+		return b[i:]
+	}
+	return ""
+}
+
+// funcJoinPath joins the input path and the paths with the separator. The
+// absolute path discards the former paths.
+func funcJoinPath(v, x interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return &funcTypeError{"joinpath", v}
+	}
+	ss := []string{s}
+	switch x := x.(type) {
+	case string:
+		ss = append(ss, x)
+	case []interface{}:
+		for _, y := range x {
+			t, ok := y.(string)
+			if !ok {
+				return &funcTypeError{"joinpath", x}
+			}
+			ss = append(ss, t)
+		}
+	default:
+		return &funcTypeError{"joinpath", x}
+	}
+	sep := pathSep(ss...)
+	var sb strings.Builder
+	for _, s := range ss {
+		if s == "" {
+			continue
+		}
+		if isAbsPath(s) {
+			sb.Reset()
+		} else if sb.Len() > 0 && !strings.HasSuffix(sb.String(), sep) &&
+			!(sb.Len() == 2 && pathVolume(sb.String()) != "") {
+			sb.WriteString(sep)
+		}
+		sb.WriteString(s)
+	}
+	return sb.String()
+}
+
+// cleanPath splits the path into the segments resolving "." and "..".
+func cleanPath(s string) []string {
+	xs := []string{}
+	for _, x := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == '/' || r == '\\'
+	}) {
+		switch x {
+		case ".":
+		case "..":
+			if len(xs) > 0 && xs[len(xs)-1] != ".." {
+				xs = xs[:len(xs)-1]
+			} else if !isAbsPath(s) {
+				xs = append(xs, x)
+			}
+		default:
+			xs = append(xs, x)
+		}
+	}
+	return xs
+}
+
+// funcRelPath returns the path of the input relative to the base path. Both
+// paths should be absolute, or relative to the same directory.
+func funcRelPath(v, x interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return &funcTypeError{"relpath", v}
+	}
+	base, ok := x.(string)
+	if !ok {
+		return &funcTypeError{"relpath", x}
+	}
+	if isAbsPath(s) != isAbsPath(base) ||
+		!strings.EqualFold(pathVolume(s), pathVolume(base)) {
+		return &relPathError{s, base}
+	}
+	xs := cleanPath(s[len(pathVolume(s)):])
+	ys := cleanPath(base[len(pathVolume(base)):])
+	var i int
+	for i < len(xs) && i < len(ys) && xs[i] == ys[i] {
+		i++
+	}
+	zs := make([]string, 0, len(ys)-i+len(xs)-i)
+	for _, y := range ys[i:] {
+		if y == ".." {
+			return &relPathError{s, base}
+		}
+		zs = append(zs, "..")
+	}
+	zs = append(zs, xs[i:]...)
+	if len(zs) == 0 {
+		return "."
+	}
+	return strings.Join(zs, pathSep(s, base))
+}
@@ -1,9 +1,20 @@
 package gojq
 
 import (
+	"compress/zlib"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"hash"
+	"html"
 	"math"
 	"math/big"
 	"net/url"
@@ -39,142 +50,298 @@ func (fn function) accept(cnt int) bool {
 
 var internalFuncs map[string]function
 
+// contextFunc is an internal function which takes the context of the execution
+// to stop the long running computation on cancellation.
+type contextFunc func(context.Context, interface{}, []interface{}) interface{}
+
+// contextFuncs are called by the compiled codes instead of the callbacks in
+// internalFuncs, which run with the background context.
+var contextFuncs map[string]contextFunc
+
 func init() {
 	internalFuncs = map[string]function{
-		"empty":          argFunc0(nil),
-		"path":           argFunc1(nil),
-		"env":            argFunc0(nil),
-		"builtins":       argFunc0(nil),
-		"input":          argFunc0(nil),
-		"modulemeta":     argFunc0(nil),
-		"length":         argFunc0(funcLength),
-		"utf8bytelength": argFunc0(funcUtf8ByteLength),
-		"keys":           argFunc0(funcKeys),
-		"has":            argFunc1(funcHas),
-		"add":            argFunc0(funcAdd),
-		"tonumber":       argFunc0(funcToNumber),
-		"tostring":       argFunc0(funcToString),
-		"type":           argFunc0(funcType),
-		"reverse":        argFunc0(funcReverse),
-		"contains":       argFunc1(funcContains),
-		"explode":        argFunc0(funcExplode),
-		"implode":        argFunc0(funcImplode),
-		"split":          {argcount1 | argcount2, false, funcSplit},
-		"tojson":         argFunc0(funcToJSON),
-		"fromjson":       argFunc0(funcFromJSON),
-		"format":         argFunc1(funcFormat),
-		"_tohtml":        argFunc0(funcToHTML),
-		"_touri":         argFunc0(funcToURI),
-		"_tocsv":         argFunc0(funcToCSV),
-		"_totsv":         argFunc0(funcToTSV),
-		"_tosh":          argFunc0(funcToSh),
-		"_tobase64":      argFunc0(funcToBase64),
-		"_tobase64d":     argFunc0(funcToBase64d),
-		"_index":         argFunc2(funcIndex),
-		"_slice":         argFunc3(funcSlice),
-		"_break":         argFunc0(funcBreak),
-		"_plus":          argFunc0(funcOpPlus),
-		"_negate":        argFunc0(funcOpNegate),
-		"_add":           argFunc2(funcOpAdd),
-		"_subtract":      argFunc2(funcOpSub),
-		"_multiply":      argFunc2(funcOpMul),
-		"_divide":        argFunc2(funcOpDiv),
-		"_modulo":        argFunc2(funcOpMod),
-		"_alternative":   argFunc2(funcOpAlt),
-		"_equal":         argFunc2(funcOpEq),
-		"_notequal":      argFunc2(funcOpNe),
-		"_greater":       argFunc2(funcOpGt),
-		"_less":          argFunc2(funcOpLt),
-		"_greatereq":     argFunc2(funcOpGe),
-		"_lesseq":        argFunc2(funcOpLe),
-		"_min_by":        argFunc1(funcMinBy),
-		"_max_by":        argFunc1(funcMaxBy),
-		"_sort_by":       argFunc1(funcSortBy),
-		"_group_by":      argFunc1(funcGroupBy),
-		"_unique_by":     argFunc1(funcUniqueBy),
-		"sin":            mathFunc("sin", math.Sin),
-		"cos":            mathFunc("cos", math.Cos),
-		"tan":            mathFunc("tan", math.Tan),
-		"asin":           mathFunc("asin", math.Asin),
-		"acos":           mathFunc("acos", math.Acos),
-		"atan":           mathFunc("atan", math.Atan),
-		"sinh":           mathFunc("sinh", math.Sinh),
-		"cosh":           mathFunc("cosh", math.Cosh),
-		"tanh":           mathFunc("tanh", math.Tanh),
-		"asinh":          mathFunc("asinh", math.Asinh),
-		"acosh":          mathFunc("acosh", math.Acosh),
-		"atanh":          mathFunc("atanh", math.Atanh),
-		"floor":          mathFunc("floor", math.Floor),
-		"round":          mathFunc("round", math.Round),
-		"nearbyint":      mathFunc("nearbyint", math.Round),
-		"rint":           mathFunc("rint", math.Round),
-		"ceil":           mathFunc("ceil", math.Ceil),
-		"trunc":          mathFunc("trunc", math.Trunc),
-		"significand":    mathFunc("significand", funcSignificand),
-		"fabs":           mathFunc("fabs", math.Abs),
-		"sqrt":           mathFunc("sqrt", math.Sqrt),
-		"cbrt":           mathFunc("cbrt", math.Cbrt),
-		"exp":            mathFunc("exp", math.Exp),
-		"exp10":          mathFunc("exp10", funcExp10),
-		"exp2":           mathFunc("exp2", math.Exp2),
-		"expm1":          mathFunc("expm1", math.Expm1),
-		"frexp":          argFunc0(funcFrexp),
-		"modf":           argFunc0(funcModf),
-		"log":            mathFunc("log", math.Log),
-		"log10":          mathFunc("log10", math.Log10),
-		"log1p":          mathFunc("log1p", math.Log1p),
-		"log2":           mathFunc("log2", math.Log2),
-		"logb":           mathFunc("logb", math.Logb),
-		"gamma":          mathFunc("gamma", math.Gamma),
-		"tgamma":         mathFunc("tgamma", math.Gamma),
-		"lgamma":         mathFunc("lgamma", funcLgamma),
-		"erf":            mathFunc("erf", math.Erf),
-		"erfc":           mathFunc("erfc", math.Erfc),
-		"j0":             mathFunc("j0", math.J0),
-		"j1":             mathFunc("j1", math.J1),
-		"y0":             mathFunc("y0", math.Y0),
-		"y1":             mathFunc("y1", math.Y1),
-		"atan2":          mathFunc2("atan2", math.Atan2),
-		"copysign":       mathFunc2("copysign", math.Copysign),
-		"drem":           mathFunc2("drem", funcDrem),
-		"fdim":           mathFunc2("fdim", math.Dim),
-		"fmax":           mathFunc2("fmax", math.Max),
-		"fmin":           mathFunc2("fmin", math.Min),
-		"fmod":           mathFunc2("fmod", math.Mod),
-		"hypot":          mathFunc2("hypot", math.Hypot),
-		"jn":             mathFunc2("jn", funcJn),
-		"ldexp":          mathFunc2("ldexp", funcLdexp),
-		"nextafter":      mathFunc2("nextafter", math.Nextafter),
-		"nexttoward":     mathFunc2("nexttoward", math.Nextafter),
-		"remainder":      mathFunc2("remainder", math.Remainder),
-		"scalb":          mathFunc2("scalb", funcScalb),
-		"scalbln":        mathFunc2("scalbln", funcScalbln),
-		"yn":             mathFunc2("yn", funcYn),
-		"pow":            mathFunc2("pow", math.Pow),
-		"pow10":          mathFunc("pow10", funcExp10),
-		"fma":            mathFunc3("fma", funcFma),
-		"infinite":       argFunc0(funcInfinite),
-		"isfinite":       argFunc0(funcIsfinite),
-		"isinfinite":     argFunc0(funcIsinfinite),
-		"nan":            argFunc0(funcNan),
-		"isnan":          argFunc0(funcIsnan),
-		"isnormal":       argFunc0(funcIsnormal),
-		"setpath":        argFunc2(funcSetpath),
-		"delpaths":       argFunc1(funcDelpaths),
-		"getpath":        argFunc1(funcGetpath),
-		"bsearch":        argFunc1(funcBsearch),
-		"gmtime":         argFunc0(funcGmtime),
-		"localtime":      argFunc0(funcLocaltime),
-		"mktime":         argFunc0(funcMktime),
-		"strftime":       argFunc1(funcStrftime),
-		"strflocaltime":  argFunc1(funcStrflocaltime),
-		"strptime":       argFunc1(funcStrptime),
-		"now":            argFunc0(funcNow),
-		"_match":         argFunc3(funcMatch),
-		"error":          {argcount0 | argcount1, false, funcError},
-		"halt":           argFunc0(funcHalt),
-		"halt_error":     {argcount0 | argcount1, false, funcHaltError},
-		"_type_error":    argFunc1(internalfuncTypeError),
+		"empty":              argFunc0(nil),
+		"path":               argFunc1(nil),
+		"env":                argFunc0(nil),
+		"builtins":           argFunc0(nil),
+		"input":              argFunc0(nil),
+		"pmap":               argFunc2(nil),
+		"error_value":        argFunc0(nil),
+		"modulemeta":         argFunc0(nil),
+		"uuid":               argFunc0(nil),
+		"uuid7":              argFunc0(nil),
+		"random":             argFunc0(nil),
+		"randint":            argFunc2(nil),
+		"shuffle":            argFunc0(nil),
+		"setseed":            argFunc1(nil),
+		"length":             argFunc0(funcLength),
+		"utf8bytelength":     argFunc0(funcUtf8ByteLength),
+		"keys":               argFunc0(funcKeys),
+		"keys_unsorted":      argFunc0(funcKeysUnsorted),
+		"has":                argFunc1(funcHas),
+		"add":                argFunc0(funcAdd),
+		"exactadd":           argFunc0(funcExactAdd),
+		"exactmul":           argFunc0(funcExactMul),
+		"ratio":              argFunc0(funcRatio),
+		"band":               bitwiseFunc("band", func(l, r int64) int64 { return l & r }),
+		"bor":                bitwiseFunc("bor", func(l, r int64) int64 { return l | r }),
+		"bxor":               bitwiseFunc("bxor", func(l, r int64) int64 { return l ^ r }),
+		"bnot":               argFunc0(funcBnot),
+		"lpad":               padFunc("lpad", func(w int) int { return w }),
+		"rpad":               padFunc("rpad", func(int) int { return 0 }),
+		"center":             padFunc("center", func(w int) int { return w / 2 }),
+		"wrap":               argFunc1(funcWrap),
+		"dirname":            argFunc0(funcDirname),
+		"basename":           argFunc0(funcBasename),
+		"extname":            argFunc0(funcExtname),
+		"joinpath":           argFunc1(funcJoinPath),
+		"relpath":            argFunc1(funcRelPath),
+		"mimetype":           argFunc0(funcMimeType),
+		"ext_to_mime":        argFunc0(funcExtToMime),
+		"glob_match":         argFunc1(funcGlobMatch),
+		"glob_to_regex":      argFunc0(funcGlobToRegex),
+		"format_number":      {argcount0 | argcount1, false, funcFormatNumber},
+		"strip_ansi":         argFunc0(funcStripANSI),
+		"termwidth":          argFunc0(funcTermWidth),
+		"truncate":           argFunc2(funcTruncate),
+		"levenshtein":        argFunc2(funcLevenshtein),
+		"fuzzy_match":        argFunc2(funcFuzzyMatch),
+		"asciify":            argFunc0(funcAsciify),
+		"mergepatch_apply":   argFunc1(funcMergePatchApply),
+		"mergepatch_diff":    argFunc1(funcMergePatchDiff),
+		"_jsonpath":          argFunc1(funcJSONPath),
+		"_paths_matching":    argFunc1(funcPathsMatching),
+		"tostream":           {argcount0, true, funcToStream},
+		"_fromstream_update": argFunc1(funcFromStreamUpdate),
+		"_fromstream_emit":   {argcount0, true, funcFromStreamEmit},
+		"_truncate_stream":   {argcount1, true, funcTruncateStream},
+		"validate":           argFunc1(funcValidate),
+		"_toschema":          argFunc0(funcToSchema),
+		"deepmerge":          {argcount1 | argcount2, false, funcDeepMerge},
+		"shl":                shiftFunc("shl", func(l int64, r uint) int64 { return l << r }),
+		"shr":                shiftFunc("shr", func(l int64, r uint) int64 { return l >> r }),
+		"tonumber":           argFunc0(funcToNumber),
+		"todatetime":         argFunc0(funcToDatetime),
+		"tostring":           argFunc0(funcToString),
+		"type":               argFunc0(funcType),
+		"reverse":            argFunc0(funcReverse),
+		"contains":           argFunc1(funcContains),
+		"explode":            argFunc0(funcExplode),
+		"implode":            argFunc0(funcImplode),
+		"split":              {argcount1 | argcount2 | argcount3, false, backgroundFunc(funcSplit)},
+		"tobytes":            argFunc0(funcToBytes),
+		"frombase64":         argFunc0(funcFromBase64),
+		"md5":                hashFunc("md5"),
+		"sha1":               hashFunc("sha1"),
+		"sha256":             hashFunc("sha256"),
+		"sha512":             hashFunc("sha512"),
+		"gzip":               compressFunc("gzip", newGzipWriter),
+		"gunzip":             decompressFunc("gunzip", newGzipReader),
+		"zlib_deflate":       compressFunc("zlib_deflate", newZlibWriter),
+		"zlib_inflate":       decompressFunc("zlib_inflate", zlib.NewReader),
+		"hmac":               {argcount2 | argcount3, false, funcHMAC},
+		"crc32":              checksumFunc("crc32", checksumCRC32),
+		"fnv1a":              checksumFunc("fnv1a", checksumFNV1a),
+		"xxhash64":           checksumFunc("xxhash64", checksumXXHash64),
+		"jwt_decode":         argFunc0(funcJWTDecode),
+		"jwt_verify":         argFunc2(funcJWTVerify),
+		"tojson":             {argcount0 | argcount1, false, funcToJSON},
+		"fromjson":           argFunc0(funcFromJSON),
+		"urlparse":           argFunc0(funcURLParse),
+		"urlformat":          argFunc0(funcURLFormat),
+		"fromquerystring":    argFunc0(funcFromQueryString),
+		"toquerystring":      argFunc0(funcToQueryString),
+		"format":             argFunc1(funcFormat),
+		"_tohtml":            argFunc0(funcToHTML),
+		"_tohtmld":           argFunc0(funcToHTMLd),
+		"_touri":             argFunc0(funcToURI),
+		"_tocsv":             argFunc0(funcToCSV),
+		"shellwords":         argFunc0(funcShellwords),
+		"frommsgpack":        argFunc0(funcFromMsgpack),
+		"tomsgpack":          argFunc0(funcToMsgpack),
+		"fromcsv":            {argcount0 | argcount1, false, funcFromCSV},
+		"tocsv":              {argcount0 | argcount1, false, funcToCSVRows},
+		"_totsv":             argFunc0(funcToTSV),
+		"_tosh":              argFunc0(funcToSh),
+		"_tobase64":          argFunc0(funcToBase64),
+		"_tobase64d":         argFunc0(funcToBase64d),
+		"_tobase32":          base32Func(base32.StdEncoding),
+		"_tobase32d":         base32dFunc(base32.StdEncoding),
+		"_tobase32hex":       base32Func(base32.HexEncoding),
+		"_tobase32hexd":      base32dFunc(base32.HexEncoding),
+		"_tozbase32":         base32Func(zbase32Encoding),
+		"_tozbase32d":        base32dFunc(zbase32Encoding),
+		"_index":             argFunc2(funcIndex),
+		"_slice":             argFunc3(funcSlice),
+		"_break":             argFunc0(funcBreak),
+		"_plus":              argFunc0(funcOpPlus),
+		"_negate":            argFunc0(funcOpNegate),
+		"_add":               argFunc2(funcOpAdd),
+		"_range":             rangeFunc(funcOpAdd),
+		"_subtract":          argFunc2(funcOpSub),
+		"_multiply":          argFunc2(funcOpMul),
+		"_divide":            argFunc2(funcOpDiv),
+		"_modulo":            argFunc2(funcOpMod),
+		"_alternative":       argFunc2(funcOpAlt),
+		"_equal":             argFunc2(funcOpEq),
+		"_notequal":          argFunc2(funcOpNe),
+		"_greater":           argFunc2(funcOpGt),
+		"_less":              argFunc2(funcOpLt),
+		"_greatereq":         argFunc2(funcOpGe),
+		"_lesseq":            argFunc2(funcOpLe),
+		"_min_by":            argFunc1(funcMinBy),
+		"_max_by":            argFunc1(funcMaxBy),
+		"_sort_by":           argFunc1(funcSortBy),
+		"_sort_by_natural":   argFunc1(funcSortByNatural),
+		"_sort_by_collate":   argFunc3(funcSortByCollate),
+		"_group_by":          argFunc1(funcGroupBy),
+		"_index_by":          argFunc1(funcIndexBy),
+		"_group_by_keys":     argFunc1(funcGroupByKeys),
+		"_unique_by":         argFunc1(funcUniqueBy),
+		"_innerjoin":         joinFunc("innerjoin", false),
+		"_leftjoin":          joinFunc("leftjoin", true),
+		"zip":                {argcount0 | argcount1, false, funcZip},
+		"_tsort":             argFunc2(funcTSort),
+		"_nlargest":          topKFunc("nlargest", -1),
+		"_nsmallest":         topKFunc("nsmallest", 1),
+		"sin":                mathFunc("sin", math.Sin),
+		"cos":                mathFunc("cos", math.Cos),
+		"tan":                mathFunc("tan", math.Tan),
+		"asin":               mathFunc("asin", math.Asin),
+		"acos":               mathFunc("acos", math.Acos),
+		"atan":               mathFunc("atan", math.Atan),
+		"sinh":               mathFunc("sinh", math.Sinh),
+		"cosh":               mathFunc("cosh", math.Cosh),
+		"tanh":               mathFunc("tanh", math.Tanh),
+		"asinh":              mathFunc("asinh", math.Asinh),
+		"acosh":              mathFunc("acosh", math.Acosh),
+		"atanh":              mathFunc("atanh", math.Atanh),
+		"floor":              roundFunc("floor", math.Floor),
+		"round":              roundFunc("round", math.Round),
+		"nearbyint":          roundFunc("nearbyint", math.Round),
+		"rint":               roundFunc("rint", math.Round),
+		"ceil":               roundFunc("ceil", math.Ceil),
+		"trunc":              roundFunc("trunc", math.Trunc),
+		"significand":        mathFunc("significand", funcSignificand),
+		"fabs":               argFunc0(funcFabs),
+		"abs":                argFunc0(funcAbs),
+		"sqrt":               mathFunc("sqrt", math.Sqrt),
+		"cbrt":               mathFunc("cbrt", math.Cbrt),
+		"exp":                mathFunc("exp", math.Exp),
+		"exp10":              mathFunc("exp10", funcExp10),
+		"exp2":               mathFunc("exp2", math.Exp2),
+		"expm1":              mathFunc("expm1", math.Expm1),
+		"frexp":              argFunc0(funcFrexp),
+		"modf":               argFunc0(funcModf),
+		"log":                mathFunc("log", math.Log),
+		"log10":              mathFunc("log10", math.Log10),
+		"log1p":              mathFunc("log1p", math.Log1p),
+		"log2":               mathFunc("log2", math.Log2),
+		"logb":               mathFunc("logb", math.Logb),
+		"gamma":              mathFunc("gamma", math.Gamma),
+		"tgamma":             mathFunc("tgamma", math.Gamma),
+		"lgamma":             mathFunc("lgamma", funcLgamma),
+		"erf":                mathFunc("erf", math.Erf),
+		"erfc":               mathFunc("erfc", math.Erfc),
+		"j0":                 mathFunc("j0", math.J0),
+		"j1":                 mathFunc("j1", math.J1),
+		"y0":                 mathFunc("y0", math.Y0),
+		"y1":                 mathFunc("y1", math.Y1),
+		"atan2":              mathFunc2("atan2", math.Atan2),
+		"copysign":           mathFunc2("copysign", math.Copysign),
+		"drem":               mathFunc2("drem", funcDrem),
+		"fdim":               mathFunc2("fdim", math.Dim),
+		"fmax":               mathFunc2("fmax", math.Max),
+		"fmin":               mathFunc2("fmin", math.Min),
+		"fmod":               mathFunc2("fmod", math.Mod),
+		"hypot":              mathFunc2("hypot", math.Hypot),
+		"jn":                 mathFunc2("jn", funcJn),
+		"ldexp":              mathFunc2("ldexp", funcLdexp),
+		"nextafter":          mathFunc2("nextafter", math.Nextafter),
+		"nexttoward":         mathFunc2("nexttoward", math.Nextafter),
+		"remainder":          mathFunc2("remainder", math.Remainder),
+		"scalb":              mathFunc2("scalb", funcScalb),
+		"scalbln":            mathFunc2("scalbln", funcScalbln),
+		"yn":                 mathFunc2("yn", funcYn),
+		"pow":                mathFunc2("pow", math.Pow),
+		"pow10":              mathFunc("pow10", funcExp10),
+		"fma":                mathFunc3("fma", funcFma),
+		"infinite":           argFunc0(funcInfinite),
+		"isfinite":           argFunc0(funcIsfinite),
+		"isinfinite":         argFunc0(funcIsinfinite),
+		"nan":                argFunc0(funcNan),
+		"isnan":              argFunc0(funcIsnan),
+		"isnormal":           argFunc0(funcIsnormal),
+		"nonfinite":          argFunc1(funcNonFinite),
+		"setpath":            argFunc2(funcSetpath),
+		"delpaths":           argFunc1(funcDelpaths),
+		"getpath":            argFunc1(funcGetpath),
+		"bsearch":            argFunc1(funcBsearch),
+		"gmtime":             argFunc0(funcGmtime),
+		"localtime":          argFunc0(funcLocaltime),
+		"mktime":             argFunc0(funcMktime),
+		"strftime":           argFunc1(funcStrftime),
+		"strflocaltime":      argFunc1(funcStrflocaltime),
+		"strptime":           argFunc1(funcStrptime),
+		"strftime_tz":        argFunc2(funcStrftimeTZ),
+		"strptime_tz":        argFunc2(funcStrptimeTZ),
+		"totimezone":         argFunc1(funcToTimeZone),
+		"duration_parse":     {argcount0 | argcount1, false, funcDurationParse},
+		"duration_format":    argFunc0(funcDurationFormat),
+		"date_add":           argFunc1(funcDateAdd),
+		"date_diff":          argFunc1(funcDateDiff),
+		"semver_parse":       argFunc0(funcSemverParse),
+		"semver_cmp":         argFunc2(funcSemverCmp),
+		"semver_satisfies":   argFunc1(funcSemverSatisfies),
+		"ip_parse":           argFunc0(funcIPParse),
+		"ip_version":         argFunc0(funcIPVersion),
+		"cidr_contains":      argFunc1(funcCIDRContains),
+		"ip_to_int":          argFunc0(funcIPToInt),
+		"int_to_ip":          {argcount0 | argcount1, false, funcIntToIP},
+		"now":                argFunc0(funcNow),
+		"now_ns":             argFunc0(funcNowNs),
+		"monotonic":          argFunc0(funcMonotonic),
+		"sleep":              argFunc1(funcSleep),
+		"_match":             {argcount3, false, backgroundFunc(funcMatch)},
+		"error":              {argcount0 | argcount1, false, funcError},
+		"halt":               argFunc0(funcHalt),
+		"halt_error":         {argcount0 | argcount1, false, funcHaltError},
+		"_type_error":        argFunc1(internalfuncTypeError),
+
+		// compiled to the constants by the compiler options
+		"have_literal_numbers": argFunc0(nil),
+		"have_decimal_numbers": argFunc0(nil),
+	}
+	contextFuncs = map[string]contextFunc{
+		"split":  resolveJQValueContextFunc(funcSplit),
+		"_match": resolveJQValueContextFunc(funcMatch),
+	}
+	for name, fn := range internalFuncs {
+		switch name {
+		case "length", "keys", "keys_unsorted", "has", "type", "_index",
+			"add", "_add", "_multiply", "_alternative", "getpath", "setpath", "delpaths", "tojson", "tostring",
+			"nonfinite", "tostream":
+		case "_min_by", "_max_by", "_sort_by", "_sort_by_natural", "_sort_by_collate", "_group_by", "_unique_by",
+			"_index_by", "_group_by_keys", "_innerjoin", "_leftjoin", "_tsort",
+			"_nlargest", "_nsmallest", "_fromstream_update", "_truncate_stream":
+			fn.callback = resolveShallowJQValueFunc(fn.callback)
+			internalFuncs[name] = fn
+		case "_subtract", "_divide", "_modulo",
+			"_equal", "_notequal", "_greater", "_less", "_greatereq", "_lesseq":
+			fn.callback = resolveJQValueArgsFunc(fn.callback)
+			internalFuncs[name] = fn
+		default:
+			if fn.callback != nil {
+				fn.callback = resolveJQValueFunc(fn.callback)
+				internalFuncs[name] = fn
+			}
+		}
+	}
+}
+
+func backgroundFunc(fn contextFunc) func(interface{}, []interface{}) interface{} {
+	return func(v interface{}, args []interface{}) interface{} {
+		return fn(context.Background(), v, args)
 	}
 }
 
@@ -220,6 +387,54 @@ func mathFunc(name string, f func(x float64) float64) function {
 	})
 }
 
+// roundFunc creates the rounding function, which returns the integers as they
+// are, so that the integers beyond the precision of float64 are kept exactly.
+func roundFunc(name string, f func(x float64) float64) function {
+	g := mathFunc(name, f)
+	return argFunc0(func(v interface{}) interface{} {
+		switch v.(type) {
+		case int, *big.Int:
+			return v
+		default:
+			return g.callback(v, nil)
+		}
+	})
+}
+
+func funcFabs(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int:
+		if v < 0 {
+			return funcOpNegate(v)
+		}
+		return v
+	case *big.Int:
+		if v.Sign() < 0 {
+			return new(big.Int).Neg(v)
+		}
+		return v
+	default:
+		x, ok := toFloat(v)
+		if !ok {
+			return &funcTypeError{"fabs", v}
+		}
+		return math.Abs(x)
+	}
+}
+
+// funcAbs returns the absolute value keeping the type of the number, unlike
+// fabs which always returns a float64 for non-integer numbers.
+func funcAbs(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int, float64, *big.Int:
+		return funcFabs(v)
+	case json.Number:
+		return json.Number(strings.TrimPrefix(string(v), "-"))
+	default:
+		return &funcTypeError{"abs", v}
+	}
+}
+
 func mathFunc2(name string, g func(x, y float64) float64) function {
 	return argFunc2(func(_, x, y interface{}) interface{} {
 		l, ok := toFloat(x)
@@ -252,6 +467,104 @@ func mathFunc3(name string, g func(x, y, z float64) float64) function {
 	})
 }
 
+// bitwiseFunc creates the bitwise operation function of the input and the
+// argument as 64-bit signed integers.
+func bitwiseFunc(name string, f func(l, r int64) int64) function {
+	return argFunc1(func(v, x interface{}) interface{} {
+		l, ok := toInt64(v)
+		if !ok {
+			return &funcTypeError{name, v}
+		}
+		r, ok := toInt64(x)
+		if !ok {
+			return &funcTypeError{name, x}
+		}
+		return normalizeNumbers(f(l, r))
+	})
+}
+
+// shiftFunc creates the bit shift function. The shift count should not be
+// negative, and the count over 63 is the same as 64 (shr is an arithmetic
+// shift so the sign bit fills the result).
+func shiftFunc(name string, f func(l int64, r uint) int64) function {
+	return argFunc1(func(v, x interface{}) interface{} {
+		l, ok := toInt64(v)
+		if !ok {
+			return &funcTypeError{name, v}
+		}
+		r, ok := toInt64(x)
+		if !ok || r < 0 {
+			return &funcTypeError{name, x}
+		}
+		if r > 64 {
+			r = 64
+		}
+		return normalizeNumbers(f(l, uint(r)))
+	})
+}
+
+func funcBnot(v interface{}) interface{} {
+	x, ok := toInt64(v)
+	if !ok {
+		return &funcTypeError{"bnot", v}
+	}
+	return normalizeNumbers(^x)
+}
+
+// hashAlgorithms are the hash algorithms available in the hash functions and
+// the hmac function.
+var hashAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+func hashFunc(name string) function {
+	h := hashAlgorithms[name]
+	return argFunc0(func(v interface{}) interface{} {
+		bs, ok := toBytes(v)
+		if !ok {
+			return &funcTypeError{name, v}
+		}
+		x := h()
+		x.Write(bs)
+		return hex.EncodeToString(x.Sum(nil))
+	})
+}
+
+func funcHMAC(v interface{}, args []interface{}) interface{} {
+	bs, ok := toBytes(v)
+	if !ok {
+		return &funcTypeError{"hmac", v}
+	}
+	name, ok := args[0].(string)
+	if !ok {
+		return &funcTypeError{"hmac", args[0]}
+	}
+	h, ok := hashAlgorithms[name]
+	if !ok {
+		return &hashAlgorithmError{name}
+	}
+	key, ok := toBytes(args[1])
+	if !ok {
+		return &funcTypeError{"hmac", args[1]}
+	}
+	encode := hex.EncodeToString
+	if len(args) == 3 {
+		switch args[2] {
+		case "hex":
+		case "base64":
+			encode = base64.StdEncoding.EncodeToString
+		default:
+			return &funcTypeError{"hmac", args[2]}
+		}
+	}
+	x := hmac.New(h, key)
+	x.Write(bs)
+	return encode(x.Sum(nil))
+}
+
 func funcLength(v interface{}) interface{} {
 	switch v := v.(type) {
 	case []interface{}:
@@ -269,8 +582,14 @@ func funcLength(v interface{}) interface{} {
 		return math.Abs(v)
 	case *big.Int:
 		return new(big.Int).Abs(v)
+	case json.Number:
+		return json.Number(strings.TrimPrefix(v.String(), "-"))
+	case []byte:
+		return len(v)
 	case nil:
 		return 0
+	case JQValue:
+		return v.JQValueLength()
 	default:
 		return &funcTypeError{"length", v}
 	}
@@ -280,6 +599,8 @@ func funcUtf8ByteLength(v interface{}) interface{} {
 	switch v := v.(type) {
 	case string:
 		return len(v)
+	case []byte:
+		return len(v)
 	default:
 		return &funcTypeError{"utf8bytelength", v}
 	}
@@ -306,11 +627,28 @@ func funcKeys(v interface{}) interface{} {
 			u[i] = x
 		}
 		return u
+	case JQValue:
+		return jqValueKeys(v)
 	default:
 		return &funcTypeError{"keys", v}
 	}
 }
 
+func funcKeysUnsorted(v interface{}) interface{} {
+	if v, ok := v.(*OrderedMap); ok {
+		ks := make([]interface{}, len(v.keys))
+		for i, k := range v.keys {
+			ks[i] = k
+		}
+		return ks
+	}
+	w := funcKeys(v)
+	if err, ok := w.(*funcTypeError); ok {
+		err.name = "keys_unsorted"
+	}
+	return w
+}
+
 func funcHas(v, x interface{}) interface{} {
 	switch v := v.(type) {
 	case []interface{}:
@@ -326,12 +664,40 @@ func funcHas(v, x interface{}) interface{} {
 		default:
 			return &hasKeyTypeError{v, x}
 		}
+	case JQValue:
+		if v.JQValueType() == "array" {
+			if x, ok := toInt(x); ok {
+				return 0 <= x && x < v.JQValueLength()
+			}
+		} else if x, ok := x.(string); ok {
+			return v.JQValueIndex(x) != nil || hasJQValueKey(v, x)
+		}
+		return &hasKeyTypeError{v, x}
 	default:
 		return &hasKeyTypeError{v, x}
 	}
 }
 
 func funcAdd(v interface{}) interface{} {
+	switch vs := v.(type) {
+	case *OrderedMap:
+		us := make([]interface{}, len(vs.keys))
+		for i, k := range vs.keys {
+			us[i] = vs.values[k]
+		}
+		v = us
+	case jqValueIter:
+		iter := vs.JQValueIter()
+		return addValues(func() (interface{}, bool) {
+			x, ok := iter.Next()
+			if ok {
+				x, _ = resolveJQValue(x)
+			}
+			return x, ok
+		})
+	case JQValue:
+		v, _ = resolveJQValue(vs)
+	}
 	if vs, ok := v.(map[string]interface{}); ok {
 		xs := make([]string, len(vs))
 		var i int
@@ -350,9 +716,27 @@ func funcAdd(v interface{}) interface{} {
 	if !ok {
 		return &funcTypeError{"add", v}
 	}
-	v = nil
-	for _, x := range vs {
+	return addValues(func() (interface{}, bool) {
+		if len(vs) == 0 {
+			return nil, false
+		}
+		x := vs[0]
+		vs = vs[1:]
+		return x, true
+	})
+}
+
+// addValues adds the values emitted by next, which may emit an error to stop.
+func addValues(next func() (interface{}, bool)) interface{} {
+	var v interface{}
+	for {
+		x, ok := next()
+		if !ok {
+			return v
+		}
 		switch y := x.(type) {
+		case error:
+			return y
 		case map[string]interface{}:
 			switch w := v.(type) {
 			case nil:
@@ -367,6 +751,23 @@ func funcAdd(v interface{}) interface{} {
 					w[k] = e
 				}
 				continue
+			case *OrderedMap:
+				w.setMap(y)
+				continue
+			}
+		case *OrderedMap:
+			switch w := v.(type) {
+			case nil:
+				v = y.clone()
+				continue
+			case map[string]interface{}:
+				m := newOrderedMapFromMap(w)
+				m.setOrderedMap(y)
+				v = m
+				continue
+			case *OrderedMap:
+				w.setOrderedMap(y)
+				continue
 			}
 		case []interface{}:
 			switch w := v.(type) {
@@ -385,13 +786,55 @@ func funcAdd(v interface{}) interface{} {
 			return err
 		}
 	}
-	return v
+}
+
+func funcExactAdd(v interface{}) interface{} {
+	return exactFold("exactadd", v, new(big.Rat), (*big.Rat).Add)
+}
+
+func funcExactMul(v interface{}) interface{} {
+	return exactFold("exactmul", v, big.NewRat(1, 1), (*big.Rat).Mul)
+}
+
+// exactFold calculates the numbers in the array using rational numbers, so that
+// the result does not accumulate the errors of floating-point numbers.
+func exactFold(
+	name string, v interface{}, x *big.Rat, f func(*big.Rat, *big.Rat, *big.Rat) *big.Rat,
+) interface{} {
+	vs, ok := v.([]interface{})
+	if !ok {
+		return &funcTypeError{name, v}
+	}
+	for _, v := range vs {
+		if v == nil {
+			continue
+		}
+		y, ok := toDecimal(v)
+		if !ok {
+			return &funcTypeError{name, v}
+		}
+		f(x, x, y)
+	}
+	return normalizeNumbersWith(fromDecimal(x), true)
+}
+
+func funcRatio(v interface{}) interface{} {
+	x, ok := toDecimal(v)
+	if !ok {
+		return &funcTypeError{"ratio", v}
+	}
+	return []interface{}{
+		normalizeNumbers(new(big.Int).Set(x.Num())),
+		normalizeNumbers(new(big.Int).Set(x.Denom())),
+	}
 }
 
 func funcToNumber(v interface{}) interface{} {
 	switch v := v.(type) {
-	case int, float64, *big.Int:
+	case int, float64, *big.Int, json.Number:
 		return v
+	case time.Time:
+		return timeToEpoch(v)
 	case string:
 		if !newLexer(v).validNumber() {
 			return fmt.Errorf("invalid number: %q", v)
@@ -403,10 +846,52 @@ func funcToNumber(v interface{}) interface{} {
 }
 
 func funcToString(v interface{}) interface{} {
-	if s, ok := v.(string); ok {
-		return s
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return formatDatetime(v)
+	default:
+		return jsonMarshal(v)
+	}
+}
+
+func funcToBytes(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	case []interface{}:
+		bs := make([]byte, len(v))
+		for i, x := range v {
+			b, ok := toInt(x)
+			if !ok || b < 0 || b > 255 {
+				return &funcTypeError{"tobytes", v}
+			}
+			bs[i] = byte(b)
+		}
+		return bs
+	default:
+		return &funcTypeError{"tobytes", v}
+	}
+}
+
+func funcFromBase64(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		bs, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			if bs, err = base64.RawStdEncoding.DecodeString(v); err != nil {
+				return err
+			}
+		}
+		return bs
+	default:
+		return &funcTypeError{"frombase64", v}
 	}
-	return funcToJSON(v)
 }
 
 func funcType(v interface{}) interface{} {
@@ -506,7 +991,7 @@ func funcImplode(v interface{}) interface{} {
 	}
 }
 
-func funcSplit(v interface{}, args []interface{}) interface{} {
+func funcSplit(ctx context.Context, v interface{}, args []interface{}) interface{} {
 	s, ok := v.(string)
 	if !ok {
 		return &funcTypeError{"split", v}
@@ -527,11 +1012,24 @@ func funcSplit(v interface{}, args []interface{}) interface{} {
 			}
 			flags = v
 		}
+		// the limit is the maximum number of splits, which is unlimited by null
+		n := -1
+		if len(args) == 3 && args[2] != nil {
+			l, ok := toInt(args[2])
+			if !ok {
+				return &funcTypeError{"split", args[2]}
+			}
+			if l >= 0 {
+				n = l + 1
+			}
+		}
 		r, err := compileRegexp(x, flags)
 		if err != nil {
 			return err
 		}
-		ss = r.Split(s, -1)
+		if ss, err = r.Split(ctx, s, n); err != nil {
+			return err
+		}
 	}
 	xs := make([]interface{}, len(ss))
 	for i, s := range ss {
@@ -553,19 +1051,70 @@ func implode(v []interface{}) interface{} {
 	return sb.String()
 }
 
-func funcToJSON(v interface{}) interface{} {
-	return jsonMarshal(v)
+// funcToJSON encodes the value in JSON. The argument is the number of spaces
+// for indentation, or an object with indent and sort_keys fields; sort_keys
+// sorts the keys of the objects kept in the insertion order.
+func funcToJSON(v interface{}, args []interface{}) interface{} {
+	if len(args) == 0 {
+		return jsonMarshal(v)
+	}
+	var indent interface{}
+	var sortKeys bool
+	switch x, _ := resolveJQValue(args[0]); x := x.(type) {
+	case map[string]interface{}:
+		for k, y := range x {
+			switch k {
+			case "indent":
+				indent = y
+			case "sort_keys":
+				b, ok := y.(bool)
+				if !ok {
+					return &funcTypeError{"tojson", y}
+				}
+				sortKeys = b
+			default:
+				return &funcTypeError{"tojson", x}
+			}
+		}
+	default:
+		indent = x
+	}
+	var n int
+	if indent != nil {
+		var ok bool
+		if n, ok = toInt(indent); !ok || n < 0 || n > 7 {
+			return &funcTypeError{"tojson", indent}
+		}
+	}
+	return jsonMarshalIndent(v, n, sortKeys)
 }
 
 func funcFromJSON(v interface{}) interface{} {
 	switch v := v.(type) {
 	case string:
 		var w interface{}
-		err := json.Unmarshal([]byte(v), &w)
-		if err != nil {
-			return err
+		dec := json.NewDecoder(strings.NewReader(v))
+		dec.UseNumber() // keep the precision of large integers
+		if err := dec.Decode(&w); err != nil ||
+			strings.TrimSpace(v[dec.InputOffset():]) != "" {
+			return json.Unmarshal([]byte(v), &w) // report the error of json.Unmarshal
 		}
-		return w
+		return normalizeNumbers(w)
+	default:
+		return &funcTypeError{"fromjson", v}
+	}
+}
+
+func funcFromJSONOrdered(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		dec := json.NewDecoder(strings.NewReader(v))
+		dec.UseNumber() // keep the precision of large integers
+		w, err := DecodeOrdered(dec)
+		if err != nil || strings.TrimSpace(v[dec.InputOffset():]) != "" {
+			return json.Unmarshal([]byte(v), &w) // report the error of json.Unmarshal
+		}
+		return normalizeNumbers(w)
 	default:
 		return &funcTypeError{"fromjson", v}
 	}
@@ -630,6 +1179,67 @@ func funcToSh(v interface{}) interface{} {
 	return s.String()
 }
 
+// funcShellwords splits the command line into the words respecting the quotes
+// and the escapes in the same way as the POSIX shell, which is the inverse of
+// @sh. The variable expansions and the other shell syntax are not supported.
+func funcShellwords(v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return &funcTypeError{"shellwords", v}
+	}
+	words := []interface{}{}
+	var sb strings.Builder
+	var inWord bool
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case ' ', '\t', '\n':
+			if inWord {
+				words = append(words, sb.String())
+				sb.Reset()
+				inWord = false
+			}
+		case '\\':
+			if i++; i == len(s) {
+				return &shellwordsError{"trailing backslash"}
+			}
+			if s[i] != '\n' {
+				sb.WriteByte(s[i])
+				inWord = true
+			}
+		case '\'':
+			j := strings.IndexByte(s[i+1:], '\'')
+			if j < 0 {
+				return &shellwordsError{"unclosed single quote"}
+			}
+			sb.WriteString(s[i+1 : i+1+j])
+			i, inWord = i+1+j, true
+		case '"':
+			for i++; ; i++ {
+				if i == len(s) {
+					return &shellwordsError{"unclosed double quote"}
+				}
+				if s[i] == '"' {
+					break
+				}
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) >= 0 {
+					if i++; s[i] == '\n' {
+						continue
+					}
+				}
+				sb.WriteByte(s[i])
+			}
+			inWord = true
+		default:
+			sb.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, sb.String())
+	}
+	return words
+}
+
 func funcToCSVTSV(typ string, v interface{}, sep string, escape func(string) string) interface{} {
 	switch xs := v.(type) {
 	case []interface{}:
@@ -678,6 +1288,15 @@ func funcToHTML(v interface{}) interface{} {
 	}
 }
 
+func funcToHTMLd(v interface{}) interface{} {
+	switch x := funcToString(v).(type) {
+	case string:
+		return html.UnescapeString(x)
+	default:
+		return x
+	}
+}
+
 func funcToURI(v interface{}) interface{} {
 	switch x := funcToString(v).(type) {
 	case string:
@@ -688,6 +1307,9 @@ func funcToURI(v interface{}) interface{} {
 }
 
 func funcToBase64(v interface{}) interface{} {
+	if v, ok := v.([]byte); ok {
+		return base64.StdEncoding.EncodeToString(v)
+	}
 	switch x := funcToString(v).(type) {
 	case string:
 		return base64.StdEncoding.EncodeToString([]byte(x))
@@ -709,6 +1331,41 @@ func funcToBase64d(v interface{}) interface{} {
 	}
 }
 
+// zbase32Encoding is the human-oriented base32 encoding (z-base-32).
+var zbase32Encoding = base32.NewEncoding("ybndrfg8ejkmcpqxot1uwisza345h769").
+	WithPadding(base32.NoPadding)
+
+func base32Func(enc *base32.Encoding) function {
+	return argFunc0(func(v interface{}) interface{} {
+		if v, ok := v.([]byte); ok {
+			return enc.EncodeToString(v)
+		}
+		switch x := funcToString(v).(type) {
+		case string:
+			return enc.EncodeToString([]byte(x))
+		default:
+			return x
+		}
+	})
+}
+
+func base32dFunc(enc *base32.Encoding) function {
+	return argFunc0(func(v interface{}) interface{} {
+		switch x := funcToString(v).(type) {
+		case string:
+			y, err := enc.DecodeString(x)
+			if err != nil {
+				if y, err = enc.WithPadding(base32.NoPadding).DecodeString(x); err != nil {
+					return err
+				}
+			}
+			return string(y)
+		default:
+			return x
+		}
+	})
+}
+
 func funcIndex(_, v, x interface{}) interface{} {
 	switch x := x.(type) {
 	case string:
@@ -717,10 +1374,15 @@ func funcIndex(_, v, x interface{}) interface{} {
 			return nil
 		case map[string]interface{}:
 			return v[x]
+		case JQValue:
+			if v.JQValueType() == "object" {
+				return v.JQValueIndex(x)
+			}
+			return &expectedObjectError{v}
 		default:
 			return &expectedObjectError{v}
 		}
-	case int, float64, *big.Int:
+	case int, float64, *big.Int, json.Number:
 		idx, _ := toInt(x)
 		switch v := v.(type) {
 		case nil:
@@ -738,6 +1400,23 @@ func funcIndex(_, v, x interface{}) interface{} {
 			default:
 				panic(v)
 			}
+		case JQValue:
+			if v.JQValueType() == "array" {
+				if l := v.JQValueLength(); idx < 0 {
+					if idx += l; idx < 0 {
+						return nil
+					}
+				} else if idx >= l {
+					return nil
+				}
+				return v.JQValueIndex(idx)
+			}
+			return &expectedArrayError{v}
+		case []byte:
+			if idx = toIndexLen(len(v), idx); idx < 0 {
+				return nil
+			}
+			return int(v[idx])
 		default:
 			return &expectedArrayError{v}
 		}
@@ -823,6 +1502,34 @@ func funcSlice(_, v, end, start interface{}) (r interface{}) {
 			return &arrayIndexNotNumberError{end}
 		}
 		return v
+	case []byte:
+		l, from, to := len(v), 0, len(v)
+		if start != nil {
+			i, ok := toInt(start)
+			if !ok {
+				return &arrayIndexNotNumberError{start}
+			}
+			if from = toIndexLen(l, i); from == -1 {
+				from = l
+			} else if from == -2 {
+				from = 0
+			}
+		}
+		if end != nil {
+			i, ok := toInt(end)
+			if !ok {
+				return &arrayIndexNotNumberError{end}
+			}
+			if to = toIndexLen(l, i); to == -1 {
+				to = l
+			} else if to == -2 {
+				to = 0
+			}
+		}
+		if from > to {
+			from = to
+		}
+		return v[from:to]
 	default:
 		return &expectedArrayError{v}
 	}
@@ -859,7 +1566,10 @@ func funcIndexSlice(start, end, index *int, a []interface{}) interface{} {
 }
 
 func toIndex(a []interface{}, i int) int {
-	l := len(a)
+	return toIndexLen(len(a), i)
+}
+
+func toIndexLen(l, i int) int {
 	switch {
 	case i < -l:
 		return -2
@@ -938,6 +1648,18 @@ func funcSortBy(v, x interface{}) interface{} {
 	return rs
 }
 
+func funcSortByNatural(v, x interface{}) interface{} {
+	items, err := sortItemsWith(v, x, compareNatural)
+	if err != nil {
+		return err
+	}
+	rs := make([]interface{}, len(items))
+	for i, x := range items {
+		rs[i] = x.value
+	}
+	return rs
+}
+
 func funcGroupBy(v, x interface{}) interface{} {
 	items, err := sortItems(v, x)
 	if err != nil {
@@ -955,6 +1677,234 @@ func funcGroupBy(v, x interface{}) interface{} {
 	return rs
 }
 
+func funcIndexBy(v, x interface{}) interface{} {
+	vs, ok := v.([]interface{})
+	if !ok {
+		return &expectedArrayError{v}
+	}
+	xs, ok := x.([]interface{})
+	if !ok {
+		return &expectedArrayError{x}
+	}
+	m := make(map[string]interface{}, len(vs))
+	for i, v := range vs {
+		for _, k := range xs[i].([]interface{}) {
+			m[funcToString(k).(string)] = v
+		}
+	}
+	return m
+}
+
+// funcGroupByKeys groups the values into the nested objects. Each key of the
+// values is the array of the keys for each level of the objects.
+func funcGroupByKeys(v, x interface{}) interface{} {
+	vs, ok := v.([]interface{})
+	if !ok {
+		return &expectedArrayError{v}
+	}
+	xs, ok := x.([]interface{})
+	if !ok {
+		return &expectedArrayError{x}
+	}
+	m := make(map[string]interface{})
+	for i, v := range vs {
+		groupByKeys(m, xs[i].([]interface{}), v)
+	}
+	return m
+}
+
+func groupByKeys(m map[string]interface{}, levels []interface{}, v interface{}) {
+	for _, k := range levels[0].([]interface{}) {
+		key := funcToString(k).(string)
+		if len(levels) == 1 {
+			vs, _ := m[key].([]interface{})
+			m[key] = append(vs, v)
+			continue
+		}
+		w, ok := m[key].(map[string]interface{})
+		if !ok {
+			w = make(map[string]interface{})
+		}
+		if groupByKeys(w, levels[1:], v); len(w) > 0 {
+			m[key] = w
+		}
+	}
+}
+
+// joinFunc creates the function to hash-join the arrays by the keys. The left
+// join emits the left elements without the matching right elements as well.
+func joinFunc(name string, outer bool) function {
+	return argFunc3(func(v, x, y, z interface{}) interface{} {
+		vs, ok := v.([]interface{})
+		if !ok {
+			return &expectedArrayError{v}
+		}
+		ws, ok := y.([]interface{})
+		if !ok {
+			return &funcTypeError{name, y}
+		}
+		xs, zs := x.([]interface{}), z.([]interface{})
+		index := make(map[string][]interface{}, len(ws))
+		for i, w := range ws {
+			key := jsonMarshal(zs[i])
+			index[key] = append(index[key], w)
+		}
+		rs := []interface{}{}
+		for i, v := range vs {
+			matches := index[jsonMarshal(xs[i])]
+			for _, w := range matches {
+				rs = append(rs, joinRow(v, w))
+			}
+			if len(matches) == 0 && outer {
+				rs = append(rs, joinRow(v, nil))
+			}
+		}
+		return rs
+	})
+}
+
+// joinRow merges the objects, or pairs the other values in an array.
+func joinRow(l, r interface{}) interface{} {
+	if l, ok := l.(map[string]interface{}); ok {
+		switch r := r.(type) {
+		case nil:
+			return l
+		case map[string]interface{}:
+			m := make(map[string]interface{}, len(l)+len(r))
+			for k, v := range l {
+				m[k] = v
+			}
+			for k, v := range r {
+				m[k] = v
+			}
+			return m
+		}
+	}
+	return []interface{}{l, r}
+}
+
+// funcZip pairs up the elements of the arrays at the same index. The result
+// stops at the shortest array unless the pad option is enabled, which pads
+// the shorter arrays with null to the longest one.
+func funcZip(v interface{}, args []interface{}) interface{} {
+	vs, ok := v.([]interface{})
+	if !ok {
+		return &expectedArrayError{v}
+	}
+	var pad bool
+	if len(args) > 0 {
+		opts, ok := args[0].(map[string]interface{})
+		if !ok {
+			return &funcTypeError{"zip", args[0]}
+		}
+		for k, x := range opts {
+			if k != "pad" {
+				return &funcTypeError{"zip", opts}
+			}
+			if pad, ok = x.(bool); !ok {
+				return &funcTypeError{"zip", x}
+			}
+		}
+	}
+	xss := make([][]interface{}, len(vs))
+	var l int
+	for i, v := range vs {
+		xs, ok := v.([]interface{})
+		if !ok {
+			return &expectedArrayError{v}
+		}
+		if i == 0 || pad && len(xs) > l || !pad && len(xs) < l {
+			l = len(xs)
+		}
+		xss[i] = xs
+	}
+	ys := make([]interface{}, l)
+	for j := range ys {
+		zs := make([]interface{}, len(xss))
+		for i, xs := range xss {
+			if j < len(xs) {
+				zs[i] = xs[j]
+			}
+		}
+		ys[j] = zs
+	}
+	return ys
+}
+
+// funcTSort sorts the elements topologically so that each element follows its
+// dependencies. The elements are visited in the input order, and dependencies
+// not found in the elements are ignored.
+func funcTSort(v, x, y interface{}) interface{} {
+	vs, ok := v.([]interface{})
+	if !ok {
+		return &expectedArrayError{v}
+	}
+	keys, deps := x.([]interface{}), y.([]interface{})
+	index := make(map[string]int, len(keys))
+	for i := len(keys) - 1; i >= 0; i-- {
+		index[jsonMarshal(keys[i])] = i
+	}
+	states, path := make([]int, len(vs)), []int{}
+	ys := make([]interface{}, 0, len(vs))
+	var visit func(int) error
+	visit = func(i int) error {
+		switch states[i] {
+		case 1:
+			cycle := []interface{}{}
+			for j := len(path) - 1; j >= 0; j-- {
+				if cycle = append(cycle, tsortKey(keys[path[j]])); path[j] == i {
+					break
+				}
+			}
+			for l, r := 0, len(cycle)-1; l < r; l, r = l+1, r-1 {
+				cycle[l], cycle[r] = cycle[r], cycle[l]
+			}
+			return &tsortCycleError{append(cycle, tsortKey(keys[i]))}
+		case 2:
+			return nil
+		}
+		states[i] = 1
+		path = append(path, i)
+		for _, d := range deps[i].([]interface{}) {
+			if j, ok := index[jsonMarshal([]interface{}{d})]; ok {
+				if err := visit(j); err != nil {
+					return err
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		states[i] = 2
+		ys = append(ys, vs[i])
+		return nil
+	}
+	for i := range vs {
+		if err := visit(i); err != nil {
+			return err
+		}
+	}
+	return ys
+}
+
+func tsortKey(v interface{}) interface{} {
+	if vs := v.([]interface{}); len(vs) == 1 {
+		return vs[0]
+	}
+	return v
+}
+
+// rangeFunc creates the range function, which adds the step by the function
+// so that the decimal mode calculates the numbers in decimals.
+func rangeFunc(add func(_, l, r interface{}) interface{}) function {
+	return function{argcount3, true, func(_ interface{}, args []interface{}) interface{} {
+		for _, x := range args {
+			if _, ok := toFloat(x); !ok {
+				return NewIter(&funcTypeError{"range", x})
+			}
+		}
+		return &rangeIter{args[0], args[1], args[2], compare(args[2], 0), add}
+	}}
+}
+
 func funcUniqueBy(v, x interface{}) interface{} {
 	items, err := sortItems(v, x)
 	if err != nil {
@@ -971,6 +1921,10 @@ func funcUniqueBy(v, x interface{}) interface{} {
 }
 
 func sortItems(v, x interface{}) ([]*sortItem, error) {
+	return sortItemsWith(v, x, nil)
+}
+
+func sortItemsWith(v, x interface{}, cmp func(interface{}, interface{}) int) ([]*sortItem, error) {
 	vs, ok := v.([]interface{})
 	if !ok {
 		return nil, &expectedArrayError{v}
@@ -986,12 +1940,115 @@ func sortItems(v, x interface{}) ([]*sortItem, error) {
 	for i, v := range vs {
 		items[i] = &sortItem{v, xs[i]}
 	}
+	if cmp == nil {
+		if sortItemsByScalarKeys(items) {
+			return items, nil
+		}
+		cmp = compare
+	}
 	sort.SliceStable(items, func(i, j int) bool {
-		return compare(items[i].key, items[j].key) < 0
+		return cmp(items[i].key, items[j].key) < 0
 	})
 	return items, nil
 }
 
+// sortItemsByScalarKeys sorts the items when all the keys are strings or all
+// the keys are numbers (the keys of sort_by(.name) are [.name]), comparing the
+// keys without the type switches of compare. The items are sorted by unstable
+// sort with the indices as the tie-breakers, which results in the same order as
+// the stable sort with less swaps. This function reports false for other keys.
+func sortItemsByScalarKeys(items []*sortItem) bool {
+	var strs stringKeyItems
+	var nums numberKeyItems
+	for i, item := range items {
+		xs, ok := item.key.([]interface{})
+		if !ok || len(xs) != 1 {
+			return false
+		}
+		switch x := xs[0].(type) {
+		case string:
+			if nums != nil {
+				return false
+			} else if strs == nil {
+				strs = make(stringKeyItems, 0, len(items))
+			}
+			strs = append(strs, stringKeyItem{x, i, item})
+		case int:
+			f := float64(x)
+			if strs != nil || int(f) != x {
+				return false // large integers are not exact in floating-point numbers
+			} else if nums == nil {
+				nums = make(numberKeyItems, 0, len(items))
+			}
+			nums = append(nums, numberKeyItem{f, i, item})
+		case float64:
+			if strs != nil {
+				return false
+			} else if nums == nil {
+				nums = make(numberKeyItems, 0, len(items))
+			}
+			nums = append(nums, numberKeyItem{x, i, item})
+		default:
+			return false
+		}
+	}
+	if strs != nil {
+		sort.Sort(strs)
+		for i, x := range strs {
+			items[i] = x.item
+		}
+	} else {
+		sort.Sort(nums)
+		for i, x := range nums {
+			items[i] = x.item
+		}
+	}
+	return true
+}
+
+type stringKeyItem struct {
+	key   string
+	index int
+	item  *sortItem
+}
+
+type stringKeyItems []stringKeyItem
+
+func (xs stringKeyItems) Len() int      { return len(xs) }
+func (xs stringKeyItems) Swap(i, j int) { xs[i], xs[j] = xs[j], xs[i] }
+func (xs stringKeyItems) Less(i, j int) bool {
+	if xs[i].key != xs[j].key {
+		return xs[i].key < xs[j].key
+	}
+	return xs[i].index < xs[j].index
+}
+
+type numberKeyItem struct {
+	key   float64
+	index int
+	item  *sortItem
+}
+
+type numberKeyItems []numberKeyItem
+
+func (xs numberKeyItems) Len() int      { return len(xs) }
+func (xs numberKeyItems) Swap(i, j int) { xs[i], xs[j] = xs[j], xs[i] }
+
+// Less orders NaN before the other numbers, as compare does.
+func (xs numberKeyItems) Less(i, j int) bool {
+	l, r := xs[i].key, xs[j].key
+	switch {
+	case l < r:
+		return true
+	case l == r:
+		return xs[i].index < xs[j].index
+	case math.IsNaN(l):
+		return !math.IsNaN(r) || xs[i].index < xs[j].index
+	default:
+		return false
+	}
+}
+
 func funcSignificand(v float64) float64 {
 	if math.IsNaN(v) || math.IsInf(v, 0) || v == 0.0 {
 		return v
@@ -1092,13 +2149,111 @@ func funcIsnormal(v interface{}) interface{} {
 	return ok && !math.IsNaN(x) && !math.IsInf(x, 0) && x != 0.0
 }
 
+func funcNonFinite(v, x interface{}) interface{} {
+	mode, ok := x.(string)
+	if !ok {
+		return &funcTypeError{"nonfinite", x}
+	}
+	switch mode {
+	case "clamp", "null", "string", "error":
+	default:
+		return &nonFiniteModeError{mode}
+	}
+	v, err := replaceNonFinite(v, mode)
+	if err != nil {
+		return err
+	}
+	return v
+}
+
+// replaceNonFinite replaces NaN and infinities in v by the mode; "clamp" emits
+// null for NaN and the largest numbers for infinities, as the encoder does,
+// "null" emits null, "string" emits "NaN", "Infinity" or "-Infinity", and
+// "error" returns an error.
+func replaceNonFinite(v interface{}, mode string) (interface{}, error) {
+	switch v := v.(type) {
+	case float64:
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			return v, nil
+		}
+		switch mode {
+		case "clamp":
+			if math.IsNaN(v) {
+				return nil, nil
+			} else if v > 0 {
+				return math.MaxFloat64, nil
+			}
+			return -math.MaxFloat64, nil
+		case "null":
+			return nil, nil
+		case "string":
+			return formatNonFinite(v), nil
+		default:
+			return nil, &nonFiniteError{v}
+		}
+	case []interface{}:
+		vs := make([]interface{}, len(v))
+		for i, x := range v {
+			y, err := replaceNonFinite(x, mode)
+			if err != nil {
+				return nil, err
+			}
+			vs[i] = y
+		}
+		return vs, nil
+	case map[string]interface{}:
+		vs := make(map[string]interface{}, len(v))
+		for k, x := range v {
+			y, err := replaceNonFinite(x, mode)
+			if err != nil {
+				return nil, err
+			}
+			vs[k] = y
+		}
+		return vs, nil
+	case *OrderedMap:
+		vs := v.clone()
+		for _, k := range v.keys {
+			y, err := replaceNonFinite(v.values[k], mode)
+			if err != nil {
+				return nil, err
+			}
+			vs.values[k] = y
+		}
+		return vs, nil
+	case JQValue:
+		w, _ := resolveJQValue(v)
+		return replaceNonFinite(w, mode)
+	default:
+		return v, nil
+	}
+}
+
+func formatNonFinite(v float64) string {
+	if math.IsNaN(v) {
+		return "NaN"
+	} else if v > 0 {
+		return "Infinity"
+	}
+	return "-Infinity"
+}
+
 func funcSetpath(v, p, w interface{}) interface{} {
+	return setpath(v, p, w, false)
+}
+
+func funcSetpathOrdered(v, p, w interface{}) interface{} {
+	return setpath(v, p, w, true)
+}
+
+func setpath(v, p, w interface{}, ordered bool) interface{} {
+	p, _ = resolveJQValue(p)
 	path, ok := p.([]interface{})
 	if !ok {
 		return &funcTypeError{"setpath", p}
 	}
 	var err error
-	if v, err = updatePaths(v, path, w, false); err != nil {
+	if v, err = updatePaths(v, path, w, false, ordered); err != nil {
 		if err, ok := err.(*funcTypeError); ok {
 			err.name = "setpath"
 		}
@@ -1108,6 +2263,7 @@ func funcSetpath(v, p, w interface{}) interface{} {
 }
 
 func funcDelpaths(v, p interface{}) interface{} {
+	p, _ = resolveJQValue(p)
 	paths, ok := p.([]interface{})
 	if !ok {
 		return &funcTypeError{"delpaths", p}
@@ -1122,14 +2278,14 @@ func funcDelpaths(v, p interface{}) interface{} {
 		if !ok {
 			return &funcTypeError{"delpaths", p}
 		}
-		if v, err = updatePaths(v, path, empty, true); err != nil {
+		if v, err = updatePaths(v, path, empty, true, false); err != nil {
 			return err
 		}
 	}
 	return deleteEmpty(v)
 }
 
-func updatePaths(v interface{}, path []interface{}, w interface{}, delpaths bool) (interface{}, error) {
+func updatePaths(v interface{}, path []interface{}, w interface{}, delpaths, ordered bool) (interface{}, error) {
 	if len(path) == 0 {
 		return w, nil
 	}
@@ -1139,14 +2295,18 @@ func updatePaths(v interface{}, path []interface{}, w interface{}, delpaths bool
 			if delpaths {
 				return v, nil
 			}
-			v = make(map[string]interface{})
+			if ordered {
+				v = NewOrderedMap()
+			} else {
+				v = make(map[string]interface{})
+			}
 		}
 		switch uu := v.(type) {
 		case map[string]interface{}:
 			if _, ok := uu[x]; !ok && delpaths {
 				return v, nil
 			}
-			u, err := updatePaths(uu[x], path[1:], w, delpaths)
+			u, err := updatePaths(uu[x], path[1:], w, delpaths, ordered)
 			if err != nil {
 				return nil, err
 			}
@@ -1156,6 +2316,20 @@ func updatePaths(v interface{}, path []interface{}, w interface{}, delpaths bool
 			}
 			vs[x] = u
 			return vs, nil
+		case *OrderedMap:
+			if _, ok := uu.values[x]; !ok && delpaths {
+				return v, nil
+			}
+			u, err := updatePaths(uu.values[x], path[1:], w, delpaths, ordered)
+			if err != nil {
+				return nil, err
+			}
+			vs := uu.clone()
+			vs.Set(x, u)
+			return vs, nil
+		case JQValue:
+			v, _ = resolveJQValue(v)
+			return updatePaths(v, path, w, delpaths, ordered)
 		default:
 			return nil, &expectedObjectError{v}
 		}
@@ -1190,7 +2364,7 @@ func updatePaths(v interface{}, path []interface{}, w interface{}, delpaths bool
 			} else if y < 0 {
 				y += l
 			}
-			u, err := updatePaths(uu[y], path[1:], w, delpaths)
+			u, err := updatePaths(uu[y], path[1:], w, delpaths, ordered)
 			if err != nil {
 				return nil, err
 			}
@@ -1202,6 +2376,9 @@ func updatePaths(v interface{}, path []interface{}, w interface{}, delpaths bool
 			copy(vs, uu)
 			vs[y] = u
 			return vs, nil
+		case JQValue:
+			v, _ = resolveJQValue(v)
+			return updatePaths(v, path, w, delpaths, ordered)
 		default:
 			return nil, &expectedArrayError{v}
 		}
@@ -1247,7 +2424,7 @@ func updatePaths(v interface{}, path []interface{}, w interface{}, delpaths bool
 					return uu, nil
 				}
 				if len(path) > 1 {
-					u, err := updatePaths(uu[start:end], path[1:], w, delpaths)
+					u, err := updatePaths(uu[start:end], path[1:], w, delpaths, ordered)
 					if err != nil {
 						return nil, err
 					}
@@ -1269,7 +2446,7 @@ func updatePaths(v interface{}, path []interface{}, w interface{}, delpaths bool
 				return vs, nil
 			}
 			if len(path) > 1 {
-				u, err := updatePaths(uu[start:end], path[1:], w, delpaths)
+				u, err := updatePaths(uu[start:end], path[1:], w, delpaths, ordered)
 				if err != nil {
 					return nil, err
 				}
@@ -1285,6 +2462,9 @@ func updatePaths(v interface{}, path []interface{}, w interface{}, delpaths bool
 			default:
 				return nil, &expectedArrayError{v}
 			}
+		case JQValue:
+			v, _ = resolveJQValue(v)
+			return updatePaths(v, path, w, delpaths, ordered)
 		default:
 			return nil, &expectedArrayError{v}
 		}
@@ -1299,6 +2479,7 @@ func updatePaths(v interface{}, path []interface{}, w interface{}, delpaths bool
 }
 
 func funcGetpath(v, p interface{}) interface{} {
+	p, _ = resolveJQValue(p)
 	keys, ok := p.([]interface{})
 	if !ok {
 		return &funcTypeError{"getpath", p}
@@ -1308,6 +2489,7 @@ func funcGetpath(v, p interface{}) interface{} {
 		switch v.(type) {
 		case map[string]interface{}:
 		case []interface{}:
+		case JQValue:
 		case nil:
 		default:
 			return &getpathError{u, p}
@@ -1335,6 +2517,9 @@ func funcBsearch(v, t interface{}) interface{} {
 }
 
 func funcGmtime(v interface{}) interface{} {
+	if t, ok := v.(time.Time); ok {
+		v = timeToEpoch(t)
+	}
 	if v, ok := toFloat(v); ok {
 		return epochToArray(v, time.UTC)
 	}
@@ -1342,6 +2527,9 @@ func funcGmtime(v interface{}) interface{} {
 }
 
 func funcLocaltime(v interface{}) interface{} {
+	if t, ok := v.(time.Time); ok {
+		v = timeToEpoch(t)
+	}
 	if v, ok := toFloat(v); ok {
 		return epochToArray(v, time.Local)
 	}
@@ -1349,7 +2537,7 @@ func funcLocaltime(v interface{}) interface{} {
 }
 
 func epochToArray(v float64, loc *time.Location) []interface{} {
-	t := time.Unix(int64(v), int64((v-math.Floor(v))*1e9)).In(loc)
+	t := epochToTime(v).In(loc)
 	return []interface{}{
 		t.Year(),
 		int(t.Month()) - 1,
@@ -1363,6 +2551,9 @@ func epochToArray(v float64, loc *time.Location) []interface{} {
 }
 
 func funcMktime(v interface{}) interface{} {
+	if t, ok := v.(time.Time); ok {
+		return float64(t.Unix())
+	}
 	if a, ok := v.([]interface{}); ok {
 		t, err := arrayToTime("mktime", a, time.UTC)
 		if err != nil {
@@ -1374,6 +2565,12 @@ func funcMktime(v interface{}) interface{} {
 }
 
 func funcStrftime(v, x interface{}) interface{} {
+	if t, ok := v.(time.Time); ok {
+		if format, ok := x.(string); ok {
+			return timefmt.Format(t, format)
+		}
+		return &funcTypeError{"strftime", x}
+	}
 	if w, ok := toFloat(v); ok {
 		v = epochToArray(w, time.UTC)
 	}
@@ -1391,6 +2588,12 @@ func funcStrftime(v, x interface{}) interface{} {
 }
 
 func funcStrflocaltime(v, x interface{}) interface{} {
+	if t, ok := v.(time.Time); ok {
+		if format, ok := x.(string); ok {
+			return timefmt.Format(t.Local(), format)
+		}
+		return &funcTypeError{"strflocaltime", x}
+	}
 	if w, ok := toFloat(v); ok {
 		v = epochToArray(w, time.Local)
 	}
@@ -1466,11 +2669,32 @@ func arrayToTime(name string, a []interface{}, loc *time.Location) (time.Time, e
 }
 
 func funcNow(interface{}) interface{} {
-	t := time.Now()
-	return float64(t.Unix()) + float64(t.Nanosecond())/1e9
+	return timeToEpoch(time.Now())
+}
+
+func funcNowNs(interface{}) interface{} {
+	return normalizeNumbers(big.NewInt(time.Now().UnixNano()))
+}
+
+// monotonicStart is the origin of the monotonic clock, which is not affected by
+// the changes of the wall clock.
+var monotonicStart = time.Now()
+
+func funcMonotonic(interface{}) interface{} {
+	return time.Since(monotonicStart).Seconds()
+}
+
+func funcSleep(v, x interface{}) interface{} {
+	d, ok := toFloat(x)
+	if !ok || d < 0 || math.IsNaN(d) || d > math.MaxInt64/float64(time.Second) {
+		return &funcTypeError{"sleep", x}
+	}
+	time.Sleep(time.Duration(d * float64(time.Second)))
+	return v
 }
 
-func funcMatch(v, re, fs, testing interface{}) interface{} {
+func funcMatch(ctx context.Context, v interface{}, args []interface{}) interface{} {
+	re, fs, testing := args[0], args[1], args[2]
 	var flags string
 	if fs != nil {
 		v, ok := fs.(string)
@@ -1493,9 +2717,14 @@ func funcMatch(v, re, fs, testing interface{}) interface{} {
 	}
 	var xs [][]int
 	if strings.ContainsRune(flags, 'g') && testing != true {
-		xs = r.FindAllStringSubmatchIndex(s, -1)
+		if xs, err = r.FindAllStringSubmatchIndex(ctx, s, -1); err != nil {
+			return err
+		}
 	} else {
-		got := r.FindStringSubmatchIndex(s)
+		got, err := r.FindStringSubmatchIndex(ctx, s)
+		if err != nil {
+			return err
+		}
 		if testing == true {
 			return got != nil
 		}
@@ -1537,7 +2766,41 @@ func funcMatch(v, re, fs, testing interface{}) interface{} {
 	return res
 }
 
-func compileRegexp(re, flags string) (*regexp.Regexp, error) {
+// regexpMatcher is implemented by *stdRegexp and *pcreRegexp. The matching of
+// *pcreRegexp fails on cancellation and when it exceeds the backtracking limit.
+type regexpMatcher interface {
+	FindStringSubmatchIndex(context.Context, string) ([]int, error)
+	FindAllStringSubmatchIndex(context.Context, string, int) ([][]int, error)
+	SubexpNames() []string
+	Split(context.Context, string, int) ([]string, error)
+}
+
+// stdRegexp implements regexpMatcher with the regexp package, which matches in
+// linear time and does not need the context.
+type stdRegexp struct {
+	*regexp.Regexp
+}
+
+func (r stdRegexp) FindStringSubmatchIndex(_ context.Context, s string) ([]int, error) {
+	return r.Regexp.FindStringSubmatchIndex(s), nil
+}
+
+func (r stdRegexp) FindAllStringSubmatchIndex(_ context.Context, s string, n int) ([][]int, error) {
+	return r.Regexp.FindAllStringSubmatchIndex(s, n), nil
+}
+
+func (r stdRegexp) Split(_ context.Context, s string, n int) ([]string, error) {
+	return r.Regexp.Split(s, n), nil
+}
+
+func compileRegexp(re, flags string) (regexpMatcher, error) {
+	if strings.ContainsRune(flags, 'P') {
+		r, err := compilePCRE(re, strings.ContainsRune(flags, 'i'), strings.ContainsRune(flags, 'm'))
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %s", re, err)
+		}
+		return r, nil
+	}
 	re = strings.ReplaceAll(re, "(?<", "(?P<")
 	if strings.ContainsRune(flags, 'i') {
 		re = "(?i)" + re
@@ -1549,7 +2812,7 @@ func compileRegexp(re, flags string) (*regexp.Regexp, error) {
 	if err != nil {
 		return nil, fmt.Errorf("invalid regular expression %q: %s", re, err)
 	}
-	return r, nil
+	return stdRegexp{r}, nil
 }
 
 func funcError(v interface{}, args []interface{}) interface{} {
@@ -1601,6 +2864,31 @@ func toInt(x interface{}) (int, bool) {
 			return maxInt, true
 		}
 		return minInt, true
+	case json.Number:
+		return floatToInt(numberToFloat(x)), true
+	default:
+		return 0, false
+	}
+}
+
+// toInt64 converts the integral number to int64, and fails on the fractional
+// numbers and the numbers out of 64-bit range.
+func toInt64(x interface{}) (int64, bool) {
+	switch x := x.(type) {
+	case int:
+		return int64(x), true
+	case float64:
+		if x != math.Trunc(x) || x < math.MinInt64 || x >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(x), true
+	case *big.Int:
+		return x.Int64(), x.IsInt64()
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return i, true
+		}
+		return toInt64(normalizeNumbers(x))
 	default:
 		return 0, false
 	}
@@ -1616,6 +2904,17 @@ func floatToInt(x float64) int {
 	return minInt
 }
 
+func toBytes(x interface{}) ([]byte, bool) {
+	switch x := x.(type) {
+	case string:
+		return []byte(x), true
+	case []byte:
+		return x, true
+	default:
+		return nil, false
+	}
+}
+
 func toFloat(x interface{}) (float64, bool) {
 	switch x := x.(type) {
 	case int:
@@ -1624,6 +2923,8 @@ func toFloat(x interface{}) (float64, bool) {
 		return x, true
 	case *big.Int:
 		return bigToFloat(x), true
+	case json.Number:
+		return numberToFloat(x), true
 	default:
 		return 0.0, false
 	}
@@ -0,0 +1,127 @@
+package gojq
+
+import (
+	"regexp"
+	"strings"
+)
+
+// globToRegexp translates the glob pattern to the regular expression, where
+// "*" matches any characters except "/", "**" as a path segment matches zero or
+// more directories, "?" matches a character except "/", "[...]" matches one of
+// the characters ("[!...]" for negation), and "{a,b}" matches either pattern.
+func globToRegexp(pattern string) (string, error) {
+	var sb strings.Builder
+	sb.WriteByte('^')
+	rs := []rune(pattern)
+	var depth int
+	for i := 0; i < len(rs); i++ {
+		switch r := rs[i]; r {
+		case '\\':
+			if i++; i == len(rs) {
+				return "", &globPatternError{pattern, "trailing backslash"}
+			}
+			sb.WriteString(regexp.QuoteMeta(string(rs[i])))
+		case '*':
+			j := i
+			for j+1 < len(rs) && rs[j+1] == '*' {
+				j++
+			}
+			if j > i && (i == 0 || rs[i-1] == '/') && (j+1 == len(rs) || rs[j+1] == '/') {
+				if j+1 == len(rs) {
+					sb.WriteString(".*")
+				} else {
+					sb.WriteString("(?:.*/)?")
+					j++
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+			i = j
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			j := i + 1
+			if j < len(rs) && (rs[j] == '!' || rs[j] == '^') {
+				j++
+			}
+			if j < len(rs) && rs[j] == ']' {
+				j++
+			}
+			for j < len(rs) && rs[j] != ']' {
+				j++
+			}
+			if j == len(rs) {
+				return "", &globPatternError{pattern, "unclosed bracket"}
+			}
+			sb.WriteByte('[')
+			k := i + 1
+			if rs[k] == '!' || rs[k] == '^' {
+				sb.WriteString("^/")
+				k++
+			}
+			for ; k < j; k++ {
+				if rs[k] == '\\' || rs[k] == '[' || rs[k] == ']' || rs[k] == '^' {
+					sb.WriteByte('\\')
+				}
+				sb.WriteRune(rs[k])
+			}
+			sb.WriteByte(']')
+			i = j
+		case '{':
+			depth++
+			sb.WriteString("(?:")
+		case ',':
+			if depth > 0 {
+				sb.WriteByte('|')
+			} else {
+				sb.WriteByte(',')
+			}
+		case '}':
+			if depth > 0 {
+				depth--
+				sb.WriteByte(')')
+			} else {
+				sb.WriteString(`\}`)
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if depth > 0 {
+		return "", &globPatternError{pattern, "unclosed brace"}
+	}
+	sb.WriteByte('$')
+	return sb.String(), nil
+}
+
+func funcGlobToRegex(v interface{}) interface{} {
+	pattern, ok := v.(string)
+	if !ok {
+		return &funcTypeError{"glob_to_regex", v}
+	}
+	re, err := globToRegexp(pattern)
+	if err != nil {
+		return err
+	}
+	return re
+}
+
+func funcGlobMatch(v, x interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return &funcTypeError{"glob_match", v}
+	}
+	pattern, ok := x.(string)
+	if !ok {
+		return &funcTypeError{"glob_match", x}
+	}
+	re, err := globToRegexp(pattern)
+	if err != nil {
+		return err
+	}
+	r, err := regexp.Compile(re)
+	if err != nil {
+		return &globPatternError{pattern, "invalid bracket"}
+	}
+	return r.MatchString(s)
+}
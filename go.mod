@@ -3,11 +3,13 @@ module github.com/itchyny/gojq
 go 1.14
 
 require (
-	github.com/google/go-cmp v0.5.4
+	github.com/google/go-cmp v0.5.8
 	github.com/itchyny/go-flags v1.5.0
 	github.com/itchyny/timefmt-go v0.1.2
+	github.com/klauspost/compress v1.15.0
 	github.com/mattn/go-isatty v0.0.12
 	github.com/mattn/go-runewidth v0.0.9
-	golang.org/x/sys v0.0.0-20210301091718-77cc2087c03b // indirect
+	golang.org/x/sys v0.0.0-20210301091718-77cc2087c03b
+	google.golang.org/protobuf v1.28.1
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
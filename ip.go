@@ -0,0 +1,156 @@
+package gojq
+
+import (
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ipAddress is the IP address with the optional network of the CIDR notation.
+type ipAddress struct {
+	ip      net.IP // 4 bytes for IPv4 and 16 bytes for IPv6
+	network *net.IPNet
+}
+
+func parseIPAddress(s string) (*ipAddress, bool) {
+	var ip net.IP
+	var network *net.IPNet
+	if strings.IndexByte(s, '/') >= 0 {
+		var err error
+		if ip, network, err = net.ParseCIDR(s); err != nil {
+			return nil, false
+		}
+	} else if ip = net.ParseIP(s); ip == nil {
+		return nil, false
+	}
+	if strings.IndexByte(s, ':') < 0 {
+		ip = ip.To4()
+	} else {
+		ip = ip.To16()
+	}
+	return &ipAddress{ip, network}, true
+}
+
+func (a *ipAddress) version() int {
+	if len(a.ip) == net.IPv4len {
+		return 4
+	}
+	return 6
+}
+
+// contains reports whether the address (or the network) is in the network.
+func (a *ipAddress) contains(b *ipAddress) bool {
+	if a.version() != b.version() {
+		return false
+	}
+	if a.network == nil {
+		return b.network == nil && a.ip.Equal(b.ip)
+	}
+	if !a.network.Contains(b.ip) {
+		return false
+	}
+	if b.network != nil {
+		x, _ := a.network.Mask.Size()
+		y, _ := b.network.Mask.Size()
+		return x <= y
+	}
+	return true
+}
+
+func (a *ipAddress) toObject() map[string]interface{} {
+	m := map[string]interface{}{
+		"address": formatIP(a.ip),
+		"version": a.version(),
+		"prefix":  nil,
+		"network": nil,
+	}
+	if a.network != nil {
+		m["prefix"], _ = a.network.Mask.Size()
+		m["network"] = formatIP(a.network.IP) + "/" + strconv.Itoa(m["prefix"].(int))
+	}
+	return m
+}
+
+// formatIP formats the IP address. Unlike net.IP, this function formats the
+// IPv4-mapped IPv6 addresses in the IPv6 notation.
+func formatIP(ip net.IP) string {
+	if len(ip) == net.IPv6len && ip.To4() != nil {
+		return "::ffff:" + ip.To4().String()
+	}
+	return ip.String()
+}
+
+func toIPAddress(name string, v interface{}) (*ipAddress, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, &funcTypeError{name, v}
+	}
+	a, ok := parseIPAddress(s)
+	if !ok {
+		return nil, &ipAddressError{s}
+	}
+	return a, nil
+}
+
+func funcIPParse(v interface{}) interface{} {
+	a, err := toIPAddress("ip_parse", v)
+	if err != nil {
+		return err
+	}
+	return a.toObject()
+}
+
+func funcIPVersion(v interface{}) interface{} {
+	a, err := toIPAddress("ip_version", v)
+	if err != nil {
+		return err
+	}
+	return a.version()
+}
+
+func funcCIDRContains(v, x interface{}) interface{} {
+	a, err := toIPAddress("cidr_contains", v)
+	if err != nil {
+		return err
+	}
+	b, err := toIPAddress("cidr_contains", x)
+	if err != nil {
+		return err
+	}
+	return b.contains(a)
+}
+
+func funcIPToInt(v interface{}) interface{} {
+	a, err := toIPAddress("ip_to_int", v)
+	if err != nil {
+		return err
+	}
+	return normalizeNumbers(new(big.Int).SetBytes(a.ip))
+}
+
+func funcIntToIP(v interface{}, args []interface{}) interface{} {
+	r, ok := toRat(v)
+	if !ok || !r.IsInt() || r.Sign() < 0 {
+		return &funcTypeError{"int_to_ip", v}
+	}
+	x := r.Num()
+	size := net.IPv4len
+	if len(args) > 0 {
+		switch n, _ := toInt(args[0]); n {
+		case 4:
+		case 6:
+			size = net.IPv6len
+		default:
+			return &funcTypeError{"int_to_ip", args[0]}
+		}
+	} else if x.BitLen() > 32 {
+		size = net.IPv6len
+	}
+	if x.BitLen() > size*8 {
+		return &funcTypeError{"int_to_ip", v}
+	}
+	ip, bs := make(net.IP, size), x.Bytes()
+	copy(ip[size-len(bs):], bs)
+	return formatIP(ip)
+}
@@ -47,3 +47,20 @@ func (iter *sliceIter) Next() (interface{}, bool) {
 	*iter = (*iter)[1:]
 	return value, true
 }
+
+// rangeIter emits the numbers from the start to the end (exclusive) by the
+// step lazily, without the recursion of the function in the query.
+type rangeIter struct {
+	value, end, step interface{}
+	sign             int
+	add              func(_, l, r interface{}) interface{}
+}
+
+func (iter *rangeIter) Next() (interface{}, bool) {
+	if iter.sign == 0 || compare(iter.value, iter.end)*iter.sign >= 0 {
+		return nil, false
+	}
+	v := iter.value
+	iter.value = iter.add(nil, v, iter.step)
+	return v, true
+}
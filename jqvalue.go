@@ -0,0 +1,262 @@
+package gojq
+
+import (
+	"context"
+	"reflect"
+	"sort"
+)
+
+// JQValue is the interface for custom values of objects and arrays. Embedders
+// can pass the values implementing this interface to the query (as the input,
+// variables or in the results of custom functions) without converting large
+// typed object graphs to map[string]interface{} or []interface{} in advance.
+//
+// The query engine consults the methods on indexing (.foo, .[0]), iteration
+// (.[]), keys, has, length and type. For any other operation, the value is
+// converted to the builtin types by JQValueToJSON. The values emitted by the
+// query may contain the custom values.
+//
+// The custom array value can also implement JQValueIter() Iter to produce the
+// elements on demand. The method is called on each iteration (.[]) and add,
+// and the returned iterator should emit the elements from the first one.
+type JQValue interface {
+	// JQValueType returns "object" or "array".
+	JQValueType() string
+
+	// JQValueLength returns the number of the elements.
+	JQValueLength() int
+
+	// JQValueIndex returns the element at the key, which is a string for
+	// objects and a non-negative int for arrays. This method returns nil
+	// when the element does not exist. The element should be a value of the
+	// builtin types or a JQValue.
+	JQValueIndex(key interface{}) interface{}
+
+	// JQValueKeys returns the keys of the elements. The keys of objects are
+	// sorted strings and the keys of arrays are ints in ascending order.
+	JQValueKeys() []interface{}
+
+	// JQValueToJSON converts the value to map[string]interface{} or
+	// []interface{}. The elements may contain JQValue.
+	JQValueToJSON() interface{}
+}
+
+// jqValueIter is implemented by the custom array values which produce the
+// elements on demand (ref: JQValue).
+type jqValueIter interface {
+	JQValueIter() Iter
+}
+
+// resolveJQValue converts the custom values in v to the builtin types. This
+// function copies the arrays and maps only when they contain custom values.
+func resolveJQValue(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case JQValue:
+		w, _ := resolveJQValue(normalizeNumbers(v.JQValueToJSON()))
+		return w, true
+	case []interface{}:
+		var ws []interface{}
+		for i, x := range v {
+			if y, ok := resolveJQValue(x); ok {
+				if ws == nil {
+					ws = make([]interface{}, len(v))
+					copy(ws, v)
+				}
+				ws[i] = y
+			}
+		}
+		if ws == nil {
+			return v, false
+		}
+		return ws, true
+	case map[string]interface{}:
+		var ws map[string]interface{}
+		for k, x := range v {
+			if y, ok := resolveJQValue(x); ok {
+				if ws == nil {
+					ws = make(map[string]interface{}, len(v))
+					for k, x := range v {
+						ws[k] = x
+					}
+				}
+				ws[k] = y
+			}
+		}
+		if ws == nil {
+			return v, false
+		}
+		return ws, true
+	default:
+		return v, false
+	}
+}
+
+// resolveJQValueFunc wraps the internal function to resolve the custom values
+// in the input and arguments.
+func resolveJQValueFunc(
+	f func(interface{}, []interface{}) interface{},
+) func(interface{}, []interface{}) interface{} {
+	return func(v interface{}, args []interface{}) interface{} {
+		u, xs := v, orderedMapArgs(args)
+		v, _ = resolveJQValue(v)
+		for i, x := range args {
+			args[i], _ = resolveJQValue(x)
+		}
+		return restoreOrderedMaps(f(v, args), u, v, xs, args)
+	}
+}
+
+// resolveJQValueContextFunc is resolveJQValueFunc for the functions taking the
+// context of the execution.
+func resolveJQValueContextFunc(f contextFunc) contextFunc {
+	return func(ctx context.Context, v interface{}, args []interface{}) interface{} {
+		u, xs := v, orderedMapArgs(args)
+		v, _ = resolveJQValue(v)
+		for i, x := range args {
+			args[i], _ = resolveJQValue(x)
+		}
+		return restoreOrderedMaps(f(ctx, v, args), u, v, xs, args)
+	}
+}
+
+// resolveShallowJQValueFunc wraps the internal function to resolve the input
+// and arguments but not their elements, so that the function can return the
+// elements as they are.
+func resolveShallowJQValueFunc(
+	f func(interface{}, []interface{}) interface{},
+) func(interface{}, []interface{}) interface{} {
+	return func(v interface{}, args []interface{}) interface{} {
+		u, xs := v, orderedMapArgs(args)
+		if w, ok := v.(JQValue); ok {
+			v = normalizeNumbers(w.JQValueToJSON())
+		}
+		for i, x := range args {
+			if w, ok := x.(JQValue); ok {
+				args[i] = normalizeNumbers(w.JQValueToJSON())
+			}
+		}
+		return restoreOrderedMaps(f(v, args), u, v, xs, args)
+	}
+}
+
+// resolveJQValueArgsFunc wraps the internal function to resolve the arguments
+// but not the input, which the operators do not use.
+func resolveJQValueArgsFunc(
+	f func(interface{}, []interface{}) interface{},
+) func(interface{}, []interface{}) interface{} {
+	return func(v interface{}, args []interface{}) interface{} {
+		xs := orderedMapArgs(args)
+		for i, x := range args {
+			args[i], _ = resolveJQValue(x)
+		}
+		return restoreOrderedMaps(f(v, args), nil, nil, xs, args)
+	}
+}
+
+// orderedMapArgs returns a copy of the arguments when any of them is
+// *OrderedMap, which is used to restore the key order in the type errors.
+func orderedMapArgs(args []interface{}) []interface{} {
+	for _, x := range args {
+		if _, ok := x.(*OrderedMap); ok {
+			return append([]interface{}{}, args...)
+		}
+	}
+	return nil
+}
+
+// restoreOrderedMaps replaces the objects resolved from *OrderedMap in the type
+// error with the original values, so that the error message and the value of
+// error/1 keep the key order.
+func restoreOrderedMaps(w, v, u interface{}, xs, ys []interface{}) interface{} {
+	if _, ok := w.(error); !ok {
+		return w
+	}
+	restore := func(x *interface{}) {
+		m, ok := (*x).(map[string]interface{})
+		if !ok {
+			return
+		}
+		p := reflect.ValueOf(m).Pointer()
+		if isResolvedOrderedMap(v, u, p) {
+			*x = v
+			return
+		}
+		for i, o := range xs {
+			if isResolvedOrderedMap(o, ys[i], p) {
+				*x = o
+				return
+			}
+		}
+	}
+	switch err := w.(type) {
+	case *funcTypeError:
+		restore(&err.v)
+	case *unaryTypeError:
+		restore(&err.v)
+	case *binopTypeError:
+		restore(&err.l)
+		restore(&err.r)
+	case *zeroDivisionError:
+		restore(&err.l)
+		restore(&err.r)
+	case *zeroModuloError:
+		restore(&err.l)
+		restore(&err.r)
+	case *funcContainsError:
+		restore(&err.l)
+		restore(&err.r)
+	case *hasKeyTypeError:
+		restore(&err.l)
+		restore(&err.r)
+	case *exitCodeError:
+		restore(&err.value)
+	}
+	return w
+}
+
+func isResolvedOrderedMap(v, u interface{}, p uintptr) bool {
+	if _, ok := v.(*OrderedMap); !ok {
+		return false
+	}
+	m, ok := u.(map[string]interface{})
+	return ok && reflect.ValueOf(m).Pointer() == p
+}
+
+// resolveOrderedJQValueFunc wraps the function in orderedFuncs to resolve the
+// custom values in the input and arguments except for *OrderedMap.
+func resolveOrderedJQValueFunc(
+	f func(interface{}, []interface{}) interface{},
+) func(interface{}, []interface{}) interface{} {
+	return func(v interface{}, args []interface{}) interface{} {
+		if w, ok := v.(JQValue); ok {
+			if _, ok := w.(*OrderedMap); !ok {
+				v = normalizeNumbers(w.JQValueToJSON())
+			}
+		}
+		for i, x := range args {
+			if w, ok := x.(JQValue); ok {
+				if _, ok := w.(*OrderedMap); !ok {
+					args[i] = normalizeNumbers(w.JQValueToJSON())
+				}
+			}
+		}
+		return f(v, args)
+	}
+}
+
+// jqValueKeys returns the keys of the custom value in the same manner as the
+// keys function.
+func jqValueKeys(v JQValue) []interface{} {
+	ks := v.JQValueKeys()
+	if ks == nil {
+		ks = []interface{}{}
+	}
+	return ks
+}
+
+// hasJQValueKey reports whether the custom object value has the key.
+func hasJQValueKey(v JQValue, key string) bool {
+	ks := v.JQValueKeys()
+	i := sort.Search(len(ks), func(i int) bool { return ks[i].(string) >= key })
+	return i < len(ks) && ks[i] == key
+}
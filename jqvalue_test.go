@@ -0,0 +1,115 @@
+package gojq_test
+
+import (
+	"testing"
+
+	"github.com/itchyny/gojq"
+)
+
+type testUser struct {
+	Name string
+	Age  int
+	Tags testTags
+}
+
+func (u *testUser) JQValueType() string { return "object" }
+
+func (u *testUser) JQValueLength() int { return 3 }
+
+func (u *testUser) JQValueIndex(key interface{}) interface{} {
+	switch key {
+	case "age":
+		return u.Age
+	case "name":
+		return u.Name
+	case "tags":
+		return u.Tags
+	default:
+		return nil
+	}
+}
+
+func (u *testUser) JQValueKeys() []interface{} {
+	return []interface{}{"age", "name", "tags"}
+}
+
+func (u *testUser) JQValueToJSON() interface{} {
+	return map[string]interface{}{"age": u.Age, "name": u.Name, "tags": u.Tags}
+}
+
+type testTags []string
+
+func (ts testTags) JQValueType() string { return "array" }
+
+func (ts testTags) JQValueLength() int { return len(ts) }
+
+func (ts testTags) JQValueIndex(key interface{}) interface{} {
+	return ts[key.(int)]
+}
+
+func (ts testTags) JQValueKeys() []interface{} {
+	ks := make([]interface{}, len(ts))
+	for i := range ts {
+		ks[i] = i
+	}
+	return ks
+}
+
+func (ts testTags) JQValueToJSON() interface{} {
+	vs := make([]interface{}, len(ts))
+	for i, t := range ts {
+		vs[i] = t
+	}
+	return vs
+}
+
+func TestJQValue(t *testing.T) {
+	testCases := []struct {
+		src      string
+		expected []string
+	}{
+		{`.name, .age, .tags[1], .tags[-1], .tags[5], .foo`, []string{`"alice"`, `30`, `"y"`, `"z"`, `null`, `null`}},
+		{`.[]`, []string{`30`, `"alice"`, `["x","y","z"]`}},
+		{`keys, length, (.tags | keys, length), type, (.tags | type)`, []string{`["age","name","tags"]`, `3`, `[0,1,2]`, `3`, `"object"`, `"array"`}},
+		{`has("age"), has("foo"), (.tags | has(2), has(3))`, []string{`true`, `false`, `true`, `false`}},
+		{`to_entries[0], (.tags | map(ascii_upcase))`, []string{`{"key":"age","value":30}`, `["X","Y","Z"]`}},
+		{`.age + 1, tojson, (. == {name: "alice", age: 30, tags: ["x", "y", "z"]}), [.tags] < [[]]`, []string{`31`, `"{\"age\":30,\"name\":\"alice\",\"tags\":[\"x\",\"y\",\"z\"]}"`, `true`, `false`}},
+		{`[paths], (.name |= ascii_upcase | .name), del(.tags[0]).tags`, []string{`[["age"],["name"],["tags"],["tags",0],["tags",1],["tags",2]]`, `"ALICE"`, `["y","z"]`}},
+		{`.age[0]`, []string{`expected an array but got: number (30)`}},
+		{`.tags.foo`, []string{`expected an object but got: array (["x","y","z"])`}},
+	}
+	input := &testUser{"alice", 30, testTags{"x", "y", "z"}}
+	for _, tc := range testCases {
+		t.Run(tc.src, func(t *testing.T) {
+			query, err := gojq.Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			iter := query.Run(input)
+			var got []string
+			for {
+				v, ok := iter.Next()
+				if !ok {
+					break
+				}
+				if err, ok := v.(error); ok {
+					got = append(got, err.Error())
+					continue
+				}
+				bs, err := gojq.Marshal(v)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, string(bs))
+			}
+			if len(got) != len(tc.expected) {
+				t.Fatalf("expected %q but got %q", tc.expected, got)
+			}
+			for i := range got {
+				if got[i] != tc.expected[i] {
+					t.Errorf("expected %q but got %q", tc.expected[i], got[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,628 @@
+package gojq
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// jsonPath is the query of JSONPath (RFC 9535). The root query starts with $,
+// and the relative query in the filter selectors starts with @.
+type jsonPath struct {
+	relative bool
+	segments []*jsonPathSegment
+}
+
+type jsonPathSegment struct {
+	descendant bool
+	selectors  []*jsonPathSelector
+}
+
+type jsonPathSelectorType int
+
+const (
+	jsonPathSelectorName jsonPathSelectorType = iota
+	jsonPathSelectorWildcard
+	jsonPathSelectorIndex
+	jsonPathSelectorSlice
+	jsonPathSelectorFilter
+)
+
+type jsonPathSelector struct {
+	typ    jsonPathSelectorType
+	name   string
+	index  int
+	slice  [3]*int // start, end and step
+	filter *jsonPathExpr
+}
+
+// jsonPathExpr is the logical expression of the filter selector. The operand
+// is either the query or the literal.
+type jsonPathExpr struct {
+	op          string // ||, &&, !, comparison operators, or empty for the operands
+	left, right *jsonPathExpr
+	path        *jsonPath
+	literal     interface{}
+}
+
+type jsonPathNode struct {
+	path  []interface{}
+	value interface{}
+}
+
+type jsonPathParser struct {
+	s string
+	i int
+}
+
+func parseJSONPath(s string) (*jsonPath, bool) {
+	p := &jsonPathParser{s: s}
+	if !p.consume("$") {
+		return nil, false
+	}
+	q, ok := p.parseSegments(false)
+	if !ok || p.i < len(p.s) {
+		return nil, false
+	}
+	return q, true
+}
+
+func (p *jsonPathParser) consume(s string) bool {
+	if strings.HasPrefix(p.s[p.i:], s) {
+		p.i += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *jsonPathParser) peek(c byte) bool {
+	return p.i < len(p.s) && p.s[p.i] == c
+}
+
+func (p *jsonPathParser) skipSpaces() {
+	for p.i < len(p.s) && strings.IndexByte(" \t\n\r", p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+func (p *jsonPathParser) parseSegments(relative bool) (*jsonPath, bool) {
+	q := &jsonPath{relative: relative}
+	for {
+		i := p.i
+		p.skipSpaces()
+		seg := &jsonPathSegment{}
+		switch {
+		case p.consume(".."):
+			seg.descendant = true
+			if !p.peek('[') {
+				sel, ok := p.parseDotSelector()
+				if !ok {
+					return nil, false
+				}
+				seg.selectors = []*jsonPathSelector{sel}
+				break
+			}
+			fallthrough
+		case p.peek('['):
+			sels, ok := p.parseBracket()
+			if !ok {
+				return nil, false
+			}
+			seg.selectors = sels
+		case p.consume("."):
+			sel, ok := p.parseDotSelector()
+			if !ok {
+				return nil, false
+			}
+			seg.selectors = []*jsonPathSelector{sel}
+		default:
+			p.i = i
+			return q, true
+		}
+		q.segments = append(q.segments, seg)
+	}
+}
+
+func (p *jsonPathParser) parseDotSelector() (*jsonPathSelector, bool) {
+	if p.consume("*") {
+		return &jsonPathSelector{typ: jsonPathSelectorWildcard}, true
+	}
+	i := p.i
+	for ; p.i < len(p.s); p.i++ {
+		if c := p.s[p.i]; !(c >= utf8.RuneSelf || c == '_' ||
+			'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' ||
+			p.i > i && ('0' <= c && c <= '9' || c == '-')) {
+			break
+		}
+	}
+	if p.i == i {
+		return nil, false
+	}
+	return &jsonPathSelector{typ: jsonPathSelectorName, name: p.s[i:p.i]}, true
+}
+
+func (p *jsonPathParser) parseBracket() ([]*jsonPathSelector, bool) {
+	p.i++ // [
+	var sels []*jsonPathSelector
+	for {
+		p.skipSpaces()
+		sel, ok := p.parseSelector()
+		if !ok {
+			return nil, false
+		}
+		sels = append(sels, sel)
+		p.skipSpaces()
+		if p.consume("]") {
+			return sels, true
+		}
+		if !p.consume(",") {
+			return nil, false
+		}
+	}
+}
+
+func (p *jsonPathParser) parseSelector() (*jsonPathSelector, bool) {
+	switch {
+	case p.consume("*"):
+		return &jsonPathSelector{typ: jsonPathSelectorWildcard}, true
+	case p.consume("?"):
+		e, ok := p.parseOr()
+		if !ok {
+			return nil, false
+		}
+		return &jsonPathSelector{typ: jsonPathSelectorFilter, filter: e}, true
+	case p.peek('\'') || p.peek('"'):
+		s, ok := p.parseString()
+		if !ok {
+			return nil, false
+		}
+		return &jsonPathSelector{typ: jsonPathSelectorName, name: s}, true
+	}
+	var xs [3]*int
+	for j := 0; ; j++ {
+		p.skipSpaces()
+		if x, ok := p.parseInt(); ok {
+			xs[j] = &x
+		}
+		p.skipSpaces()
+		if j == 2 || !p.consume(":") {
+			if j > 0 {
+				return &jsonPathSelector{typ: jsonPathSelectorSlice, slice: xs}, true
+			}
+			if xs[0] == nil {
+				return nil, false
+			}
+			return &jsonPathSelector{typ: jsonPathSelectorIndex, index: *xs[0]}, true
+		}
+	}
+}
+
+func (p *jsonPathParser) parseInt() (int, bool) {
+	i := p.i
+	p.consume("-")
+	for p.i < len(p.s) && '0' <= p.s[p.i] && p.s[p.i] <= '9' {
+		p.i++
+	}
+	x, err := strconv.Atoi(p.s[i:p.i])
+	if err != nil {
+		p.i = i
+		return 0, false
+	}
+	return x, true
+}
+
+func (p *jsonPathParser) parseString() (string, bool) {
+	q := p.s[p.i]
+	var sb strings.Builder
+	for p.i++; p.i < len(p.s); p.i++ {
+		switch c := p.s[p.i]; c {
+		case q:
+			p.i++
+			return sb.String(), true
+		case '\\':
+			if p.i++; p.i >= len(p.s) {
+				return "", false
+			}
+			switch c = p.s[p.i]; c {
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case '\\', '/', '\'', '"':
+				sb.WriteByte(c)
+			case 'u':
+				r, ok := p.parseUnicodeEscape()
+				if !ok {
+					return "", false
+				}
+				sb.WriteRune(r)
+			default:
+				return "", false
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", false
+}
+
+func (p *jsonPathParser) parseUnicodeEscape() (rune, bool) {
+	if p.i+5 > len(p.s) {
+		return 0, false
+	}
+	x, err := strconv.ParseUint(p.s[p.i+1:p.i+5], 16, 16)
+	if err != nil {
+		return 0, false
+	}
+	p.i += 4
+	r := rune(x)
+	if utf16.IsSurrogate(r) && strings.HasPrefix(p.s[p.i+1:], "\\u") && p.i+11 <= len(p.s) {
+		if y, err := strconv.ParseUint(p.s[p.i+3:p.i+7], 16, 16); err == nil {
+			if s := utf16.DecodeRune(r, rune(y)); s != utf8.RuneError {
+				p.i += 6
+				return s, true
+			}
+		}
+	}
+	return r, true
+}
+
+func (p *jsonPathParser) parseOr() (*jsonPathExpr, bool) {
+	l, ok := p.parseAnd()
+	if !ok {
+		return nil, false
+	}
+	for {
+		p.skipSpaces()
+		if !p.consume("||") {
+			return l, true
+		}
+		r, ok := p.parseAnd()
+		if !ok {
+			return nil, false
+		}
+		l = &jsonPathExpr{op: "||", left: l, right: r}
+	}
+}
+
+func (p *jsonPathParser) parseAnd() (*jsonPathExpr, bool) {
+	l, ok := p.parseNot()
+	if !ok {
+		return nil, false
+	}
+	for {
+		p.skipSpaces()
+		if !p.consume("&&") {
+			return l, true
+		}
+		r, ok := p.parseNot()
+		if !ok {
+			return nil, false
+		}
+		l = &jsonPathExpr{op: "&&", left: l, right: r}
+	}
+}
+
+func (p *jsonPathParser) parseNot() (*jsonPathExpr, bool) {
+	p.skipSpaces()
+	if p.consume("!") {
+		e, ok := p.parseNot()
+		if !ok {
+			return nil, false
+		}
+		return &jsonPathExpr{op: "!", left: e}, true
+	}
+	return p.parseComparison()
+}
+
+func (p *jsonPathParser) parseComparison() (*jsonPathExpr, bool) {
+	if p.consume("(") {
+		e, ok := p.parseOr()
+		if p.skipSpaces(); !ok || !p.consume(")") {
+			return nil, false
+		}
+		return e, true
+	}
+	l, ok := p.parseOperand()
+	if !ok {
+		return nil, false
+	}
+	p.skipSpaces()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			p.skipSpaces()
+			r, ok := p.parseOperand()
+			if !ok {
+				return nil, false
+			}
+			return &jsonPathExpr{op: op, left: l, right: r}, true
+		}
+	}
+	if l.path == nil {
+		return nil, false
+	}
+	return l, true
+}
+
+func (p *jsonPathParser) parseOperand() (*jsonPathExpr, bool) {
+	switch {
+	case p.consume("@"), p.consume("$"):
+		q, ok := p.parseSegments(p.s[p.i-1] == '@')
+		if !ok {
+			return nil, false
+		}
+		return &jsonPathExpr{path: q}, true
+	case p.peek('\'') || p.peek('"'):
+		s, ok := p.parseString()
+		if !ok {
+			return nil, false
+		}
+		return &jsonPathExpr{literal: s}, true
+	case p.consume("true"):
+		return &jsonPathExpr{literal: true}, true
+	case p.consume("false"):
+		return &jsonPathExpr{literal: false}, true
+	case p.consume("null"):
+		return &jsonPathExpr{literal: nil}, true
+	}
+	i := p.i
+	p.consume("-")
+	for p.i < len(p.s) && strings.IndexByte("0123456789.eE+-", p.s[p.i]) >= 0 {
+		p.i++
+	}
+	if x, err := strconv.Atoi(p.s[i:p.i]); err == nil {
+		return &jsonPathExpr{literal: x}, true
+	}
+	x, err := strconv.ParseFloat(p.s[i:p.i], 64)
+	if err != nil {
+		return nil, false
+	}
+	return &jsonPathExpr{literal: x}, true
+}
+
+func (q *jsonPath) evaluate(root, current interface{}) []*jsonPathNode {
+	v := root
+	if q.relative {
+		v = current
+	}
+	nodes := []*jsonPathNode{{[]interface{}{}, v}}
+	for _, seg := range q.segments {
+		var xs []*jsonPathNode
+		for _, n := range nodes {
+			if seg.descendant {
+				n.descendants(func(n *jsonPathNode) {
+					for _, sel := range seg.selectors {
+						xs = sel.apply(root, n, xs)
+					}
+				})
+			} else {
+				for _, sel := range seg.selectors {
+					xs = sel.apply(root, n, xs)
+				}
+			}
+		}
+		nodes = xs
+	}
+	return nodes
+}
+
+func (n *jsonPathNode) child(key, value interface{}) *jsonPathNode {
+	path := make([]interface{}, len(n.path)+1)
+	copy(path, n.path)
+	path[len(n.path)] = key
+	return &jsonPathNode{path, value}
+}
+
+func (n *jsonPathNode) children() []*jsonPathNode {
+	switch v := n.value.(type) {
+	case []interface{}:
+		xs := make([]*jsonPathNode, len(v))
+		for i, x := range v {
+			xs[i] = n.child(i, x)
+		}
+		return xs
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		xs := make([]*jsonPathNode, len(keys))
+		for i, k := range keys {
+			xs[i] = n.child(k, v[k])
+		}
+		return xs
+	case *OrderedMap:
+		xs := make([]*jsonPathNode, len(v.keys))
+		for i, k := range v.keys {
+			xs[i] = n.child(k, v.values[k])
+		}
+		return xs
+	default:
+		return nil
+	}
+}
+
+// descendants calls the function with the node and its descendants in the
+// document order.
+func (n *jsonPathNode) descendants(f func(*jsonPathNode)) {
+	f(n)
+	for _, c := range n.children() {
+		c.descendants(f)
+	}
+}
+
+func (sel *jsonPathSelector) apply(root interface{}, n *jsonPathNode, xs []*jsonPathNode) []*jsonPathNode {
+	switch sel.typ {
+	case jsonPathSelectorName:
+		switch m := n.value.(type) {
+		case map[string]interface{}:
+			if v, ok := m[sel.name]; ok {
+				xs = append(xs, n.child(sel.name, v))
+			}
+		case *OrderedMap:
+			if v, ok := m.values[sel.name]; ok {
+				xs = append(xs, n.child(sel.name, v))
+			}
+		}
+	case jsonPathSelectorWildcard:
+		xs = append(xs, n.children()...)
+	case jsonPathSelectorIndex:
+		if a, ok := n.value.([]interface{}); ok {
+			i := sel.index
+			if i < 0 {
+				i += len(a)
+			}
+			if 0 <= i && i < len(a) {
+				xs = append(xs, n.child(i, a[i]))
+			}
+		}
+	case jsonPathSelectorSlice:
+		if a, ok := n.value.([]interface{}); ok {
+			for _, i := range sel.sliceIndices(len(a)) {
+				xs = append(xs, n.child(i, a[i]))
+			}
+		}
+	case jsonPathSelectorFilter:
+		for _, c := range n.children() {
+			if sel.filter.test(root, c.value) {
+				xs = append(xs, c)
+			}
+		}
+	}
+	return xs
+}
+
+// sliceIndices returns the indices of the array selected by the slice. The
+// negative indices count from the end, and the negative step reverses.
+func (sel *jsonPathSelector) sliceIndices(l int) []int {
+	step := 1
+	if sel.slice[2] != nil {
+		if step = *sel.slice[2]; step == 0 {
+			return nil
+		}
+	}
+	start, end := 0, l
+	if step < 0 {
+		start, end = l-1, -l-1
+	}
+	if sel.slice[0] != nil {
+		start = *sel.slice[0]
+	}
+	if sel.slice[1] != nil {
+		end = *sel.slice[1]
+	}
+	if start < 0 {
+		start += l
+	}
+	if end < 0 {
+		end += l
+	}
+	var xs []int
+	if step > 0 {
+		start, end = clampInt(start, 0, l), clampInt(end, 0, l)
+		for i := start; i < end; i += step {
+			xs = append(xs, i)
+		}
+	} else {
+		start, end = clampInt(start, -1, l-1), clampInt(end, -1, l-1)
+		for i := start; end < i; i += step {
+			xs = append(xs, i)
+		}
+	}
+	return xs
+}
+
+func clampInt(x, min, max int) int {
+	if x < min {
+		return min
+	}
+	if x > max {
+		return max
+	}
+	return x
+}
+
+func (e *jsonPathExpr) test(root, current interface{}) bool {
+	switch e.op {
+	case "||":
+		return e.left.test(root, current) || e.right.test(root, current)
+	case "&&":
+		return e.left.test(root, current) && e.right.test(root, current)
+	case "!":
+		return !e.left.test(root, current)
+	case "":
+		return len(e.path.evaluate(root, current)) > 0
+	}
+	l, lok := e.left.operand(root, current)
+	r, rok := e.right.operand(root, current)
+	switch e.op {
+	case "==":
+		return jsonPathEqual(l, lok, r, rok)
+	case "!=":
+		return !jsonPathEqual(l, lok, r, rok)
+	case "<":
+		return jsonPathLess(l, lok, r, rok)
+	case "<=":
+		return jsonPathLess(l, lok, r, rok) || jsonPathEqual(l, lok, r, rok)
+	case ">":
+		return jsonPathLess(r, rok, l, lok)
+	default:
+		return jsonPathLess(r, rok, l, lok) || jsonPathEqual(l, lok, r, rok)
+	}
+}
+
+// operand evaluates the operand of the comparison. The query results in the
+// value only when it selects exactly one node.
+func (e *jsonPathExpr) operand(root, current interface{}) (interface{}, bool) {
+	if e.path == nil {
+		return e.literal, true
+	}
+	nodes := e.path.evaluate(root, current)
+	if len(nodes) != 1 {
+		return nil, false
+	}
+	return nodes[0].value, true
+}
+
+func jsonPathEqual(l interface{}, lok bool, r interface{}, rok bool) bool {
+	if !lok || !rok {
+		return lok == rok
+	}
+	return compare(l, r) == 0
+}
+
+// jsonPathLess compares the numbers or the strings. The values of the other
+// types are not ordered.
+func jsonPathLess(l interface{}, lok bool, r interface{}, rok bool) bool {
+	if !lok || !rok {
+		return false
+	}
+	t := getTypeOrdNum(l)
+	return (t == 3 || t == 5) && t == getTypeOrdNum(r) && compare(l, r) < 0
+}
+
+func funcJSONPath(v, x interface{}) interface{} {
+	s, ok := x.(string)
+	if !ok {
+		return &funcTypeError{"jsonpath", x}
+	}
+	q, ok := parseJSONPath(s)
+	if !ok {
+		return &jsonPathError{s}
+	}
+	nodes := q.evaluate(v, v)
+	xs := make([]interface{}, len(nodes))
+	for i, n := range nodes {
+		xs[i] = []interface{}{n.path, n.value}
+	}
+	return xs
+}
@@ -0,0 +1,139 @@
+package gojq
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// jwtAlgorithms are the signature algorithms supported by jwt_verify.
+var jwtAlgorithms = map[string]crypto.Hash{
+	"HS256": crypto.SHA256, "HS384": crypto.SHA384, "HS512": crypto.SHA512,
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+// parseJWT splits the token in the compact serialization, and decodes the
+// header and the payload. The signature is kept in base64url.
+func parseJWT(name string, v interface{}) ([]string, map[string]interface{}, interface{}, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, nil, nil, &funcTypeError{name, v}
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return nil, nil, nil, &jwtError{"invalid token: expected 3 parts but got " + strconv.Itoa(len(parts))}
+	}
+	var header map[string]interface{}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, nil, nil, &jwtError{"invalid token header: " + err.Error()}
+	}
+	var payload interface{}
+	if err := decodeJWTPart(parts[1], &payload); err != nil {
+		return nil, nil, nil, &jwtError{"invalid token payload: " + err.Error()}
+	}
+	return parts, header, payload, nil
+}
+
+func decodeJWTPart(s string, v interface{}) error {
+	bs, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, v)
+}
+
+func funcJWTDecode(v interface{}) interface{} {
+	parts, header, payload, err := parseJWT("jwt_decode", v)
+	if err != nil {
+		return err
+	}
+	return map[string]interface{}{
+		"header":    header,
+		"payload":   payload,
+		"signature": parts[2],
+	}
+}
+
+// funcJWTVerify verifies the signature of the token with the key; a secret for
+// the HMAC algorithms, and a public key or a certificate in PEM for the others.
+// The algorithm of the token header must match the specified one.
+func funcJWTVerify(v, x, y interface{}) interface{} {
+	alg, ok := y.(string)
+	if !ok {
+		return &funcTypeError{"jwt_verify", y}
+	}
+	hash, ok := jwtAlgorithms[alg]
+	if !ok {
+		return &jwtError{"unknown algorithm: " + alg}
+	}
+	key, ok := toBytes(x)
+	if !ok {
+		return &funcTypeError{"jwt_verify", x}
+	}
+	parts, header, _, err := parseJWT("jwt_verify", v)
+	if err != nil {
+		return err
+	}
+	if header["alg"] != alg {
+		return false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[2], "="))
+	if err != nil {
+		return false
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	if alg[0] == 'H' {
+		h := hmac.New(hash.New, key)
+		h.Write(signed)
+		return hmac.Equal(h.Sum(nil), sig)
+	}
+	pub, err := parseJWTPublicKey(key)
+	if err != nil {
+		return &jwtError{"invalid key: " + err.Error()}
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return alg[0] == 'R' && rsa.VerifyPKCS1v15(pub, hash, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if alg[0] != 'E' || len(sig) != 2*size {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(pub, digest, r, s)
+	default:
+		return &jwtError{"invalid key: unsupported public key type"}
+	}
+}
+
+func parseJWTPublicKey(key []byte) (interface{}, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	}
+}
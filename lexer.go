@@ -46,13 +46,18 @@ var keywords = map[string]int{
 	"foreach": tokForeach,
 }
 
-func (l *lexer) Lex(lval *yySymType) (tokenType int) {
-	defer func() { l.tokenType = tokenType }()
+func (l *lexer) Lex(lval *yySymType) int {
+	l.tokenType = l.lex(lval)
+	return l.tokenType
+}
+
+func (l *lexer) lex(lval *yySymType) int {
 	if len(l.source) == l.offset {
 		l.token = ""
 		return eof
 	}
 	if l.inString {
+		lval.offset = l.offset
 		tok, str := l.scanString(l.offset)
 		lval.token = str
 		return tok
@@ -62,6 +67,7 @@ func (l *lexer) Lex(lval *yySymType) (tokenType int) {
 		l.token = ""
 		return eof
 	}
+	lval.offset = l.offset - 1
 	switch {
 	case isIdent(ch, false):
 		i := l.offset - 1
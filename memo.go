@@ -0,0 +1,182 @@
+package gojq
+
+import (
+	"container/list"
+	"encoding/json"
+	"math"
+	"sync"
+)
+
+const (
+	// memoCacheSize is the maximum number of the inputs cached for each function.
+	memoCacheSize = 1024
+	// memoMaxValues is the maximum number of the values of a call to cache.
+	memoMaxValues = 1024
+	// memoMaxSteps is the maximum number of the steps of a call to cache.
+	memoMaxSteps = 1 << 20
+	// memoProbeCalls is the number of the calls to check the cache hit ratio.
+	memoProbeCalls = 256
+)
+
+// memoCache caches the results of a pure function without arguments for each
+// input, and evicts the least recently used entry when it is full. The cache
+// gets disabled when the hit ratio is low, or the function emits too many
+// values (it may be an infinite generator), to fall back to the usual calls.
+// The cache is shared by the environments running the same code concurrently.
+type memoCache struct {
+	mu       sync.Mutex
+	entries  map[interface{}]*list.Element
+	order    *list.List
+	calls    int
+	hits     int
+	disabled bool
+}
+
+type memoEntry struct {
+	key    interface{}
+	values []interface{}
+}
+
+func newMemoCache() *memoCache {
+	return &memoCache{entries: make(map[interface{}]*list.Element), order: list.New()}
+}
+
+// lookup returns the cached values for the key, or nil on cache miss. This
+// method reports false when the cache is disabled.
+func (c *memoCache) lookup(key interface{}) ([]interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.disabled {
+		return nil, false
+	}
+	c.calls++
+	if e, ok := c.entries[key]; ok {
+		c.hits++
+		c.order.MoveToFront(e)
+		return e.Value.(*memoEntry).values, true
+	}
+	if c.calls >= memoProbeCalls && c.hits*4 < c.calls {
+		c.disabled = true
+		return nil, false
+	}
+	return nil, true
+}
+
+func (c *memoCache) store(key interface{}, values []interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*memoEntry).values = values
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&memoEntry{key, values})
+	if c.order.Len() > memoCacheSize {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.entries, e.Value.(*memoEntry).key)
+	}
+}
+
+func (c *memoCache) disable() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.disabled = true
+}
+
+// memoKey returns the key of the cache for the input. Only the scalar values
+// are cached, not to spend time on hashing large values.
+func memoKey(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case nil, bool, int, string, json.Number:
+		return v, true
+	case float64:
+		return v, !math.IsNaN(v)
+	default:
+		return nil, false
+	}
+}
+
+// callMemo calls the pure function at pc with the input v, and returns the
+// values it emits (or a LimitError to terminate the execution). When it is not
+// cacheable, or the function raises an error, this method reports false so
+// that the function is called as usual.
+func (env *env) callMemo(pc int, v interface{}) (interface{}, bool) {
+	c := env.memo[pc]
+	if c == nil {
+		return nil, false
+	}
+	key, ok := memoKey(v)
+	if !ok {
+		return nil, false
+	}
+	if vs, ok := c.lookup(key); !ok {
+		return nil, false
+	} else if vs != nil {
+		return vs, true
+	}
+	sub := newMemoEnv(env, pc, v)
+	defer func() { env.steps = sub.steps }()
+	vs := []interface{}{}
+	for {
+		w, ok := sub.Next()
+		if !ok {
+			break
+		}
+		if err, ok := w.(error); ok {
+			if _, ok := err.(*stepLimitError); ok &&
+				(env.limits.steps == 0 || sub.steps <= env.limits.steps) {
+				c.disable()
+				return nil, false
+			}
+			if _, ok := err.(LimitError); ok || env.ctx.Err() != nil {
+				return err, true
+			}
+			return nil, false
+		}
+		if vs = append(vs, w); len(vs) > memoMaxValues {
+			c.disable()
+			return nil, false
+		}
+	}
+	c.store(key, vs)
+	return vs, true
+}
+
+// newMemoEnv creates an environment to call the function at pc with the input
+// v. The scope stack is empty since the pure function does not refer to the
+// variables in the outer scopes.
+func newMemoEnv(parent *env, pc int, v interface{}) *env {
+	sub := &env{
+		pc:        pc,
+		stack:     newStack(),
+		scopes:    newStack(),
+		paths:     newStack(),
+		codes:     parent.codes,
+		codeinfos: parent.codeinfos,
+		spans:     parent.spans,
+		ctx:       parent.ctx,
+		ordered:   parent.ordered,
+		limits:    parent.limits,
+		steps:     parent.steps,
+		callindex: -1,
+		parallel:  parent.parallel,
+		effects:   parent.effects,
+		memo:      parent.memo,
+	}
+	if sub.limits.steps == 0 || sub.limits.steps > sub.steps+memoMaxSteps {
+		sub.limits.steps = sub.steps + memoMaxSteps
+	}
+	sub.push(v)
+	return sub
+}
+
+// emitValues emits the values in order, by forking at pc for the rest.
+func (env *env) emitValues(op opcode, pc int, vs []interface{}) {
+	if len(vs) > 1 {
+		env.push(vs[1:])
+		env.pushfork(op, pc)
+		env.pop()
+	}
+	env.push(vs[0])
+}
@@ -0,0 +1,95 @@
+package gojq
+
+// funcMergePatchApply applies the JSON Merge Patch (RFC 7386) to the value.
+// The null values in the patch remove the keys, and the values other than
+// objects (including arrays) replace the target values.
+func funcMergePatchApply(v, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	m, _ := v.(map[string]interface{})
+	w := make(map[string]interface{}, len(m)+len(p))
+	for k, v := range m {
+		w[k] = v
+	}
+	for k, x := range p {
+		if x == nil {
+			delete(w, k)
+		} else {
+			w[k] = funcMergePatchApply(w[k], x)
+		}
+	}
+	return w
+}
+
+func funcMergePatchApplyOrdered(v, patch interface{}) interface{} {
+	p, ok := toOrderedMap(patch)
+	if !ok {
+		return patch
+	}
+	w, ok := toOrderedMap(v)
+	if ok {
+		w = w.clone()
+	} else {
+		w = NewOrderedMap()
+	}
+	for _, k := range p.keys {
+		if x := p.values[k]; x == nil {
+			w.Delete(k)
+		} else {
+			w.Set(k, funcMergePatchApplyOrdered(w.values[k], x))
+		}
+	}
+	return w
+}
+
+// funcMergePatchDiff creates the JSON Merge Patch which transforms the value
+// to the other. Note that the null values in the objects cannot be represented
+// in the merge patch, since they are treated as the removals.
+func funcMergePatchDiff(v, w interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return w
+	}
+	n, ok := w.(map[string]interface{})
+	if !ok {
+		return w
+	}
+	d := make(map[string]interface{})
+	for k := range m {
+		if _, ok := n[k]; !ok {
+			d[k] = nil
+		}
+	}
+	for k, y := range n {
+		if x, ok := m[k]; !ok || compare(x, y) != 0 {
+			d[k] = funcMergePatchDiff(x, y)
+		}
+	}
+	return d
+}
+
+func funcMergePatchDiffOrdered(v, w interface{}) interface{} {
+	m, ok := toOrderedMap(v)
+	if !ok {
+		return w
+	}
+	n, ok := toOrderedMap(w)
+	if !ok {
+		return w
+	}
+	d := NewOrderedMap()
+	for _, k := range m.keys {
+		if _, ok := n.values[k]; !ok {
+			d.Set(k, nil)
+		}
+	}
+	for _, k := range n.keys {
+		y := n.values[k]
+		if x, ok := m.values[k]; !ok || compare(x, y) != 0 {
+			d.Set(k, funcMergePatchDiffOrdered(x, y))
+		}
+	}
+	return d
+}
@@ -0,0 +1,216 @@
+package gojq
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+)
+
+// mimeSignatures are the magic bytes of the file formats at the offsets. The
+// bytes are masked before comparing when the mask is given, so that the zero
+// bytes of the mask match any bytes.
+var mimeSignatures = []struct {
+	offset int
+	magic  string
+	mask   string
+	typ    string
+}{
+	{0, "\x89PNG\r\n\x1a\n", "", "image/png"},
+	{0, "\xff\xd8\xff", "", "image/jpeg"},
+	{0, "GIF87a", "", "image/gif"},
+	{0, "GIF89a", "", "image/gif"},
+	{0, "RIFF\x00\x00\x00\x00WEBP", "\xff\xff\xff\xff\x00\x00\x00\x00\xff\xff\xff\xff", "image/webp"},
+	{0, "RIFF\x00\x00\x00\x00WAVE", "\xff\xff\xff\xff\x00\x00\x00\x00\xff\xff\xff\xff", "audio/wav"},
+	{0, "RIFF\x00\x00\x00\x00AVI ", "\xff\xff\xff\xff\x00\x00\x00\x00\xff\xff\xff\xff", "video/x-msvideo"},
+	{0, "BM\x00\x00\x00\x00\x00\x00\x00\x00", "\xff\xff\x00\x00\x00\x00\xff\xff\xff\xff", "image/bmp"},
+	{0, "\x00\x00\x01\x00", "", "image/x-icon"},
+	{0, "II*\x00", "", "image/tiff"},
+	{0, "MM\x00*", "", "image/tiff"},
+	{4, "ftypavif", "", "image/avif"},
+	{4, "ftypheic", "", "image/heic"},
+	{4, "ftypqt  ", "", "video/quicktime"},
+	{4, "ftyp", "", "video/mp4"},
+	{0, "\x1aE\xdf\xa3", "", "video/webm"},
+	{0, "OggS", "", "audio/ogg"},
+	{0, "fLaC", "", "audio/flac"},
+	{0, "ID3", "", "audio/mpeg"},
+	{0, "\xff\xfb", "", "audio/mpeg"},
+	{0, "%PDF-", "", "application/pdf"},
+	{0, "PK\x03\x04", "", "application/zip"},
+	{0, "\x1f\x8b", "", "application/gzip"},
+	{0, "BZh", "", "application/x-bzip2"},
+	{0, "\xfd7zXZ\x00", "", "application/x-xz"},
+	{0, "(\xb5/\xfd", "", "application/zstd"},
+	{0, "7z\xbc\xaf\x27\x1c", "", "application/x-7z-compressed"},
+	{0, "Rar!\x1a\x07", "", "application/vnd.rar"},
+	{257, "ustar", "", "application/x-tar"},
+	{0, "\x00asm", "", "application/wasm"},
+	{0, "\x7fELF", "", "application/x-elf"},
+	{0, "MZ", "", "application/vnd.microsoft.portable-executable"},
+	{0, "wOFF", "", "font/woff"},
+	{0, "wOF2", "", "font/woff2"},
+	{0, "\x00\x01\x00\x00\x00", "", "font/ttf"},
+	{0, "OTTO", "", "font/otf"},
+	{0, "SQLite format 3\x00", "", "application/vnd.sqlite3"},
+}
+
+// sniffMimeType detects the media type of the data by the magic bytes, and
+// falls back to the text types for the valid UTF-8 contents.
+func sniffMimeType(bs []byte) string {
+	for _, s := range mimeSignatures {
+		if len(bs) < s.offset+len(s.magic) {
+			continue
+		}
+		matched := true
+		for i := 0; i < len(s.magic); i++ {
+			b := bs[s.offset+i]
+			if s.mask != "" {
+				b &= s.mask[i]
+			}
+			if b != s.magic[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return s.typ
+		}
+	}
+	bs = bytes.TrimPrefix(bs, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(bs) || bytes.IndexFunc(bs, isBinaryRune) >= 0 {
+		return "application/octet-stream"
+	}
+	t := bytes.TrimLeft(bs, " \t\r\n")
+	switch {
+	case len(t) == 0:
+		return "text/plain"
+	case hasPrefixFold(t, "<!doctype html") || hasPrefixFold(t, "<html"):
+		return "text/html"
+	case bytes.HasPrefix(t, []byte("<svg")):
+		return "image/svg+xml"
+	case bytes.HasPrefix(t, []byte("<?xml")):
+		if bytes.Contains(t, []byte("<svg")) {
+			return "image/svg+xml"
+		}
+		return "application/xml"
+	case (t[0] == '{' || t[0] == '[') && json.Valid(t):
+		return "application/json"
+	default:
+		return "text/plain"
+	}
+}
+
+// isBinaryRune reports whether the rune is a control character which does not
+// appear in the texts; the whitespaces and the escape character are allowed.
+func isBinaryRune(r rune) bool {
+	return r < 0x20 && (r < '\t' || '\r' < r) && r != 0x1b
+}
+
+func hasPrefixFold(bs []byte, prefix string) bool {
+	return len(bs) >= len(prefix) && strings.EqualFold(string(bs[:len(prefix)]), prefix)
+}
+
+func funcMimeType(v interface{}) interface{} {
+	var bs []byte
+	switch v := v.(type) {
+	case []byte:
+		bs = v
+	case string:
+		var err error
+		if bs, err = base64.StdEncoding.DecodeString(v); err != nil {
+			if bs, err = base64.RawStdEncoding.DecodeString(v); err != nil {
+				return &funcTypeError{"mimetype", v}
+			}
+		}
+	default:
+		return &funcTypeError{"mimetype", v}
+	}
+	return sniffMimeType(bs)
+}
+
+// mimeTypesByExt are the media types of the file extensions, which are defined
+// here not to depend on the mime type files of the system.
+var mimeTypesByExt = map[string]string{
+	".7z":    "application/x-7z-compressed",
+	".avi":   "video/x-msvideo",
+	".avif":  "image/avif",
+	".bmp":   "image/bmp",
+	".bz2":   "application/x-bzip2",
+	".css":   "text/css",
+	".csv":   "text/csv",
+	".doc":   "application/msword",
+	".docx":  "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".flac":  "audio/flac",
+	".gif":   "image/gif",
+	".gz":    "application/gzip",
+	".heic":  "image/heic",
+	".htm":   "text/html",
+	".html":  "text/html",
+	".ico":   "image/x-icon",
+	".ics":   "text/calendar",
+	".jpeg":  "image/jpeg",
+	".jpg":   "image/jpeg",
+	".js":    "text/javascript",
+	".json":  "application/json",
+	".jsonl": "application/jsonl",
+	".md":    "text/markdown",
+	".mjs":   "text/javascript",
+	".mov":   "video/quicktime",
+	".mp3":   "audio/mpeg",
+	".mp4":   "video/mp4",
+	".mpeg":  "video/mpeg",
+	".oga":   "audio/ogg",
+	".ogg":   "audio/ogg",
+	".ogv":   "video/ogg",
+	".otf":   "font/otf",
+	".pdf":   "application/pdf",
+	".png":   "image/png",
+	".ppt":   "application/vnd.ms-powerpoint",
+	".pptx":  "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".rar":   "application/vnd.rar",
+	".svg":   "image/svg+xml",
+	".tar":   "application/x-tar",
+	".tif":   "image/tiff",
+	".tiff":  "image/tiff",
+	".toml":  "application/toml",
+	".ttf":   "font/ttf",
+	".tsv":   "text/tab-separated-values",
+	".txt":   "text/plain",
+	".wasm":  "application/wasm",
+	".wav":   "audio/wav",
+	".webm":  "video/webm",
+	".webp":  "image/webp",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+	".xls":   "application/vnd.ms-excel",
+	".xlsx":  "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".xml":   "application/xml",
+	".xz":    "application/x-xz",
+	".yaml":  "application/yaml",
+	".yml":   "application/yaml",
+	".zip":   "application/zip",
+	".zst":   "application/zstd",
+}
+
+// funcExtToMime looks up the media type of the extension (with or without the
+// leading dot) or the file name. This function results in null when unknown.
+func funcExtToMime(v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return &funcTypeError{"ext_to_mime", v}
+	}
+	ext := strings.ToLower(s)
+	if !strings.HasPrefix(ext, ".") {
+		if e := funcExtname(ext).(string); e != "" {
+			ext = e
+		} else {
+			ext = "." + ext
+		}
+	}
+	if typ, ok := mimeTypesByExt[ext]; ok {
+		return typ
+	}
+	return nil
+}
@@ -1,30 +1,38 @@
 package gojq
 
 import (
+	"bytes"
 	"encoding/json"
-	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 )
 
 type moduleLoader struct {
+	fsys  moduleFS
 	paths []string
 }
 
+// moduleFS is the file system of the modules (ref: NewModuleLoaderFS).
+type moduleFS interface {
+	Stat(name string) (os.FileInfo, error)
+	ReadFile(name string) ([]byte, error)
+}
+
 // NewModuleLoader creates a new ModuleLoader reading local modules in the paths.
 func NewModuleLoader(paths []string) ModuleLoader {
-	return &moduleLoader{paths}
+	return &moduleLoader{nil, paths}
 }
 
 func (l *moduleLoader) LoadInitModules() ([]*Query, error) {
 	var qs []*Query
 	for _, path := range l.paths {
-		if filepath.Base(path) != ".jq" {
+		if l.base(path) != ".jq" {
 			continue
 		}
-		fi, err := os.Stat(path)
+		fi, err := l.stat(path)
 		if err != nil {
 			if os.IsNotExist(err) {
 				continue
@@ -34,7 +42,7 @@ func (l *moduleLoader) LoadInitModules() ([]*Query, error) {
 		if fi.IsDir() {
 			continue
 		}
-		cnt, err := ioutil.ReadFile(path)
+		cnt, err := l.readFile(path)
 		if err != nil {
 			return nil, err
 		}
@@ -52,7 +60,7 @@ func (l *moduleLoader) LoadModuleWithMeta(name string, meta map[string]interface
 	if err != nil {
 		return nil, err
 	}
-	cnt, err := ioutil.ReadFile(path)
+	cnt, err := l.readFile(path)
 	if err != nil {
 		return nil, err
 	}
@@ -68,13 +76,12 @@ func (l *moduleLoader) LoadJSONWithMeta(name string, meta map[string]interface{}
 	if err != nil {
 		return nil, err
 	}
-	f, err := os.Open(path)
+	cnt, err := l.readFile(path)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 	var vals []interface{}
-	dec := json.NewDecoder(f)
+	dec := json.NewDecoder(bytes.NewReader(cnt))
 	dec.UseNumber()
 	for {
 		var val interface{}
@@ -82,13 +89,6 @@ func (l *moduleLoader) LoadJSONWithMeta(name string, meta map[string]interface{}
 			if err == io.EOF {
 				break
 			}
-			if _, err := f.Seek(0, io.SeekStart); err != nil {
-				return nil, err
-			}
-			cnt, er := ioutil.ReadAll(f)
-			if er != nil {
-				return nil, er
-			}
 			return nil, &jsonParseError{path, string(cnt), err}
 		}
 		vals = append(vals, val)
@@ -98,20 +98,48 @@ func (l *moduleLoader) LoadJSONWithMeta(name string, meta map[string]interface{}
 
 func (l *moduleLoader) lookupModule(name, extension string, meta map[string]interface{}) (string, error) {
 	paths := l.paths
-	if path := searchPath(meta); path != "" {
+	if path := l.searchPath(meta); path != "" {
 		paths = append([]string{path}, paths...)
 	}
 	for _, base := range paths {
-		path := filepath.Clean(filepath.Join(base, name+extension))
-		if _, err := os.Stat(path); err == nil {
+		path := l.join(base, name+extension)
+		if _, err := l.stat(path); err == nil {
 			return path, err
 		}
-		path = filepath.Clean(filepath.Join(base, name, filepath.Base(name)+extension))
-		if _, err := os.Stat(path); err == nil {
+		path = l.join(base, name, l.base(name)+extension)
+		if _, err := l.stat(path); err == nil {
 			return path, err
 		}
 	}
-	return "", fmt.Errorf("module not found: %q", name)
+	return "", &moduleNotFoundError{name}
+}
+
+func (l *moduleLoader) stat(name string) (os.FileInfo, error) {
+	if l.fsys != nil {
+		return l.fsys.Stat(name)
+	}
+	return os.Stat(name)
+}
+
+func (l *moduleLoader) readFile(name string) ([]byte, error) {
+	if l.fsys != nil {
+		return l.fsys.ReadFile(name)
+	}
+	return ioutil.ReadFile(name)
+}
+
+func (l *moduleLoader) join(elem ...string) string {
+	if l.fsys != nil {
+		return path.Join(elem...)
+	}
+	return filepath.Clean(filepath.Join(elem...))
+}
+
+func (l *moduleLoader) base(name string) string {
+	if l.fsys != nil {
+		return path.Base(name)
+	}
+	return filepath.Base(name)
 }
 
 // This is a dirty hack to implement the "search" field.
@@ -135,12 +163,12 @@ func parseModule(path, cnt string) (*Query, error) {
 	return q, nil
 }
 
-func searchPath(meta map[string]interface{}) string {
+func (l *moduleLoader) searchPath(meta map[string]interface{}) string {
 	x, ok := meta["$$path"]
 	if !ok {
 		return ""
 	}
-	path, ok := x.(string)
+	p, ok := x.(string)
 	if !ok {
 		return ""
 	}
@@ -152,5 +180,8 @@ func searchPath(meta map[string]interface{}) string {
 	if !ok {
 		return ""
 	}
-	return filepath.Join(filepath.Dir(path), s)
+	if l.fsys != nil {
+		return path.Join(path.Dir(p), s)
+	}
+	return filepath.Join(filepath.Dir(p), s)
 }
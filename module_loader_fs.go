@@ -0,0 +1,25 @@
+//go:build go1.16
+// +build go1.16
+
+package gojq
+
+import "io/fs"
+
+// NewModuleLoaderFS creates a new ModuleLoader reading modules in the paths of
+// the file system. The paths are slash-separated and unrooted, like "." and
+// "lib/jq". Use this loader with embed.FS to ship modules with the program.
+func NewModuleLoaderFS(fsys fs.FS, paths []string) ModuleLoader {
+	return &moduleLoader{moduleFileSystem{fsys}, paths}
+}
+
+type moduleFileSystem struct {
+	fsys fs.FS
+}
+
+func (m moduleFileSystem) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(m.fsys, name)
+}
+
+func (m moduleFileSystem) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(m.fsys, name)
+}
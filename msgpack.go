@@ -0,0 +1,396 @@
+package gojq
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+func funcFromMsgpack(v interface{}) interface{} {
+	return fromMsgpack(v, false)
+}
+
+func funcFromMsgpackOrdered(v interface{}) interface{} {
+	return fromMsgpack(v, true)
+}
+
+func fromMsgpack(v interface{}, ordered bool) interface{} {
+	var bs []byte
+	switch v := v.(type) {
+	case []byte:
+		bs = v
+	case string:
+		var err error
+		if bs, err = base64.StdEncoding.DecodeString(v); err != nil {
+			if bs, err = base64.RawStdEncoding.DecodeString(v); err != nil {
+				return &funcTypeError{"frommsgpack", v}
+			}
+		}
+	default:
+		return &funcTypeError{"frommsgpack", v}
+	}
+	d := &msgpackDecoder{bs, 0, ordered}
+	w, err := d.decode(0)
+	if err != nil {
+		return &msgpackError{d.offset, err}
+	}
+	if d.offset != len(d.bs) {
+		return &msgpackError{d.offset, errors.New("trailing bytes")}
+	}
+	return w
+}
+
+// msgpackDecoder decodes the MessagePack format. The binaries are decoded to
+// byte strings, and the timestamps to the seconds since the Unix epoch. The
+// maps are decoded to *OrderedMap when ordered is true.
+type msgpackDecoder struct {
+	bs      []byte
+	offset  int
+	ordered bool
+}
+
+var errMsgpackEOF = errors.New("unexpected end of data")
+
+const msgpackMaxDepth = 10000
+
+func (d *msgpackDecoder) read(n int) ([]byte, error) {
+	if n < 0 || len(d.bs)-d.offset < n {
+		return nil, errMsgpackEOF
+	}
+	bs := d.bs[d.offset : d.offset+n]
+	d.offset += n
+	return bs, nil
+}
+
+func (d *msgpackDecoder) readUint(n int) (uint64, error) {
+	bs, err := d.read(n)
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 1:
+		return uint64(bs[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(bs)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(bs)), nil
+	default:
+		return binary.BigEndian.Uint64(bs), nil
+	}
+}
+
+func (d *msgpackDecoder) decode(depth int) (interface{}, error) {
+	if depth > msgpackMaxDepth {
+		return nil, errors.New("nesting too deep")
+	}
+	bs, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	switch c := bs[0]; {
+	case c <= 0x7f:
+		return int(c), nil
+	case c >= 0xe0:
+		return int(int8(c)), nil
+	case c <= 0x8f:
+		return d.decodeMap(int(c&0x0f), depth)
+	case c <= 0x9f:
+		return d.decodeArray(int(c&0x0f), depth)
+	case c <= 0xbf:
+		return d.decodeString(int(c & 0x1f))
+	}
+	switch c := bs[0]; c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.readUint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		bs, err := d.read(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, bs...), nil
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.readUint(1 << (c - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeExt(int(n))
+	case 0xca:
+		n, err := d.readUint(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(uint32(n))), nil
+	case 0xcb:
+		n, err := d.readUint(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(n), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := d.readUint(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		return normalizeNumbers(new(big.Int).SetUint64(n)), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		n, err := d.readUint(size)
+		if err != nil {
+			return nil, err
+		}
+		// sign extension of the integer in the size of bytes
+		shift := 64 - 8*size
+		return normalizeNumbers(int64(n<<shift) >> shift), nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.decodeExt(1 << (c - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.readUint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeString(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.readUint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(int(n), depth)
+	case 0xde, 0xdf:
+		n, err := d.readUint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(int(n), depth)
+	default:
+		return nil, errors.New("invalid type byte 0x" + strconv.FormatUint(uint64(c), 16))
+	}
+}
+
+func (d *msgpackDecoder) decodeString(n int) (interface{}, error) {
+	bs, err := d.read(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(bs), nil
+}
+
+func (d *msgpackDecoder) decodeArray(n int, depth int) (interface{}, error) {
+	if n > len(d.bs)-d.offset {
+		return nil, errMsgpackEOF
+	}
+	vs := make([]interface{}, n)
+	for i := range vs {
+		var err error
+		if vs[i], err = d.decode(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+	return vs, nil
+}
+
+func (d *msgpackDecoder) decodeMap(n int, depth int) (interface{}, error) {
+	if n > (len(d.bs)-d.offset)/2 {
+		return nil, errMsgpackEOF
+	}
+	m := make(map[string]interface{}, n)
+	var keys []string
+	if d.ordered {
+		keys = make([]string, 0, n)
+	}
+	for i := 0; i < n; i++ {
+		k, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			// the keys of other types are converted in the same way as tostring
+			key = funcToString(k).(string)
+		}
+		if m[key], err = d.decode(depth + 1); err != nil {
+			return nil, err
+		}
+		if keys != nil {
+			keys = append(keys, key)
+		}
+	}
+	if keys != nil {
+		return newOrderedMapWithKeys(keys, m), nil
+	}
+	return m, nil
+}
+
+// decodeExt decodes the extension types. Only the timestamp type is supported.
+func (d *msgpackDecoder) decodeExt(n int) (interface{}, error) {
+	bs, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	typ := int8(bs[0])
+	if bs, err = d.read(n); err != nil {
+		return nil, err
+	}
+	if typ != -1 {
+		return nil, errors.New("unsupported extension type " + strconv.Itoa(int(typ)))
+	}
+	var sec int64
+	var nsec uint32
+	switch n {
+	case 4:
+		sec = int64(binary.BigEndian.Uint32(bs))
+	case 8:
+		x := binary.BigEndian.Uint64(bs)
+		sec, nsec = int64(x&(1<<34-1)), uint32(x>>34)
+	case 12:
+		sec, nsec = int64(binary.BigEndian.Uint64(bs[4:])), binary.BigEndian.Uint32(bs)
+	default:
+		return nil, errors.New("invalid timestamp length " + strconv.Itoa(n))
+	}
+	if nsec == 0 {
+		return normalizeNumbers(sec), nil
+	}
+	return float64(sec) + float64(nsec)/1e9, nil
+}
+
+func funcToMsgpack(v interface{}) interface{} {
+	bs, err := appendMsgpack(nil, v)
+	if err != nil {
+		return err
+	}
+	return bs
+}
+
+// appendMsgpack encodes the value in the MessagePack format. The integral
+// numbers are encoded as integers in the smallest format, byte strings as
+// binaries, and the keys of the objects are sorted unless the key order is
+// preserved.
+func appendMsgpack(bs []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(bs, 0xc0), nil
+	case bool:
+		if v {
+			return append(bs, 0xc3), nil
+		}
+		return append(bs, 0xc2), nil
+	case int:
+		return appendMsgpackInt(bs, int64(v)), nil
+	case float64:
+		if math.Trunc(v) == v && -1<<63 <= v && v < 1<<63 {
+			return appendMsgpackInt(bs, int64(v)), nil
+		}
+		return appendMsgpackUint(append(bs, 0xcb), math.Float64bits(v), 8), nil
+	case *big.Int:
+		if v.IsInt64() {
+			return appendMsgpackInt(bs, v.Int64()), nil
+		}
+		if v.IsUint64() {
+			return appendMsgpackUint(append(bs, 0xcf), v.Uint64(), 8), nil
+		}
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return appendMsgpackUint(append(bs, 0xcb), math.Float64bits(f), 8), nil
+	case string:
+		bs = appendMsgpackLength(bs, len(v), 0xa0, 31, 0xd9, 0xda)
+		return append(bs, v...), nil
+	case []byte:
+		bs = appendMsgpackLength(bs, len(v), 0, -1, 0xc4, 0xc5)
+		return append(bs, v...), nil
+	case []interface{}:
+		bs = appendMsgpackLength(bs, len(v), 0x90, 15, 0, 0xdc)
+		var err error
+		for _, x := range v {
+			if bs, err = appendMsgpack(bs, x); err != nil {
+				return nil, err
+			}
+		}
+		return bs, nil
+	case map[string]interface{}:
+		bs = appendMsgpackLength(bs, len(v), 0x80, 15, 0, 0xde)
+		var err error
+		for _, k := range sortedKeys(v) {
+			bs, _ = appendMsgpack(bs, k)
+			if bs, err = appendMsgpack(bs, v[k]); err != nil {
+				return nil, err
+			}
+		}
+		return bs, nil
+	case *OrderedMap:
+		bs = appendMsgpackLength(bs, v.Len(), 0x80, 15, 0, 0xde)
+		var err error
+		for _, k := range v.keys {
+			bs, _ = appendMsgpack(bs, k)
+			if bs, err = appendMsgpack(bs, v.values[k]); err != nil {
+				return nil, err
+			}
+		}
+		return bs, nil
+	case json.Number:
+		return appendMsgpack(bs, normalizeNumbers(v))
+	default:
+		return nil, &funcTypeError{"tomsgpack", v}
+	}
+}
+
+func appendMsgpackInt(bs []byte, n int64) []byte {
+	switch {
+	case 0 <= n && n <= 0x7f, -32 <= n && n < 0:
+		return append(bs, byte(n))
+	case 0 < n:
+		switch {
+		case n <= math.MaxUint8:
+			return appendMsgpackUint(append(bs, 0xcc), uint64(n), 1)
+		case n <= math.MaxUint16:
+			return appendMsgpackUint(append(bs, 0xcd), uint64(n), 2)
+		case n <= math.MaxUint32:
+			return appendMsgpackUint(append(bs, 0xce), uint64(n), 4)
+		default:
+			return appendMsgpackUint(append(bs, 0xcf), uint64(n), 8)
+		}
+	default:
+		switch {
+		case math.MinInt8 <= n:
+			return appendMsgpackUint(append(bs, 0xd0), uint64(n), 1)
+		case math.MinInt16 <= n:
+			return appendMsgpackUint(append(bs, 0xd1), uint64(n), 2)
+		case math.MinInt32 <= n:
+			return appendMsgpackUint(append(bs, 0xd2), uint64(n), 4)
+		default:
+			return appendMsgpackUint(append(bs, 0xd3), uint64(n), 8)
+		}
+	}
+}
+
+func appendMsgpackUint(bs []byte, n uint64, size int) []byte {
+	for i := size - 1; i >= 0; i-- {
+		bs = append(bs, byte(n>>(8*i)))
+	}
+	return bs
+}
+
+// appendMsgpackLength appends the header of the length; in the fix format when
+// the length is up to fixMax, and otherwise in the formats of 8-bit (when head8
+// is not zero), 16-bit and 32-bit lengths, where the last one is head16 + 1.
+func appendMsgpackLength(bs []byte, n int, fix byte, fixMax int, head8, head16 byte) []byte {
+	switch {
+	case n <= fixMax:
+		return append(bs, fix|byte(n))
+	case n <= math.MaxUint8 && head8 != 0:
+		return appendMsgpackUint(append(bs, head8), uint64(n), 1)
+	case n <= math.MaxUint16:
+		return appendMsgpackUint(append(bs, head16), uint64(n), 2)
+	default:
+		return appendMsgpackUint(append(bs, head16+1), uint64(n), 4)
+	}
+}
@@ -8,12 +8,23 @@ import (
 )
 
 func normalizeNumbers(v interface{}) interface{} {
+	return normalizeNumbersWith(v, false)
+}
+
+// normalizeNumbersWith normalizes the numbers in v. When precise is true, this
+// function keeps the json.Number values which float64 cannot represent exactly,
+// or which are not written in the same text as float64 is encoded (like 1.50 or
+// 1e2), so that the numbers are emitted as they are in the input.
+func normalizeNumbersWith(v interface{}, precise bool) interface{} {
 	switch v := v.(type) {
 	case json.Number:
 		if i, err := v.Int64(); err == nil && minInt <= i && i <= maxInt {
 			return int(i)
 		}
 		if strings.ContainsAny(v.String(), ".eE") {
+			if precise && (!isCanonicalFloat(v) || !isExactFloat(v)) {
+				return v
+			}
 			if f, err := v.Float64(); err == nil {
 				return f
 			}
@@ -66,12 +77,17 @@ func normalizeNumbers(v interface{}) interface{} {
 		return float64(v)
 	case map[string]interface{}:
 		for k, x := range v {
-			v[k] = normalizeNumbers(x)
+			v[k] = normalizeNumbersWith(x, precise)
 		}
 		return v
 	case []interface{}:
 		for i, x := range v {
-			v[i] = normalizeNumbers(x)
+			v[i] = normalizeNumbersWith(x, precise)
+		}
+		return v
+	case *OrderedMap:
+		for k, x := range v.values {
+			v.values[k] = normalizeNumbersWith(x, precise)
 		}
 		return v
 	default:
@@ -79,6 +95,28 @@ func normalizeNumbers(v interface{}) interface{} {
 	}
 }
 
+func isExactFloat(v json.Number) bool {
+	r, ok := new(big.Rat).SetString(v.String())
+	if !ok {
+		return true
+	}
+	_, exact := r.Float64()
+	return exact
+}
+
+// isCanonicalFloat reports whether the number is written in the same text as
+// the float64 value is encoded.
+func isCanonicalFloat(v json.Number) bool {
+	f, err := v.Float64()
+	return err == nil && jsonMarshal(f) == v.String()
+}
+
+// numberToFloat converts the number kept by WithPreciseNumbers to float64.
+func numberToFloat(v json.Number) float64 {
+	f, _ := v.Float64()
+	return f
+}
+
 // It's ok to delete destructively because this function is used right after
 // updatePaths, where it shallow-copies maps or slices on updates.
 func deleteEmpty(v interface{}) interface{} {
@@ -106,6 +144,15 @@ func deleteEmpty(v interface{}) interface{} {
 			v[i] = nil
 		}
 		return v[:j]
+	case *OrderedMap:
+		for _, k := range v.Keys() {
+			if w := v.values[k]; w == struct{}{} {
+				v.Delete(k)
+			} else {
+				v.values[k] = deleteEmpty(w)
+			}
+		}
+		return v
 	default:
 		return v
 	}
@@ -0,0 +1,132 @@
+package gojq
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// numberFormat is the options of format_number.
+type numberFormat struct {
+	decimals  int
+	thousands string
+	point     string
+	round     string
+}
+
+var numberRoundingModes = map[string]struct{}{
+	"half-up": {}, "half-down": {}, "half-even": {},
+	"up": {}, "down": {}, "ceiling": {}, "floor": {},
+}
+
+// funcFormatNumber formats the number with the options; decimals to round at
+// the number of fractional digits, thousands to separate the integral digits,
+// point to change the decimal point, and round to choose the rounding mode.
+// The floating-point numbers are rounded in the shortest decimal notation, so
+// that 2.675 is rounded up to 2.68 in half-up mode.
+func funcFormatNumber(v interface{}, args []interface{}) interface{} {
+	var r *big.Rat
+	if f, ok := v.(float64); ok {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return &funcTypeError{"format_number", v}
+		}
+		r, _ = new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64))
+	} else if w, ok := toRat(v); ok {
+		r = w
+	} else {
+		return &funcTypeError{"format_number", v}
+	}
+	opts := numberFormat{decimals: -1, point: ".", round: "half-up"}
+	if len(args) > 0 {
+		m, ok := args[0].(map[string]interface{})
+		if !ok {
+			return &funcTypeError{"format_number", args[0]}
+		}
+		for k, x := range m {
+			switch k {
+			case "decimals":
+				if opts.decimals, ok = toInt(x); !ok || opts.decimals < 0 || opts.decimals > 1000 {
+					return &funcTypeError{"format_number", x}
+				}
+			case "thousands", "point", "round":
+				s, ok := x.(string)
+				if !ok {
+					return &funcTypeError{"format_number", x}
+				}
+				switch k {
+				case "thousands":
+					opts.thousands = s
+				case "point":
+					opts.point = s
+				default:
+					if _, ok := numberRoundingModes[s]; !ok {
+						return &funcTypeError{"format_number", x}
+					}
+					opts.round = s
+				}
+			default:
+				return &funcTypeError{"format_number", m}
+			}
+		}
+	}
+	return formatNumber(r, &opts)
+}
+
+func formatNumber(r *big.Rat, opts *numberFormat) string {
+	decimals := opts.decimals
+	if decimals < 0 {
+		// the number of the fractional digits of the terminating decimal
+		x := new(big.Rat).Set(r)
+		for decimals = 0; !x.IsInt(); decimals++ {
+			x.Mul(x, big.NewRat(10, 1))
+		}
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	x := new(big.Rat).Mul(r, new(big.Rat).SetInt(scale))
+	q, m := new(big.Int).QuoRem(x.Num(), x.Denom(), new(big.Int))
+	if m.Sign() != 0 {
+		var inc bool
+		switch c := new(big.Int).Abs(m); opts.round {
+		case "up":
+			inc = true
+		case "down":
+		case "ceiling":
+			inc = r.Sign() > 0
+		case "floor":
+			inc = r.Sign() < 0
+		default:
+			switch c.Lsh(c, 1).Cmp(x.Denom()) {
+			case 1:
+				inc = true
+			case 0:
+				inc = opts.round == "half-up" ||
+					opts.round == "half-even" && q.Bit(0) == 1
+			}
+		}
+		if inc {
+			q.Add(q, big.NewInt(int64(r.Sign())))
+		}
+	}
+	neg := q.Sign() < 0
+	digits := q.Abs(q).String()
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	intPart, fracPart := digits[:len(digits)-decimals], digits[len(digits)-decimals:]
+	var sb strings.Builder
+	if neg {
+		sb.WriteByte('-')
+	}
+	for i := 0; i < len(intPart); i++ {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			sb.WriteString(opts.thousands)
+		}
+		sb.WriteByte(intPart[i])
+	}
+	if decimals > 0 {
+		sb.WriteString(opts.point)
+		sb.WriteString(fracPart)
+	}
+	return sb.String()
+}
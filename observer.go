@@ -0,0 +1,43 @@
+package gojq
+
+import "time"
+
+// OutputEvent is the information of an output of the query, which is passed to
+// the observer set by WithOutputObserver.
+type OutputEvent struct {
+	// Value is the emitted value, or an error.
+	Value interface{}
+
+	// Index is the index of the output in the run, starting from zero.
+	Index int
+
+	// Duration is the time spent to produce the output, excluding the time
+	// the caller spent between the calls of the Next method.
+	Duration time.Duration
+
+	// Elapsed is the time since the run started.
+	Elapsed time.Duration
+}
+
+type observedIter struct {
+	iter     Iter
+	observer func(*OutputEvent)
+	start    time.Time
+	index    int
+}
+
+func newObservedIter(iter Iter, observer func(*OutputEvent)) Iter {
+	return &observedIter{iter: iter, observer: observer, start: time.Now()}
+}
+
+func (iter *observedIter) Next() (interface{}, bool) {
+	start := time.Now()
+	v, ok := iter.iter.Next()
+	if !ok {
+		return nil, false
+	}
+	now := time.Now()
+	iter.observer(&OutputEvent{v, iter.index, now.Sub(start), now.Sub(iter.start)})
+	iter.index++
+	return v, true
+}
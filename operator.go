@@ -1,6 +1,7 @@
 package gojq
 
 import (
+	"encoding/json"
 	"math"
 	"math/big"
 	"reflect"
@@ -230,6 +231,8 @@ func binopTypeSwitch(
 			return callbackFloats(float64(l), r)
 		case *big.Int:
 			return callbackBigInts(big.NewInt(int64(l)), r)
+		case json.Number:
+			return callbackFloats(float64(l), numberToFloat(r))
 		default:
 			return fallback(l, r)
 		}
@@ -241,6 +244,8 @@ func binopTypeSwitch(
 			return callbackFloats(l, r)
 		case *big.Int:
 			return callbackFloats(l, bigToFloat(r))
+		case json.Number:
+			return callbackFloats(l, numberToFloat(r))
 		default:
 			return fallback(l, r)
 		}
@@ -252,6 +257,16 @@ func binopTypeSwitch(
 			return callbackFloats(bigToFloat(l), r)
 		case *big.Int:
 			return callbackBigInts(l, r)
+		case json.Number:
+			return callbackFloats(bigToFloat(l), numberToFloat(r))
+		default:
+			return fallback(l, r)
+		}
+	case json.Number:
+		switch r.(type) {
+		case int, float64, *big.Int, json.Number:
+			return binopTypeSwitch(numberToFloat(l), r, callbackInts, callbackFloats,
+				callbackBigInts, callbackStrings, callbackArrays, callbackMaps, fallback)
 		default:
 			return fallback(l, r)
 		}
@@ -289,6 +304,8 @@ func funcOpPlus(v interface{}) interface{} {
 		return v
 	case *big.Int:
 		return v
+	case json.Number:
+		return v
 	default:
 		return &unaryTypeError{"plus", v}
 	}
@@ -302,6 +319,11 @@ func funcOpNegate(v interface{}) interface{} {
 		return -v
 	case *big.Int:
 		return new(big.Int).Neg(v)
+	case json.Number:
+		if strings.HasPrefix(v.String(), "-") {
+			return v[1:]
+		}
+		return "-" + v
 	default:
 		return &unaryTypeError{"negate", v}
 	}
@@ -313,6 +335,15 @@ func funcOpAdd(_, l, r interface{}) interface{} {
 	} else if r == nil {
 		return l
 	}
+	if m, ok := addOrderedMaps(l, r); ok {
+		return m
+	}
+	if _, ok := l.(JQValue); ok {
+		l, _ = resolveJQValue(l)
+	}
+	if _, ok := r.(JQValue); ok {
+		r, _ = resolveJQValue(r)
+	}
 	return binopTypeSwitch(l, r,
 		func(l, r int) interface{} { return l + r },
 		func(l, r float64) interface{} { return l + r },
@@ -337,7 +368,18 @@ func funcOpAdd(_, l, r interface{}) interface{} {
 			}
 			return m
 		},
-		func(l, r interface{}) interface{} { return &binopTypeError{"add", l, r} },
+		func(l, r interface{}) interface{} {
+			if l, ok := l.([]byte); ok {
+				if r, ok := r.([]byte); ok {
+					v := make([]byte, 0, len(l)+len(r))
+					return append(append(v, l...), r...)
+				}
+			}
+			if v, ok := addDatetime(l, r); ok {
+				return v
+			}
+			return &binopTypeError{"add", l, r}
+		},
 	)
 }
 
@@ -364,11 +406,21 @@ func funcOpSub(_, l, r interface{}) interface{} {
 			return a
 		},
 		func(l, r map[string]interface{}) interface{} { return &binopTypeError{"subtract", l, r} },
-		func(l, r interface{}) interface{} { return &binopTypeError{"subtract", l, r} },
+		func(l, r interface{}) interface{} {
+			if v, ok := subDatetime(l, r); ok {
+				return v
+			}
+			return &binopTypeError{"subtract", l, r}
+		},
 	)
 }
 
 func funcOpMul(_, l, r interface{}) interface{} {
+	if m, ok := mergeOrderedMaps(l, r); ok {
+		return m
+	}
+	l, _ = resolveJQValue(l)
+	r, _ = resolveJQValue(r)
 	return binopTypeSwitch(l, r,
 		func(l, r int) interface{} { return l * r },
 		func(l, r float64) interface{} { return l * r },
@@ -419,6 +471,117 @@ func deepMergeObjects(l, r map[string]interface{}) interface{} {
 	return m
 }
 
+// deepMerger merges the values recursively. The arrays are concatenated,
+// replaced or united by the arrays option, and the null values are ignored
+// when the nulls option is keep.
+type deepMerger struct {
+	arrays, nulls string
+	ordered       bool
+}
+
+func (m *deepMerger) merge(l, r interface{}) interface{} {
+	if r == nil && m.nulls == "keep" {
+		return l
+	}
+	if m.ordered {
+		if l, ok := toOrderedMap(l); ok {
+			if r, ok := toOrderedMap(r); ok {
+				w := l.clone()
+				for _, k := range r.keys {
+					v := r.values[k]
+					if x, ok := w.values[k]; ok {
+						v = m.merge(x, v)
+					} else if v == nil && m.nulls == "keep" {
+						continue
+					}
+					w.Set(k, v)
+				}
+				return w
+			}
+		}
+	}
+	switch l := l.(type) {
+	case map[string]interface{}:
+		if r, ok := r.(map[string]interface{}); ok {
+			w := make(map[string]interface{}, len(l)+len(r))
+			for k, v := range l {
+				w[k] = v
+			}
+			for k, v := range r {
+				if x, ok := w[k]; ok {
+					v = m.merge(x, v)
+				} else if v == nil && m.nulls == "keep" {
+					continue
+				}
+				w[k] = v
+			}
+			return w
+		}
+	case []interface{}:
+		if r, ok := r.([]interface{}); ok {
+			switch m.arrays {
+			case "concat":
+				return append(append(make([]interface{}, 0, len(l)+len(r)), l...), r...)
+			case "union":
+				w := append(make([]interface{}, 0, len(l)+len(r)), l...)
+			L:
+				for _, v := range r {
+					for _, x := range w {
+						if compare(x, v) == 0 {
+							continue L
+						}
+					}
+					w = append(w, v)
+				}
+				return w
+			}
+		}
+	}
+	return r
+}
+
+func funcDeepMerge(v interface{}, args []interface{}) interface{} {
+	return deepMerge(v, args, false)
+}
+
+func funcDeepMergeOrdered(v interface{}, args []interface{}) interface{} {
+	return deepMerge(v, args, true)
+}
+
+func deepMerge(v interface{}, args []interface{}, ordered bool) interface{} {
+	m := &deepMerger{"replace", "override", ordered}
+	if len(args) > 1 {
+		if x, ok := args[1].(*OrderedMap); ok {
+			args[1] = x.JQValueToJSON()
+		}
+		opts, ok := args[1].(map[string]interface{})
+		if !ok {
+			return &funcTypeError{"deepmerge", args[1]}
+		}
+		for k, x := range opts {
+			switch k {
+			case "arrays":
+				switch x {
+				case "concat", "replace", "union":
+					m.arrays = x.(string)
+				default:
+					return &funcTypeError{"deepmerge", x}
+				}
+			case "nulls":
+				switch x {
+				case "keep", "override":
+					m.nulls = x.(string)
+				default:
+					return &funcTypeError{"deepmerge", x}
+				}
+			default:
+				return &funcTypeError{"deepmerge", opts}
+			}
+		}
+	}
+	return m.merge(v, args[0])
+}
+
 func funcOpDiv(_, l, r interface{}) interface{} {
 	return binopTypeSwitch(l, r,
 		func(l, r int) interface{} {
@@ -57,6 +57,147 @@ func WithIterFunction(name string, minarity, maxarity int,
 	)
 }
 
+// WithPreciseNumbers is a compiler option to keep the precision of the numbers
+// in the input values and the number literals in the query. The numbers of
+// json.Number type which float64 cannot represent exactly (for example
+// high-precision decimals), or which are written in other forms than float64
+// is encoded (like 1.50 or 1e2), are kept as they are, and emitted in the same
+// text unless they are used in arithmetic operations. Integers are always kept
+// exactly using *big.Int for large values. Note that the emitted values may
+// contain json.Number with this option.
+func WithPreciseNumbers() CompilerOption {
+	return func(c *compiler) {
+		c.precise = true
+	}
+}
+
+// WithDecimal is a compiler option to calculate the numbers in decimals. The
+// addition, subtraction, multiplication and division of non-integer numbers are
+// calculated exactly in arbitrary-precision decimals, so that 0.1 + 0.2 results
+// in 0.3. The quotients which cannot be represented by finite decimals are
+// rounded to 16 digits after the decimal point. This option implies
+// WithPreciseNumbers, and the emitted values may contain json.Number.
+func WithDecimal() CompilerOption {
+	return func(c *compiler) {
+		c.precise = true
+		c.decimal = true
+	}
+}
+
+// WithDatetime is a compiler option to handle dates as datetime values. With
+// this option, mktime (and fromdate, fromdateiso8601) produces a datetime value
+// of time.Time type instead of the seconds since the Unix epoch. The datetime
+// values (type "datetime") can be compared with each other, added (or
+// subtracted) with the number of seconds, and subtracted with each other to get
+// the seconds between them. They are emitted in RFC 3339 format by encoders.
+// Note that the emitted values may contain time.Time with this option.
+func WithDatetime() CompilerOption {
+	return func(c *compiler) {
+		c.datetime = true
+	}
+}
+
+// WithPreserveOrder is a compiler option to keep the key order of objects. The
+// query constructs objects of *OrderedMap type, which keeps the insertion order
+// of the keys. The updates of objects such as setpath, del and addition keep
+// the order of *OrderedMap values in the input (use DecodeOrdered to decode
+// them). Note that the emitted values may contain *OrderedMap.
+func WithPreserveOrder() CompilerOption {
+	return func(c *compiler) {
+		c.ordered = true
+	}
+}
+
+// WithOptimization is a compiler option to optimize the compiled instructions
+// after the compilation. The optimizer removes the pairs of pushing a value and
+// popping it, replaces the duplication of a value followed by overwriting it,
+// and specializes the indexing by constant keys (like .foo and .[0]) into one
+// instruction. Use Code.Disassemble to see the optimized instructions.
+func WithOptimization() CompilerOption {
+	return func(c *compiler) {
+		c.optimize = true
+	}
+}
+
+// WithMaxSteps is a compiler option to limit the number of the execution steps
+// of the query for each input. The step is a primitive operation of the virtual
+// machine, including backtracking. When the query exceeds the limit, the result
+// iterator emits an error implementing LimitError and stops. This option is
+// useful to bound the execution of untrusted queries deterministically, unlike
+// the timeout of the context. Zero means unlimited.
+func WithMaxSteps(n int) CompilerOption {
+	return func(c *compiler) {
+		c.limits.steps = n
+	}
+}
+
+// WithMaxDepth is a compiler option to limit the depth of the function calls,
+// which bounds the recursion of the query. Note that the calls in the builtin
+// functions are also counted. When the query exceeds the limit, the result
+// iterator emits an error implementing LimitError and stops. Zero means
+// unlimited.
+func WithMaxDepth(n int) CompilerOption {
+	return func(c *compiler) {
+		c.limits.depth = n
+	}
+}
+
+// WithMaxValueSize is a compiler option to limit the size of the values which
+// the query constructs; the length of arrays and objects, and the bytes of
+// strings. When the query exceeds the limit, the result iterator emits an error
+// implementing LimitError and stops. Note that the values given to the query
+// are not checked. Zero means unlimited.
+func WithMaxValueSize(n int) CompilerOption {
+	return func(c *compiler) {
+		c.limits.size = n
+	}
+}
+
+// WithOutputObserver is a compiler option to observe the outputs of the query.
+// The observer is called with each value and error emitted by the result
+// iterator, along with the timing information, before the Next method returns.
+// This is useful to collect metrics of the query, or to apply backpressure by
+// blocking in the observer. The observer is called in the goroutine calling
+// the Next method, so it should be safe for concurrent use when the code runs
+// in multiple goroutines.
+func WithOutputObserver(f func(*OutputEvent)) CompilerOption {
+	return func(c *compiler) {
+		c.observer = f
+	}
+}
+
+// WithWarningHandler is a compiler option to receive the warnings of the query.
+// The warnings are reported on compilation for the constructs which are likely
+// to be bugs; the function definitions which are not used, and the comparisons
+// with null which always result in the same boolean. The warnings do not stop
+// the compilation, and the modules are not checked.
+func WithWarningHandler(f func(string)) CompilerOption {
+	return func(c *compiler) {
+		c.warn = f
+	}
+}
+
+// WithTypeCheck is a compiler option to warn the expressions which always fail
+// at runtime, like indexing a number by a key or adding a string to a number.
+// The kinds of the values are inferred through the query from the literals and
+// the builtin functions, and the warnings are reported to the handler given by
+// WithWarningHandler. The errors caught by try or ? are not reported.
+func WithTypeCheck() CompilerOption {
+	return func(c *compiler) {
+		c.typecheck = true
+	}
+}
+
+// WithShadowingWarning is a compiler option to warn the variables shadowing the
+// ones of the same name in the outer scopes, which are reported to the handler
+// given by WithWarningHandler. This is not enabled by default because rebinding
+// a variable in the inner scope is a common idiom.
+func WithShadowingWarning() CompilerOption {
+	return func(c *compiler) {
+		c.shadowing = true
+	}
+}
+
 func withFunction(name string, minarity, maxarity int, iter bool,
 	f func(interface{}, []interface{}) interface{}) CompilerOption {
 	if !(0 <= minarity && minarity <= maxarity && maxarity <= 30) {
@@ -86,6 +227,19 @@ func withFunction(name string, minarity, maxarity int, iter bool,
 	}
 }
 
+// WithRandomSeed is a compiler option to make the random functions (such as
+// uuid) reproducible. The functions generate the values from the pseudo-random
+// number generator initialized by the seed, instead of the cryptographically
+// secure random number generator. The generator is shared by the runs of the
+// code, so the values depend on the order of the calls when the code runs in
+// multiple goroutines. Note that uuid7 still uses the current time for the
+// timestamp part.
+func WithRandomSeed(seed int64) CompilerOption {
+	return func(c *compiler) {
+		c.random = newSeededRandom(seed)
+	}
+}
+
 // WithInputIter is a compiler option for input iterator used by input(s)/0.
 // Note that input and inputs functions are not allowed by default. We have
 // to distinguish the query input and the values for input(s) functions. For
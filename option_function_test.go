@@ -2,10 +2,12 @@ package gojq_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
 	"strconv"
+	"testing"
 
 	"github.com/itchyny/gojq"
 )
@@ -69,3 +71,46 @@ func ExampleWithFunction() {
 	// 13.5
 	// 3e+40
 }
+
+var errSentinel = errors.New("sentinel error")
+
+type customError struct {
+	v interface{}
+}
+
+func (err *customError) Error() string {
+	return fmt.Sprintf("custom error: %v", err.v)
+}
+
+func TestWithFunction_Error(t *testing.T) {
+	query, err := gojq.Parse(`.[] | f`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(
+		query,
+		gojq.WithFunction("f", 0, 0, func(x interface{}, _ []interface{}) interface{} {
+			if x == nil {
+				return errSentinel
+			}
+			return &customError{x}
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := code.Run([]interface{}{nil, 1})
+	v, _ := iter.Next()
+	if err, ok := v.(error); !ok || !errors.Is(err, errSentinel) {
+		t.Errorf("expected the sentinel error but got: %v", v)
+	} else if err.Error() != errSentinel.Error() {
+		t.Errorf("expected: %v, got: %v", errSentinel, err)
+	} else if e, ok := err.(gojq.RuntimeError); !ok || e.Name() != "f" {
+		t.Errorf("expected a runtime error of f but got: %#v", err)
+	}
+	v, _ = iter.Next()
+	var e *customError
+	if err, ok := v.(error); !ok || !errors.As(err, &e) || e.v != 1 {
+		t.Errorf("expected the custom error but got: %v", v)
+	}
+}
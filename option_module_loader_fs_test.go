@@ -0,0 +1,56 @@
+//go:build go1.16
+// +build go1.16
+
+package gojq_test
+
+import (
+	"fmt"
+	"log"
+	"testing/fstest"
+
+	"github.com/itchyny/gojq"
+)
+
+func ExampleNewModuleLoaderFS() {
+	fsys := fstest.MapFS{
+		"lib/module1.jq": {Data: []byte(`
+			import "module2" as foo;
+			def f: foo::f + 1;
+		`)},
+		"lib/module2/module2.jq": {Data: []byte(`
+			def f: .foo;
+		`)},
+		"lib/data.json": {Data: []byte(`{"bar": 42}`)},
+	}
+	query, err := gojq.Parse(`
+		import "module1" as m;
+		import "data" as $data;
+		m::f, $data[0].bar
+	`)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	code, err := gojq.Compile(
+		query,
+		gojq.WithModuleLoader(gojq.NewModuleLoaderFS(fsys, []string{"lib"})),
+	)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	input := map[string]interface{}{"foo": 41}
+	iter := code.Run(input)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			log.Fatalln(err)
+		}
+		fmt.Printf("%#v\n", v)
+	}
+
+	// Output:
+	// 42
+	// 42
+}
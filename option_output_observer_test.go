@@ -0,0 +1,53 @@
+package gojq_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/itchyny/gojq"
+)
+
+func ExampleWithOutputObserver() {
+	query, err := gojq.Parse(".[] | 10 / .")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	var values, errors int
+	code, err := gojq.Compile(
+		query,
+		gojq.WithOutputObserver(func(e *gojq.OutputEvent) {
+			if _, ok := e.Value.(error); ok {
+				errors++
+			} else {
+				values++
+			}
+			if e.Duration < 0 || e.Elapsed < e.Duration {
+				log.Fatalln("invalid timing")
+			}
+			fmt.Printf("observed #%d: %v\n", e.Index, e.Value)
+		}),
+	)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	iter := code.Run([]interface{}{1, 2, 0})
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if _, ok := v.(error); ok {
+			continue
+		}
+		fmt.Printf("%#v\n", v)
+	}
+	fmt.Printf("values: %d, errors: %d\n", values, errors)
+
+	// Output:
+	// observed #0: 10
+	// 10
+	// observed #1: 5
+	// 5
+	// observed #2: cannot divide number (10) by: number (0)
+	// values: 2, errors: 1
+}
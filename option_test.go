@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"math/big"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/itchyny/gojq"
 )
@@ -815,3 +818,279 @@ func TestWithInputIter(t *testing.T) {
 		t.Errorf("expected: %v, got: %v", expected, n)
 	}
 }
+
+func TestWithPreserveOrder(t *testing.T) {
+	query, err := gojq.Parse(`., (.c = 3 | del(.b)), {y: 1, x: .a}, keys_unsorted`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(query, gojq.WithPreserveOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := gojq.DecodeOrdered(json.NewDecoder(strings.NewReader(`{"b":1,"a":{"z":2,"y":3}}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := code.Run(v)
+	var got []string
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			t.Fatal(err)
+		}
+		bs, err := gojq.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(bs))
+	}
+	expected := []string{
+		`{"b":1,"a":{"z":2,"y":3}}`,
+		`{"a":{"z":2,"y":3},"c":3}`,
+		`{"y":1,"x":{"z":2,"y":3}}`,
+		`["b","a"]`,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+}
+
+func TestWithDecimal(t *testing.T) {
+	query, err := gojq.Parse(`.[0] + .[1], .[0] * 3 == 0.3, 1 / 3, 5 / 2, .[1] - .[0] - 0.1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(query, gojq.WithDecimal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := code.Run([]interface{}{0.1, 0.2})
+	var got []interface{}
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	expected := []interface{}{
+		json.Number("0.3"), true, json.Number("0.3333333333333333"), json.Number("2.5"), 0,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+}
+
+func TestWithDatetime(t *testing.T) {
+	query, err := gojq.Parse(`fromdate | ., . + 60, type`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(query, gojq.WithDatetime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := code.Run("2015-03-05T23:51:47Z")
+	var got []interface{}
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	expected := []interface{}{
+		time.Date(2015, 3, 5, 23, 51, 47, 0, time.UTC),
+		time.Date(2015, 3, 5, 23, 52, 47, 0, time.UTC),
+		"datetime",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+}
+
+func TestWithLimits(t *testing.T) {
+	testCases := []struct {
+		src      string
+		option   gojq.CompilerOption
+		expected []interface{}
+		err      string
+		limit    string
+	}{
+		{
+			src:      `range(3)`,
+			option:   gojq.WithMaxSteps(1000),
+			expected: []interface{}{0, 1, 2},
+		},
+		{
+			src:    `try last(range(1e9)) catch "caught"`,
+			option: gojq.WithMaxSteps(1000),
+			err:    "exceeds the maximum number of execution steps: 1000",
+			limit:  "steps",
+		},
+		{
+			src:      `def f: if . < 3 then (. + 1 | f) + 1 else . end; f`,
+			option:   gojq.WithMaxDepth(10),
+			expected: []interface{}{6},
+		},
+		{
+			src:    `def f: if . < 10 then (. + 1 | f) + 1 else . end; try f catch "caught"`,
+			option: gojq.WithMaxDepth(10),
+			err:    "exceeds the maximum recursion depth: 10",
+			limit:  "depth",
+		},
+		{
+			src:      `[range(3)], {a: 1, b: 2, c: 3}, "abc" * 1`,
+			option:   gojq.WithMaxValueSize(3),
+			expected: []interface{}{[]interface{}{0, 1, 2}, map[string]interface{}{"a": 1, "b": 2, "c": 3}, "abc"},
+		},
+		{
+			src:    `[range(4)]?`,
+			option: gojq.WithMaxValueSize(3),
+			err:    "exceeds the maximum value size: 3",
+			limit:  "value size",
+		},
+		{
+			src:    `{a: ., b: 2, c: 3, d: 4}`,
+			option: gojq.WithMaxValueSize(3),
+			err:    "exceeds the maximum value size: 3",
+			limit:  "value size",
+		},
+		{
+			src:    `"ab" * 2`,
+			option: gojq.WithMaxValueSize(3),
+			err:    "exceeds the maximum value size: 3",
+			limit:  "value size",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.src, func(t *testing.T) {
+			query, err := gojq.Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, err := gojq.Compile(query, tc.option)
+			if err != nil {
+				t.Fatal(err)
+			}
+			iter := code.Run(0)
+			var got []interface{}
+			for {
+				v, ok := iter.Next()
+				if !ok {
+					break
+				}
+				if err, ok := v.(error); ok {
+					if err.Error() != tc.err {
+						t.Fatalf("expected error: %v, got: %v", tc.err, err)
+					}
+					var er gojq.LimitError
+					if !errors.As(err, &er) {
+						t.Fatalf("expected LimitError but got: %T", err)
+					}
+					if name, _ := er.Limit(); name != tc.limit {
+						t.Errorf("expected limit: %v, got: %v", tc.limit, name)
+					}
+					if v, ok := iter.Next(); ok {
+						t.Errorf("should not emit a value after the error but got: %v", v)
+					}
+					tc.err = ""
+					break
+				}
+				got = append(got, v)
+			}
+			if tc.err != "" {
+				t.Errorf("expected error: %v", tc.err)
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected: %v, got: %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestWithRandomSeed(t *testing.T) {
+	query, err := gojq.Parse("uuid, uuid, uuid7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := func(seed int64) []interface{} {
+		code, err := gojq.Compile(query, gojq.WithRandomSeed(seed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		iter := code.Run(nil)
+		var got []interface{}
+		for {
+			v, ok := iter.Next()
+			if !ok {
+				break
+			}
+			if err, ok := v.(error); ok {
+				t.Fatal(err)
+			}
+			got = append(got, v)
+		}
+		return got
+	}
+	xs, ys, zs := run(1), run(1), run(2)
+	if !reflect.DeepEqual(xs[:2], ys[:2]) {
+		t.Errorf("expected the same values with the same seed: %v, %v", xs, ys)
+	}
+	if xs[1] == xs[0] || xs[0] == zs[0] {
+		t.Errorf("expected different values: %v, %v", xs, zs)
+	}
+	if x, y := xs[2].(string), ys[2].(string); x[19:] != y[19:] {
+		t.Errorf("expected the same random part of uuid7: %v, %v", x, y)
+	}
+}
+
+func TestWithRandomSeed_Race(t *testing.T) {
+	query, err := gojq.Parse("range(100) | uuid, random, randint(0; 10), ([range(10)] | shuffle)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(query, gojq.WithRandomSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	seen := map[string]struct{}{}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			iter := code.Run(nil)
+			for {
+				v, ok := iter.Next()
+				if !ok {
+					break
+				}
+				if err, ok := v.(error); ok {
+					t.Error(err)
+					break
+				}
+				if s, ok := v.(string); ok {
+					mu.Lock()
+					seen[s] = struct{}{}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if expected := 1000; len(seen) != expected {
+		t.Errorf("expected %d distinct uuids but got: %d", expected, len(seen))
+	}
+}
@@ -0,0 +1,96 @@
+package gojq_test
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"testing"
+
+	"github.com/itchyny/gojq"
+)
+
+func ExampleWithTypeCheck() {
+	query, err := gojq.Parse(`.foo | length | .bar, ("a" + 1), try (1 | .[]) catch .`)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	_, err = gojq.Compile(
+		query,
+		gojq.WithWarningHandler(func(msg string) {
+			fmt.Println(msg)
+		}),
+		gojq.WithTypeCheck(),
+	)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// Output:
+	// .bar always fails: expected an object but got: number
+	// "a" + 1 always fails: cannot add: string and number
+}
+
+func TestWithTypeCheck(t *testing.T) {
+	testCases := []struct {
+		src      string
+		expected []string
+	}{
+		{`.foo.bar | .[0] | .[]`, nil},
+		{`1 | .foo`, []string{".foo always fails: expected an object but got: number"}},
+		{`"a" | .[0]`, nil},
+		{`{} | .[0]`, []string{".[0] always fails: expected an array but got: object"}},
+		{`(1, "a") | .foo`, []string{".foo always fails: expected an object but got: number or string"}},
+		{`(1, {}) | .foo`, nil},
+		{`(null, 1) | .foo`, nil},
+		{`.foo? | 1 | .bar?`, nil},
+		{`true | .[]`, []string{".[] always fails: cannot iterate over: boolean"}},
+		{`[1] | .[] | .foo`, nil},
+		{`keys | .foo`, []string{".foo always fails: expected an object but got: array"}},
+		{`to_entries | map(.key)`, nil},
+		{`1 | to_entries`, []string{"to_entries always fails: to_entries cannot be applied to: number"}},
+		{`"a" | sort_by(.)`, []string{`sort_by(.) always fails: sort_by cannot be applied to: string`}},
+		{`def sort_by(f): f; "a" | sort_by(.)`, nil},
+		{`def length: .; {} | length | .a`, nil},
+		{`{} | length | -.`, nil},
+		{`"a" | -.`, []string{`-. always fails: cannot negate: string`}},
+		{`{} - {}`, []string{`{} - {} always fails: cannot subtract: object and object`}},
+		{`null + 1 | .[0]`, []string{`.[0] always fails: expected an array but got: number`}},
+		{`"a" * 2 | .foo`, nil},
+		{`{(1): 2}`, []string{`1 always fails: expected a string for object key but got: number`}},
+		{`1 | {foo}`, []string{`foo always fails: expected an object but got: number`}},
+		{`1 as $x | {$x}`, nil},
+		{`[] | .[1:]`, nil},
+		{`{} | .[1:]`, []string{`.[1:] always fails: cannot slice: object`}},
+		{`1 | .[:1]`, []string{`.[:1] always fails: cannot slice: number`}},
+		{`if . then 1 else "a" end | .[0]`, nil},
+		{`if . then 1 else 2 end | .[0]`, []string{`.[0] always fails: expected an array but got: number`}},
+		{`reduce .[] as $x (0; . + $x) | .foo`, nil},
+		{`empty | .foo`, nil},
+		{`1 as $x | .foo`, nil},
+		{`1 | . as $x | .foo`, []string{`.foo always fails: expected an object but got: number`}},
+		{`def f: 1 | .foo; f`, []string{`.foo always fails: expected an object but got: number`}},
+		{`"\(1 | .foo)"`, []string{`.foo always fails: expected an object but got: number`}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.src, func(t *testing.T) {
+			query, err := gojq.Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			_, err = gojq.Compile(
+				query,
+				gojq.WithWarningHandler(func(msg string) {
+					got = append(got, msg)
+				}),
+				gojq.WithTypeCheck(),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected: %q, got: %q", tc.expected, got)
+			}
+		})
+	}
+}
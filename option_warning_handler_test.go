@@ -0,0 +1,48 @@
+package gojq_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/itchyny/gojq"
+)
+
+func ExampleWithWarningHandler() {
+	query, err := gojq.Parse(`def f: 1; .[] as $x | .[] as $x | $x == null, (length != null)`)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	_, err = gojq.Compile(
+		query,
+		gojq.WithWarningHandler(func(msg string) {
+			fmt.Println(msg)
+		}),
+	)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// Output:
+	// function f/0 is defined but not used
+	// comparison of length and null is always true
+}
+
+func ExampleWithShadowingWarning() {
+	query, err := gojq.Parse(`.[] as $x | .[] as $x | $x`)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	_, err = gojq.Compile(
+		query,
+		gojq.WithWarningHandler(func(msg string) {
+			fmt.Println(msg)
+		}),
+		gojq.WithShadowingWarning(),
+	)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// Output:
+	// variable $x shadows the variable of the same name in the outer scope
+}
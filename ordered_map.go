@@ -0,0 +1,332 @@
+package gojq
+
+import (
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+)
+
+// OrderedMap is an object keeping the insertion order of the keys. With the
+// WithPreserveOrder option, the query constructs objects of this type, and the
+// updates of the objects (setpath, del, addition, etc.) keep the key order.
+// Use DecodeOrdered to decode JSON values with objects of this type.
+//
+// OrderedMap implements JQValue, so the functions not aware of the key order
+// handle the value as a map[string]interface{}. Do not modify the map after
+// passing it to the query.
+type OrderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// orderedFuncs are the functions replacing the internal functions when the key
+// order is preserved by WithPreserveOrder.
+var orderedFuncs map[string]function
+
+func init() {
+	orderedFuncs = map[string]function{
+		"fromjson":           argFunc0(funcFromJSONOrdered),
+		"_fromstream_update": argFunc1(funcFromStreamUpdateOrdered),
+		"deepmerge":          {argcount1 | argcount2, false, funcDeepMergeOrdered},
+		"mergepatch_apply":   argFunc1(funcMergePatchApplyOrdered),
+		"mergepatch_diff":    argFunc1(funcMergePatchDiffOrdered),
+		"tocsv":              {argcount0 | argcount1, false, funcToCSVRows},
+		"frommsgpack":        argFunc0(funcFromMsgpackOrdered),
+		"tomsgpack":          argFunc0(funcToMsgpack),
+		"_toschema":          argFunc0(funcToSchemaOrdered),
+		"_jsonpath":          argFunc1(funcJSONPath),
+		"_paths_matching":    argFunc1(funcPathsMatching),
+		"setpath":            argFunc2(funcSetpathOrdered),
+		"_tohtml":            argFunc0(funcToHTML),
+		"_touri":             argFunc0(funcToURI),
+		"_tobase64":          argFunc0(funcToBase64),
+		"_tobase32":          base32Func(base32.StdEncoding),
+		"_tobase32hex":       base32Func(base32.HexEncoding),
+		"_tozbase32":         base32Func(zbase32Encoding),
+	}
+	for name, fn := range orderedFuncs {
+		fn.callback = resolveOrderedJQValueFunc(fn.callback)
+		orderedFuncs[name] = fn
+	}
+}
+
+// NewOrderedMap creates an empty OrderedMap.
+func NewOrderedMap() *OrderedMap {
+	return &OrderedMap{values: make(map[string]interface{})}
+}
+
+func newOrderedMapFromMap(m map[string]interface{}) *OrderedMap {
+	keys := make([]string, 0, len(m))
+	values := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		keys = append(keys, k)
+		values[k] = v
+	}
+	sort.Strings(keys)
+	return &OrderedMap{keys, values}
+}
+
+func newOrderedMapWithKeys(keys []string, values map[string]interface{}) *OrderedMap {
+	if len(keys) != len(values) {
+		ks := make([]string, 0, len(values))
+		seen := make(map[string]struct{}, len(values))
+		for _, k := range keys {
+			if _, ok := seen[k]; !ok {
+				seen[k] = struct{}{}
+				ks = append(ks, k)
+			}
+		}
+		keys = ks
+	}
+	return &OrderedMap{keys, values}
+}
+
+// Len returns the number of the keys.
+func (m *OrderedMap) Len() int {
+	return len(m.keys)
+}
+
+// Keys returns the keys in the insertion order.
+func (m *OrderedMap) Keys() []string {
+	return m.keys
+}
+
+// Get returns the value of the key.
+func (m *OrderedMap) Get(key string) (interface{}, bool) {
+	v, ok := m.values[key]
+	return v, ok
+}
+
+// Set sets the value of the key. The key is appended unless it exists.
+func (m *OrderedMap) Set(key string, value interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Delete deletes the key.
+func (m *OrderedMap) Delete(key string) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+	delete(m.values, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+func (m *OrderedMap) clone() *OrderedMap {
+	keys := make([]string, len(m.keys), len(m.keys)+1)
+	copy(keys, m.keys)
+	values := make(map[string]interface{}, len(m.values)+1)
+	for k, v := range m.values {
+		values[k] = v
+	}
+	return &OrderedMap{keys, values}
+}
+
+// toOrderedMap returns the object as *OrderedMap, where the keys of the map are
+// sorted. The returned value should be cloned before updating.
+func toOrderedMap(v interface{}) (*OrderedMap, bool) {
+	switch v := v.(type) {
+	case *OrderedMap:
+		return v, true
+	case map[string]interface{}:
+		return newOrderedMapFromMap(v), true
+	default:
+		return nil, false
+	}
+}
+
+func (m *OrderedMap) setMap(n map[string]interface{}) {
+	keys := make([]string, 0, len(n))
+	for k, v := range n {
+		if _, ok := m.values[k]; !ok {
+			keys = append(keys, k)
+		}
+		m.values[k] = v
+	}
+	sort.Strings(keys)
+	m.keys = append(m.keys, keys...)
+}
+
+func (m *OrderedMap) setOrderedMap(n *OrderedMap) {
+	for _, k := range n.keys {
+		m.Set(k, n.values[k])
+	}
+}
+
+// addOrderedMaps merges the objects when either of them is *OrderedMap.
+func addOrderedMaps(l, r interface{}) (*OrderedMap, bool) {
+	var m *OrderedMap
+	switch l := l.(type) {
+	case *OrderedMap:
+		switch r.(type) {
+		case *OrderedMap, map[string]interface{}:
+			m = l.clone()
+		default:
+			return nil, false
+		}
+	case map[string]interface{}:
+		if _, ok := r.(*OrderedMap); !ok {
+			return nil, false
+		}
+		m = newOrderedMapFromMap(l)
+	default:
+		return nil, false
+	}
+	switch r := r.(type) {
+	case *OrderedMap:
+		m.setOrderedMap(r)
+	case map[string]interface{}:
+		m.setMap(r)
+	}
+	return m, true
+}
+
+// mergeOrderedMaps merges the objects recursively when either of them is
+// *OrderedMap.
+func mergeOrderedMaps(l, r interface{}) (*OrderedMap, bool) {
+	_, lok := l.(*OrderedMap)
+	_, rok := r.(*OrderedMap)
+	if !lok && !rok {
+		return nil, false
+	}
+	lm, ok := toOrderedMap(l)
+	if !ok {
+		return nil, false
+	}
+	rm, ok := toOrderedMap(r)
+	if !ok {
+		return nil, false
+	}
+	m := lm.clone()
+	for _, k := range rm.keys {
+		v := rm.values[k]
+		if w, ok := mergeOrderedMaps(m.values[k], v); ok {
+			v = w
+		} else if x, ok := m.values[k].(map[string]interface{}); ok {
+			if y, ok := v.(map[string]interface{}); ok {
+				v = deepMergeObjects(x, y)
+			}
+		}
+		m.Set(k, v)
+	}
+	return m, true
+}
+
+// MarshalJSON implements json.Marshaler.
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	return jsonMarshalBytes(m), nil
+}
+
+// JQValueType implements JQValue.
+func (m *OrderedMap) JQValueType() string {
+	return "object"
+}
+
+// JQValueLength implements JQValue.
+func (m *OrderedMap) JQValueLength() int {
+	return len(m.keys)
+}
+
+// JQValueIndex implements JQValue.
+func (m *OrderedMap) JQValueIndex(key interface{}) interface{} {
+	if key, ok := key.(string); ok {
+		return m.values[key]
+	}
+	return nil
+}
+
+// JQValueKeys implements JQValue.
+func (m *OrderedMap) JQValueKeys() []interface{} {
+	keys := make([]string, len(m.keys))
+	copy(keys, m.keys)
+	sort.Strings(keys)
+	ks := make([]interface{}, len(keys))
+	for i, k := range keys {
+		ks[i] = k
+	}
+	return ks
+}
+
+// JQValueToJSON implements JQValue.
+func (m *OrderedMap) JQValueToJSON() interface{} {
+	values := make(map[string]interface{}, len(m.values))
+	for k, v := range m.values {
+		values[k] = v
+	}
+	return values
+}
+
+// DecodeOrdered decodes the next JSON value from the decoder. The objects are
+// decoded to *OrderedMap to keep the key order. This function returns io.EOF
+// when there is no more value.
+func DecodeOrdered(dec *json.Decoder) (interface{}, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	return decodeOrdered(dec, t)
+}
+
+func decodeOrdered(dec *json.Decoder, t json.Token) (interface{}, error) {
+	switch t {
+	case json.Delim('{'):
+		m := NewOrderedMap()
+		for dec.More() {
+			t, err := dec.Token()
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			k, ok := t.(string)
+			if !ok {
+				return nil, errors.New("invalid object key")
+			}
+			if t, err = dec.Token(); err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			v, err := decodeOrdered(dec, t)
+			if err != nil {
+				return nil, err
+			}
+			m.Set(k, v)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return m, nil
+	case json.Delim('['):
+		vs := []interface{}{}
+		for dec.More() {
+			t, err := dec.Token()
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			v, err := decodeOrdered(dec, t)
+			if err != nil {
+				return nil, err
+			}
+			vs = append(vs, v)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return vs, nil
+	default:
+		return t, nil
+	}
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -1,20 +1,55 @@
 %{
 package gojq
 
+import "sync"
+
 // Parse parses a query.
 func Parse(src string) (*Query, error) {
 	l := newLexer(src)
-	if yyParse(l) > 0 {
+	p := parserPool.Get().(*yyParserImpl)
+	defer func() {
+		*p = yyParserImpl{} // release the values on the stack
+		parserPool.Put(p)
+	}()
+	if p.Parse(l) > 0 {
 		return nil, l.err
 	}
 	return l.result, nil
 }
+
+// The terms and their contents are allocated at once to reduce the allocations
+// on parsing.
+
+func newIndexTerm(index Index) *Term {
+	x := &struct {
+		term  Term
+		index Index
+	}{Term{Type: TermTypeIndex, Offset: index.Offset}, index}
+	x.term.Index = &x.index
+	return &x.term
+}
+
+func newFuncTerm(f Func, offset int) *Term {
+	x := &struct {
+		term Term
+		f    Func
+	}{Term{Type: TermTypeFunc, Offset: offset}, f}
+	x.term.Func = &x.f
+	return &x.term
+}
+
+// parserPool reuses the parsers, which have the stack of the parsing states
+// in themselves, for the applications parsing many small queries.
+var parserPool = sync.Pool{
+	New: func() interface{} { return yyNewParser() },
+}
 %}
 
 %union {
   value    interface{}
   token    string
   operator Operator
+  offset   int
 }
 
 %type<value> program moduleheader programbody imports import metaopt funcdefs funcdef funcdefargs query
@@ -145,7 +180,7 @@ query
     }
     | query '|' query
     {
-        $$ = &Query{Left: $1.(*Query), Op: OpPipe, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: OpPipe, Right: $3.(*Query), Offset: $<offset>2}
     }
     | term tokAs bindpatterns '|' query
     {
@@ -154,79 +189,79 @@ query
     }
     | tokReduce term tokAs pattern '(' query ';' query ')'
     {
-        $$ = &Query{Term: &Term{Type: TermTypeReduce, Reduce: &Reduce{$2.(*Term), $4.(*Pattern), $6.(*Query), $8.(*Query)}}}
+        $$ = &Query{Term: &Term{Type: TermTypeReduce, Offset: $<offset>1, Reduce: &Reduce{$2.(*Term), $4.(*Pattern), $6.(*Query), $8.(*Query)}}}
     }
     | tokForeach term tokAs pattern '(' query ';' query ')'
     {
-        $$ = &Query{Term: &Term{Type: TermTypeForeach, Foreach: &Foreach{$2.(*Term), $4.(*Pattern), $6.(*Query), $8.(*Query), nil}}}
+        $$ = &Query{Term: &Term{Type: TermTypeForeach, Offset: $<offset>1, Foreach: &Foreach{$2.(*Term), $4.(*Pattern), $6.(*Query), $8.(*Query), nil}}}
     }
     | tokForeach term tokAs pattern '(' query ';' query ';' query ')'
     {
-        $$ = &Query{Term: &Term{Type: TermTypeForeach, Foreach: &Foreach{$2.(*Term), $4.(*Pattern), $6.(*Query), $8.(*Query), $10.(*Query)}}}
+        $$ = &Query{Term: &Term{Type: TermTypeForeach, Offset: $<offset>1, Foreach: &Foreach{$2.(*Term), $4.(*Pattern), $6.(*Query), $8.(*Query), $10.(*Query)}}}
     }
     | tokIf query tokThen query ifelifs ifelse tokEnd
     {
-        $$ = &Query{Term: &Term{Type: TermTypeIf, If: &If{$2.(*Query), $4.(*Query), $5.([]*IfElif), $6.(*Query)}}}
+        $$ = &Query{Term: &Term{Type: TermTypeIf, Offset: $<offset>1, If: &If{$2.(*Query), $4.(*Query), $5.([]*IfElif), $6.(*Query)}}}
     }
     | tokTry query trycatch
     {
-        $$ = &Query{Term: &Term{Type: TermTypeTry, Try: &Try{$2.(*Query), $3.(*Query)}}}
+        $$ = &Query{Term: &Term{Type: TermTypeTry, Offset: $<offset>1, Try: &Try{$2.(*Query), $3.(*Query)}}}
     }
     | tokLabel tokVariable '|' query
     {
-        $$ = &Query{Term: &Term{Type: TermTypeLabel, Label: &Label{$2, $4.(*Query)}}}
+        $$ = &Query{Term: &Term{Type: TermTypeLabel, Offset: $<offset>1, Label: &Label{$2, $4.(*Query)}}}
     }
     | query '?'
     {
         if t := $1.(*Query).Term; t != nil {
             t.SuffixList = append(t.SuffixList, &Suffix{Optional: true})
         } else {
-            $$ = &Query{Term: &Term{Type: TermTypeQuery, Query: $1.(*Query), SuffixList: []*Suffix{{Optional: true}}}}
+            $$ = &Query{Term: &Term{Type: TermTypeQuery, Query: $1.(*Query), SuffixList: []*Suffix{{Optional: true}}, Offset: $<offset>1}}
         }
     }
     | query ',' query
     {
-        $$ = &Query{Left: $1.(*Query), Op: OpComma, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: OpComma, Right: $3.(*Query), Offset: $<offset>2}
     }
     | query tokAltOp query
     {
-        $$ = &Query{Left: $1.(*Query), Op: $2, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: $2, Right: $3.(*Query), Offset: $<offset>2}
     }
     | query tokUpdateOp query
     {
-        $$ = &Query{Left: $1.(*Query), Op: $2, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: $2, Right: $3.(*Query), Offset: $<offset>2}
     }
     | query tokOrOp query
     {
-        $$ = &Query{Left: $1.(*Query), Op: OpOr, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: OpOr, Right: $3.(*Query), Offset: $<offset>2}
     }
     | query tokAndOp query
     {
-        $$ = &Query{Left: $1.(*Query), Op: OpAnd, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: OpAnd, Right: $3.(*Query), Offset: $<offset>2}
     }
     | query tokCompareOp query
     {
-        $$ = &Query{Left: $1.(*Query), Op: $2, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: $2, Right: $3.(*Query), Offset: $<offset>2}
     }
     | query '+' query
     {
-        $$ = &Query{Left: $1.(*Query), Op: OpAdd, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: OpAdd, Right: $3.(*Query), Offset: $<offset>2}
     }
     | query '-' query
     {
-        $$ = &Query{Left: $1.(*Query), Op: OpSub, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: OpSub, Right: $3.(*Query), Offset: $<offset>2}
     }
     | query '*' query
     {
-        $$ = &Query{Left: $1.(*Query), Op: OpMul, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: OpMul, Right: $3.(*Query), Offset: $<offset>2}
     }
     | query '/' query
     {
-        $$ = &Query{Left: $1.(*Query), Op: OpDiv, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: OpDiv, Right: $3.(*Query), Offset: $<offset>2}
     }
     | query '%' query
     {
-        $$ = &Query{Left: $1.(*Query), Op: OpMod, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: OpMod, Right: $3.(*Query), Offset: $<offset>2}
     }
     | term %prec tokTermPost
     {
@@ -298,99 +333,99 @@ objectpattern
 term
     : '.'
     {
-        $$ = &Term{Type: TermTypeIdentity}
+        $$ = &Term{Type: TermTypeIdentity, Offset: $<offset>1}
     }
     | tokRecurse
     {
-        $$ = &Term{Type: TermTypeRecurse}
+        $$ = &Term{Type: TermTypeRecurse, Offset: $<offset>1}
     }
     | tokIndex
     {
-        $$ = &Term{Type: TermTypeIndex, Index: &Index{Name: $1}}
+        $$ = newIndexTerm(Index{Name: $1, Offset: $<offset>1})
     }
     | '.' suffix
     {
         if $2.(*Suffix).Iter {
-            $$ = &Term{Type: TermTypeIdentity, SuffixList: []*Suffix{$2.(*Suffix)}}
+            $$ = &Term{Type: TermTypeIdentity, SuffixList: []*Suffix{$2.(*Suffix)}, Offset: $<offset>1}
         } else {
-            $$ = &Term{Type: TermTypeIndex, Index: $2.(*Suffix).Index}
+            $$ = &Term{Type: TermTypeIndex, Index: $2.(*Suffix).Index, Offset: $<offset>1}
         }
     }
     | '.' string
     {
-        $$ = &Term{Type: TermTypeIndex, Index: &Index{Str: $2.(*String)}}
+        $$ = newIndexTerm(Index{Str: $2.(*String), Offset: $<offset>1})
     }
     | tokNull
     {
-        $$ = &Term{Type: TermTypeNull}
+        $$ = &Term{Type: TermTypeNull, Offset: $<offset>1}
     }
     | tokTrue
     {
-        $$ = &Term{Type: TermTypeTrue}
+        $$ = &Term{Type: TermTypeTrue, Offset: $<offset>1}
     }
     | tokFalse
     {
-        $$ = &Term{Type: TermTypeFalse}
+        $$ = &Term{Type: TermTypeFalse, Offset: $<offset>1}
     }
     | tokIdentModuleIdent
     {
-        $$ = &Term{Type: TermTypeFunc, Func: &Func{Name: $1}}
+        $$ = newFuncTerm(Func{Name: $1}, $<offset>1)
     }
     | tokIdentModuleIdent '(' args ')'
     {
-        $$ = &Term{Type: TermTypeFunc, Func: &Func{Name: $1, Args: $3.([]*Query)}}
+        $$ = newFuncTerm(Func{Name: $1, Args: $3.([]*Query)}, $<offset>1)
     }
     | tokVariableModuleVariable
     {
-        $$ = &Term{Type: TermTypeFunc, Func: &Func{Name: $1}}
+        $$ = newFuncTerm(Func{Name: $1}, $<offset>1)
     }
     | tokNumber
     {
-        $$ = &Term{Type: TermTypeNumber, Number: $1}
+        $$ = &Term{Type: TermTypeNumber, Number: $1, Offset: $<offset>1}
     }
     | tokFormat
     {
-        $$ = &Term{Type: TermTypeFormat, Format: $1}
+        $$ = &Term{Type: TermTypeFormat, Format: $1, Offset: $<offset>1}
     }
     | tokFormat string
     {
-        $$ = &Term{Type: TermTypeFormat, Format: $1, Str: $2.(*String)}
+        $$ = &Term{Type: TermTypeFormat, Format: $1, Str: $2.(*String), Offset: $<offset>1}
     }
     | string
     {
-        $$ = &Term{Type: TermTypeString, Str: $1.(*String)}
+        $$ = &Term{Type: TermTypeString, Str: $1.(*String), Offset: $<offset>1}
     }
     | '(' query ')'
     {
-        $$ = &Term{Type: TermTypeQuery, Query: $2.(*Query)}
+        $$ = &Term{Type: TermTypeQuery, Query: $2.(*Query), Offset: $<offset>1}
     }
     | '-' term
     {
-        $$ = &Term{Type: TermTypeUnary, Unary: &Unary{OpSub, $2.(*Term)}}
+        $$ = &Term{Type: TermTypeUnary, Unary: &Unary{OpSub, $2.(*Term)}, Offset: $<offset>1}
     }
     | '+' term
     {
-        $$ = &Term{Type: TermTypeUnary, Unary: &Unary{OpAdd, $2.(*Term)}}
+        $$ = &Term{Type: TermTypeUnary, Unary: &Unary{OpAdd, $2.(*Term)}, Offset: $<offset>1}
     }
     | '{' object '}'
     {
-        $$ = &Term{Type: TermTypeObject, Object: &Object{$2.([]*ObjectKeyVal)}}
+        $$ = &Term{Type: TermTypeObject, Object: &Object{$2.([]*ObjectKeyVal)}, Offset: $<offset>1}
     }
     | '[' query ']'
     {
-        $$ = &Term{Type: TermTypeArray, Array: &Array{$2.(*Query)}}
+        $$ = &Term{Type: TermTypeArray, Array: &Array{$2.(*Query)}, Offset: $<offset>1}
     }
     | '[' ']'
     {
-        $$ = &Term{Type: TermTypeArray, Array: &Array{}}
+        $$ = &Term{Type: TermTypeArray, Array: &Array{}, Offset: $<offset>1}
     }
     | tokBreak tokVariable
     {
-        $$ = &Term{Type: TermTypeBreak, Break: $2}
+        $$ = &Term{Type: TermTypeBreak, Break: $2, Offset: $<offset>1}
     }
     | term tokIndex
     {
-        $1.(*Term).SuffixList = append($1.(*Term).SuffixList, &Suffix{Index: &Index{Name: $2}})
+        $1.(*Term).SuffixList = append($1.(*Term).SuffixList, &Suffix{Index: &Index{Name: $2, Offset: $<offset>2}})
     }
     | term suffix
     {
@@ -406,7 +441,7 @@ term
     }
     | term '.' string
     {
-        $1.(*Term).SuffixList = append($1.(*Term).SuffixList, &Suffix{Index: &Index{Str: $3.(*String)}})
+        $1.(*Term).SuffixList = append($1.(*Term).SuffixList, &Suffix{Index: &Index{Str: $3.(*String), Offset: $<offset>2}})
     }
 
 string
@@ -449,19 +484,19 @@ suffix
     }
     | '[' query ']'
     {
-        $$ = &Suffix{Index: &Index{Start: $2.(*Query)}}
+        $$ = &Suffix{Index: &Index{Start: $2.(*Query), Offset: $<offset>1}}
     }
     | '[' query ':' ']'
     {
-        $$ = &Suffix{Index: &Index{Start: $2.(*Query), IsSlice: true}}
+        $$ = &Suffix{Index: &Index{Start: $2.(*Query), IsSlice: true, Offset: $<offset>1}}
     }
     | '[' ':' query ']'
     {
-        $$ = &Suffix{Index: &Index{End: $3.(*Query)}}
+        $$ = &Suffix{Index: &Index{End: $3.(*Query), Offset: $<offset>1}}
     }
     | '[' query ':' query ']'
     {
-        $$ = &Suffix{Index: &Index{Start: $2.(*Query), IsSlice: true, End: $4.(*Query)}}
+        $$ = &Suffix{Index: &Index{Start: $2.(*Query), IsSlice: true, End: $4.(*Query), Offset: $<offset>1}}
     }
 
 args
@@ -0,0 +1,86 @@
+package gojq
+
+// pathGlob matches the paths against the pattern, where "*" matches any key or
+// index at one level and "**" matches zero or more levels.
+type pathGlob struct {
+	paths []interface{}
+	seen  map[string]struct{}
+}
+
+func (g *pathGlob) match(v interface{}, path []interface{}, pattern []interface{}) {
+	if len(pattern) == 0 {
+		key := jsonMarshal(path)
+		if _, ok := g.seen[key]; !ok {
+			g.seen[key] = struct{}{}
+			g.paths = append(g.paths, path)
+		}
+		return
+	}
+	switch p := pattern[0].(type) {
+	case string:
+		switch p {
+		case "**":
+			g.match(v, path, pattern[1:])
+			g.children(v, path, pattern)
+		case "*":
+			g.children(v, path, pattern[1:])
+		default:
+			switch v := v.(type) {
+			case map[string]interface{}:
+				if w, ok := v[p]; ok {
+					g.match(w, appendPath(path, p), pattern[1:])
+				}
+			case *OrderedMap:
+				if w, ok := v.values[p]; ok {
+					g.match(w, appendPath(path, p), pattern[1:])
+				}
+			}
+		}
+	case int:
+		if v, ok := v.([]interface{}); ok && 0 <= p && p < len(v) {
+			g.match(v[p], appendPath(path, p), pattern[1:])
+		}
+	}
+}
+
+func (g *pathGlob) children(v interface{}, path []interface{}, pattern []interface{}) {
+	switch v := v.(type) {
+	case []interface{}:
+		for i, w := range v {
+			g.match(w, appendPath(path, i), pattern)
+		}
+	case map[string]interface{}:
+		for _, k := range sortedKeys(v) {
+			g.match(v[k], appendPath(path, k), pattern)
+		}
+	case *OrderedMap:
+		for _, k := range v.keys {
+			g.match(v.values[k], appendPath(path, k), pattern)
+		}
+	}
+}
+
+func appendPath(path []interface{}, k interface{}) []interface{} {
+	p := make([]interface{}, len(path)+1)
+	copy(p, path)
+	p[len(path)] = k
+	return p
+}
+
+func funcPathsMatching(v, x interface{}) interface{} {
+	xs, ok := x.([]interface{})
+	if !ok {
+		return &funcTypeError{"paths_matching", x}
+	}
+	pattern := make([]interface{}, len(xs))
+	for i, x := range xs {
+		if _, ok := x.(string); ok {
+			pattern[i] = x
+		} else if pattern[i], ok = toInt(x); !ok {
+			return &funcTypeError{"paths_matching", xs}
+		}
+	}
+	g := &pathGlob{[]interface{}{}, map[string]struct{}{}}
+	g.match(v, []interface{}{}, pattern)
+	return g.paths
+}
@@ -0,0 +1,1286 @@
+package gojq
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// pcreRegexp is a backtracking regular expression engine, enabled by the P flag
+// of the regular expression functions. In addition to the syntax of the regexp
+// package, it supports lookahead and lookbehind assertions, atomic groups,
+// possessive quantifiers and backreferences. Lookbehind assertions may have
+// variable length. Note that the matching time is exponential in the worst
+// case, unlike the regexp package, so the number of backtracking is limited.
+type pcreRegexp struct {
+	expr    string
+	prog    []pcreInst
+	repeats int
+	names   []string
+}
+
+type pcreOp int
+
+const (
+	pcreOpEmpty pcreOp = iota
+	pcreOpLiteral
+	pcreOpClass
+	pcreOpAnyChar
+	pcreOpAnyCharNotNL
+	pcreOpBeginLine
+	pcreOpEndLine
+	pcreOpBeginText
+	pcreOpEndText
+	pcreOpEndTextNL
+	pcreOpWordBoundary
+	pcreOpNoWordBoundary
+	pcreOpConcat
+	pcreOpAlternate
+	pcreOpCapture
+	pcreOpRepeat
+	pcreOpAtomic
+	pcreOpLookahead
+	pcreOpLookbehind
+	pcreOpBackref
+)
+
+type pcreNode struct {
+	op       pcreOp
+	r        rune
+	class    *pcreClass
+	fold     bool // case insensitive literal and backreference
+	negate   bool // negative lookaround
+	greedy   bool
+	min, max int // max < 0 for unbounded repeat
+	index    int // capture group index of capture and backreference
+	subs     []*pcreNode
+}
+
+type pcreClass struct {
+	ranges []rune // pairs of lower and upper bounds
+	tables []*unicode.RangeTable
+	subs   []*pcreClass
+	negate bool
+}
+
+func (c *pcreClass) matches(r rune, fold bool) bool {
+	if c.contains(r) {
+		return !c.negate
+	}
+	if fold {
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			if c.contains(f) {
+				return !c.negate
+			}
+		}
+	}
+	return c.negate
+}
+
+func (c *pcreClass) contains(r rune) bool {
+	for i := 0; i < len(c.ranges); i += 2 {
+		if c.ranges[i] <= r && r <= c.ranges[i+1] {
+			return true
+		}
+	}
+	for _, t := range c.tables {
+		if unicode.Is(t, r) {
+			return true
+		}
+	}
+	for _, c := range c.subs {
+		if c.matches(r, false) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	pcreDigitClass = &pcreClass{ranges: []rune{'0', '9'}}
+	pcreWordClass  = &pcreClass{ranges: []rune{'0', '9', 'A', 'Z', '_', '_', 'a', 'z'}}
+	pcreSpaceClass = &pcreClass{ranges: []rune{'\t', '\n', '\f', '\r', ' ', ' '}}
+)
+
+var pcrePOSIXClasses = map[string]*pcreClass{
+	"alnum":  {ranges: []rune{'0', '9', 'A', 'Z', 'a', 'z'}},
+	"alpha":  {ranges: []rune{'A', 'Z', 'a', 'z'}},
+	"ascii":  {ranges: []rune{0, 0x7f}},
+	"blank":  {ranges: []rune{'\t', '\t', ' ', ' '}},
+	"cntrl":  {ranges: []rune{0, 0x1f, 0x7f, 0x7f}},
+	"digit":  pcreDigitClass,
+	"graph":  {ranges: []rune{'!', '~'}},
+	"lower":  {ranges: []rune{'a', 'z'}},
+	"print":  {ranges: []rune{' ', '~'}},
+	"punct":  {ranges: []rune{'!', '/', ':', '@', '[', '`', '{', '~'}},
+	"space":  {ranges: []rune{'\t', '\r', ' ', ' '}},
+	"upper":  {ranges: []rune{'A', 'Z'}},
+	"word":   pcreWordClass,
+	"xdigit": {ranges: []rune{'0', '9', 'A', 'F', 'a', 'f'}},
+}
+
+func compilePCRE(expr string, fold, dotall bool) (*pcreRegexp, error) {
+	p := &pcreParser{src: expr, fold: fold, dotall: dotall, names: []string{""}}
+	node, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	c := &pcreCompiler{}
+	c.compile(node)
+	c.append(pcreInst{op: pcreInstMatch})
+	return &pcreRegexp{expr, c.insts, c.repeats, p.names}, nil
+}
+
+type pcreParser struct {
+	src               string
+	pos               int
+	fold, dotall      bool
+	multiline         bool
+	names             []string
+	backrefs          []int
+	namedBackrefs     []string
+	namedBackrefNodes []*pcreNode
+}
+
+func (p *pcreParser) parse() (*pcreNode, error) {
+	node, err := p.parseAlternate()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.src) {
+		return nil, errors.New("unexpected )")
+	}
+	for _, i := range p.backrefs {
+		if i >= len(p.names) {
+			return nil, errors.New("invalid backreference: \\" + strconv.Itoa(i))
+		}
+	}
+	for i, name := range p.namedBackrefs {
+		index := -1
+		for j, n := range p.names {
+			if n == name {
+				index = j
+			}
+		}
+		if index < 0 {
+			return nil, errors.New("invalid backreference: \\k<" + name + ">")
+		}
+		p.namedBackrefNodes[i].index = index
+	}
+	return node, nil
+}
+
+func (p *pcreParser) parseAlternate() (*pcreNode, error) {
+	var subs []*pcreNode
+	for {
+		node, err := p.parseConcat()
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, node)
+		if p.pos >= len(p.src) || p.src[p.pos] != '|' {
+			break
+		}
+		p.pos++
+	}
+	if len(subs) == 1 {
+		return subs[0], nil
+	}
+	return &pcreNode{op: pcreOpAlternate, subs: subs}, nil
+}
+
+func (p *pcreParser) parseConcat() (*pcreNode, error) {
+	var subs []*pcreNode
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '|' || c == ')' {
+			break
+		}
+		switch c {
+		case '*', '+', '?':
+			return nil, errors.New("missing argument to repetition operator: " + string(c))
+		case '{':
+			if _, _, ok := p.peekRepeat(); ok {
+				return nil, errors.New("missing argument to repetition operator: {")
+			}
+		}
+		node, err := p.parseAtom()
+		if err != nil {
+			return nil, err
+		}
+		if node == nil {
+			continue
+		}
+		if node, err = p.parseQuantifier(node); err != nil {
+			return nil, err
+		}
+		subs = append(subs, node)
+	}
+	switch len(subs) {
+	case 0:
+		return &pcreNode{op: pcreOpEmpty}, nil
+	case 1:
+		return subs[0], nil
+	default:
+		return &pcreNode{op: pcreOpConcat, subs: subs}, nil
+	}
+}
+
+func (p *pcreParser) parseQuantifier(node *pcreNode) (*pcreNode, error) {
+	for p.pos < len(p.src) {
+		var min, max int
+		switch p.src[p.pos] {
+		case '*':
+			min, max = 0, -1
+			p.pos++
+		case '+':
+			min, max = 1, -1
+			p.pos++
+		case '?':
+			min, max = 0, 1
+			p.pos++
+		case '{':
+			var ok bool
+			if min, max, ok = p.peekRepeat(); !ok {
+				return node, nil
+			}
+			i := strings.IndexByte(p.src[p.pos:], '}') + 1
+			if min > 1000 || max > 1000 || max >= 0 && min > max {
+				return nil, errors.New("invalid repeat count: " + p.src[p.pos:p.pos+i])
+			}
+			p.pos += i
+		default:
+			return node, nil
+		}
+		node = &pcreNode{op: pcreOpRepeat, min: min, max: max, greedy: true, subs: []*pcreNode{node}}
+		if p.pos < len(p.src) {
+			switch p.src[p.pos] {
+			case '?':
+				node.greedy = false
+				p.pos++
+			case '+':
+				node = &pcreNode{op: pcreOpAtomic, subs: []*pcreNode{node}}
+				p.pos++
+			}
+		}
+	}
+	return node, nil
+}
+
+// peekRepeat parses the repeat count of {n}, {n,} or {n,m} without consuming.
+func (p *pcreParser) peekRepeat() (int, int, bool) {
+	s := p.src[p.pos:]
+	i := strings.IndexByte(s, '}')
+	if i < 0 {
+		return 0, 0, false
+	}
+	s = s[1:i]
+	min, max := s, s
+	if j := strings.IndexByte(s, ','); j >= 0 {
+		min, max = s[:j], s[j+1:]
+	}
+	m, err := strconv.Atoi(min)
+	if err != nil || m < 0 || min[0] == '+' {
+		return 0, 0, false
+	}
+	if max == "" {
+		return m, -1, true
+	}
+	n, err := strconv.Atoi(max)
+	if err != nil || n < 0 || max[0] == '+' {
+		return 0, 0, false
+	}
+	return m, n, true
+}
+
+func (p *pcreParser) parseAtom() (*pcreNode, error) {
+	r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += size
+	switch r {
+	case '(':
+		return p.parseGroup()
+	case '[':
+		c, err := p.parseClass()
+		if err != nil {
+			return nil, err
+		}
+		return &pcreNode{op: pcreOpClass, class: c, fold: p.fold}, nil
+	case '.':
+		if p.dotall {
+			return &pcreNode{op: pcreOpAnyChar}, nil
+		}
+		return &pcreNode{op: pcreOpAnyCharNotNL}, nil
+	case '^':
+		if p.multiline {
+			return &pcreNode{op: pcreOpBeginLine}, nil
+		}
+		return &pcreNode{op: pcreOpBeginText}, nil
+	case '$':
+		if p.multiline {
+			return &pcreNode{op: pcreOpEndLine}, nil
+		}
+		return &pcreNode{op: pcreOpEndText}, nil
+	case '\\':
+		return p.parseEscape()
+	default:
+		return &pcreNode{op: pcreOpLiteral, r: r, fold: p.fold}, nil
+	}
+}
+
+func (p *pcreParser) parseGroup() (*pcreNode, error) {
+	fold, dotall, multiline := p.fold, p.dotall, p.multiline
+	defer func() { p.fold, p.dotall, p.multiline = fold, dotall, multiline }()
+	node := &pcreNode{op: pcreOpCapture}
+	if strings.HasPrefix(p.src[p.pos:], "?") {
+		p.pos++
+		switch {
+		case strings.HasPrefix(p.src[p.pos:], ":"):
+			node = nil
+		case strings.HasPrefix(p.src[p.pos:], ">"):
+			node = &pcreNode{op: pcreOpAtomic}
+		case strings.HasPrefix(p.src[p.pos:], "="):
+			node = &pcreNode{op: pcreOpLookahead}
+		case strings.HasPrefix(p.src[p.pos:], "!"):
+			node = &pcreNode{op: pcreOpLookahead, negate: true}
+		case strings.HasPrefix(p.src[p.pos:], "<="):
+			node = &pcreNode{op: pcreOpLookbehind}
+			p.pos++
+		case strings.HasPrefix(p.src[p.pos:], "<!"):
+			node = &pcreNode{op: pcreOpLookbehind, negate: true}
+			p.pos++
+		case strings.HasPrefix(p.src[p.pos:], "<"), strings.HasPrefix(p.src[p.pos:], "P<"):
+			if p.src[p.pos] == 'P' {
+				p.pos++
+			}
+			i := strings.IndexByte(p.src[p.pos:], '>')
+			if i < 0 || !isPCREGroupName(p.src[p.pos+1:p.pos+i]) {
+				return nil, errors.New("invalid named capture: " + p.src[p.pos:])
+			}
+			name := p.src[p.pos+1 : p.pos+i]
+			for _, n := range p.names {
+				if n == name {
+					return nil, errors.New("duplicate capture group name: " + name)
+				}
+			}
+			node.index = len(p.names)
+			p.names = append(p.names, name)
+			p.pos += i
+		default:
+			if err := p.parseFlags(); err != nil {
+				return nil, err
+			}
+			if p.src[p.pos] == ')' {
+				p.pos++
+				// the flags are effective until the end of the enclosing group
+				fold, dotall, multiline = p.fold, p.dotall, p.multiline
+				return nil, nil
+			}
+			node = nil
+		}
+		p.pos++
+	} else {
+		node.index = len(p.names)
+		p.names = append(p.names, "")
+	}
+	sub, err := p.parseAlternate()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos >= len(p.src) {
+		return nil, errors.New("missing closing )")
+	}
+	p.pos++
+	if node == nil {
+		return sub, nil
+	}
+	node.subs = []*pcreNode{sub}
+	return node, nil
+}
+
+func isPCREGroupName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseFlags parses the flags of (?flags) and (?flags:re) until ')' or ':'.
+func (p *pcreParser) parseFlags() error {
+	start, enable := p.pos, true
+loop:
+	for ; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case 'i':
+			p.fold = enable
+		case 's':
+			p.dotall = enable
+		case 'm':
+			p.multiline = enable
+		case '-':
+			if !enable {
+				break loop
+			}
+			enable = false
+		case ')', ':':
+			if p.pos > start && p.src[p.pos-1] != '-' {
+				return nil
+			}
+			break loop
+		default:
+			break loop
+		}
+	}
+	end := strings.IndexByte(p.src[start:], ')')
+	if end < 0 {
+		return errors.New("missing closing )")
+	}
+	return errors.New("invalid or unsupported group: (?" + p.src[start:start+end+1])
+}
+
+func (p *pcreParser) parseEscape() (*pcreNode, error) {
+	if p.pos >= len(p.src) {
+		return nil, errors.New("trailing backslash at end of expression")
+	}
+	r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+	switch r {
+	case 'A':
+		p.pos++
+		return &pcreNode{op: pcreOpBeginText}, nil
+	case 'z':
+		p.pos++
+		return &pcreNode{op: pcreOpEndText}, nil
+	case 'Z':
+		p.pos++
+		return &pcreNode{op: pcreOpEndTextNL}, nil
+	case 'b':
+		p.pos++
+		return &pcreNode{op: pcreOpWordBoundary}, nil
+	case 'B':
+		p.pos++
+		return &pcreNode{op: pcreOpNoWordBoundary}, nil
+	case 'k':
+		p.pos++
+		if !strings.HasPrefix(p.src[p.pos:], "<") {
+			return nil, errors.New("invalid escape sequence: \\k")
+		}
+		i := strings.IndexByte(p.src[p.pos:], '>')
+		if i < 0 {
+			return nil, errors.New("invalid backreference: \\k" + p.src[p.pos:])
+		}
+		name := p.src[p.pos+1 : p.pos+i]
+		p.pos += i + 1
+		node := &pcreNode{op: pcreOpBackref, fold: p.fold}
+		if index, err := strconv.Atoi(name); err == nil {
+			p.backrefs = append(p.backrefs, index)
+			node.index = index
+		} else {
+			p.namedBackrefs = append(p.namedBackrefs, name)
+			p.namedBackrefNodes = append(p.namedBackrefNodes, node)
+		}
+		return node, nil
+	}
+	if '1' <= r && r <= '9' {
+		i := p.pos
+		for i < len(p.src) && '0' <= p.src[i] && p.src[i] <= '9' {
+			i++
+		}
+		index, _ := strconv.Atoi(p.src[p.pos:i])
+		p.pos = i
+		p.backrefs = append(p.backrefs, index)
+		return &pcreNode{op: pcreOpBackref, index: index, fold: p.fold}, nil
+	}
+	r, c, err := p.parseClassEscape()
+	if err != nil {
+		return nil, err
+	}
+	if c != nil {
+		return &pcreNode{op: pcreOpClass, class: c, fold: p.fold}, nil
+	}
+	return &pcreNode{op: pcreOpLiteral, r: r, fold: p.fold}, nil
+}
+
+// parseClassEscape parses the escape sequence after the backslash, which is
+// available in the character classes. It returns either a rune or a class.
+func (p *pcreParser) parseClassEscape() (rune, *pcreClass, error) {
+	r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += size
+	switch r {
+	case 'd':
+		return 0, pcreDigitClass, nil
+	case 'D':
+		return 0, &pcreClass{subs: []*pcreClass{pcreDigitClass}, negate: true}, nil
+	case 'w':
+		return 0, pcreWordClass, nil
+	case 'W':
+		return 0, &pcreClass{subs: []*pcreClass{pcreWordClass}, negate: true}, nil
+	case 's':
+		return 0, pcreSpaceClass, nil
+	case 'S':
+		return 0, &pcreClass{subs: []*pcreClass{pcreSpaceClass}, negate: true}, nil
+	case 'p', 'P':
+		name := p.src[p.pos:]
+		if strings.HasPrefix(name, "{") {
+			i := strings.IndexByte(name, '}')
+			if i < 0 {
+				return 0, nil, errors.New("invalid character class range: \\" + string(r) + name)
+			}
+			name = name[1:i]
+			p.pos += i + 1
+		} else if name != "" {
+			_, size := utf8.DecodeRuneInString(name)
+			name = name[:size]
+			p.pos += size
+		}
+		negate := r == 'P'
+		if strings.HasPrefix(name, "^") {
+			name, negate = name[1:], !negate
+		}
+		t := unicode.Categories[name]
+		if t == nil {
+			if t = unicode.Scripts[name]; t == nil && name != "Any" {
+				return 0, nil, errors.New("invalid character class range: \\" + string(r) + "{" + name + "}")
+			}
+		}
+		if t == nil {
+			return 0, &pcreClass{ranges: []rune{0, unicode.MaxRune}, negate: negate}, nil
+		}
+		return 0, &pcreClass{tables: []*unicode.RangeTable{t}, negate: negate}, nil
+	case 'n':
+		return '\n', nil, nil
+	case 't':
+		return '\t', nil, nil
+	case 'r':
+		return '\r', nil, nil
+	case 'f':
+		return '\f', nil, nil
+	case 'v':
+		return '\v', nil, nil
+	case 'a':
+		return '\a', nil, nil
+	case 'e':
+		return '\x1b', nil, nil
+	case '0':
+		return 0, nil, nil
+	case 'x', 'u':
+		s := p.src[p.pos:]
+		var n int
+		if strings.HasPrefix(s, "{") {
+			n = strings.IndexByte(s, '}')
+			if n < 0 {
+				return 0, nil, errors.New("invalid escape sequence: \\" + string(r) + s)
+			}
+			s, n = s[1:n], n+1
+		} else {
+			n = 2
+			if r == 'u' {
+				n = 4
+			}
+			if len(s) < n {
+				return 0, nil, errors.New("invalid escape sequence: \\" + string(r) + s)
+			}
+			s = s[:n]
+		}
+		x, err := strconv.ParseUint(s, 16, 32)
+		if err != nil || x > unicode.MaxRune {
+			return 0, nil, errors.New("invalid escape sequence: \\" + string(r) + p.src[p.pos:p.pos+n])
+		}
+		p.pos += n
+		return rune(x), nil, nil
+	}
+	if r < utf8.RuneSelf && !('0' <= r && r <= '9' || 'A' <= r && r <= 'Z' || 'a' <= r && r <= 'z') {
+		return r, nil, nil
+	}
+	return 0, nil, errors.New("invalid escape sequence: \\" + string(r))
+}
+
+func (p *pcreParser) parseClass() (*pcreClass, error) {
+	start := p.pos - 1
+	c := &pcreClass{}
+	if strings.HasPrefix(p.src[p.pos:], "^") {
+		c.negate = true
+		p.pos++
+	}
+	for first := true; ; first = false {
+		if p.pos >= len(p.src) {
+			return nil, errors.New("missing closing ]: " + p.src[start:])
+		}
+		if p.src[p.pos] == ']' && !first {
+			p.pos++
+			return c, nil
+		}
+		if strings.HasPrefix(p.src[p.pos:], "[:") {
+			if i := strings.Index(p.src[p.pos:], ":]"); i > 0 {
+				name, negate := p.src[p.pos+2:p.pos+i], false
+				if strings.HasPrefix(name, "^") {
+					name, negate = name[1:], true
+				}
+				x, ok := pcrePOSIXClasses[name]
+				if !ok {
+					return nil, errors.New("invalid character class range: " + p.src[p.pos:p.pos+i+2])
+				}
+				if negate {
+					x = &pcreClass{subs: []*pcreClass{x}, negate: true}
+				}
+				c.subs = append(c.subs, x)
+				p.pos += i + 2
+				continue
+			}
+		}
+		if strings.HasPrefix(p.src[p.pos:], "[") {
+			p.pos++
+			x, err := p.parseClass()
+			if err != nil {
+				return nil, err
+			}
+			c.subs = append(c.subs, x)
+			continue
+		}
+		lo, x, err := p.parseClassRune()
+		if err != nil {
+			return nil, err
+		}
+		if x != nil {
+			c.subs = append(c.subs, x)
+			continue
+		}
+		hi := lo
+		if strings.HasPrefix(p.src[p.pos:], "-") && !strings.HasPrefix(p.src[p.pos:], "-]") {
+			p.pos++
+			if p.pos >= len(p.src) {
+				return nil, errors.New("missing closing ]: " + p.src[start:])
+			}
+			if hi, x, err = p.parseClassRune(); err != nil {
+				return nil, err
+			}
+			if x != nil || hi < lo {
+				return nil, errors.New("invalid character class range: " + p.src[start:p.pos])
+			}
+		}
+		c.ranges = append(c.ranges, lo, hi)
+	}
+}
+
+func (p *pcreParser) parseClassRune() (rune, *pcreClass, error) {
+	r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += size
+	if r != '\\' {
+		return r, nil, nil
+	}
+	if p.pos >= len(p.src) {
+		return 0, nil, errors.New("trailing backslash at end of expression")
+	}
+	if p.src[p.pos] == 'b' {
+		p.pos++
+		return '\b', nil, nil
+	}
+	return p.parseClassEscape()
+}
+
+// SubexpNames returns the names of the capture groups, like regexp.Regexp.
+func (re *pcreRegexp) SubexpNames() []string {
+	return re.names
+}
+
+// FindStringSubmatchIndex returns the indices of the leftmost match and the
+// capture groups, like regexp.Regexp.
+func (re *pcreRegexp) FindStringSubmatchIndex(ctx context.Context, s string) ([]int, error) {
+	return re.newMatcher(ctx, s).find(0)
+}
+
+// FindAllStringSubmatchIndex returns the indices of the successive matches,
+// like regexp.Regexp.
+func (re *pcreRegexp) FindAllStringSubmatchIndex(ctx context.Context, s string, n int) ([][]int, error) {
+	var xs [][]int
+	m := re.newMatcher(ctx, s)
+	for pos, prevEnd := 0, -1; (n < 0 || len(xs) < n) && pos <= len(s); {
+		x, err := m.find(pos)
+		if err != nil {
+			return nil, err
+		}
+		if x == nil {
+			break
+		}
+		accept := true
+		if x[1] == pos {
+			// an empty match right after the previous match is ignored
+			accept = x[0] != prevEnd
+			if _, size := utf8.DecodeRuneInString(s[pos:]); size > 0 {
+				pos += size
+			} else {
+				pos++
+			}
+		} else {
+			pos = x[1]
+		}
+		prevEnd = x[1]
+		if accept {
+			xs = append(xs, x)
+		}
+	}
+	return xs, nil
+}
+
+// Split slices the string into the substrings separated by the matches, like
+// regexp.Regexp.
+func (re *pcreRegexp) Split(ctx context.Context, s string, n int) ([]string, error) {
+	if n == 0 {
+		return nil, nil
+	}
+	if re.expr != "" && s == "" {
+		return []string{""}, nil
+	}
+	xs, err := re.FindAllStringSubmatchIndex(ctx, s, n)
+	if err != nil {
+		return nil, err
+	}
+	var ss []string
+	var begin, end int
+	for _, x := range xs {
+		if n > 0 && len(ss) == n-1 {
+			break
+		}
+		end = x[0]
+		if x[1] != 0 {
+			ss = append(ss, s[begin:end])
+		}
+		begin = x[1]
+	}
+	if end != len(s) {
+		ss = append(ss, s[begin:])
+	}
+	return ss, nil
+}
+
+// pcreBacktrackLimit is the maximum number of backtracking in a search, like
+// the match limit of PCRE.
+const pcreBacktrackLimit = 10000000
+
+type pcreBacktrackLimitError struct {
+	expr string
+}
+
+func (err *pcreBacktrackLimitError) Error() string {
+	return "backtracking limit exceeded in regular expression: " + strconv.Quote(err.expr)
+}
+
+type pcreInstOp int
+
+const (
+	pcreInstMatch pcreInstOp = iota
+	pcreInstRune
+	pcreInstRuneRepeat
+	pcreInstAssert
+	pcreInstBackref
+	pcreInstSplit
+	pcreInstJump
+	pcreInstCaptureStart
+	pcreInstCaptureEnd
+	pcreInstRepeatInit
+	pcreInstRepeatLoop
+	pcreInstRepeatBody
+	pcreInstRepeatEnd
+	pcreInstLookStart
+	pcreInstLookEnd
+)
+
+// pcreInst is an instruction of the compiled program. The index is the capture
+// group of capture and the repeat counter of repeat instructions, and x and y
+// are the jump targets.
+type pcreInst struct {
+	op    pcreInstOp
+	node  *pcreNode
+	index int
+	x, y  int
+}
+
+type pcreCompiler struct {
+	insts   []pcreInst
+	repeats int
+}
+
+func (c *pcreCompiler) append(inst pcreInst) int {
+	c.insts = append(c.insts, inst)
+	return len(c.insts) - 1
+}
+
+func (c *pcreCompiler) compile(n *pcreNode) {
+	switch n.op {
+	case pcreOpEmpty:
+	case pcreOpLiteral, pcreOpClass, pcreOpAnyChar, pcreOpAnyCharNotNL:
+		c.append(pcreInst{op: pcreInstRune, node: n})
+	case pcreOpConcat:
+		for _, n := range n.subs {
+			c.compile(n)
+		}
+	case pcreOpAlternate:
+		var jumps []int
+		for i, sub := range n.subs {
+			if i == len(n.subs)-1 {
+				c.compile(sub)
+				break
+			}
+			split := c.append(pcreInst{op: pcreInstSplit})
+			c.insts[split].x = split + 1
+			c.compile(sub)
+			jumps = append(jumps, c.append(pcreInst{op: pcreInstJump}))
+			c.insts[split].y = len(c.insts)
+		}
+		for _, i := range jumps {
+			c.insts[i].x = len(c.insts)
+		}
+	case pcreOpCapture:
+		c.append(pcreInst{op: pcreInstCaptureStart, index: n.index})
+		c.compile(n.subs[0])
+		c.append(pcreInst{op: pcreInstCaptureEnd, index: n.index})
+	case pcreOpRepeat:
+		if isPCRESingleRune(n.subs[0]) {
+			c.append(pcreInst{op: pcreInstRuneRepeat, node: n})
+			break
+		}
+		index := c.repeats
+		c.repeats++
+		c.append(pcreInst{op: pcreInstRepeatInit, index: index})
+		loop := c.append(pcreInst{op: pcreInstRepeatLoop, node: n, index: index})
+		c.append(pcreInst{op: pcreInstRepeatBody, index: index})
+		c.compile(n.subs[0])
+		end := c.append(pcreInst{op: pcreInstRepeatEnd, node: n, index: index, x: loop})
+		c.insts[loop].x, c.insts[loop].y = loop+1, end+1
+		c.insts[end].y = end + 1
+	case pcreOpAtomic, pcreOpLookahead, pcreOpLookbehind:
+		start := c.append(pcreInst{op: pcreInstLookStart, node: n})
+		c.compile(n.subs[0])
+		c.insts[start].x = c.append(pcreInst{op: pcreInstLookEnd, node: n}) + 1
+	case pcreOpBackref:
+		c.append(pcreInst{op: pcreInstBackref, node: n})
+	default:
+		c.append(pcreInst{op: pcreInstAssert, node: n})
+	}
+}
+
+type pcreEntryKind int
+
+const (
+	// restores the start position of the capture group pc to x
+	pcreEntryCaptureStart pcreEntryKind = iota
+	// restores the indices of the capture group pc to x and y
+	pcreEntryCapture
+	// restores the count and start of the repeat counter pc to x and y
+	pcreEntryCounter
+	// resumes at pc with pos
+	pcreEntryChoice
+	// restores the repeat counter of the loop at pc to x and y, and resumes at
+	// the exit of the loop with pos
+	pcreEntryRepeat
+	// resumes at pc with pos shorter by one character, until pos reaches x
+	pcreEntryRuneGreedy
+	// resumes after the repeat at pc with pos longer by one character, where
+	// x is the current count
+	pcreEntryRuneLazy
+	// resumes at pc with the lookbehind starting one character earlier
+	pcreEntryLookbehind
+	// the barrier of the lookaround or atomic group started at pc with pos
+	pcreEntryBarrier
+)
+
+type pcreEntry struct {
+	kind    pcreEntryKind
+	pc, pos int
+	x, y    int
+}
+
+// pcreMatcher runs the compiled program with an explicit backtracking stack,
+// so that the matching does not overflow the goroutine stack on long inputs.
+// The stack also holds the entries to restore the captures and the counters on
+// backtracking.
+type pcreMatcher struct {
+	re         *pcreRegexp
+	input      string
+	caps       []int
+	starts     []int // start position of each capture group being matched
+	counters   []int // count and start position of each repeat
+	stack      []pcreEntry
+	barriers   []int
+	ctx        context.Context
+	steps      int
+	backtracks int
+}
+
+func (re *pcreRegexp) newMatcher(ctx context.Context, s string) *pcreMatcher {
+	return &pcreMatcher{
+		re:       re,
+		input:    s,
+		caps:     make([]int, len(re.names)*2),
+		starts:   make([]int, len(re.names)),
+		counters: make([]int, re.repeats*2),
+		ctx:      ctx,
+	}
+}
+
+// find returns the indices of the leftmost match starting from start. The
+// number of backtracking is limited over all the starting positions.
+func (m *pcreMatcher) find(start int) ([]int, error) {
+	m.backtracks = 0
+	for i := start; i <= len(m.input); {
+		for j := range m.caps {
+			m.caps[j] = -1
+		}
+		m.stack, m.barriers = m.stack[:0], m.barriers[:0]
+		if ok, err := m.run(i); err != nil {
+			return nil, err
+		} else if ok {
+			return append([]int(nil), m.caps...), nil
+		}
+		if _, size := utf8.DecodeRuneInString(m.input[i:]); size > 0 {
+			i += size
+		} else {
+			break
+		}
+	}
+	return nil, nil
+}
+
+func (m *pcreMatcher) run(start int) (bool, error) {
+	prog := m.re.prog
+	for pc, pos := 0, start; ; {
+		if m.steps++; m.steps&0xfff == 0 {
+			if err := m.ctx.Err(); err != nil {
+				return false, err
+			}
+		}
+		inst, ok := &prog[pc], true
+		switch inst.op {
+		case pcreInstMatch:
+			m.caps[0], m.caps[1] = start, pos
+			return true, nil
+		case pcreInstRune:
+			r, size := utf8.DecodeRuneInString(m.input[pos:])
+			if ok = size > 0 && m.matchRune(inst.node, r); ok {
+				pc, pos = pc+1, pos+size
+			}
+		case pcreInstRuneRepeat:
+			pos, ok = m.matchRepeatRune(pc, pos)
+			pc++
+		case pcreInstAssert:
+			if ok = m.matchAssert(inst.node, pos); ok {
+				pc++
+			}
+		case pcreInstBackref:
+			var size int
+			if size, ok = m.matchBackref(inst.node, pos); ok {
+				pc, pos = pc+1, pos+size
+			}
+		case pcreInstSplit:
+			m.push(pcreEntryChoice, inst.y, pos, 0, 0)
+			pc = inst.x
+		case pcreInstJump:
+			pc = inst.x
+		case pcreInstCaptureStart:
+			m.push(pcreEntryCaptureStart, inst.index, 0, m.starts[inst.index], 0)
+			m.starts[inst.index] = pos
+			pc++
+		case pcreInstCaptureEnd:
+			i := inst.index * 2
+			m.push(pcreEntryCapture, inst.index, 0, m.caps[i], m.caps[i+1])
+			m.caps[i], m.caps[i+1] = m.starts[inst.index], pos
+			pc++
+		case pcreInstRepeatInit:
+			m.pushCounter(inst.index)
+			m.counters[inst.index*2] = 0
+			pc++
+		case pcreInstRepeatLoop:
+			n, count := inst.node, m.counters[inst.index*2]
+			switch {
+			case count < n.min:
+				pc = inst.x
+			case n.max >= 0 && count >= n.max:
+				pc = inst.y
+			case n.greedy:
+				m.push(pcreEntryRepeat, pc, pos, count, m.counters[inst.index*2+1])
+				m.counters[inst.index*2], m.counters[inst.index*2+1] = count+1, pos
+				pc = inst.x + 1
+			default:
+				m.push(pcreEntryChoice, inst.x, pos, 0, 0)
+				pc = inst.y
+			}
+		case pcreInstRepeatBody:
+			m.pushCounter(inst.index)
+			m.counters[inst.index*2]++
+			m.counters[inst.index*2+1] = pos
+			pc++
+		case pcreInstRepeatEnd:
+			if m.counters[inst.index*2]-1 >= inst.node.min &&
+				m.counters[inst.index*2+1] == pos {
+				// stop repeating after the empty match
+				pc = inst.y
+			} else {
+				pc = inst.x
+			}
+		case pcreInstLookStart:
+			m.barriers = append(m.barriers, len(m.stack))
+			m.push(pcreEntryBarrier, pc, pos, 0, 0)
+			if inst.node.op == pcreOpLookbehind {
+				m.push(pcreEntryLookbehind, pc+1, pos, 0, 0)
+			}
+			pc++
+		case pcreInstLookEnd:
+			n, b := inst.node, m.stack[m.barriers[len(m.barriers)-1]]
+			if ok = n.op != pcreOpLookbehind || pos == b.pos; !ok {
+				break
+			}
+			m.cut(!n.negate)
+			if ok = !n.negate; ok {
+				if n.op != pcreOpAtomic {
+					pos = b.pos
+				}
+				pc++
+			}
+		default:
+			panic(inst.op)
+		}
+		if !ok {
+			var err error
+			if pc, pos, err = m.backtrack(); err != nil || pc < 0 {
+				return false, err
+			}
+		}
+	}
+}
+
+func (m *pcreMatcher) push(kind pcreEntryKind, pc, pos, x, y int) {
+	m.stack = append(m.stack, pcreEntry{kind, pc, pos, x, y})
+}
+
+func (m *pcreMatcher) pushCounter(index int) {
+	m.push(pcreEntryCounter, index, 0, m.counters[index*2], m.counters[index*2+1])
+}
+
+// restore undoes the change of the capture or the counter by the entry.
+func (m *pcreMatcher) restore(e *pcreEntry) {
+	switch e.kind {
+	case pcreEntryCaptureStart:
+		m.starts[e.pc] = e.x
+	case pcreEntryCapture:
+		m.caps[e.pc*2], m.caps[e.pc*2+1] = e.x, e.y
+	case pcreEntryCounter:
+		m.counters[e.pc*2], m.counters[e.pc*2+1] = e.x, e.y
+	case pcreEntryRepeat:
+		index := m.re.prog[e.pc].index
+		m.counters[index*2], m.counters[index*2+1] = e.x, e.y
+	}
+}
+
+// backtrack pops the stack until an entry to resume from, and returns the
+// program counter and the position. The program counter is negative when
+// there is nothing to resume from.
+func (m *pcreMatcher) backtrack() (int, int, error) {
+	for len(m.stack) > 0 {
+		e := m.stack[len(m.stack)-1]
+		m.stack = m.stack[:len(m.stack)-1]
+		switch e.kind {
+		case pcreEntryCaptureStart, pcreEntryCapture, pcreEntryCounter:
+			m.restore(&e)
+			continue
+		case pcreEntryBarrier:
+			m.barriers = m.barriers[:len(m.barriers)-1]
+			if inst := &m.re.prog[e.pc]; inst.node.negate {
+				return inst.x, e.pos, nil
+			}
+			continue
+		}
+		if m.backtracks++; m.backtracks > pcreBacktrackLimit {
+			return -1, 0, &pcreBacktrackLimitError{m.re.expr}
+		}
+		switch e.kind {
+		case pcreEntryChoice:
+			return e.pc, e.pos, nil
+		case pcreEntryRepeat:
+			m.restore(&e)
+			return m.re.prog[e.pc].y, e.pos, nil
+		case pcreEntryRuneGreedy:
+			_, size := utf8.DecodeLastRuneInString(m.input[:e.pos])
+			if e.pos -= size; e.pos > e.x {
+				m.stack = append(m.stack, e)
+			}
+			return e.pc, e.pos, nil
+		case pcreEntryRuneLazy:
+			n := m.re.prog[e.pc].node
+			if n.max >= 0 && e.x >= n.max {
+				continue
+			}
+			r, size := utf8.DecodeRuneInString(m.input[e.pos:])
+			if size == 0 || !m.matchRune(n.subs[0], r) {
+				continue
+			}
+			e.pos, e.x = e.pos+size, e.x+1
+			m.stack = append(m.stack, e)
+			return e.pc + 1, e.pos, nil
+		case pcreEntryLookbehind:
+			if e.pos == 0 {
+				continue
+			}
+			_, size := utf8.DecodeLastRuneInString(m.input[:e.pos])
+			e.pos -= size
+			m.stack = append(m.stack, e)
+			return e.pc, e.pos, nil
+		default:
+			panic(e.kind)
+		}
+	}
+	return -1, 0, nil
+}
+
+// cut removes the entries above the innermost barrier, and the barrier itself.
+// The changes of the captures and the counters are kept if keep is true, and
+// otherwise undone.
+func (m *pcreMatcher) cut(keep bool) {
+	i := m.barriers[len(m.barriers)-1]
+	m.barriers = m.barriers[:len(m.barriers)-1]
+	es := m.stack[i+1:]
+	m.stack = m.stack[:i]
+	if !keep {
+		for j := len(es) - 1; j >= 0; j-- {
+			m.restore(&es[j])
+		}
+		return
+	}
+	for _, e := range es {
+		switch e.kind {
+		case pcreEntryCaptureStart, pcreEntryCapture, pcreEntryCounter:
+			m.stack = append(m.stack, e)
+		case pcreEntryRepeat:
+			m.pushCounter(m.re.prog[e.pc].index)
+			m.stack[len(m.stack)-1].x, m.stack[len(m.stack)-1].y = e.x, e.y
+		}
+	}
+}
+
+// matchRepeatRune matches the repetition of the single character iteratively,
+// and pushes the entry to try the other counts on backtracking.
+func (m *pcreMatcher) matchRepeatRune(pc, i int) (int, bool) {
+	n := m.re.prog[pc].node
+	j, min := i, -1
+	for count := 0; ; count++ {
+		if count == n.min {
+			min = j
+			if !n.greedy {
+				break
+			}
+		}
+		if n.max >= 0 && count >= n.max {
+			break
+		}
+		r, size := utf8.DecodeRuneInString(m.input[j:])
+		if size == 0 || !m.matchRune(n.subs[0], r) {
+			break
+		}
+		j += size
+	}
+	if min < 0 {
+		return i, false
+	}
+	if !n.greedy {
+		m.push(pcreEntryRuneLazy, pc, j, n.min, 0)
+	} else if j > min {
+		m.push(pcreEntryRuneGreedy, pc+1, j, min, 0)
+	}
+	return j, true
+}
+
+func isPCRESingleRune(n *pcreNode) bool {
+	switch n.op {
+	case pcreOpLiteral, pcreOpClass, pcreOpAnyChar, pcreOpAnyCharNotNL:
+		return true
+	default:
+		return false
+	}
+}
+
+func (m *pcreMatcher) matchRune(n *pcreNode, r rune) bool {
+	switch n.op {
+	case pcreOpLiteral:
+		if r == n.r {
+			return true
+		}
+		if n.fold {
+			for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+				if f == n.r {
+					return true
+				}
+			}
+		}
+		return false
+	case pcreOpClass:
+		return n.class.matches(r, n.fold)
+	case pcreOpAnyChar:
+		return true
+	default:
+		return r != '\n'
+	}
+}
+
+func (m *pcreMatcher) matchAssert(n *pcreNode, i int) bool {
+	switch n.op {
+	case pcreOpBeginLine:
+		return i == 0 || m.input[i-1] == '\n'
+	case pcreOpEndLine:
+		return i == len(m.input) || m.input[i] == '\n'
+	case pcreOpBeginText:
+		return i == 0
+	case pcreOpEndText:
+		return i == len(m.input)
+	case pcreOpEndTextNL:
+		return i == len(m.input) || i == len(m.input)-1 && m.input[i] == '\n'
+	case pcreOpWordBoundary:
+		return m.isWordBoundary(i)
+	case pcreOpNoWordBoundary:
+		return !m.isWordBoundary(i)
+	default:
+		panic(n.op)
+	}
+}
+
+// matchBackref matches the backreference at the position i, and returns the
+// length of the matched string.
+func (m *pcreMatcher) matchBackref(n *pcreNode, i int) (int, bool) {
+	start, end := m.caps[n.index*2], m.caps[n.index*2+1]
+	if start < 0 {
+		return 0, false
+	}
+	s, t := m.input[start:end], m.input[i:]
+	if strings.HasPrefix(t, s) {
+		return len(s), true
+	}
+	if !n.fold {
+		return 0, false
+	}
+	// simple case folding preserves the number of runes
+	j := 0
+	for l := utf8.RuneCountInString(s); l > 0 && j < len(t); l-- {
+		_, size := utf8.DecodeRuneInString(t[j:])
+		j += size
+	}
+	return j, strings.EqualFold(s, t[:j])
+}
+
+func (m *pcreMatcher) isWordBoundary(i int) bool {
+	var before, after bool
+	if i > 0 {
+		before = isPCREWordByte(m.input[i-1])
+	}
+	if i < len(m.input) {
+		after = isPCREWordByte(m.input[i])
+	}
+	return before != after
+}
+
+func isPCREWordByte(c byte) bool {
+	return '0' <= c && c <= '9' || 'A' <= c && c <= 'Z' || c == '_' || 'a' <= c && c <= 'z'
+}
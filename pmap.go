@@ -0,0 +1,117 @@
+package gojq
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// parallelMap applies the closure f to the elements of the array v on n
+// goroutines (GOMAXPROCS when n is 0 or null), and returns the array of the
+// results in the order of the elements. Each element is evaluated in a copy of
+// the environment, so that the closure can refer to the variables and the
+// functions in the outer scopes. When the closure raises an error, this function
+// returns the error of the first element and the environment which raised it.
+func parallelMap(parent *env, v, n interface{}, f [2]int) (interface{}, *env) {
+	if w, ok := v.(JQValue); ok {
+		v = normalizeNumbers(w.JQValueToJSON())
+	}
+	xs, ok := v.([]interface{})
+	if !ok {
+		return &funcTypeError{"pmap", v}, nil
+	}
+	workers := runtime.GOMAXPROCS(0)
+	if n != nil {
+		if workers, ok = toInt(n); !ok || workers < 0 {
+			return &funcTypeError{"pmap", n}, nil
+		} else if workers == 0 {
+			workers = runtime.GOMAXPROCS(0)
+		}
+	}
+	if workers > len(xs) {
+		workers = len(xs)
+	}
+	results := make([][]interface{}, len(xs))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var err error
+	var failure *env
+	failed := len(xs)
+	indices := make(chan int)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				mu.Lock()
+				skip := i > failed
+				mu.Unlock()
+				if skip {
+					continue
+				}
+				sub := newParallelEnv(parent, xs[i], f)
+				for {
+					w, ok := sub.Next()
+					if !ok {
+						break
+					}
+					if e, ok := w.(error); ok {
+						mu.Lock()
+						if i < failed {
+							failed, failure, err = i, sub, e
+						}
+						mu.Unlock()
+						break
+					}
+					results[i] = append(results[i], w)
+				}
+			}
+		}()
+	}
+	for i := range xs {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+	if failure != nil {
+		if e := errors.Unwrap(err); e != nil {
+			err = e // unwrap the runtime error to report it at the call site
+		}
+		return err, failure
+	}
+	ys := []interface{}{}
+	for _, r := range results {
+		ys = append(ys, r...)
+	}
+	return ys, nil
+}
+
+// newParallelEnv creates an environment to call the closure f with the input v.
+// The scope stack is copied and its index is reset, so that the environment
+// emits the values on returning from the closure.
+func newParallelEnv(parent *env, v interface{}, f [2]int) *env {
+	scopes := &stack{
+		data:  append([]block{}, parent.scopes.data...),
+		index: -1,
+		limit: len(parent.scopes.data) - 1,
+	}
+	sub := &env{
+		pc:        f[0],
+		stack:     newStack(),
+		scopes:    scopes,
+		paths:     newStack(),
+		values:    append([]interface{}{}, parent.values...),
+		codes:     parent.codes,
+		codeinfos: parent.codeinfos,
+		spans:     parent.spans,
+		offset:    parent.offset,
+		ctx:       parent.ctx,
+		ordered:   parent.ordered,
+		limits:    parent.limits,
+		callindex: f[1],
+		parallel:  true,
+		effects:   parent.effects,
+	}
+	sub.push(v)
+	return sub
+}
@@ -17,6 +17,7 @@ type Query struct {
 	Op       Operator
 	Right    *Query
 	Func     string
+	Offset   int // byte offset of the operator token in the source
 }
 
 // Run the query.
@@ -107,7 +108,7 @@ func (e *Query) countCommaQueries() int {
 	return 1
 }
 
-// Import ...
+// Import is an import or include directive of a module.
 type Import struct {
 	ImportPath  string
 	ImportAlias string
@@ -138,7 +139,7 @@ func (e *Import) writeTo(s *strings.Builder) {
 	s.WriteString(";\n")
 }
 
-// FuncDef ...
+// FuncDef is a function definition.
 type FuncDef struct {
 	Name string
 	Args []string
@@ -169,12 +170,14 @@ func (e *FuncDef) writeTo(s *strings.Builder) {
 	s.WriteByte(';')
 }
 
-// Minify ...
+// Minify minifies the function definition.
 func (e *FuncDef) Minify() {
 	e.Body.minify()
 }
 
-// Term ...
+// Term is a term of a query, which is a primary expression optionally
+// followed by a list of suffixes. The Type field determines which of the
+// other fields is set.
 type Term struct {
 	Type       TermType
 	Index      *Index
@@ -193,6 +196,7 @@ type Term struct {
 	Break      string
 	Query      *Query
 	SuffixList []*Suffix
+	Offset     int // byte offset of the first token in the source
 }
 
 func (e *Term) String() string {
@@ -336,7 +340,7 @@ func (e *Term) toIndices() []interface{} {
 	}
 }
 
-// Unary ...
+// Unary is a unary operator applied to a term.
 type Unary struct {
 	Op   Operator
 	Term *Term
@@ -357,7 +361,7 @@ func (e *Unary) minify() {
 	e.Term.minify()
 }
 
-// Pattern ...
+// Pattern is a destructuring pattern of a variable binding.
 type Pattern struct {
 	Name   string
 	Array  []*Pattern
@@ -394,7 +398,7 @@ func (e *Pattern) writeTo(s *strings.Builder) {
 	}
 }
 
-// PatternObject ...
+// PatternObject is a key and value pair of an object pattern.
 type PatternObject struct {
 	Key       string
 	KeyString *String
@@ -428,13 +432,14 @@ func (e *PatternObject) writeTo(s *strings.Builder) {
 	}
 }
 
-// Index ...
+// Index is an index or a slice of a term.
 type Index struct {
 	Name    string
 	Str     *String
 	Start   *Query
 	IsSlice bool
 	End     *Query
+	Offset  int // byte offset of the '.' or '[' token in the source
 }
 
 func (e *Index) String() string {
@@ -498,7 +503,7 @@ func (e *Index) toIndices() []interface{} {
 	return []interface{}{e.Name}
 }
 
-// Func ...
+// Func is a function call or a variable reference.
 type Func struct {
 	Name string
 	Args []*Query
@@ -537,7 +542,7 @@ func (e *Func) toFunc() string {
 	return e.Name
 }
 
-// String ...
+// String is a string literal, which may contain interpolated queries.
 type String struct {
 	Str     string
 	Queries []*Query
@@ -573,7 +578,7 @@ func (e *String) minify() {
 	}
 }
 
-// Object ...
+// Object is an object construction.
 type Object struct {
 	KeyVals []*ObjectKeyVal
 }
@@ -605,7 +610,7 @@ func (e *Object) minify() {
 	}
 }
 
-// ObjectKeyVal ...
+// ObjectKeyVal is a key and value pair of an object construction.
 type ObjectKeyVal struct {
 	Key           string
 	KeyString     *String
@@ -656,7 +661,7 @@ func (e *ObjectKeyVal) minify() {
 	}
 }
 
-// ObjectVal ...
+// ObjectVal is a value of an object construction.
 type ObjectVal struct {
 	Queries []*Query
 }
@@ -682,7 +687,7 @@ func (e *ObjectVal) minify() {
 	}
 }
 
-// Array ...
+// Array is an array construction.
 type Array struct {
 	Query *Query
 }
@@ -707,7 +712,8 @@ func (e *Array) minify() {
 	}
 }
 
-// Suffix ...
+// Suffix is a suffix of a term, which is an index, an iterator, an optional
+// operator or a variable binding.
 type Suffix struct {
 	Index    *Index
 	Iter     bool
@@ -762,7 +768,7 @@ func (e *Suffix) toIndices() []interface{} {
 	return e.Index.toIndices()
 }
 
-// Bind ...
+// Bind is a variable binding with the body query.
 type Bind struct {
 	Patterns []*Pattern
 	Body     *Query
@@ -794,7 +800,7 @@ func (e *Bind) minify() {
 	e.Body.minify()
 }
 
-// If ...
+// If is an if-then-elif-else-end expression.
 type If struct {
 	Cond *Query
 	Then *Query
@@ -835,7 +841,7 @@ func (e *If) minify() {
 	}
 }
 
-// IfElif ...
+// IfElif is an elif clause of an if expression.
 type IfElif struct {
 	Cond *Query
 	Then *Query
@@ -859,7 +865,7 @@ func (e *IfElif) minify() {
 	e.Then.minify()
 }
 
-// Try ...
+// Try is a try-catch expression.
 type Try struct {
 	Body  *Query
 	Catch *Query
@@ -887,7 +893,7 @@ func (e *Try) minify() {
 	}
 }
 
-// Reduce ...
+// Reduce is a reduce expression.
 type Reduce struct {
 	Term    *Term
 	Pattern *Pattern
@@ -919,7 +925,7 @@ func (e *Reduce) minify() {
 	e.Update.minify()
 }
 
-// Foreach ...
+// Foreach is a foreach expression.
 type Foreach struct {
 	Term    *Term
 	Pattern *Pattern
@@ -959,7 +965,7 @@ func (e *Foreach) minify() {
 	}
 }
 
-// Label ...
+// Label is a label expression.
 type Label struct {
 	Ident string
 	Body  *Query
@@ -982,7 +988,7 @@ func (e *Label) minify() {
 	e.Body.minify()
 }
 
-// ConstTerm ...
+// ConstTerm is a constant term, used in module metadata.
 type ConstTerm struct {
 	Object *ConstObject
 	Array  *ConstArray
@@ -1035,7 +1041,7 @@ func (e *ConstTerm) toValue() interface{} {
 	}
 }
 
-// ConstObject ...
+// ConstObject is a constant object.
 type ConstObject struct {
 	KeyVals []*ConstObjectKeyVal
 }
@@ -1077,7 +1083,7 @@ func (e *ConstObject) ToValue() map[string]interface{} {
 	return v
 }
 
-// ConstObjectKeyVal ...
+// ConstObjectKeyVal is a key and value pair of a constant object.
 type ConstObjectKeyVal struct {
 	Key       string
 	KeyString string
@@ -1100,7 +1106,7 @@ func (e *ConstObjectKeyVal) writeTo(s *strings.Builder) {
 	e.Val.writeTo(s)
 }
 
-// ConstArray ...
+// ConstArray is a constant array.
 type ConstArray struct {
 	Elems []*ConstTerm
 }
@@ -1129,3 +1135,194 @@ func (e *ConstArray) toValue() []interface{} {
 	}
 	return v
 }
+
+// Node is the interface implemented by all the nodes of the abstract syntax
+// tree. The String method prints the node as a jq query.
+type Node interface {
+	String() string
+}
+
+// Walk traverses the abstract syntax tree in depth-first order. It starts by
+// calling f(node); if f returns true, Walk visits each of the non-nil children
+// of the node in the order they appear in the source.
+func Walk(node Node, f func(Node) bool) {
+	if !f(node) {
+		return
+	}
+	switch e := node.(type) {
+	case *Query:
+		if e.Meta != nil {
+			Walk(e.Meta, f)
+		}
+		for _, im := range e.Imports {
+			Walk(im, f)
+		}
+		for _, fd := range e.FuncDefs {
+			Walk(fd, f)
+		}
+		if e.Term != nil {
+			Walk(e.Term, f)
+		} else if e.Right != nil {
+			Walk(e.Left, f)
+			Walk(e.Right, f)
+		}
+	case *Import:
+		if e.Meta != nil {
+			Walk(e.Meta, f)
+		}
+	case *FuncDef:
+		Walk(e.Body, f)
+	case *Term:
+		switch {
+		case e.Index != nil:
+			Walk(e.Index, f)
+		case e.Func != nil:
+			Walk(e.Func, f)
+		case e.Object != nil:
+			Walk(e.Object, f)
+		case e.Array != nil:
+			Walk(e.Array, f)
+		case e.Unary != nil:
+			Walk(e.Unary, f)
+		case e.If != nil:
+			Walk(e.If, f)
+		case e.Try != nil:
+			Walk(e.Try, f)
+		case e.Reduce != nil:
+			Walk(e.Reduce, f)
+		case e.Foreach != nil:
+			Walk(e.Foreach, f)
+		case e.Label != nil:
+			Walk(e.Label, f)
+		case e.Query != nil:
+			Walk(e.Query, f)
+		case e.Str != nil:
+			Walk(e.Str, f)
+		}
+		for _, s := range e.SuffixList {
+			Walk(s, f)
+		}
+	case *Unary:
+		Walk(e.Term, f)
+	case *Pattern:
+		for _, p := range e.Array {
+			Walk(p, f)
+		}
+		for _, p := range e.Object {
+			Walk(p, f)
+		}
+	case *PatternObject:
+		if e.KeyString != nil {
+			Walk(e.KeyString, f)
+		}
+		if e.KeyQuery != nil {
+			Walk(e.KeyQuery, f)
+		}
+		if e.Val != nil {
+			Walk(e.Val, f)
+		}
+	case *Index:
+		if e.Str != nil {
+			Walk(e.Str, f)
+		}
+		if e.Start != nil {
+			Walk(e.Start, f)
+		}
+		if e.End != nil {
+			Walk(e.End, f)
+		}
+	case *Func:
+		for _, q := range e.Args {
+			Walk(q, f)
+		}
+	case *String:
+		for _, q := range e.Queries {
+			Walk(q, f)
+		}
+	case *Object:
+		for _, kv := range e.KeyVals {
+			Walk(kv, f)
+		}
+	case *ObjectKeyVal:
+		if e.KeyString != nil {
+			Walk(e.KeyString, f)
+		}
+		if e.KeyQuery != nil {
+			Walk(e.KeyQuery, f)
+		}
+		if e.KeyOnlyString != nil {
+			Walk(e.KeyOnlyString, f)
+		}
+		if e.Val != nil {
+			Walk(e.Val, f)
+		}
+	case *ObjectVal:
+		for _, q := range e.Queries {
+			Walk(q, f)
+		}
+	case *Array:
+		if e.Query != nil {
+			Walk(e.Query, f)
+		}
+	case *Suffix:
+		if e.Index != nil {
+			Walk(e.Index, f)
+		}
+		if e.Bind != nil {
+			Walk(e.Bind, f)
+		}
+	case *Bind:
+		for _, p := range e.Patterns {
+			Walk(p, f)
+		}
+		Walk(e.Body, f)
+	case *If:
+		Walk(e.Cond, f)
+		Walk(e.Then, f)
+		for _, ie := range e.Elif {
+			Walk(ie, f)
+		}
+		if e.Else != nil {
+			Walk(e.Else, f)
+		}
+	case *IfElif:
+		Walk(e.Cond, f)
+		Walk(e.Then, f)
+	case *Try:
+		Walk(e.Body, f)
+		if e.Catch != nil {
+			Walk(e.Catch, f)
+		}
+	case *Reduce:
+		Walk(e.Term, f)
+		Walk(e.Pattern, f)
+		Walk(e.Start, f)
+		Walk(e.Update, f)
+	case *Foreach:
+		Walk(e.Term, f)
+		Walk(e.Pattern, f)
+		Walk(e.Start, f)
+		Walk(e.Update, f)
+		if e.Extract != nil {
+			Walk(e.Extract, f)
+		}
+	case *Label:
+		Walk(e.Body, f)
+	case *ConstTerm:
+		if e.Object != nil {
+			Walk(e.Object, f)
+		} else if e.Array != nil {
+			Walk(e.Array, f)
+		}
+	case *ConstObject:
+		for _, kv := range e.KeyVals {
+			Walk(kv, f)
+		}
+	case *ConstObjectKeyVal:
+		Walk(e.Val, f)
+	case *ConstArray:
+		for _, e := range e.Elems {
+			Walk(e, f)
+		}
+	}
+}
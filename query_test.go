@@ -3,6 +3,7 @@ package gojq_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -135,6 +136,79 @@ func TestQueryRun_InvalidPathError(t *testing.T) {
 	}
 }
 
+func TestQueryRun_RuntimeError(t *testing.T) {
+	testCases := []struct {
+		src        string
+		input      interface{}
+		start, end int
+		name       string
+		value      interface{}
+	}{
+		{`.foo`, 1, 0, 4, ".", 1},
+		{`.foo.bar`, map[string]interface{}{"foo": "x"}, 4, 8, ".", "x"},
+		{`.foo[0]`, map[string]interface{}{"foo": 1}, 4, 5, ".", 1},
+		{`tostring | tonumber`, "x", 11, 19, "tonumber", "x"},
+		{`1 - "x"`, nil, 2, 3, "-", nil},
+		{`.a += "x"`, map[string]interface{}{"a": 1}, 3, 5, "+=", 1},
+		{`[1, 2] | map(ltrimstr(1) | error)`, nil, 27, 32, "error", 1},
+		{`[[1]] | flatten(-1)`, nil, 8, 15, "flatten", []interface{}{[]interface{}{1}}},
+		{`-"x"`, nil, 0, 1, "-", "x"},
+		{`.[]`, 1, -1, -1, "", 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.src, func(t *testing.T) {
+			query, err := gojq.Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, _ := query.Run(tc.input).Next()
+			e, ok := v.(gojq.RuntimeError)
+			if !ok {
+				t.Fatalf("expected a runtime error but got: %v", v)
+			}
+			if start, end := e.Span(); start != tc.start || end != tc.end {
+				t.Errorf("expected span: [%d, %d], got: [%d, %d]", tc.start, tc.end, start, end)
+			}
+			if name := e.Name(); name != tc.name {
+				t.Errorf("expected name: %q, got: %q", tc.name, name)
+			}
+			if input := e.Input(); !reflect.DeepEqual(input, tc.value) {
+				t.Errorf("expected input: %#v, got: %#v", tc.value, input)
+			}
+			if errors.Unwrap(e) == nil {
+				t.Errorf("expected an underlying error")
+			}
+		})
+	}
+}
+
+func TestQueryRun_RegexpBacktracking(t *testing.T) {
+	query, err := gojq.Parse(`("a" * 1000000) | test("^(a|b)*$"; "P")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := query.Run(nil).Next(); v != true {
+		t.Errorf("expected true but got: %v", v)
+	}
+
+	query, err = gojq.Parse(`"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa!" | test("(a+)+$"; "P")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _ := query.Run(nil).Next()
+	if err, ok := v.(gojq.RuntimeError); !ok ||
+		!strings.Contains(err.Error(), "backtracking limit exceeded") {
+		t.Errorf("expected backtracking limit error but got: %v", v)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	v, _ = query.RunWithContext(ctx, nil).Next()
+	if err, ok := v.(error); !ok || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context deadline exceeded but got: %v", v)
+	}
+}
+
 func TestQueryRun_NumericTypes(t *testing.T) {
 	query, err := gojq.Parse(".[] != 0")
 	if err != nil {
@@ -220,11 +294,50 @@ func TestQueryString(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	clearOffset(q)
+	clearOffset(r)
 	if !reflect.DeepEqual(q, r) {
 		t.Errorf("\n%v\n%v", q, r)
 	}
 }
 
+func clearOffset(q *gojq.Query) {
+	gojq.Walk(q, func(node gojq.Node) bool {
+		switch e := node.(type) {
+		case *gojq.Query:
+			e.Offset = 0
+		case *gojq.Term:
+			e.Offset = 0
+		case *gojq.Index:
+			e.Offset = 0
+		}
+		return true
+	})
+}
+
+func ExampleWalk() {
+	query, err := gojq.Parse(`.foo | map(.bar + 1)`)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	gojq.Walk(query, func(node gojq.Node) bool {
+		switch e := node.(type) {
+		case *gojq.Term:
+			fmt.Printf("%d: term %s\n", e.Offset, e)
+		case *gojq.Func:
+			fmt.Printf("function %s/%d\n", e.Name, len(e.Args))
+		}
+		return true
+	})
+
+	// Output:
+	// 0: term .foo
+	// 7: term map(.bar + 1)
+	// function map/1
+	// 11: term .bar
+	// 18: term 1
+}
+
 func BenchmarkRun(b *testing.B) {
 	query, err := gojq.Parse("range(1000)")
 	if err != nil {
@@ -254,3 +367,12 @@ func BenchmarkParse(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkParseSmallQuery(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_, err := gojq.Parse(`.items[] | select(.name == "foo" and .count > 1) | {id, tags: [.tags[] | ascii_downcase]}`)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package gojq
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	mathrand "math/rand"
+	"time"
+)
+
+func newSeededRandom(seed int64) *mathrand.Rand {
+	return mathrand.New(mathrand.NewSource(seed))
+}
+
+// randomSource returns the random number generator of the random functions,
+// and the function to release it. The generator uses the cryptographically
+// secure random number generator unless WithRandomSeed is specified. The
+// seeded generator is shared by the runs of the code, so it is locked until
+// released.
+func (c *compiler) randomSource() (*mathrand.Rand, func()) {
+	c.randomMu.Lock()
+	if c.random != nil {
+		return c.random, c.randomMu.Unlock
+	}
+	c.randomMu.Unlock()
+	return mathrand.New(cryptoSource{}), func() {}
+}
+
+// cryptoSource is the source of math/rand using crypto/rand.
+type cryptoSource struct{}
+
+func (cryptoSource) Int63() int64 {
+	return int64(cryptoSource{}.Uint64() >> 1)
+}
+
+func (cryptoSource) Uint64() uint64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return binary.BigEndian.Uint64(b[:])
+}
+
+func (cryptoSource) Seed(int64) {}
+
+func (c *compiler) funcUUID(interface{}, []interface{}) interface{} {
+	var u [16]byte
+	r, release := c.randomSource()
+	defer release()
+	if _, err := io.ReadFull(r, u[:]); err != nil {
+		return err
+	}
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return formatUUID(u)
+}
+
+func (c *compiler) funcUUID7(interface{}, []interface{}) interface{} {
+	var u [16]byte
+	r, release := c.randomSource()
+	defer release()
+	if _, err := io.ReadFull(r, u[6:]); err != nil {
+		return err
+	}
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(time.Now().UnixNano()/int64(time.Millisecond)))
+	copy(u[:6], ms[2:])
+	u[6] = u[6]&0x0f | 0x70
+	u[8] = u[8]&0x3f | 0x80
+	return formatUUID(u)
+}
+
+func formatUUID(u [16]byte) string {
+	var buf [36]byte
+	hex.Encode(buf[:8], u[:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+func (c *compiler) funcRandom(interface{}, []interface{}) interface{} {
+	r, release := c.randomSource()
+	defer release()
+	return r.Float64()
+}
+
+func (c *compiler) funcRandint(_ interface{}, args []interface{}) interface{} {
+	min, ok := toInt(args[0])
+	if !ok {
+		return &funcTypeError{"randint", args[0]}
+	}
+	max, ok := toInt(args[1])
+	if !ok {
+		return &funcTypeError{"randint", args[1]}
+	}
+	if min > max {
+		return &randintRangeError{args[0], args[1]}
+	}
+	r, release := c.randomSource()
+	defer release()
+	switch d := uint64(max) - uint64(min); {
+	case d < 1<<63-1:
+		return min + int(r.Int63n(int64(d)+1))
+	case d == 1<<64-1:
+		return int(r.Uint64())
+	default:
+		return min + int(r.Uint64()%(d+1))
+	}
+}
+
+func (c *compiler) funcShuffle(v interface{}, _ []interface{}) interface{} {
+	vs, ok := v.([]interface{})
+	if !ok {
+		return &funcTypeError{"shuffle", v}
+	}
+	ws := make([]interface{}, len(vs))
+	copy(ws, vs)
+	r, release := c.randomSource()
+	defer release()
+	r.Shuffle(len(ws), func(i, j int) {
+		ws[i], ws[j] = ws[j], ws[i]
+	})
+	return ws
+}
+
+func (c *compiler) funcSetseed(v interface{}, args []interface{}) interface{} {
+	seed, ok := toInt(args[0])
+	if !ok {
+		return &funcTypeError{"setseed", args[0]}
+	}
+	c.randomMu.Lock()
+	defer c.randomMu.Unlock()
+	c.random = newSeededRandom(int64(seed))
+	return v
+}
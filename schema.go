@@ -0,0 +1,654 @@
+package gojq
+
+import (
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// schemaValidator validates the values against the JSON Schema (draft 2020-12).
+// The format keyword is an annotation, and the references are resolved only
+// in the schema document.
+type schemaValidator struct {
+	root    interface{}
+	ids     map[string]interface{}
+	regexps map[string]*regexp.Regexp
+	depth   int
+}
+
+// schemaAnnotation is the properties and the items evaluated by the schema,
+// which are used by unevaluatedProperties and unevaluatedItems.
+type schemaAnnotation struct {
+	props map[string]bool
+	items map[int]bool
+}
+
+func (a *schemaAnnotation) merge(b *schemaAnnotation) {
+	for k := range b.props {
+		a.props[k] = true
+	}
+	for i := range b.items {
+		a.items[i] = true
+	}
+}
+
+func newSchemaValidator(schema interface{}) *schemaValidator {
+	s := &schemaValidator{
+		root:    schema,
+		ids:     make(map[string]interface{}),
+		regexps: make(map[string]*regexp.Regexp),
+	}
+	s.collectIDs(schema)
+	return s
+}
+
+// collectIDs collects the subschemas identified by $id and $anchor.
+func (s *schemaValidator) collectIDs(schema interface{}) {
+	switch schema := schema.(type) {
+	case map[string]interface{}:
+		if id, ok := schema["$id"].(string); ok {
+			s.ids[strings.TrimSuffix(id, "#")] = schema
+		}
+		for _, k := range []string{"$anchor", "$dynamicAnchor"} {
+			if anchor, ok := schema[k].(string); ok {
+				s.ids["#"+anchor] = schema
+			}
+		}
+		for k, v := range schema {
+			if k != "enum" && k != "const" {
+				s.collectIDs(v)
+			}
+		}
+	case []interface{}:
+		for _, v := range schema {
+			s.collectIDs(v)
+		}
+	}
+}
+
+func (s *schemaValidator) resolve(ref string) (interface{}, bool) {
+	if schema, ok := s.ids[ref]; ok {
+		return schema, true
+	}
+	root := s.root
+	if i := strings.IndexByte(ref, '#'); i > 0 {
+		var ok bool
+		if root, ok = s.ids[ref[:i]]; !ok {
+			return nil, false
+		}
+		ref = ref[i:]
+	}
+	if !strings.HasPrefix(ref, "#") {
+		return nil, false
+	}
+	if ref = ref[1:]; ref == "" {
+		return root, true
+	}
+	if !strings.HasPrefix(ref, "/") {
+		return nil, false
+	}
+	v := root
+	for _, k := range strings.Split(ref[1:], "/") {
+		k = strings.NewReplacer("~1", "/", "~0", "~").Replace(unescapeURIComponent(k))
+		switch w := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = w[k]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(k)
+			if err != nil || i < 0 || i >= len(w) {
+				return nil, false
+			}
+			v = w[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func unescapeURIComponent(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				sb.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+func (s *schemaValidator) regexp(pattern string) (*regexp.Regexp, bool) {
+	if r, ok := s.regexps[pattern]; ok {
+		return r, r != nil
+	}
+	var r *regexp.Regexp
+	if x, err := compileRegexp(pattern, ""); err == nil {
+		r = x.(stdRegexp).Regexp // compiled by the regexp package without the P flag
+	}
+	s.regexps[pattern] = r
+	return r, r != nil
+}
+
+func newSchemaViolation(inst, kw, msg string) interface{} {
+	return map[string]interface{}{
+		"instanceLocation": inst,
+		"keywordLocation":  kw,
+		"error":            msg,
+	}
+}
+
+func escapeJSONPointer(k string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(k)
+}
+
+// validate validates the value against the schema, and returns the violations
+// and the annotation. The inst and kw are the JSON pointers of the instance
+// and the keyword.
+func (s *schemaValidator) validate(schema, v interface{}, inst, kw string) ([]interface{}, *schemaAnnotation) {
+	a := &schemaAnnotation{make(map[string]bool), make(map[int]bool)}
+	m, ok := schema.(map[string]interface{})
+	if !ok {
+		if schema == false {
+			return []interface{}{newSchemaViolation(inst, kw, "value is not allowed")}, a
+		}
+		return nil, a
+	}
+	if s.depth++; s.depth > 1000 {
+		s.depth--
+		return []interface{}{newSchemaViolation(inst, kw, "schema reference is too deep")}, a
+	}
+	defer func() { s.depth-- }()
+	var errs []interface{}
+	fail := func(keyword, msg string) {
+		errs = append(errs, newSchemaViolation(inst, kw+"/"+keyword, msg))
+	}
+	apply := func(schema, v interface{}, inst, kw string) bool {
+		es, b := s.validate(schema, v, inst, kw)
+		if len(es) > 0 {
+			errs = append(errs, es...)
+			return false
+		}
+		a.merge(b)
+		return true
+	}
+	test := func(schema, v interface{}) (*schemaAnnotation, bool) {
+		es, b := s.validate(schema, v, inst, kw)
+		return b, len(es) == 0
+	}
+	for _, k := range []string{"$ref", "$dynamicRef"} {
+		if ref, ok := m[k].(string); ok {
+			if r, ok := s.resolve(ref); ok {
+				apply(r, v, inst, kw+"/"+k)
+			} else {
+				fail(k, "cannot resolve reference "+strconv.Quote(ref))
+			}
+		}
+	}
+	if t, ok := m["type"]; ok {
+		var types []string
+		switch t := t.(type) {
+		case string:
+			types = []string{t}
+		case []interface{}:
+			for _, t := range t {
+				if t, ok := t.(string); ok {
+					types = append(types, t)
+				}
+			}
+		}
+		if !schemaTypeMatches(types, v) {
+			fail("type", "expected "+strings.Join(types, " or ")+" but got "+typeof(v))
+		}
+	}
+	if xs, ok := m["enum"].([]interface{}); ok {
+		var found bool
+		for _, x := range xs {
+			if compare(v, x) == 0 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("enum", "value is not one of the enum values")
+		}
+	}
+	if x, ok := m["const"]; ok && compare(v, x) != 0 {
+		fail("const", "value is not equal to the const value")
+	}
+	if getTypeOrdNum(v) == 3 {
+		s.validateNumber(m, v, fail)
+	}
+	if v, ok := v.(string); ok {
+		s.validateString(m, v, fail)
+	}
+	if v, ok := v.([]interface{}); ok {
+		s.validateArray(m, v, inst, kw, a, fail, apply, test)
+	}
+	if v, ok := v.(map[string]interface{}); ok {
+		s.validateObject(m, v, inst, kw, a, fail, apply)
+	}
+	if xs, ok := m["allOf"].([]interface{}); ok {
+		for i, x := range xs {
+			apply(x, v, inst, kw+"/allOf/"+strconv.Itoa(i))
+		}
+	}
+	if xs, ok := m["anyOf"].([]interface{}); ok {
+		var found bool
+		for _, x := range xs {
+			if b, ok := test(x, v); ok {
+				a.merge(b)
+				found = true
+			}
+		}
+		if !found {
+			fail("anyOf", "value does not match any of the schemas")
+		}
+	}
+	if xs, ok := m["oneOf"].([]interface{}); ok {
+		var count int
+		for _, x := range xs {
+			if b, ok := test(x, v); ok {
+				a.merge(b)
+				count++
+			}
+		}
+		if count != 1 {
+			fail("oneOf", "value matches "+strconv.Itoa(count)+" of the schemas but expected exactly one")
+		}
+	}
+	if x, ok := m["not"]; ok {
+		if _, ok := test(x, v); ok {
+			fail("not", "value matches the schema of not")
+		}
+	}
+	if x, ok := m["if"]; ok {
+		if b, ok := test(x, v); ok {
+			a.merge(b)
+			if x, ok := m["then"]; ok {
+				apply(x, v, inst, kw+"/then")
+			}
+		} else if x, ok := m["else"]; ok {
+			apply(x, v, inst, kw+"/else")
+		}
+	}
+	if x, ok := m["unevaluatedItems"]; ok {
+		if v, ok := v.([]interface{}); ok {
+			for i, w := range v {
+				if !a.items[i] {
+					apply(x, w, inst+"/"+strconv.Itoa(i), kw+"/unevaluatedItems")
+				}
+			}
+			for i := range v {
+				a.items[i] = true
+			}
+		}
+	}
+	if x, ok := m["unevaluatedProperties"]; ok {
+		if v, ok := v.(map[string]interface{}); ok {
+			for _, k := range sortedKeys(v) {
+				if !a.props[k] {
+					apply(x, v[k], inst+"/"+escapeJSONPointer(k), kw+"/unevaluatedProperties")
+				}
+			}
+			for k := range v {
+				a.props[k] = true
+			}
+		}
+	}
+	return errs, a
+}
+
+func schemaTypeMatches(types []string, v interface{}) bool {
+	t := typeof(v)
+	for _, typ := range types {
+		switch {
+		case typ == t:
+			return true
+		case typ == "integer" && t == "number":
+			if r, ok := toRat(v); ok && r.IsInt() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *schemaValidator) validateNumber(m map[string]interface{}, v interface{}, fail func(string, string)) {
+	for _, c := range []struct {
+		keyword string
+		invalid func(int) bool
+		msg     string
+	}{
+		{"minimum", func(c int) bool { return c < 0 }, "less than the minimum"},
+		{"exclusiveMinimum", func(c int) bool { return c <= 0 }, "less than or equal to the exclusive minimum"},
+		{"maximum", func(c int) bool { return c > 0 }, "greater than the maximum"},
+		{"exclusiveMaximum", func(c int) bool { return c >= 0 }, "greater than or equal to the exclusive maximum"},
+	} {
+		if x, ok := m[c.keyword]; ok && getTypeOrdNum(x) == 3 && c.invalid(compare(v, x)) {
+			fail(c.keyword, "value "+jsonMarshal(v)+" is "+c.msg+" "+jsonMarshal(x))
+		}
+	}
+	if x, ok := m["multipleOf"]; ok {
+		if d, ok := toRat(x); ok && d.Sign() > 0 {
+			if r, ok := toRat(v); ok && !new(big.Rat).Quo(r, d).IsInt() {
+				fail("multipleOf", "value "+jsonMarshal(v)+" is not a multiple of "+jsonMarshal(x))
+			}
+		}
+	}
+}
+
+func (s *schemaValidator) validateString(m map[string]interface{}, v string, fail func(string, string)) {
+	l := utf8.RuneCountInString(v)
+	if x, ok := toInt(m["minLength"]); ok && l < x {
+		fail("minLength", "string length "+strconv.Itoa(l)+" is less than "+strconv.Itoa(x))
+	}
+	if x, ok := toInt(m["maxLength"]); ok && l > x {
+		fail("maxLength", "string length "+strconv.Itoa(l)+" is greater than "+strconv.Itoa(x))
+	}
+	if x, ok := m["pattern"].(string); ok {
+		if r, ok := s.regexp(x); !ok {
+			fail("pattern", "invalid pattern "+strconv.Quote(x))
+		} else if !r.MatchString(v) {
+			fail("pattern", "string does not match the pattern "+strconv.Quote(x))
+		}
+	}
+}
+
+func (s *schemaValidator) validateArray(
+	m map[string]interface{}, v []interface{}, inst, kw string, a *schemaAnnotation,
+	fail func(string, string), apply func(interface{}, interface{}, string, string) bool,
+	test func(interface{}, interface{}) (*schemaAnnotation, bool),
+) {
+	if x, ok := toInt(m["minItems"]); ok && len(v) < x {
+		fail("minItems", "array length "+strconv.Itoa(len(v))+" is less than "+strconv.Itoa(x))
+	}
+	if x, ok := toInt(m["maxItems"]); ok && len(v) > x {
+		fail("maxItems", "array length "+strconv.Itoa(len(v))+" is greater than "+strconv.Itoa(x))
+	}
+	if m["uniqueItems"] == true {
+	L:
+		for i := range v {
+			for j := i + 1; j < len(v); j++ {
+				if compare(v[i], v[j]) == 0 {
+					fail("uniqueItems", "array items at "+strconv.Itoa(i)+" and "+strconv.Itoa(j)+" are equal")
+					break L
+				}
+			}
+		}
+	}
+	var n int
+	if xs, ok := m["prefixItems"].([]interface{}); ok {
+		for i, x := range xs {
+			if i < len(v) {
+				apply(x, v[i], inst+"/"+strconv.Itoa(i), kw+"/prefixItems/"+strconv.Itoa(i))
+				a.items[i] = true
+			}
+		}
+		n = len(xs)
+	}
+	if x, ok := m["items"]; ok {
+		for i := n; i < len(v); i++ {
+			apply(x, v[i], inst+"/"+strconv.Itoa(i), kw+"/items")
+			a.items[i] = true
+		}
+	}
+	if x, ok := m["contains"]; ok {
+		var count int
+		for i, w := range v {
+			if _, ok := test(x, w); ok {
+				a.items[i] = true
+				count++
+			}
+		}
+		min, ok := toInt(m["minContains"])
+		if !ok {
+			min = 1
+		}
+		if count < min {
+			if _, ok := m["minContains"]; ok {
+				fail("minContains", "array contains "+strconv.Itoa(count)+" matching items but expected at least "+strconv.Itoa(min))
+			} else {
+				fail("contains", "array does not contain the items matching the schema")
+			}
+		}
+		if max, ok := toInt(m["maxContains"]); ok && count > max {
+			fail("maxContains", "array contains "+strconv.Itoa(count)+" matching items but expected at most "+strconv.Itoa(max))
+		}
+	}
+}
+
+func (s *schemaValidator) validateObject(
+	m map[string]interface{}, v map[string]interface{}, inst, kw string, a *schemaAnnotation,
+	fail func(string, string), apply func(interface{}, interface{}, string, string) bool,
+) {
+	if x, ok := toInt(m["minProperties"]); ok && len(v) < x {
+		fail("minProperties", "object has "+strconv.Itoa(len(v))+" properties but expected at least "+strconv.Itoa(x))
+	}
+	if x, ok := toInt(m["maxProperties"]); ok && len(v) > x {
+		fail("maxProperties", "object has "+strconv.Itoa(len(v))+" properties but expected at most "+strconv.Itoa(x))
+	}
+	if xs, ok := m["required"].([]interface{}); ok {
+		for _, x := range xs {
+			if k, ok := x.(string); ok {
+				if _, ok := v[k]; !ok {
+					fail("required", "missing required property "+strconv.Quote(k))
+				}
+			}
+		}
+	}
+	if x, ok := m["dependentRequired"].(map[string]interface{}); ok {
+		for _, k := range sortedKeys(x) {
+			if _, ok := v[k]; !ok {
+				continue
+			}
+			xs, _ := x[k].([]interface{})
+			for _, y := range xs {
+				if l, ok := y.(string); ok {
+					if _, ok := v[l]; !ok {
+						fail("dependentRequired/"+escapeJSONPointer(k),
+							"missing property "+strconv.Quote(l)+" required by "+strconv.Quote(k))
+					}
+				}
+			}
+		}
+	}
+	keys := sortedKeys(v)
+	props, _ := m["properties"].(map[string]interface{})
+	patterns, _ := m["patternProperties"].(map[string]interface{})
+	for _, k := range keys {
+		var evaluated bool
+		if x, ok := props[k]; ok {
+			apply(x, v[k], inst+"/"+escapeJSONPointer(k), kw+"/properties/"+escapeJSONPointer(k))
+			evaluated = true
+		}
+		for _, p := range sortedKeys(patterns) {
+			if r, ok := s.regexp(p); ok && r.MatchString(k) {
+				apply(patterns[p], v[k], inst+"/"+escapeJSONPointer(k), kw+"/patternProperties/"+escapeJSONPointer(p))
+				evaluated = true
+			}
+		}
+		if evaluated {
+			a.props[k] = true
+		} else if x, ok := m["additionalProperties"]; ok {
+			apply(x, v[k], inst+"/"+escapeJSONPointer(k), kw+"/additionalProperties")
+			a.props[k] = true
+		}
+		if x, ok := m["propertyNames"]; ok {
+			apply(x, k, inst+"/"+escapeJSONPointer(k), kw+"/propertyNames")
+		}
+	}
+	if x, ok := m["dependentSchemas"].(map[string]interface{}); ok {
+		for _, k := range sortedKeys(x) {
+			if _, ok := v[k]; ok {
+				apply(x[k], v, inst, kw+"/dependentSchemas/"+escapeJSONPointer(k))
+			}
+		}
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func funcValidate(v, x interface{}) interface{} {
+	switch x.(type) {
+	case map[string]interface{}, bool:
+	default:
+		return &funcTypeError{"validate", x}
+	}
+	errs, _ := newSchemaValidator(x).validate(x, v, "", "")
+	if len(errs) == 0 {
+		return true
+	}
+	return errs
+}
+
+// schemaInference infers the JSON Schema from the sample values. The schemas
+// of the samples are merged; the types are united, the properties of the
+// objects and the items of the arrays are merged, and the properties in all
+// the objects are required.
+type schemaInference struct {
+	types   map[string]bool
+	objects int
+	props   map[string]*schemaInference
+	keys    []string // keys of props in the order of appearance
+	counts  map[string]int
+	items   *schemaInference
+}
+
+func newSchemaInference() *schemaInference {
+	return &schemaInference{
+		types:  make(map[string]bool),
+		props:  make(map[string]*schemaInference),
+		counts: make(map[string]int),
+	}
+}
+
+func (s *schemaInference) add(v interface{}) {
+	switch v := v.(type) {
+	case []interface{}:
+		s.types["array"] = true
+		for _, v := range v {
+			if s.items == nil {
+				s.items = newSchemaInference()
+			}
+			s.items.add(v)
+		}
+	case map[string]interface{}:
+		s.addObject(sortedKeys(v), v)
+	case *OrderedMap:
+		s.addObject(v.keys, v.values)
+	default:
+		switch t := typeof(v); t {
+		case "number":
+			if r, ok := toRat(v); ok && r.IsInt() {
+				s.types["integer"] = true
+			} else {
+				s.types["number"] = true
+			}
+		case "datetime", "bytes":
+			s.types["string"] = true
+		default:
+			s.types[t] = true
+		}
+	}
+}
+
+func (s *schemaInference) addObject(keys []string, values map[string]interface{}) {
+	s.types["object"] = true
+	s.objects++
+	for _, k := range keys {
+		if s.props[k] == nil {
+			s.props[k] = newSchemaInference()
+			s.keys = append(s.keys, k)
+		}
+		s.props[k].add(values[k])
+		s.counts[k]++
+	}
+}
+
+// toSchema builds the schema, where the properties are sorted by the keys, or
+// kept in the order of appearance in *OrderedMap when ordered is true.
+func (s *schemaInference) toSchema(ordered bool) interface{} {
+	var types []interface{}
+	for _, t := range []string{"null", "boolean", "integer", "number", "string", "array", "object"} {
+		if s.types[t] && (t != "integer" || !s.types["number"]) {
+			types = append(types, t)
+		}
+	}
+	m := make(map[string]interface{})
+	switch len(types) {
+	case 0:
+	case 1:
+		m["type"] = types[0]
+	default:
+		m["type"] = types
+	}
+	if s.items != nil {
+		m["items"] = s.items.toSchema(ordered)
+	}
+	if s.types["object"] {
+		props := make(map[string]interface{}, len(s.props))
+		required := []interface{}{}
+		keys := make([]string, len(s.keys))
+		copy(keys, s.keys)
+		if !ordered {
+			sort.Strings(keys)
+		}
+		for _, k := range keys {
+			props[k] = s.props[k].toSchema(ordered)
+			if s.counts[k] == s.objects {
+				required = append(required, k)
+			}
+		}
+		m["properties"], m["required"] = props, required
+		if ordered {
+			m["properties"] = newOrderedMapWithKeys(keys, props)
+		}
+	}
+	if ordered {
+		keys := make([]string, 0, len(m))
+		for _, k := range []string{"type", "items", "properties", "required"} {
+			if _, ok := m[k]; ok {
+				keys = append(keys, k)
+			}
+		}
+		return newOrderedMapWithKeys(keys, m)
+	}
+	return m
+}
+
+func funcToSchema(v interface{}) interface{} {
+	return toSchema(v, false)
+}
+
+func funcToSchemaOrdered(v interface{}) interface{} {
+	return toSchema(v, true)
+}
+
+func toSchema(v interface{}, ordered bool) interface{} {
+	vs, ok := v.([]interface{})
+	if !ok {
+		return &expectedArrayError{v}
+	}
+	s := newSchemaInference()
+	for _, v := range vs {
+		s.add(v)
+	}
+	return s.toSchema(ordered)
+}
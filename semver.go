@@ -0,0 +1,403 @@
+package gojq
+
+import (
+	"strconv"
+	"strings"
+)
+
+// semver is the semantic version (https://semver.org/).
+type semver struct {
+	major, minor, patch int
+	prerelease, build   []string
+}
+
+func parseSemver(s string) (*semver, bool) {
+	p, ok := parseSemverPartial(strings.TrimPrefix(s, "v"))
+	if !ok || p.n < 3 {
+		return nil, false
+	}
+	return p.semver, true
+}
+
+// semverPartial is the version in the ranges, which can omit the components
+// or use the wildcards (1.2, 1.x, *).
+type semverPartial struct {
+	*semver
+	n int // the number of the specified components
+}
+
+func parseSemverPartial(s string) (*semverPartial, bool) {
+	v := &semver{}
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		if v.build = strings.Split(s[i+1:], "."); !isSemverIdentifiers(v.build, false) {
+			return nil, false
+		}
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		if v.prerelease = strings.Split(s[i+1:], "."); !isSemverIdentifiers(v.prerelease, true) {
+			return nil, false
+		}
+		s = s[:i]
+	}
+	xs := strings.Split(s, ".")
+	if len(xs) > 3 {
+		return nil, false
+	}
+	n := len(xs)
+	for i, x := range xs {
+		if x == "x" || x == "X" || x == "*" {
+			if n == len(xs) {
+				n = i
+			}
+			continue
+		}
+		if n < len(xs) {
+			return nil, false
+		}
+		d, ok := parseSemverNumber(x)
+		if !ok {
+			return nil, false
+		}
+		switch i {
+		case 0:
+			v.major = d
+		case 1:
+			v.minor = d
+		default:
+			v.patch = d
+		}
+	}
+	if n < 3 && (v.prerelease != nil || v.build != nil) {
+		return nil, false
+	}
+	return &semverPartial{v, n}, true
+}
+
+func parseSemverNumber(s string) (int, bool) {
+	if s == "" || len(s) > 1 && s[0] == '0' || !isSemverNumeric(s) {
+		return 0, false
+	}
+	d, err := strconv.Atoi(s)
+	return d, err == nil
+}
+
+func isSemverIdentifiers(xs []string, prerelease bool) bool {
+	for _, x := range xs {
+		if x == "" {
+			return false
+		}
+		for i := 0; i < len(x); i++ {
+			if c := x[i]; !('0' <= c && c <= '9' || 'A' <= c && c <= 'Z' ||
+				'a' <= c && c <= 'z' || c == '-') {
+				return false
+			}
+		}
+		if prerelease && len(x) > 1 && x[0] == '0' && isSemverNumeric(x) {
+			return false
+		}
+	}
+	return true
+}
+
+func (v *semver) compare(w *semver) int {
+	if c := compare(v.major, w.major); c != 0 {
+		return c
+	}
+	if c := compare(v.minor, w.minor); c != 0 {
+		return c
+	}
+	if c := compare(v.patch, w.patch); c != 0 {
+		return c
+	}
+	switch {
+	case len(v.prerelease) == 0:
+		if len(w.prerelease) == 0 {
+			return 0
+		}
+		return 1
+	case len(w.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.prerelease) && i < len(w.prerelease); i++ {
+		if c := compareSemverIdentifier(v.prerelease[i], w.prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return compare(len(v.prerelease), len(w.prerelease))
+}
+
+// compareSemverIdentifier compares the identifiers of the pre-release versions.
+// The numeric identifiers have lower precedence than the alphanumeric ones.
+func compareSemverIdentifier(x, y string) int {
+	switch xn, yn := isSemverNumeric(x), isSemverNumeric(y); {
+	case xn && yn:
+		if c := compare(len(x), len(y)); c != 0 {
+			return c
+		}
+		return strings.Compare(x, y)
+	case xn:
+		return -1
+	case yn:
+		return 1
+	default:
+		return strings.Compare(x, y)
+	}
+}
+
+func isSemverNumeric(s string) bool {
+	return strings.Trim(s, "0123456789") == ""
+}
+
+func (v *semver) toObject() map[string]interface{} {
+	prerelease := make([]interface{}, len(v.prerelease))
+	for i, x := range v.prerelease {
+		prerelease[i] = x
+		if isSemverNumeric(x) {
+			if d, err := strconv.Atoi(x); err == nil {
+				prerelease[i] = d
+			}
+		}
+	}
+	build := make([]interface{}, len(v.build))
+	for i, x := range v.build {
+		build[i] = x
+	}
+	return map[string]interface{}{
+		"major":      v.major,
+		"minor":      v.minor,
+		"patch":      v.patch,
+		"prerelease": prerelease,
+		"build":      build,
+	}
+}
+
+type semverComparator struct {
+	op string
+	v  *semver
+}
+
+func (c *semverComparator) matches(v *semver) bool {
+	x := v.compare(c.v)
+	switch c.op {
+	case "<":
+		return x < 0
+	case "<=":
+		return x <= 0
+	case ">":
+		return x > 0
+	case ">=":
+		return x >= 0
+	default:
+		return x == 0
+	}
+}
+
+// parseSemverRange parses the range in the syntax of npm; the comparator sets
+// joined by ||, which consist of the comparators separated by spaces. The
+// comparators are the primitive ones (<, <=, >, >=, =), the X-ranges (1.2.x),
+// the tilde ranges (~1.2.3), the caret ranges (^1.2.3) and the hyphen ranges
+// (1.2.3 - 2.3.4).
+func parseSemverRange(s string) ([][]*semverComparator, bool) {
+	var sets [][]*semverComparator
+	for _, s := range strings.Split(s, "||") {
+		var tokens []string
+		for _, t := range strings.Fields(s) {
+			if i := len(tokens) - 1; i >= 0 && strings.Trim(tokens[i], "<>=~^") == "" {
+				tokens[i] += t
+			} else {
+				tokens = append(tokens, t)
+			}
+		}
+		if len(tokens) == 3 && tokens[1] == "-" {
+			set, ok := semverHyphenRange(tokens[0], tokens[2])
+			if !ok {
+				return nil, false
+			}
+			sets = append(sets, set)
+			continue
+		}
+		set := []*semverComparator{}
+		for _, t := range tokens {
+			cs, ok := parseSemverComparator(t)
+			if !ok {
+				return nil, false
+			}
+			set = append(set, cs...)
+		}
+		sets = append(sets, set)
+	}
+	return sets, true
+}
+
+func parseSemverComparator(s string) ([]*semverComparator, bool) {
+	var op string
+	for _, o := range []string{">=", "<=", "~>", ">", "<", "=", "~", "^"} {
+		if strings.HasPrefix(s, o) {
+			op, s = o, s[len(o):]
+			break
+		}
+	}
+	p, ok := parseSemverPartial(strings.TrimPrefix(s, "v"))
+	if !ok {
+		return nil, false
+	}
+	lower := &semverComparator{">=", p.semver}
+	switch op {
+	case "~", "~>":
+		if p.n == 0 {
+			return nil, true
+		}
+		if p.n == 1 {
+			return []*semverComparator{lower, {"<", &semver{major: p.major + 1, prerelease: []string{"0"}}}}, true
+		}
+		return []*semverComparator{lower, {"<", &semver{major: p.major, minor: p.minor + 1, prerelease: []string{"0"}}}}, true
+	case "^":
+		if p.n == 0 {
+			return nil, true
+		}
+		upper := &semver{major: p.major + 1, prerelease: []string{"0"}}
+		if p.major == 0 && p.n >= 2 {
+			upper = &semver{minor: p.minor + 1, prerelease: []string{"0"}}
+			if p.minor == 0 && p.n == 3 {
+				upper = &semver{patch: p.patch + 1, prerelease: []string{"0"}}
+			}
+		}
+		return []*semverComparator{lower, {"<", upper}}, true
+	}
+	if p.n == 3 {
+		if op == "" {
+			op = "="
+		}
+		return []*semverComparator{{op, p.semver}}, true
+	}
+	none := []*semverComparator{{"<", &semver{prerelease: []string{"0"}}}}
+	switch op {
+	case ">":
+		if p.n == 0 {
+			return none, true
+		}
+		return []*semverComparator{{">=", p.bump()}}, true
+	case ">=":
+		return []*semverComparator{lower}, true
+	case "<":
+		if p.n == 0 {
+			return none, true
+		}
+		return []*semverComparator{{"<", p.withPrerelease()}}, true
+	case "<=":
+		if p.n == 0 {
+			return nil, true
+		}
+		return []*semverComparator{{"<", p.bump().withPrerelease()}}, true
+	default:
+		if p.n == 0 {
+			return nil, true
+		}
+		return []*semverComparator{lower, {"<", p.bump().withPrerelease()}}, true
+	}
+}
+
+func semverHyphenRange(l, r string) ([]*semverComparator, bool) {
+	p, ok := parseSemverPartial(strings.TrimPrefix(l, "v"))
+	if !ok {
+		return nil, false
+	}
+	q, ok := parseSemverPartial(strings.TrimPrefix(r, "v"))
+	if !ok {
+		return nil, false
+	}
+	set := []*semverComparator{{">=", p.semver}}
+	switch q.n {
+	case 0:
+	case 3:
+		set = append(set, &semverComparator{"<=", q.semver})
+	default:
+		set = append(set, &semverComparator{"<", q.bump().withPrerelease()})
+	}
+	return set, true
+}
+
+// bump increments the last specified component of the partial version.
+func (p *semverPartial) bump() *semver {
+	if p.n == 1 {
+		return &semver{major: p.major + 1}
+	}
+	return &semver{major: p.major, minor: p.minor + 1}
+}
+
+// withPrerelease returns the lowest pre-release version of the version.
+func (v *semver) withPrerelease() *semver {
+	return &semver{major: v.major, minor: v.minor, patch: v.patch, prerelease: []string{"0"}}
+}
+
+// satisfies reports whether the version satisfies the range. The pre-release
+// version satisfies the comparator set only when some comparator has the
+// pre-release version of the same major, minor and patch versions.
+func (v *semver) satisfies(sets [][]*semverComparator) bool {
+L:
+	for _, set := range sets {
+		allowed := len(v.prerelease) == 0
+		for _, c := range set {
+			if !c.matches(v) {
+				continue L
+			}
+			if len(c.v.prerelease) > 0 && c.v.major == v.major &&
+				c.v.minor == v.minor && c.v.patch == v.patch {
+				allowed = true
+			}
+		}
+		if allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func toSemver(name string, v interface{}) (*semver, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, &funcTypeError{name, v}
+	}
+	w, ok := parseSemver(s)
+	if !ok {
+		return nil, &semverError{"semantic version", s}
+	}
+	return w, nil
+}
+
+func funcSemverParse(v interface{}) interface{} {
+	w, err := toSemver("semver_parse", v)
+	if err != nil {
+		return err
+	}
+	return w.toObject()
+}
+
+func funcSemverCmp(_, x, y interface{}) interface{} {
+	v, err := toSemver("semver_cmp", x)
+	if err != nil {
+		return err
+	}
+	w, err := toSemver("semver_cmp", y)
+	if err != nil {
+		return err
+	}
+	return v.compare(w)
+}
+
+func funcSemverSatisfies(v, x interface{}) interface{} {
+	w, err := toSemver("semver_satisfies", v)
+	if err != nil {
+		return err
+	}
+	s, ok := x.(string)
+	if !ok {
+		return &funcTypeError{"semver_satisfies", x}
+	}
+	sets, ok := parseSemverRange(s)
+	if !ok {
+		return &semverError{"semantic version range", s}
+	}
+	return w.satisfies(sets)
+}
@@ -52,11 +52,14 @@ func (s *stack) lookup(f func(v interface{}) bool) interface{} {
 	panic("stack.lookup")
 }
 
+// save records the index and the limit to restore on backtracking, and raises
+// the limit to protect the values below the index. The limit before raising is
+// recorded so that the stack does not grow on each iteration.
 func (s *stack) save(index, limit *int) {
-	if s.index >= s.limit {
+	*index, *limit = s.index, s.limit
+	if s.index > s.limit {
 		s.limit = s.index
 	}
-	*index, *limit = s.index, s.limit
 }
 
 func (s *stack) restore(index, limit int) {
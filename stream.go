@@ -0,0 +1,257 @@
+package gojq
+
+import "sort"
+
+// fromStreamState is the state of fromstream, which builds the value from the
+// stream events in place. The value is not shared until it is emitted.
+type fromStreamState struct {
+	value   interface{}
+	done    bool
+	ordered bool
+}
+
+func funcFromStreamUpdate(v, x interface{}) interface{} {
+	return fromStreamUpdate(v, x, false)
+}
+
+func funcFromStreamUpdateOrdered(v, x interface{}) interface{} {
+	return fromStreamUpdate(v, x, true)
+}
+
+func fromStreamUpdate(v, x interface{}, ordered bool) interface{} {
+	s, ok := v.(*fromStreamState)
+	if !ok || s.done {
+		s = &fromStreamState{ordered: ordered}
+	}
+	xs, ok := x.([]interface{})
+	if !ok || len(xs) == 0 || len(xs) > 2 {
+		return &funcTypeError{"fromstream", x}
+	}
+	path, ok := xs[0].([]interface{})
+	if !ok {
+		return &funcTypeError{"fromstream", x}
+	}
+	if len(xs) == 1 {
+		s.done = len(path) == 1
+		return s
+	}
+	var err error
+	if s.value, err = fromStreamSet(s.value, path, xs[1], s.ordered); err != nil {
+		return err
+	}
+	s.done = len(path) == 0
+	return s
+}
+
+func funcFromStreamEmit(v interface{}, _ []interface{}) interface{} {
+	if s, ok := v.(*fromStreamState); ok && s.done {
+		return NewIter(s.value)
+	}
+	return emptyIter{}
+}
+
+// fromStreamSet sets the value at the path, updating the containers in place.
+// The containers in the leaf values are copied not to update the input values.
+// The objects are built as *OrderedMap when ordered is true.
+func fromStreamSet(x interface{}, path []interface{}, v interface{}, ordered bool) (interface{}, error) {
+	if len(path) == 0 {
+		return copyContainers(v), nil
+	}
+	switch p := path[0].(type) {
+	case string:
+		if ordered {
+			m, ok := x.(*OrderedMap)
+			if !ok {
+				if x != nil {
+					return nil, &expectedObjectError{x}
+				}
+				m = NewOrderedMap()
+			}
+			w, err := fromStreamSet(m.values[p], path[1:], v, ordered)
+			if err != nil {
+				return nil, err
+			}
+			m.Set(p, w)
+			return m, nil
+		}
+		m, ok := x.(map[string]interface{})
+		if !ok {
+			if x != nil {
+				return nil, &expectedObjectError{x}
+			}
+			m = map[string]interface{}{}
+		}
+		w, err := fromStreamSet(m[p], path[1:], v, ordered)
+		if err != nil {
+			return nil, err
+		}
+		m[p] = w
+		return m, nil
+	default:
+		i, ok := toInt(p)
+		if !ok || i < 0 {
+			return nil, &funcTypeError{"fromstream", path}
+		}
+		a, ok := x.([]interface{})
+		if !ok && x != nil {
+			return nil, &expectedArrayError{x}
+		}
+		for len(a) <= i {
+			a = append(a, nil)
+		}
+		w, err := fromStreamSet(a[i], path[1:], v, ordered)
+		if err != nil {
+			return nil, err
+		}
+		a[i] = w
+		return a, nil
+	}
+}
+
+func copyContainers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		w := make([]interface{}, len(v))
+		for i, x := range v {
+			w[i] = copyContainers(x)
+		}
+		return w
+	case map[string]interface{}:
+		w := make(map[string]interface{}, len(v))
+		for k, x := range v {
+			w[k] = copyContainers(x)
+		}
+		return w
+	case *OrderedMap:
+		w := v.clone()
+		for k, x := range w.values {
+			w.values[k] = copyContainers(x)
+		}
+		return w
+	default:
+		return v
+	}
+}
+
+func funcTruncateStream(v interface{}, args []interface{}) interface{} {
+	n, ok := toInt(args[0])
+	if !ok {
+		return NewIter(&funcTypeError{"truncate_stream", args[0]})
+	}
+	xs, ok := v.([]interface{})
+	if !ok || len(xs) == 0 {
+		return NewIter(&funcTypeError{"truncate_stream", v})
+	}
+	path, ok := xs[0].([]interface{})
+	if !ok {
+		return NewIter(&funcTypeError{"truncate_stream", v})
+	}
+	if len(path) <= n || n < 0 {
+		return emptyIter{}
+	}
+	ys := make([]interface{}, len(xs))
+	copy(ys, xs)
+	ys[0] = path[n:]
+	return NewIter(ys)
+}
+
+// toStreamIter emits the stream events of the value lazily in the depth-first
+// order, where the keys of the objects are sorted.
+type toStreamIter struct {
+	value   interface{}
+	pending bool
+	path    []interface{}
+	frames  []*toStreamFrame
+}
+
+type toStreamFrame struct {
+	keys   []interface{}
+	values []interface{}
+	index  int
+}
+
+func newToStreamFrame(v interface{}) *toStreamFrame {
+	switch v := v.(type) {
+	case []interface{}:
+		if len(v) == 0 {
+			return nil
+		}
+		keys := make([]interface{}, len(v))
+		for i := range v {
+			keys[i] = i
+		}
+		return &toStreamFrame{keys, v, 0}
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return nil
+		}
+		ks := make([]string, 0, len(v))
+		for k := range v {
+			ks = append(ks, k)
+		}
+		sort.Strings(ks)
+		keys, values := make([]interface{}, len(ks)), make([]interface{}, len(ks))
+		for i, k := range ks {
+			keys[i], values[i] = k, v[k]
+		}
+		return &toStreamFrame{keys, values, 0}
+	case *OrderedMap:
+		if v.Len() == 0 {
+			return nil
+		}
+		keys, values := make([]interface{}, v.Len()), make([]interface{}, v.Len())
+		for i, k := range v.keys {
+			keys[i], values[i] = k, v.values[k]
+		}
+		return &toStreamFrame{keys, values, 0}
+	default:
+		return nil
+	}
+}
+
+func (iter *toStreamIter) Next() (interface{}, bool) {
+	for {
+		if iter.pending {
+			iter.pending = false
+			v := iter.value
+			if w, ok := v.(JQValue); ok {
+				if _, ok := v.(*OrderedMap); !ok {
+					v = normalizeNumbers(w.JQValueToJSON())
+				}
+			}
+			if f := newToStreamFrame(v); f != nil {
+				iter.frames = append(iter.frames, f)
+				iter.path = append(iter.path, nil)
+				continue
+			}
+			if m, ok := v.(*OrderedMap); ok {
+				v = m.JQValueToJSON()
+			}
+			return []interface{}{iter.copyPath(), v}, true
+		}
+		if len(iter.frames) == 0 {
+			return nil, false
+		}
+		f := iter.frames[len(iter.frames)-1]
+		if f.index < len(f.keys) {
+			iter.path[len(iter.path)-1] = f.keys[f.index]
+			iter.value, iter.pending = f.values[f.index], true
+			f.index++
+			continue
+		}
+		v := []interface{}{iter.copyPath()}
+		iter.frames = iter.frames[:len(iter.frames)-1]
+		iter.path = iter.path[:len(iter.path)-1]
+		return v, true
+	}
+}
+
+func (iter *toStreamIter) copyPath() []interface{} {
+	path := make([]interface{}, len(iter.path))
+	copy(path, iter.path)
+	return path
+}
+
+func funcToStream(v interface{}, _ []interface{}) interface{} {
+	return &toStreamIter{value: v, pending: true}
+}
@@ -0,0 +1,291 @@
+package gojq
+
+import (
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// textWidth calculates the display width of the strings. The East Asian
+// ambiguous characters are treated as narrow regardless of the locale.
+var textWidth = &runewidth.Condition{}
+
+func toText(name string, v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	default:
+		if _, ok := toFloat(v); ok {
+			return jsonMarshal(v), nil
+		}
+		return "", &funcTypeError{name, v}
+	}
+}
+
+// padFunc creates the padding function, which pads the input to the display
+// width with the fill string. The left padding is calculated by the function
+// from the width to pad.
+func padFunc(name string, left func(int) int) function {
+	return argFunc2(func(v, x, y interface{}) interface{} {
+		s, err := toText(name, v)
+		if err != nil {
+			return err
+		}
+		width, ok := toInt(x)
+		if !ok {
+			return &funcTypeError{name, x}
+		}
+		fill, ok := y.(string)
+		if !ok || textWidth.StringWidth(fill) == 0 {
+			return &funcTypeError{name, y}
+		}
+		w := width - textWidth.StringWidth(s)
+		if w <= 0 {
+			return s
+		}
+		l := left(w)
+		return padding(fill, l) + s + padding(fill, w-l)
+	})
+}
+
+// padding repeats the fill string to the display width. The rest which the
+// wide character does not fit in is filled with spaces.
+func padding(fill string, width int) string {
+	var sb strings.Builder
+	for w := 0; w < width; {
+		for _, r := range fill {
+			rw := textWidth.RuneWidth(r)
+			if w+rw > width {
+				sb.WriteString(strings.Repeat(" ", width-w))
+				return sb.String()
+			}
+			sb.WriteRune(r)
+			if w += rw; w == width {
+				return sb.String()
+			}
+		}
+	}
+	return sb.String()
+}
+
+func funcWrap(v, x interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return &funcTypeError{"wrap", v}
+	}
+	width, ok := toInt(x)
+	if !ok || width <= 0 {
+		return &funcTypeError{"wrap", x}
+	}
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = wrapLine(line, width)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// wrapLine breaks the line at the spaces so that each line fits in the display
+// width. The words longer than the width are broken at the characters.
+func wrapLine(s string, width int) string {
+	var sb strings.Builder
+	var w int
+	for _, word := range strings.Fields(s) {
+		ww := textWidth.StringWidth(word)
+		if w > 0 {
+			if w+1+ww <= width {
+				sb.WriteByte(' ')
+				sb.WriteString(word)
+				w += 1 + ww
+				continue
+			}
+			sb.WriteByte('\n')
+			w = 0
+		}
+		for _, r := range word {
+			rw := textWidth.RuneWidth(r)
+			if w > 0 && w+rw > width {
+				sb.WriteByte('\n')
+				w = 0
+			}
+			sb.WriteRune(r)
+			w += rw
+		}
+	}
+	return sb.String()
+}
+
+func funcTruncate(v, x, y interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return &funcTypeError{"truncate", v}
+	}
+	width, ok := toInt(x)
+	if !ok || width < 0 {
+		return &funcTypeError{"truncate", x}
+	}
+	ellipsis, ok := y.(string)
+	if !ok {
+		return &funcTypeError{"truncate", y}
+	}
+	if textWidth.StringWidth(s) <= width {
+		return s
+	}
+	if w := textWidth.StringWidth(ellipsis); w < width {
+		return truncateWidth(s, width-w) + ellipsis
+	}
+	return truncateWidth(ellipsis, width)
+}
+
+// truncateWidth returns the longest prefix of the string within the width.
+func truncateWidth(s string, width int) string {
+	var w int
+	for i, r := range s {
+		if w += textWidth.RuneWidth(r); w > width {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// stripANSI removes the ANSI escape sequences; the control sequences (colors
+// and cursor movements), the operating system commands (hyperlinks and window
+// titles), and the other escape sequences (character set designations).
+func stripANSI(s string) string {
+	i := strings.IndexByte(s, '\x1b')
+	if i < 0 {
+		return s
+	}
+	var sb strings.Builder
+	for ; i >= 0; i = strings.IndexByte(s, '\x1b') {
+		sb.WriteString(s[:i])
+		s = s[i+1:]
+		if s == "" {
+			break
+		}
+		switch s[0] {
+		case '[':
+			j := 1
+			for j < len(s) && (s[j] < 0x40 || s[j] > 0x7e) {
+				j++
+			}
+			if j < len(s) {
+				j++
+			}
+			s = s[j:]
+		case ']':
+			j := 1
+			for ; j < len(s); j++ {
+				if s[j] == '\a' {
+					j++
+					break
+				}
+				if s[j] == '\x1b' && j+1 < len(s) && s[j+1] == '\\' {
+					j += 2
+					break
+				}
+			}
+			s = s[j:]
+		default:
+			j := 0
+			for j < len(s)-1 && 0x20 <= s[j] && s[j] <= 0x2f {
+				j++
+			}
+			s = s[j+1:]
+		}
+	}
+	sb.WriteString(s)
+	return sb.String()
+}
+
+func funcStripANSI(v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return &funcTypeError{"strip_ansi", v}
+	}
+	return stripANSI(s)
+}
+
+// funcTermWidth calculates the display width of the string ignoring the ANSI
+// escape sequences. The width of the multi-line string is of the widest line.
+func funcTermWidth(v interface{}) interface{} {
+	s, err := toText("termwidth", v)
+	if err != nil {
+		return err
+	}
+	var width int
+	for _, line := range strings.Split(stripANSI(s), "\n") {
+		if w := textWidth.StringWidth(line); w > width {
+			width = w
+		}
+	}
+	return width
+}
+
+// levenshtein calculates the edit distance of the strings in characters.
+func levenshtein(s, t string) int {
+	xs, ys := []rune(s), []rune(t)
+	ds := make([]int, len(ys)+1)
+	for j := range ds {
+		ds[j] = j
+	}
+	for _, x := range xs {
+		prev := ds[0]
+		ds[0]++
+		for j, y := range ys {
+			d := prev
+			if x != y {
+				d = minInt3(prev, ds[j], ds[j+1]) + 1
+			}
+			prev, ds[j+1] = ds[j+1], d
+		}
+	}
+	return ds[len(ys)]
+}
+
+func minInt3(x, y, z int) int {
+	if y < x {
+		x = y
+	}
+	if z < x {
+		x = z
+	}
+	return x
+}
+
+func funcLevenshtein(_, x, y interface{}) interface{} {
+	s, ok := x.(string)
+	if !ok {
+		return &funcTypeError{"levenshtein", x}
+	}
+	t, ok := y.(string)
+	if !ok {
+		return &funcTypeError{"levenshtein", y}
+	}
+	return levenshtein(s, t)
+}
+
+// funcFuzzyMatch checks the similarity of the input and the pattern is greater
+// than or equal to the threshold. The similarity is 1 - distance / length of
+// the longer string, which ranges from 0 to 1.
+func funcFuzzyMatch(v, x, y interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return &funcTypeError{"fuzzy_match", v}
+	}
+	t, ok := x.(string)
+	if !ok {
+		return &funcTypeError{"fuzzy_match", x}
+	}
+	threshold, ok := toFloat(y)
+	if !ok {
+		return &funcTypeError{"fuzzy_match", y}
+	}
+	l := len([]rune(s))
+	if m := len([]rune(t)); m > l {
+		l = m
+	}
+	if l == 0 {
+		return true
+	}
+	return 1-float64(levenshtein(s, t))/float64(l) >= threshold
+}
@@ -0,0 +1,175 @@
+package gojq
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/itchyny/timefmt-go"
+)
+
+var timeZones sync.Map // map[string]*time.Location
+
+// loadTimeZone loads the location of the IANA time zone name, or the fixed
+// offset like "+09:00" and "-0500".
+func loadTimeZone(name string) (*time.Location, bool) {
+	if loc, ok := timeZones.Load(name); ok {
+		return loc.(*time.Location), true
+	}
+	loc, ok := parseZoneOffset(name)
+	if !ok {
+		var err error
+		if loc, err = time.LoadLocation(name); err != nil {
+			return nil, false
+		}
+	}
+	timeZones.Store(name, loc)
+	return loc, true
+}
+
+func parseZoneOffset(s string) (*time.Location, bool) {
+	if len(s) != 5 && len(s) != 6 || s[0] != '+' && s[0] != '-' {
+		return nil, false
+	}
+	hh, mm := s[1:3], s[3:]
+	if len(s) == 6 {
+		if s[3] != ':' {
+			return nil, false
+		}
+		mm = s[4:]
+	}
+	h, err := strconv.Atoi(hh)
+	if err != nil || h > 23 || hh[0] == '+' || hh[0] == '-' {
+		return nil, false
+	}
+	m, err := strconv.Atoi(mm)
+	if err != nil || m > 59 || mm[0] == '+' || mm[0] == '-' {
+		return nil, false
+	}
+	offset := (h*60 + m) * 60
+	if s[0] == '-' {
+		offset = -offset
+	}
+	return time.FixedZone(s, offset), true
+}
+
+func toTimeZone(fname string, v interface{}) (*time.Location, error) {
+	name, ok := v.(string)
+	if !ok {
+		return nil, &funcTypeError{fname, v}
+	}
+	loc, ok := loadTimeZone(name)
+	if !ok {
+		return nil, &timeZoneError{name}
+	}
+	return loc, nil
+}
+
+func funcStrftimeTZ(v, tz, x interface{}) interface{} {
+	loc, err := toTimeZone("strftime_tz", tz)
+	if err != nil {
+		return err
+	}
+	format, ok := x.(string)
+	if !ok {
+		return &funcTypeError{"strftime_tz", x}
+	}
+	switch v := v.(type) {
+	case time.Time:
+		return timefmt.Format(v.In(loc), format)
+	case []interface{}:
+		t, err := arrayToTime("strftime_tz", v, loc)
+		if err != nil {
+			return err
+		}
+		return timefmt.Format(t, format)
+	default:
+		if w, ok := toFloat(v); ok {
+			return timefmt.Format(epochToTime(w).In(loc), format)
+		}
+		return &funcTypeError{"strftime_tz", v}
+	}
+}
+
+func funcStrptimeTZ(v, tz, x interface{}) interface{} {
+	loc, err := toTimeZone("strptime_tz", tz)
+	if err != nil {
+		return err
+	}
+	format, ok := x.(string)
+	if !ok {
+		return &funcTypeError{"strptime_tz", x}
+	}
+	s, ok := v.(string)
+	if !ok {
+		return &funcTypeError{"strptime_tz", v}
+	}
+	t, err := timefmt.Parse(s, format)
+	if err != nil {
+		return err
+	}
+	return epochToArray(timeToEpoch(interpretTimeZone(t, format, loc)), time.UTC)
+}
+
+func funcToTimeZone(v, tz interface{}) interface{} {
+	loc, err := toTimeZone("totimezone", tz)
+	if err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case time.Time:
+		return v.In(loc)
+	case []interface{}:
+		t, err := arrayToTime("totimezone", v, time.UTC)
+		if err != nil {
+			return err
+		}
+		return epochToArray(timeToEpoch(t), loc)
+	default:
+		if x, ok := toFloat(v); ok {
+			return epochToArray(x, loc)
+		}
+		return &funcTypeError{"totimezone", v}
+	}
+}
+
+// interpretTimeZone interprets the parsed time in the location, unless the
+// format has the offset directive. The zone abbreviations of the location
+// (EST and EDT in America/New_York, for example) are resolved to the offsets.
+func interpretTimeZone(t time.Time, format string, loc *time.Location) time.Time {
+	var hasOffset, hasAbbr bool
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		for i++; i < len(format) && format[i] == ':'; i++ {
+		}
+		if i < len(format) {
+			switch format[i] {
+			case 'z', 's':
+				hasOffset = true
+			case 'Z':
+				hasAbbr = true
+			}
+		}
+	}
+	if hasOffset {
+		return t
+	}
+	year, month, day := t.Date()
+	hour, min, sec := t.Clock()
+	if hasAbbr {
+		name, _ := t.Zone()
+		if name == "UTC" || name == "GMT" {
+			return t
+		}
+		for _, m := range []time.Month{time.January, time.July} {
+			if n, offset := time.Date(year, m, 1, 0, 0, 0, 0, loc).Zone(); n == name {
+				return time.Date(year, month, day, hour, min, sec, t.Nanosecond(),
+					time.FixedZone(name, offset))
+			}
+		}
+		return t
+	}
+	return time.Date(year, month, day, hour, min, sec, t.Nanosecond(), loc)
+}
@@ -0,0 +1,67 @@
+package gojq
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// topK is the bounded heap of the indices of the elements, keeping the worst
+// element at the top to be replaced by the better one.
+type topK struct {
+	keys    []interface{}
+	indices []int
+	sign    int
+}
+
+func (h *topK) better(i, j int) bool {
+	if c := compare(h.keys[i], h.keys[j]) * h.sign; c != 0 {
+		return c < 0
+	}
+	return i < j
+}
+
+func (h *topK) Len() int           { return len(h.indices) }
+func (h *topK) Less(i, j int) bool { return h.better(h.indices[j], h.indices[i]) }
+func (h *topK) Swap(i, j int)      { h.indices[i], h.indices[j] = h.indices[j], h.indices[i] }
+func (h *topK) Push(x interface{}) { h.indices = append(h.indices, x.(int)) }
+func (h *topK) Pop() interface{} {
+	i := h.indices[len(h.indices)-1]
+	h.indices = h.indices[:len(h.indices)-1]
+	return i
+}
+
+// topKFunc creates the function to find the k elements with the largest (or
+// the smallest) keys without sorting the whole array. The result is sorted,
+// and the former elements come first on ties.
+func topKFunc(name string, sign int) function {
+	return argFunc2(func(v, x, y interface{}) interface{} {
+		vs, ok := v.([]interface{})
+		if !ok {
+			return &expectedArrayError{v}
+		}
+		k, ok := toInt(x)
+		if !ok || k < 0 {
+			return &funcTypeError{name, x}
+		}
+		if k > len(vs) {
+			k = len(vs)
+		}
+		h := &topK{y.([]interface{}), make([]int, 0, k), sign}
+		for i := range vs {
+			if h.Len() < k {
+				heap.Push(h, i)
+			} else if k > 0 && h.better(i, h.indices[0]) {
+				h.indices[0] = i
+				heap.Fix(h, 0)
+			}
+		}
+		sort.Slice(h.indices, func(i, j int) bool {
+			return h.better(h.indices[i], h.indices[j])
+		})
+		ys := make([]interface{}, len(h.indices))
+		for i, j := range h.indices {
+			ys[i] = vs[j]
+		}
+		return ys
+	})
+}
@@ -0,0 +1,428 @@
+package gojq
+
+import "strings"
+
+// kinds is the set of the types of the values which an expression may emit.
+type kinds uint8
+
+const (
+	kindNull kinds = 1 << iota
+	kindBoolean
+	kindNumber
+	kindString
+	kindArray
+	kindObject
+	kindOther // byte strings, datetime values and custom values
+
+	kindNone kinds = 0
+	kindAny  kinds = 1<<iota - 1
+)
+
+func (ks kinds) String() string {
+	var names []string
+	for i, name := range []string{
+		"null", "boolean", "number", "string", "array", "object", "other",
+	} {
+		if ks&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, " or ")
+}
+
+// builtinKinds is the input kinds accepted by the builtin functions without
+// arguments, and the kinds of the values they emit.
+var builtinKinds = map[string][2]kinds{
+	"length":         {kindNull | kindNumber | kindString | kindArray | kindObject, kindNumber},
+	"utf8bytelength": {kindString, kindNumber},
+	"not":            {kindAny, kindBoolean},
+	"keys":           {kindArray | kindObject, kindArray},
+	"keys_unsorted":  {kindArray | kindObject, kindArray},
+	"to_entries":     {kindArray | kindObject, kindArray},
+	"from_entries":   {kindArray, kindObject},
+	"add":            {kindArray | kindObject, kindAny},
+	"tostring":       {kindAny, kindString},
+	"tojson":         {kindAny, kindString},
+	"fromjson":       {kindString, kindAny},
+	"tonumber":       {kindNumber | kindString, kindNumber},
+	"type":           {kindAny, kindString},
+	"ascii_downcase": {kindString, kindString},
+	"ascii_upcase":   {kindString, kindString},
+	"explode":        {kindString, kindArray},
+	"implode":        {kindArray, kindString},
+	"reverse":        {kindArray | kindString, kindArray | kindString},
+	"sort":           {kindArray, kindArray},
+	"unique":         {kindArray, kindArray},
+	"flatten":        {kindArray | kindObject, kindArray},
+	"min":            {kindArray, kindAny},
+	"max":            {kindArray, kindAny},
+	"floor":          {kindNumber, kindNumber},
+	"ceil":           {kindNumber, kindNumber},
+	"round":          {kindNumber, kindNumber},
+	"sqrt":           {kindNumber, kindNumber},
+	"fabs":           {kindNumber, kindNumber},
+	"abs":            {kindNumber, kindNumber},
+	"toarray":        {kindAny, kindArray},
+	"empty":          {kindAny, kindNone},
+	"error":          {kindAny, kindNone},
+	"null":           {kindAny, kindNull},
+	"true":           {kindAny, kindBoolean},
+	"false":          {kindAny, kindBoolean},
+}
+
+// checkTypes infers the kinds of the values through the query, and warns the
+// expressions which always fail at runtime, like indexing a number by a key.
+// The inference is conservative; the inputs, the variables and the functions
+// defined in the query can emit values of any kind, and the errors caught by
+// try or ? are not reported.
+func checkTypes(q *Query, warn func(string)) {
+	c := &typeChecker{warn: warn, defined: make(map[string]bool)}
+	Walk(q, func(n Node) bool {
+		if fd, ok := n.(*FuncDef); ok {
+			c.defined[fd.Name] = true
+			for _, arg := range fd.Args {
+				c.defined[arg] = true
+			}
+		}
+		return true
+	})
+	c.query(q, kindAny)
+}
+
+type typeChecker struct {
+	warn    func(string)
+	defined map[string]bool // the names of the functions defined in the query
+	catch   int             // the depth of try and ?
+}
+
+func (c *typeChecker) fail(e Node, message string) {
+	if c.catch == 0 {
+		c.warn(e.String() + " always fails: " + message)
+	}
+}
+
+func (c *typeChecker) queries(qs []*Query, in kinds) {
+	for _, q := range qs {
+		c.query(q, in)
+	}
+}
+
+// query returns the kinds of the values emitted by the query for the input.
+func (c *typeChecker) query(e *Query, in kinds) kinds {
+	for _, fd := range e.FuncDefs {
+		c.query(fd.Body, kindAny)
+	}
+	if e.Term != nil {
+		return c.term(e.Term, in)
+	}
+	if e.Right == nil {
+		if e.Func == "." || e.Func == "" {
+			return in
+		}
+		return c.function(e, &Func{Name: e.Func}, in)
+	}
+	switch e.Op {
+	case OpPipe:
+		return c.query(e.Right, c.query(e.Left, in))
+	case OpComma, OpAlt:
+		return c.query(e.Left, in) | c.query(e.Right, in)
+	case OpAdd, OpSub, OpMul, OpDiv, OpMod:
+		return c.binop(e, c.query(e.Left, in), c.query(e.Right, in))
+	case OpEq, OpNe, OpGt, OpLt, OpGe, OpLe, OpAnd, OpOr:
+		c.query(e.Left, in)
+		c.query(e.Right, in)
+		return kindBoolean
+	default:
+		c.query(e.Left, in)
+		c.query(e.Right, in)
+		return kindAny
+	}
+}
+
+// binop returns the kinds of the result of the arithmetic operator, and warns
+// when the operator fails for all the kinds of the operands.
+func (c *typeChecker) binop(e *Query, l, r kinds) kinds {
+	if l == kindNone || r == kindNone || (l|r)&kindOther != 0 {
+		return kindAny
+	}
+	var ks kinds
+	for i := kindNull; i < kindOther; i <<= 1 {
+		for j := kindNull; j < kindOther; j <<= 1 {
+			if l&i != 0 && r&j != 0 {
+				ks |= binopKinds(e.Op, i, j)
+			}
+		}
+	}
+	if ks == kindNone {
+		var name string
+		switch e.Op {
+		case OpAdd:
+			name = "add"
+		case OpSub:
+			name = "subtract"
+		case OpMul:
+			name = "multiply"
+		case OpDiv:
+			name = "divide"
+		default:
+			name = "modulo"
+		}
+		c.fail(e, "cannot "+name+": "+l.String()+" and "+r.String())
+	}
+	return ks
+}
+
+func binopKinds(op Operator, l, r kinds) kinds {
+	switch {
+	case l == kindNumber && r == kindNumber:
+		return kindNumber
+	case op == OpAdd:
+		if l == kindNull {
+			return r
+		} else if r == kindNull {
+			return l
+		} else if l == r && l != kindBoolean {
+			return l
+		}
+	case op == OpSub:
+		if l == kindArray && r == kindArray {
+			return kindArray
+		}
+	case op == OpMul:
+		if l == kindString && r == kindNumber || l == kindNumber && r == kindString {
+			return kindString | kindNull
+		} else if l == kindObject && r == kindObject {
+			return kindObject
+		}
+	case op == OpDiv:
+		if l == kindString && r == kindString {
+			return kindArray
+		}
+	}
+	return kindNone
+}
+
+// term returns the kinds of the values emitted by the term for the input.
+func (c *typeChecker) term(e *Term, in kinds) kinds {
+	var optional bool
+	for _, s := range e.SuffixList {
+		optional = optional || s.Optional
+	}
+	if optional {
+		c.catch++
+		defer func() { c.catch-- }()
+	}
+	ks := kindAny
+	switch e.Type {
+	case TermTypeIdentity:
+		ks = in
+	case TermTypeNull:
+		ks = kindNull
+	case TermTypeTrue, TermTypeFalse:
+		ks = kindBoolean
+	case TermTypeNumber:
+		ks = kindNumber
+	case TermTypeString:
+		c.str(e.Str, in)
+		ks = kindString
+	case TermTypeFormat:
+		if e.Str != nil {
+			c.str(e.Str, in)
+		}
+		ks = kindString
+	case TermTypeIndex:
+		ks = c.index(e, e.Index, in)
+	case TermTypeFunc:
+		ks = c.function(e, e.Func, in)
+	case TermTypeObject:
+		c.object(e.Object, in)
+		ks = kindObject
+	case TermTypeArray:
+		if e.Array.Query != nil {
+			c.query(e.Array.Query, in)
+		}
+		ks = kindArray
+	case TermTypeUnary:
+		if ks = c.term(e.Unary.Term, in); ks&(kindNumber|kindOther) == 0 && ks != kindNone {
+			if e.Unary.Op == OpSub {
+				c.fail(e, "cannot negate: "+ks.String())
+			} else {
+				c.fail(e, "cannot plus: "+ks.String())
+			}
+		}
+		ks &= kindNumber | kindOther
+	case TermTypeIf:
+		ks = c.ifelse(e.If, in)
+	case TermTypeTry:
+		c.catch++
+		ks = c.query(e.Try.Body, in)
+		c.catch--
+		if e.Try.Catch != nil {
+			ks |= c.query(e.Try.Catch, kindAny)
+		}
+	case TermTypeReduce:
+		c.term(e.Reduce.Term, in)
+		c.query(e.Reduce.Start, in)
+		c.query(e.Reduce.Update, kindAny)
+	case TermTypeForeach:
+		c.term(e.Foreach.Term, in)
+		c.query(e.Foreach.Start, in)
+		c.query(e.Foreach.Update, kindAny)
+		if e.Foreach.Extract != nil {
+			c.query(e.Foreach.Extract, kindAny)
+		}
+	case TermTypeLabel:
+		ks = c.query(e.Label.Body, in)
+	case TermTypeBreak:
+		ks = kindNone
+	case TermTypeQuery:
+		ks = c.query(e.Query, in)
+	}
+	for _, s := range e.SuffixList {
+		switch {
+		case s.Index != nil:
+			ks = c.index(e, s.Index, ks)
+		case s.Iter:
+			if ks&(kindArray|kindObject|kindOther) == 0 && ks != kindNone {
+				c.fail(e, "cannot iterate over: "+ks.String())
+			}
+			if ks != kindNone {
+				ks = kindAny
+			}
+		case s.Bind != nil:
+			ks = c.query(s.Bind.Body, in)
+		}
+	}
+	return ks
+}
+
+// index returns the kinds of the values indexed by the key or the slice, and
+// warns when the input cannot be indexed by them.
+func (c *typeChecker) index(t Node, e *Index, in kinds) kinds {
+	var key kinds
+	switch {
+	case e.Name != "":
+		key = kindString
+	case e.Str != nil:
+		c.str(e.Str, kindAny)
+		key = kindString
+	case e.IsSlice || e.Start == nil:
+		for _, q := range []*Query{e.Start, e.End} {
+			if q != nil {
+				c.query(q, kindAny)
+			}
+		}
+		if in&(kindNull|kindString|kindArray|kindOther) == 0 && in != kindNone {
+			c.fail(t, "cannot slice: "+in.String())
+		}
+		return in & (kindNull | kindString | kindArray | kindOther)
+	default:
+		key = c.query(e.Start, kindAny)
+	}
+	if in == kindNone || in&(kindNull|kindOther) != 0 {
+		return kindAny
+	}
+	switch key {
+	case kindString:
+		if in&kindObject == 0 {
+			c.fail(t, "expected an object but got: "+in.String())
+		}
+	case kindNumber:
+		if in&(kindArray|kindString) == 0 {
+			c.fail(t, "expected an array but got: "+in.String())
+		}
+	}
+	return kindAny
+}
+
+// function returns the kinds of the values emitted by the builtin function, and
+// warns when the function cannot be applied to the input.
+func (c *typeChecker) function(t Node, e *Func, in kinds) kinds {
+	name := e.Name
+	if len(e.Args) > 0 || c.defined[name] {
+		for _, q := range e.Args {
+			c.query(q, kindAny)
+		}
+		switch name {
+		case "select", "recurse", "limit", "first", "last", "until", "while", "repeat":
+			return kindAny
+		case "map", "sort_by", "group_by", "unique_by", "min_by", "max_by",
+			"split", "splits", "test", "match", "capture", "startswith", "endswith",
+			"join", "has", "contains", "inside", "ltrimstr", "rtrimstr",
+			"sub", "gsub", "ascii", "with_entries":
+			if c.defined[name] {
+				return kindAny
+			}
+		default:
+			return kindAny
+		}
+		switch name {
+		case "split", "splits", "test", "match", "capture", "startswith", "endswith", "sub", "gsub":
+			c.expect(t, name, in, kindString)
+		case "map", "with_entries":
+			c.expect(t, name, in, kindArray|kindObject)
+		case "sort_by", "group_by", "unique_by", "min_by", "max_by", "join":
+			c.expect(t, name, in, kindArray)
+		case "has":
+			c.expect(t, name, in, kindArray|kindObject)
+		}
+		return kindAny
+	}
+	if name[0] == '$' || strings.Contains(name, "::") {
+		return kindAny
+	}
+	ks, ok := builtinKinds[name]
+	if !ok {
+		return kindAny
+	}
+	c.expect(t, name, in, ks[0])
+	if in == kindNone {
+		return kindNone
+	}
+	return ks[1]
+}
+
+func (c *typeChecker) expect(t Node, name string, in, accepted kinds) {
+	if in&(accepted|kindOther) == 0 && in != kindNone {
+		c.fail(t, name+" cannot be applied to: "+in.String())
+	}
+}
+
+func (c *typeChecker) ifelse(e *If, in kinds) kinds {
+	c.query(e.Cond, in)
+	ks := c.query(e.Then, in)
+	for _, elif := range e.Elif {
+		c.query(elif.Cond, in)
+		ks |= c.query(elif.Then, in)
+	}
+	if e.Else != nil {
+		return ks | c.query(e.Else, in)
+	}
+	return ks | in
+}
+
+func (c *typeChecker) str(e *String, in kinds) {
+	c.queries(e.Queries, in)
+}
+
+func (c *typeChecker) object(e *Object, in kinds) {
+	for _, kv := range e.KeyVals {
+		switch {
+		case kv.KeyOnly != "":
+			if kv.KeyOnly[0] != '$' {
+				c.index(kv, &Index{Name: kv.KeyOnly}, in)
+			}
+		case kv.KeyOnlyString != nil:
+			c.str(kv.KeyOnlyString, in)
+		case kv.KeyString != nil:
+			c.str(kv.KeyString, in)
+		case kv.KeyQuery != nil:
+			if ks := c.query(kv.KeyQuery, in); ks&(kindString|kindOther) == 0 && ks != kindNone {
+				c.fail(kv.KeyQuery, "expected a string for object key but got: "+ks.String())
+			}
+		}
+		if kv.Val != nil {
+			c.queries(kv.Val.Queries, in)
+		}
+	}
+}
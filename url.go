@@ -0,0 +1,200 @@
+package gojq
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func funcURLParse(v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return &funcTypeError{"urlparse", v}
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	query, err := parseQueryString(u.RawQuery)
+	if err != nil {
+		return err
+	}
+	m := map[string]interface{}{
+		"scheme":   nil,
+		"user":     nil,
+		"password": nil,
+		"host":     nil,
+		"port":     nil,
+		"path":     u.Path,
+		"query":    query,
+		"fragment": nil,
+	}
+	if u.Scheme != "" {
+		m["scheme"] = u.Scheme
+	}
+	if u.User != nil {
+		m["user"] = u.User.Username()
+		if password, ok := u.User.Password(); ok {
+			m["password"] = password
+		}
+	}
+	if u.Host != "" {
+		m["host"] = u.Hostname()
+		if port := u.Port(); port != "" {
+			if p, err := strconv.Atoi(port); err == nil {
+				m["port"] = p
+			}
+		}
+	}
+	if u.Fragment != "" {
+		m["fragment"] = u.Fragment
+	}
+	return m
+}
+
+func funcURLFormat(v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return &funcTypeError{"urlformat", v}
+	}
+	var u url.URL
+	for _, k := range []string{"scheme", "host", "path", "fragment"} {
+		switch x := m[k].(type) {
+		case nil:
+		case string:
+			switch k {
+			case "scheme":
+				u.Scheme = x
+			case "host":
+				u.Host = x
+			case "path":
+				u.Path = x
+			case "fragment":
+				u.Fragment = x
+			}
+		default:
+			return &urlComponentError{k, x}
+		}
+	}
+	switch user := m["user"].(type) {
+	case nil:
+	case string:
+		switch password := m["password"].(type) {
+		case nil:
+			u.User = url.User(user)
+		case string:
+			u.User = url.UserPassword(user, password)
+		default:
+			return &urlComponentError{"password", password}
+		}
+	default:
+		return &urlComponentError{"user", user}
+	}
+	if x := m["port"]; x != nil {
+		port, ok := toInt(x)
+		if !ok || port < 0 || port > 65535 {
+			return &urlComponentError{"port", x}
+		}
+		u.Host = net.JoinHostPort(u.Host, strconv.Itoa(port))
+	} else if strings.Contains(u.Host, ":") {
+		u.Host = "[" + u.Host + "]"
+	}
+	switch x := m["query"].(type) {
+	case nil:
+	case string:
+		u.RawQuery = x
+	case map[string]interface{}:
+		q, err := formatQueryString(x)
+		if err != nil {
+			return err
+		}
+		u.RawQuery = q
+	default:
+		return &urlComponentError{"query", x}
+	}
+	return u.String()
+}
+
+func funcFromQueryString(v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return &funcTypeError{"fromquerystring", v}
+	}
+	q, err := parseQueryString(strings.TrimPrefix(s, "?"))
+	if err != nil {
+		return err
+	}
+	return q
+}
+
+func funcToQueryString(v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return &funcTypeError{"toquerystring", v}
+	}
+	q, err := formatQueryString(m)
+	if err != nil {
+		return err
+	}
+	return q
+}
+
+// parseQueryString parses the query string into an object. The values of the
+// repeated keys are collected into an array.
+func parseQueryString(s string) (map[string]interface{}, error) {
+	q, err := url.ParseQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{}, len(q))
+	for k, vs := range q {
+		if len(vs) == 1 {
+			m[k] = vs[0]
+			continue
+		}
+		xs := make([]interface{}, len(vs))
+		for i, v := range vs {
+			xs[i] = v
+		}
+		m[k] = xs
+	}
+	return m, nil
+}
+
+// formatQueryString formats the object into a query string, sorted by keys.
+// The arrays are formatted as the repeated keys.
+func formatQueryString(m map[string]interface{}) (string, error) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		vs, ok := m[k].([]interface{})
+		if !ok {
+			vs = []interface{}{m[k]}
+		}
+		for _, v := range vs {
+			var s string
+			switch v := v.(type) {
+			case map[string]interface{}, []interface{}:
+				return "", &urlComponentError{"query", m}
+			case nil:
+			case string:
+				s = v
+			default:
+				s = jsonMarshal(v)
+			}
+			if sb.Len() > 0 {
+				sb.WriteByte('&')
+			}
+			sb.WriteString(url.QueryEscape(k))
+			sb.WriteByte('=')
+			sb.WriteString(url.QueryEscape(s))
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,179 @@
+package gojq
+
+import "strconv"
+
+// checkWarnings reports the suspicious constructs of the query, which do not
+// prevent the compilation but are likely to be bugs. The shadowed variables are
+// reported only when shadowing is true, since rebinding a variable is a common
+// idiom.
+func checkWarnings(q *Query, warn func(string), shadowing bool) {
+	(&warner{warn, shadowing}).check(q, nil)
+}
+
+type warner struct {
+	warn      func(string)
+	shadowing bool
+}
+
+// check traverses the node with the variables bound in the enclosing scopes.
+func (w *warner) check(node Node, vars []string) {
+	Walk(node, func(n Node) bool {
+		switch n := n.(type) {
+		case *Query:
+			w.checkUnusedFuncDefs(n)
+			w.checkNullComparison(n)
+		case *FuncDef:
+			args := vars[:len(vars):len(vars)]
+			for _, arg := range n.Args {
+				if arg[0] == '$' {
+					args = append(args, arg)
+				}
+			}
+			w.check(n.Body, args)
+			return false
+		case *Bind:
+			names := vars
+			for _, p := range n.Patterns {
+				w.check(p, vars)
+				names = w.bind(names, vars, p)
+			}
+			w.check(n.Body, names)
+			return false
+		case *Reduce:
+			w.check(n.Term, vars)
+			w.check(n.Pattern, vars)
+			w.check(n.Start, vars)
+			w.check(n.Update, w.bind(vars, vars, n.Pattern))
+			return false
+		case *Foreach:
+			w.check(n.Term, vars)
+			w.check(n.Pattern, vars)
+			w.check(n.Start, vars)
+			names := w.bind(vars, vars, n.Pattern)
+			w.check(n.Update, names)
+			if n.Extract != nil {
+				w.check(n.Extract, names)
+			}
+			return false
+		}
+		return true
+	})
+}
+
+// bind appends the variables of the pattern to names, and warns the variables
+// shadowing the ones in vars.
+func (w *warner) bind(names, vars []string, p *Pattern) []string {
+	names = names[:len(names):len(names)]
+	for _, name := range patternVariables(p, nil) {
+		if w.shadowing && containsString(vars, name) {
+			w.warn("variable " + name + " shadows the variable of the same name in the outer scope")
+		}
+		if !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func patternVariables(p *Pattern, names []string) []string {
+	if p.Name != "" {
+		return append(names, p.Name)
+	}
+	for _, p := range p.Array {
+		names = patternVariables(p, names)
+	}
+	for _, kv := range p.Object {
+		if kv.KeyOnly != "" {
+			names = append(names, kv.KeyOnly)
+		} else if kv.Key != "" && kv.Key[0] == '$' {
+			names = append(names, kv.Key)
+		}
+		if kv.Val != nil {
+			names = patternVariables(kv.Val, names)
+		}
+	}
+	return names
+}
+
+// checkUnusedFuncDefs warns the function definitions which are not called in
+// the following definitions nor the body of the query.
+func (w *warner) checkUnusedFuncDefs(e *Query) {
+	for i, fd := range e.FuncDefs {
+		var used bool
+		f := func(n Node) bool {
+			if n, ok := n.(*Func); ok && n.Name == fd.Name && len(n.Args) == len(fd.Args) {
+				used = true
+			}
+			return !used
+		}
+		for _, fd := range e.FuncDefs[i+1:] {
+			Walk(fd, f)
+		}
+		if e.Term != nil {
+			Walk(e.Term, f)
+		} else if e.Right != nil {
+			Walk(e.Left, f)
+			Walk(e.Right, f)
+		}
+		if !used {
+			w.warn("function " + fd.Name + "/" + strconv.Itoa(len(fd.Args)) + " is defined but not used")
+		}
+	}
+}
+
+// checkNullComparison warns the comparison of null and the expression which
+// never emits null.
+func (w *warner) checkNullComparison(e *Query) {
+	if e.Op != OpEq && e.Op != OpNe {
+		return
+	}
+	var x *Query
+	if isNullQuery(e.Left) {
+		x = e.Right
+	} else if isNullQuery(e.Right) {
+		x = e.Left
+	} else {
+		return
+	}
+	if isNonNullQuery(x) {
+		w.warn("comparison of " + x.String() + " and null is always " +
+			strconv.FormatBool(e.Op == OpNe))
+	}
+}
+
+func isNullQuery(e *Query) bool {
+	return e.Term != nil && e.Term.Type == TermTypeNull && len(e.Term.SuffixList) == 0
+}
+
+func isNonNullQuery(e *Query) bool {
+	t := e.Term
+	if t == nil || len(t.SuffixList) > 0 {
+		return false
+	}
+	switch t.Type {
+	case TermTypeTrue, TermTypeFalse, TermTypeObject, TermTypeArray,
+		TermTypeNumber, TermTypeFormat, TermTypeString:
+		return true
+	case TermTypeFunc:
+		if len(t.Func.Args) > 0 {
+			return false
+		}
+		switch t.Func.Name {
+		case "length", "utf8bytelength", "keys", "keys_unsorted", "type",
+			"tostring", "tojson", "to_entries", "not":
+			return true
+		}
+	case TermTypeQuery:
+		return isNonNullQuery(t.Query)
+	}
+	return false
+}
+
+func containsString(xs []string, x string) bool {
+	for _, y := range xs {
+		if x == y {
+			return true
+		}
+	}
+	return false
+}